@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"myexpress-tracker/configs"
+	"myexpress-tracker/internal/classifier"
+	"myexpress-tracker/internal/database"
+)
+
+// Reports how well category suggestions would have predicted each user's
+// most recent expenses when trained only on their older history.
+func main() {
+	holdout := flag.Float64("holdout", 0.2, "fraction of each user's newest expenses held out for testing")
+	userID := flag.Int64("user", 0, "evaluate a single user ID (default: all users)")
+	flag.Parse()
+
+	if *holdout <= 0 || *holdout >= 1 {
+		log.Fatalf("holdout must be between 0 and 1")
+	}
+
+	cfg := configs.LoadConfig()
+
+	db, err := database.InitDB(cfg.DatabasePath)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	var userIDs []int64
+	if *userID > 0 {
+		userIDs = append(userIDs, *userID)
+	} else {
+		rows, err := db.Query(`SELECT id FROM users ORDER BY id`)
+		if err != nil {
+			log.Fatalf("Failed to query users: %v", err)
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				log.Fatalf("Failed to scan user: %v", err)
+			}
+			userIDs = append(userIDs, id)
+		}
+		rows.Close()
+	}
+
+	var overall classifier.Evaluation
+	for _, id := range userIDs {
+		examples, err := classifier.LoadExamples(db.DB, id)
+		if err != nil {
+			log.Fatalf("Failed to load expenses for user %d: %v", id, err)
+		}
+
+		result := classifier.Evaluate(examples, *holdout)
+		if result.Test == 0 {
+			log.Printf("user %d: not enough labelled expenses (%d)", id, len(examples))
+			continue
+		}
+
+		log.Printf("user %d: train=%d test=%d accuracy=%.1f%% top3=%.1f%%",
+			id, result.Train, result.Test, result.Accuracy*100, result.Top3Rate*100)
+		overall.Add(result)
+	}
+
+	if overall.Test == 0 {
+		log.Printf("No users with enough data to evaluate")
+		return
+	}
+
+	log.Printf("overall: train=%d test=%d accuracy=%.1f%% top3=%.1f%%",
+		overall.Train, overall.Test, overall.Accuracy*100, overall.Top3Rate*100)
+}
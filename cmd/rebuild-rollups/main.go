@@ -0,0 +1,35 @@
+package main
+
+import (
+	"log"
+
+	"myexpress-tracker/configs"
+	"myexpress-tracker/internal/database"
+)
+
+// Recomputes the monthly rollup tables from raw income and expense rows.
+// Run it to repair the rollups if they ever drift from the source data.
+func main() {
+	cfg := configs.LoadConfig()
+
+	db, err := database.InitDB(cfg.DatabasePath)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.RunMigrations(); err != nil {
+		log.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	if err := db.RebuildRollups(); err != nil {
+		log.Fatalf("Failed to rebuild rollups: %v", err)
+	}
+
+	var buckets int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM monthly_rollup`).Scan(&buckets); err != nil {
+		log.Fatalf("Failed to count rollups: %v", err)
+	}
+
+	log.Printf("Rollups rebuilt: %d monthly category buckets", buckets)
+}
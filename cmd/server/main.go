@@ -1,18 +1,30 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"myexpress-tracker/configs"
 	"myexpress-tracker/internal/auth"
+	"myexpress-tracker/internal/cache"
+	"myexpress-tracker/internal/classifier"
 	"myexpress-tracker/internal/database"
+	"myexpress-tracker/internal/duplicates"
+	"myexpress-tracker/internal/forecast"
 	"myexpress-tracker/internal/handlers"
+	"myexpress-tracker/internal/insights"
 	"myexpress-tracker/internal/middleware"
+	"myexpress-tracker/internal/notify"
 	"myexpress-tracker/internal/repository"
+	"myexpress-tracker/internal/rules"
+	"myexpress-tracker/internal/stream"
+	"myexpress-tracker/internal/webhooks"
 )
 
 func main() {
@@ -38,18 +50,151 @@ func main() {
 	categoryRepo := repository.NewCategoryRepository(db.DB)
 	incomeRepo := repository.NewIncomeRepository(db.DB)
 	expenseRepo := repository.NewExpenseRepository(db.DB)
+	rollupRepo := repository.NewRollupRepository(db.DB)
+	insightRepo := repository.NewInsightRepository(db.DB)
+	ruleRepo := repository.NewRuleRepository(db.DB)
+	idempotencyRepo := repository.NewIdempotencyRepository(db.DB)
+	auditRepo := repository.NewAuditRepository(db.DB)
+	householdRepo := repository.NewHouseholdRepository(db.DB)
+	contactRepo := repository.NewContactRepository(db.DB)
+	settlementRepo := repository.NewSettlementRepository(db.DB)
+	goalRepo := repository.NewGoalRepository(db.DB)
+	loanRepo := repository.NewLoanRepository(db.DB)
+	billRepo := repository.NewBillRepository(db.DB)
+	notificationRepo := repository.NewNotificationRepository(db.DB)
+	webhookRepo := repository.NewWebhookRepository(db.DB)
+	syncRepo := repository.NewSyncRepository(db.DB)
+	importRepo := repository.NewImportRepository(db.DB)
+
+	// Drop cached dashboard summaries whenever a user's records change
+	summaryCache := cache.NewMemoryCache()
+	invalidateSummary := func(event repository.ChangeEvent) {
+		summaryCache.Invalidate(event.UserID)
+	}
+	incomeRepo.OnChange(invalidateSummary)
+	expenseRepo.OnChange(invalidateSummary)
+	goalRepo.OnChange(invalidateSummary)
+
+	// Push changes and refreshed totals to users' open dashboard streams
+	streamBroker := stream.NewBroker(db.DB)
+	incomeRepo.OnChange(streamBroker.OnChange)
+	expenseRepo.OnChange(streamBroker.OnChange)
+	goalRepo.OnChange(streamBroker.OnChange)
 
 	// Initialize auth service
 	authService := auth.NewService(cfg.JWTSecret, cfg.JWTExpiration)
 
+	// Initialize services
+	forecastService := forecast.NewService(db.DB)
+	ruleEngine := rules.NewEngine(ruleRepo)
+	duplicateDetector := duplicates.NewDetector(expenseRepo)
+
+	// Keep per-user category classifiers in step with expense writes
+	classifierService := classifier.NewService(db.DB)
+	expenseRepo.OnChange(func(event repository.ChangeEvent) {
+		if event.Action == repository.ActionCreated || event.Action == repository.ActionRestored {
+			if err := classifierService.LearnExpense(event.UserID, event.ID); err != nil {
+				log.Printf("classifier: failed to learn expense %d: %v", event.ID, err)
+			}
+			return
+		}
+		classifierService.Forget(event.UserID)
+	})
+
+	// Look for unusual spending after every expense write and nightly
+	insightEngine := insights.NewEngine(db.DB, insightRepo)
+	insightEngine.Start()
+	expenseRepo.OnChange(func(event repository.ChangeEvent) {
+		insightEngine.Trigger(event.UserID)
+	})
+
+	// Notify users of due bills and unusual spending in the app, by email and by webhook
+	notifyService := notify.NewService(notificationRepo, billRepo)
+	notifyService.Register(notify.NewWebhookChannel())
+	if cfg.SMTPHost != "" {
+		notifyService.Register(&notify.EmailChannel{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		})
+	}
+	notifyService.Start()
+	insightRepo.OnChange(func(event repository.ChangeEvent) {
+		insight, err := insightRepo.GetByID(event.ID, event.UserID)
+		if err != nil || insight == nil {
+			log.Printf("notify: failed to load insight %d: %v", event.ID, err)
+			return
+		}
+		if err := notifyService.NotifyInsight(insight); err != nil {
+			log.Printf("notify: failed to notify user %d of insight %d: %v", event.UserID, event.ID, err)
+		}
+	})
+
+	// Push changes to personal income and expenses to users' webhooks
+	webhookDispatcher := webhooks.NewDispatcher(webhookRepo, incomeRepo, expenseRepo)
+	webhookDispatcher.Start()
+	incomeRepo.OnChange(webhookDispatcher.OnChange)
+	expenseRepo.OnChange(webhookDispatcher.OnChange)
+
+	// Purge expired idempotency keys hourly
+	go func() {
+		for range time.Tick(time.Hour) {
+			if _, err := idempotencyRepo.PurgeBefore(time.Now().Add(-cfg.IdempotencyWindow)); err != nil {
+				log.Printf("Failed to purge idempotency keys: %v", err)
+			}
+		}
+	}()
+
+	// Discard import previews that were not committed within a day
+	go func() {
+		for range time.Tick(time.Hour) {
+			if _, err := importRepo.PurgePreviewsBefore(time.Now().Add(-24 * time.Hour)); err != nil {
+				log.Printf("Failed to purge import previews: %v", err)
+			}
+		}
+	}()
+
+	// Permanently delete trashed records once they pass the retention period
+	go func() {
+		for range time.Tick(time.Hour) {
+			before := time.Now().Add(-cfg.TrashRetention)
+			if _, err := incomeRepo.PurgeDeletedBefore(context.Background(), before); err != nil {
+				log.Printf("Failed to purge trashed income: %v", err)
+			}
+			if _, err := expenseRepo.PurgeDeletedBefore(context.Background(), before); err != nil {
+				log.Printf("Failed to purge trashed expenses: %v", err)
+			}
+		}
+	}()
+
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(userRepo, authService)
 	userHandler := handlers.NewUserHandler(userRepo)
 	categoryHandler := handlers.NewCategoryHandler(categoryRepo)
 	incomeHandler := handlers.NewIncomeHandler(incomeRepo, categoryRepo)
-	expenseHandler := handlers.NewExpenseHandler(expenseRepo, categoryRepo)
-	dashboardHandler := handlers.NewDashboardHandler(db.DB)
+	expenseHandler := handlers.NewExpenseHandler(expenseRepo, categoryRepo, ruleEngine, classifierService, duplicateDetector)
+	dashboardHandler := handlers.NewDashboardHandler(db.DB, summaryCache, goalRepo)
+	streamHandler := handlers.NewStreamHandler(streamBroker)
 	exportHandler := handlers.NewExportHandler(db.DB)
+	reportHandler := handlers.NewReportHandler(rollupRepo)
+	forecastHandler := handlers.NewForecastHandler(forecastService)
+	insightHandler := handlers.NewInsightHandler(insightRepo)
+	ruleHandler := handlers.NewRuleHandler(ruleRepo, expenseRepo, categoryRepo)
+	trashHandler := handlers.NewTrashHandler(incomeRepo, expenseRepo, cfg.TrashRetention)
+	auditHandler := handlers.NewAuditHandler(auditRepo)
+	bulkHandler := handlers.NewBulkHandler(incomeRepo, expenseRepo, categoryRepo)
+	householdHandler := handlers.NewHouseholdHandler(householdRepo)
+	contactHandler := handlers.NewContactHandler(contactRepo)
+	settlementHandler := handlers.NewSettlementHandler(settlementRepo)
+	goalHandler := handlers.NewGoalHandler(goalRepo, categoryRepo)
+	loanHandler := handlers.NewLoanHandler(loanRepo, expenseRepo, categoryRepo)
+	billHandler := handlers.NewBillHandler(billRepo, expenseRepo, categoryRepo)
+	notificationHandler := handlers.NewNotificationHandler(notificationRepo, notifyService)
+	webhookHandler := handlers.NewWebhookHandler(webhookRepo, webhookDispatcher)
+	syncHandler := handlers.NewSyncHandler(syncRepo, incomeRepo, expenseRepo, categoryRepo, userRepo)
+	importHandler := handlers.NewImportHandler(importRepo, incomeRepo, expenseRepo, categoryRepo, ruleEngine, classifierService, duplicateDetector)
 
 	// Create router
 	mux := http.NewServeMux()
@@ -58,17 +203,38 @@ func main() {
 	mux.HandleFunc("/api/auth/register", authHandler.Register)
 	mux.HandleFunc("/api/auth/login", authHandler.Login)
 
+	// personalAuth protects endpoints that only work with personal records
+	personalAuth := func(next http.Handler) http.Handler {
+		return middleware.PersonalOnly(middleware.AuthMiddleware(authService, householdRepo)(next))
+	}
+
 	// Protected routes - User Profile & Settings
 	userMux := http.NewServeMux()
 	userMux.HandleFunc("/api/user/profile", userHandler.GetProfile)
 	userMux.HandleFunc("/api/user/settings", userHandler.UpdateSettings)
-	mux.Handle("/api/user/profile", middleware.AuthMiddleware(authService)(userMux))
-	mux.Handle("/api/user/settings", middleware.AuthMiddleware(authService)(userMux))
+	mux.Handle("/api/user/profile", personalAuth(userMux))
+	mux.Handle("/api/user/settings", personalAuth(userMux))
 
 	// Protected routes - Categories (can be accessed with auth)
 	categoryMux := http.NewServeMux()
-	categoryMux.HandleFunc("/api/categories", categoryHandler.GetCategories)
-	mux.Handle("/api/categories", middleware.AuthMiddleware(authService)(categoryMux))
+	categoryMux.HandleFunc("/api/categories", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			categoryHandler.GetCategories(w, r)
+		} else if r.Method == http.MethodPost {
+			categoryHandler.CreateCategory(w, r)
+		} else {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+	mux.Handle("/api/categories", middleware.AuthMiddleware(authService, householdRepo)(categoryMux))
+
+	// Create endpoints replay their response for retried Idempotency-Keys
+	idempotent := middleware.Idempotency(idempotencyRepo, cfg.IdempotencyWindow)
+
+	createIncome := idempotent(http.HandlerFunc(incomeHandler.CreateIncome))
+	createExpense := idempotent(http.HandlerFunc(expenseHandler.CreateExpense))
+	createImport := idempotent(http.HandlerFunc(importHandler.CreateImport))
+	commitImport := idempotent(http.HandlerFunc(importHandler.CommitImport))
 
 	// Protected routes - Income
 	incomeMux := http.NewServeMux()
@@ -76,7 +242,7 @@ func main() {
 		if r.Method == http.MethodGet {
 			incomeHandler.GetIncomes(w, r)
 		} else if r.Method == http.MethodPost {
-			incomeHandler.CreateIncome(w, r)
+			createIncome.ServeHTTP(w, r)
 		} else {
 			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
 		}
@@ -84,14 +250,22 @@ func main() {
 	incomeMux.HandleFunc("/api/income/", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPut {
 			incomeHandler.UpdateIncome(w, r)
+		} else if r.Method == http.MethodPatch {
+			incomeHandler.PatchIncome(w, r)
 		} else if r.Method == http.MethodDelete {
 			incomeHandler.DeleteIncome(w, r)
+		} else if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/history") {
+			auditHandler.GetHistory(w, r)
+		} else if r.Method == http.MethodGet {
+			incomeHandler.GetIncome(w, r)
 		} else {
 			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
 		}
 	})
-	mux.Handle("/api/income", middleware.AuthMiddleware(authService)(incomeMux))
-	mux.Handle("/api/income/", middleware.AuthMiddleware(authService)(incomeMux))
+	incomeMux.HandleFunc("/api/income/bulk", bulkHandler.BulkIncome)
+	mux.Handle("/api/income", middleware.AuthMiddleware(authService, householdRepo)(incomeMux))
+	mux.Handle("/api/income/", middleware.AuthMiddleware(authService, householdRepo)(incomeMux))
+	mux.Handle("/api/income/bulk", personalAuth(incomeMux))
 
 	// Protected routes - Expense
 	expenseMux := http.NewServeMux()
@@ -99,7 +273,7 @@ func main() {
 		if r.Method == http.MethodGet {
 			expenseHandler.GetExpenses(w, r)
 		} else if r.Method == http.MethodPost {
-			expenseHandler.CreateExpense(w, r)
+			createExpense.ServeHTTP(w, r)
 		} else {
 			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
 		}
@@ -107,31 +281,448 @@ func main() {
 	expenseMux.HandleFunc("/api/expense/", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPut {
 			expenseHandler.UpdateExpense(w, r)
+		} else if r.Method == http.MethodPatch {
+			expenseHandler.PatchExpense(w, r)
 		} else if r.Method == http.MethodDelete {
 			expenseHandler.DeleteExpense(w, r)
+		} else if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/history") {
+			auditHandler.GetHistory(w, r)
+		} else if r.Method == http.MethodGet {
+			expenseHandler.GetExpense(w, r)
+		} else {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+	expenseMux.HandleFunc("/api/expense/suggest", expenseHandler.SuggestCategory)
+	expenseMux.HandleFunc("/api/expense/duplicates", expenseHandler.GetDuplicates)
+	expenseMux.HandleFunc("/api/expense/shared", settlementHandler.GetSharedWithMe)
+	expenseMux.HandleFunc("/api/expense/merge", expenseHandler.MergeExpenses)
+	expenseMux.HandleFunc("/api/expense/bulk", bulkHandler.BulkExpenses)
+	mux.Handle("/api/expense", middleware.AuthMiddleware(authService, householdRepo)(expenseMux))
+	mux.Handle("/api/expense/", middleware.AuthMiddleware(authService, householdRepo)(expenseMux))
+	mux.Handle("/api/expense/suggest", personalAuth(expenseMux))
+	mux.Handle("/api/expense/shared", personalAuth(expenseMux))
+	mux.Handle("/api/expense/duplicates", personalAuth(expenseMux))
+	mux.Handle("/api/expense/merge", personalAuth(expenseMux))
+	mux.Handle("/api/expense/bulk", personalAuth(expenseMux))
+
+	// Protected routes - Categorization rules
+	ruleMux := http.NewServeMux()
+	ruleMux.HandleFunc("/api/rules", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			ruleHandler.GetRules(w, r)
+		} else if r.Method == http.MethodPost {
+			ruleHandler.CreateRule(w, r)
 		} else {
 			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
 		}
 	})
-	mux.Handle("/api/expense", middleware.AuthMiddleware(authService)(expenseMux))
-	mux.Handle("/api/expense/", middleware.AuthMiddleware(authService)(expenseMux))
+	ruleMux.HandleFunc("/api/rules/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			ruleHandler.UpdateRule(w, r)
+		} else if r.Method == http.MethodDelete {
+			ruleHandler.DeleteRule(w, r)
+		} else {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+	ruleMux.HandleFunc("/api/rules/test", ruleHandler.TestRule)
+	ruleMux.HandleFunc("/api/rules/apply", ruleHandler.ApplyRules)
+	mux.Handle("/api/rules", personalAuth(ruleMux))
+	mux.Handle("/api/rules/", personalAuth(ruleMux))
+
+	// Protected routes - Trash
+	trashMux := http.NewServeMux()
+	trashMux.HandleFunc("/api/trash", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			trashHandler.GetTrash(w, r)
+		} else if r.Method == http.MethodDelete {
+			trashHandler.EmptyTrash(w, r)
+		} else {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+	trashMux.HandleFunc("/api/trash/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			trashHandler.RestoreItem(w, r)
+		} else if r.Method == http.MethodDelete {
+			trashHandler.PurgeItem(w, r)
+		} else {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+	mux.Handle("/api/trash", middleware.AuthMiddleware(authService, householdRepo)(trashMux))
+	mux.Handle("/api/trash/", middleware.AuthMiddleware(authService, householdRepo)(trashMux))
+
+	// Protected routes - Households and invitations
+	householdMux := http.NewServeMux()
+	householdMux.HandleFunc("/api/households", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			householdHandler.GetHouseholds(w, r)
+		} else if r.Method == http.MethodPost {
+			householdHandler.CreateHousehold(w, r)
+		} else {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+	householdMux.HandleFunc("/api/households/", func(w http.ResponseWriter, r *http.Request) {
+		pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		collection := ""
+		if len(pathParts) > 3 {
+			collection = pathParts[3]
+		}
+
+		switch {
+		case len(pathParts) == 3 && r.Method == http.MethodGet:
+			householdHandler.GetHousehold(w, r)
+		case len(pathParts) == 3 && r.Method == http.MethodPut:
+			householdHandler.RenameHousehold(w, r)
+		case len(pathParts) == 3 && r.Method == http.MethodDelete:
+			householdHandler.DeleteHousehold(w, r)
+		case collection == "members" && r.Method == http.MethodPut:
+			householdHandler.UpdateMember(w, r)
+		case collection == "members" && r.Method == http.MethodDelete:
+			householdHandler.RemoveMember(w, r)
+		case collection == "invitations" && len(pathParts) == 4 && r.Method == http.MethodGet:
+			householdHandler.GetInvitations(w, r)
+		case collection == "invitations" && len(pathParts) == 4 && r.Method == http.MethodPost:
+			householdHandler.CreateInvitation(w, r)
+		case collection == "invitations" && r.Method == http.MethodDelete:
+			householdHandler.RevokeInvitation(w, r)
+		default:
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		}
+	})
+	householdMux.HandleFunc("/api/invitations", householdHandler.GetPendingInvitations)
+	householdMux.HandleFunc("/api/invitations/", householdHandler.RespondInvitation)
+	mux.Handle("/api/households", personalAuth(householdMux))
+	mux.Handle("/api/households/", personalAuth(householdMux))
+	mux.Handle("/api/invitations", personalAuth(householdMux))
+	mux.Handle("/api/invitations/", personalAuth(householdMux))
+
+	// Protected routes - Contacts, settlements and balances for shared expenses
+	sharingMux := http.NewServeMux()
+	sharingMux.HandleFunc("/api/contacts", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			contactHandler.GetContacts(w, r)
+		} else if r.Method == http.MethodPost {
+			contactHandler.CreateContact(w, r)
+		} else {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+	sharingMux.HandleFunc("/api/contacts/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			contactHandler.UpdateContact(w, r)
+		} else if r.Method == http.MethodDelete {
+			contactHandler.DeleteContact(w, r)
+		} else {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+	sharingMux.HandleFunc("/api/settlements", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			settlementHandler.GetSettlements(w, r)
+		} else if r.Method == http.MethodPost {
+			settlementHandler.CreateSettlement(w, r)
+		} else {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+	sharingMux.HandleFunc("/api/settlements/", settlementHandler.DeleteSettlement)
+	sharingMux.HandleFunc("/api/balances", settlementHandler.GetBalances)
+	mux.Handle("/api/contacts", personalAuth(sharingMux))
+	mux.Handle("/api/contacts/", personalAuth(sharingMux))
+	mux.Handle("/api/settlements", personalAuth(sharingMux))
+	mux.Handle("/api/settlements/", personalAuth(sharingMux))
+	mux.Handle("/api/balances", personalAuth(sharingMux))
+
+	// Protected routes - Savings goals
+	goalMux := http.NewServeMux()
+	goalMux.HandleFunc("/api/goals", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			goalHandler.GetGoals(w, r)
+		} else if r.Method == http.MethodPost {
+			goalHandler.CreateGoal(w, r)
+		} else {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+	goalMux.HandleFunc("/api/goals/", func(w http.ResponseWriter, r *http.Request) {
+		pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+		switch {
+		case len(pathParts) == 3 && r.Method == http.MethodGet:
+			goalHandler.GetGoal(w, r)
+		case len(pathParts) == 3 && r.Method == http.MethodPut:
+			goalHandler.UpdateGoal(w, r)
+		case len(pathParts) == 3 && r.Method == http.MethodDelete:
+			goalHandler.DeleteGoal(w, r)
+		case len(pathParts) == 4 && pathParts[3] == "contributions" && r.Method == http.MethodGet:
+			goalHandler.GetContributions(w, r)
+		case len(pathParts) == 4 && pathParts[3] == "contributions" && r.Method == http.MethodPost:
+			goalHandler.AddContribution(w, r)
+		case len(pathParts) == 5 && pathParts[3] == "contributions" && r.Method == http.MethodDelete:
+			goalHandler.DeleteContribution(w, r)
+		default:
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		}
+	})
+	mux.Handle("/api/goals", personalAuth(goalMux))
+	mux.Handle("/api/goals/", personalAuth(goalMux))
+
+	// Protected routes - Loans and amortization
+	loanMux := http.NewServeMux()
+	loanMux.HandleFunc("/api/loans", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			loanHandler.GetLoans(w, r)
+		} else if r.Method == http.MethodPost {
+			loanHandler.CreateLoan(w, r)
+		} else {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+	loanMux.HandleFunc("/api/loans/", func(w http.ResponseWriter, r *http.Request) {
+		pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		child := ""
+		if len(pathParts) > 3 {
+			child = pathParts[3]
+		}
+
+		switch {
+		case len(pathParts) == 3 && r.Method == http.MethodGet:
+			loanHandler.GetLoan(w, r)
+		case len(pathParts) == 3 && r.Method == http.MethodPut:
+			loanHandler.UpdateLoan(w, r)
+		case len(pathParts) == 3 && r.Method == http.MethodDelete:
+			loanHandler.DeleteLoan(w, r)
+		case len(pathParts) == 4 && child == "schedule" && r.Method == http.MethodGet:
+			loanHandler.GetSchedule(w, r)
+		case len(pathParts) == 4 && child == "what-if" && r.Method == http.MethodPost:
+			loanHandler.WhatIf(w, r)
+		case len(pathParts) == 4 && child == "payments" && r.Method == http.MethodGet:
+			loanHandler.GetPayments(w, r)
+		case len(pathParts) == 4 && child == "payments" && r.Method == http.MethodPost:
+			loanHandler.AddPayment(w, r)
+		case len(pathParts) == 5 && child == "payments" && r.Method == http.MethodDelete:
+			loanHandler.DeletePayment(w, r)
+		default:
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		}
+	})
+	mux.Handle("/api/loans", personalAuth(loanMux))
+	mux.Handle("/api/loans/", personalAuth(loanMux))
+
+	// Protected routes - Bills and due dates
+	billMux := http.NewServeMux()
+	billMux.HandleFunc("/api/bills", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			billHandler.GetBills(w, r)
+		} else if r.Method == http.MethodPost {
+			billHandler.CreateBill(w, r)
+		} else {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+	billMux.HandleFunc("/api/bills/", func(w http.ResponseWriter, r *http.Request) {
+		pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		child := ""
+		if len(pathParts) > 3 {
+			child = pathParts[3]
+		}
+
+		switch {
+		case len(pathParts) == 3 && pathParts[2] == "upcoming" && r.Method == http.MethodGet:
+			billHandler.GetUpcoming(w, r)
+		case len(pathParts) == 3 && pathParts[2] == "overdue" && r.Method == http.MethodGet:
+			billHandler.GetOverdue(w, r)
+		case len(pathParts) == 3 && pathParts[2] == "calendar" && r.Method == http.MethodGet:
+			billHandler.GetCalendarFeed(w, r)
+		case len(pathParts) == 3 && pathParts[2] == "calendar" && r.Method == http.MethodPost:
+			billHandler.CreateCalendarFeed(w, r)
+		case len(pathParts) == 3 && pathParts[2] == "calendar" && r.Method == http.MethodDelete:
+			billHandler.DeleteCalendarFeed(w, r)
+		case len(pathParts) == 3 && r.Method == http.MethodGet:
+			billHandler.GetBill(w, r)
+		case len(pathParts) == 3 && r.Method == http.MethodPut:
+			billHandler.UpdateBill(w, r)
+		case len(pathParts) == 3 && r.Method == http.MethodDelete:
+			billHandler.DeleteBill(w, r)
+		case len(pathParts) == 4 && child == "pay" && r.Method == http.MethodPost:
+			billHandler.PayBill(w, r)
+		case len(pathParts) == 4 && child == "payments" && r.Method == http.MethodGet:
+			billHandler.GetPayments(w, r)
+		case len(pathParts) == 5 && child == "payments" && r.Method == http.MethodDelete:
+			billHandler.DeletePayment(w, r)
+		default:
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		}
+	})
+	mux.Handle("/api/bills", personalAuth(billMux))
+	mux.Handle("/api/bills/", personalAuth(billMux))
+
+	// Public route - iCalendar feed of a user's bills, authorized by the token in its URL
+	mux.HandleFunc("/api/calendar/", billHandler.ServeCalendar)
+
+	// Protected routes - Notifications
+	notificationMux := http.NewServeMux()
+	notificationMux.HandleFunc("/api/notifications", notificationHandler.GetNotifications)
+	notificationMux.HandleFunc("/api/notifications/", func(w http.ResponseWriter, r *http.Request) {
+		pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		action := ""
+		if len(pathParts) == 3 {
+			action = pathParts[2]
+		}
+
+		switch {
+		case action == "unread-count" && r.Method == http.MethodGet:
+			notificationHandler.GetUnreadCount(w, r)
+		case action == "read-all" && r.Method == http.MethodPost:
+			notificationHandler.MarkAllRead(w, r)
+		case action == "preferences" && r.Method == http.MethodGet:
+			notificationHandler.GetPreferences(w, r)
+		case action == "preferences" && r.Method == http.MethodPut:
+			notificationHandler.UpdatePreferences(w, r)
+		case action == "settings" && r.Method == http.MethodGet:
+			notificationHandler.GetSettings(w, r)
+		case action == "settings" && r.Method == http.MethodPut:
+			notificationHandler.UpdateSettings(w, r)
+		case action == "deliveries" && r.Method == http.MethodGet:
+			notificationHandler.GetDeliveries(w, r)
+		case action == "test" && r.Method == http.MethodPost:
+			notificationHandler.SendTest(w, r)
+		case len(pathParts) == 4 && pathParts[3] == "read" && r.Method == http.MethodPost:
+			notificationHandler.MarkRead(w, r)
+		default:
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		}
+	})
+	mux.Handle("/api/notifications", personalAuth(notificationMux))
+	mux.Handle("/api/notifications/", personalAuth(notificationMux))
+
+	// Protected routes - Outgoing webhooks
+	webhookMux := http.NewServeMux()
+	webhookMux.HandleFunc("/api/webhooks", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			webhookHandler.GetWebhooks(w, r)
+		} else if r.Method == http.MethodPost {
+			webhookHandler.CreateWebhook(w, r)
+		} else {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+	webhookMux.HandleFunc("/api/webhooks/", func(w http.ResponseWriter, r *http.Request) {
+		pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		child := ""
+		if len(pathParts) > 3 {
+			child = pathParts[3]
+		}
+
+		switch {
+		case len(pathParts) == 3 && pathParts[2] == "events" && r.Method == http.MethodGet:
+			webhookHandler.GetEvents(w, r)
+		case len(pathParts) == 3 && r.Method == http.MethodGet:
+			webhookHandler.GetWebhook(w, r)
+		case len(pathParts) == 3 && r.Method == http.MethodPut:
+			webhookHandler.UpdateWebhook(w, r)
+		case len(pathParts) == 3 && r.Method == http.MethodDelete:
+			webhookHandler.DeleteWebhook(w, r)
+		case len(pathParts) == 4 && child == "ping" && r.Method == http.MethodPost:
+			webhookHandler.Ping(w, r)
+		case len(pathParts) == 4 && child == "rotate-secret" && r.Method == http.MethodPost:
+			webhookHandler.RotateSecret(w, r)
+		case len(pathParts) == 4 && child == "deliveries" && r.Method == http.MethodGet:
+			webhookHandler.GetDeliveries(w, r)
+		case len(pathParts) == 5 && child == "deliveries" && r.Method == http.MethodGet:
+			webhookHandler.GetDelivery(w, r)
+		case len(pathParts) == 6 && child == "deliveries" && pathParts[5] == "redeliver" && r.Method == http.MethodPost:
+			webhookHandler.Redeliver(w, r)
+		default:
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		}
+	})
+	mux.Handle("/api/webhooks", personalAuth(webhookMux))
+	mux.Handle("/api/webhooks/", personalAuth(webhookMux))
+
+	// Protected routes - Offline sync
+	syncMux := http.NewServeMux()
+	syncMux.HandleFunc("/api/sync/changes", syncHandler.GetChanges)
+	syncMux.HandleFunc("/api/sync/push", syncHandler.Push)
+	mux.Handle("/api/sync/", personalAuth(syncMux))
+
+	// Protected routes - Statement imports
+	importMux := http.NewServeMux()
+	importMux.HandleFunc("/api/imports", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			importHandler.GetImports(w, r)
+		} else if r.Method == http.MethodPost {
+			createImport.ServeHTTP(w, r)
+		} else {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+	importMux.HandleFunc("/api/imports/", func(w http.ResponseWriter, r *http.Request) {
+		pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+		switch {
+		case len(pathParts) == 3 && r.Method == http.MethodGet:
+			importHandler.GetImport(w, r)
+		case len(pathParts) == 3 && r.Method == http.MethodDelete:
+			importHandler.DeleteImport(w, r)
+		case len(pathParts) == 4 && pathParts[3] == "commit" && r.Method == http.MethodPost:
+			commitImport.ServeHTTP(w, r)
+		default:
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		}
+	})
+	mux.Handle("/api/imports", middleware.AuthMiddleware(authService, householdRepo)(importMux))
+	mux.Handle("/api/imports/", middleware.AuthMiddleware(authService, householdRepo)(importMux))
+
+	// Protected routes - Audit log
+	auditMux := http.NewServeMux()
+	auditMux.HandleFunc("/api/audit", auditHandler.GetAuditLog)
+	mux.Handle("/api/audit", middleware.AuthMiddleware(authService, householdRepo)(auditMux))
 
 	// Protected routes - Dashboard
 	dashboardMux := http.NewServeMux()
 	dashboardMux.HandleFunc("/api/dashboard", dashboardHandler.GetDashboard)
-	mux.Handle("/api/dashboard", middleware.AuthMiddleware(authService)(dashboardMux))
+	mux.Handle("/api/dashboard", middleware.AuthMiddleware(authService, householdRepo)(dashboardMux))
+
+	// Protected routes - Live updates
+	streamMux := http.NewServeMux()
+	streamMux.HandleFunc("/api/stream", streamHandler.Stream)
+	mux.Handle("/api/stream", personalAuth(streamMux))
+
+	// Protected routes - Reports
+	reportMux := http.NewServeMux()
+	reportMux.HandleFunc("/api/reports/trends", reportHandler.GetTrends)
+	mux.Handle("/api/reports/trends", personalAuth(reportMux))
+
+	// Protected routes - Forecast
+	forecastMux := http.NewServeMux()
+	forecastMux.HandleFunc("/api/forecast", forecastHandler.GetForecast)
+	mux.Handle("/api/forecast", personalAuth(forecastMux))
+
+	// Protected routes - Insights
+	insightMux := http.NewServeMux()
+	insightMux.HandleFunc("/api/insights", insightHandler.GetInsights)
+	insightMux.HandleFunc("/api/insights/", insightHandler.DismissInsight)
+	mux.Handle("/api/insights", personalAuth(insightMux))
+	mux.Handle("/api/insights/", personalAuth(insightMux))
 
 	// Protected routes - Export
 	exportMux := http.NewServeMux()
 	exportMux.HandleFunc("/api/export/pdf", exportHandler.ExportToPDF)
-	mux.Handle("/api/export/pdf", middleware.AuthMiddleware(authService)(exportMux))
+	exportMux.HandleFunc("/api/export/qif", exportHandler.ExportToQIF)
+	mux.Handle("/api/export/pdf", personalAuth(exportMux))
+	mux.Handle("/api/export/qif", personalAuth(exportMux))
 
 	// Serve static files (HTML, CSS, JS)
 	fs := http.FileServer(http.Dir("./web"))
 	mux.Handle("/", fs)
 
-	// Apply CORS middleware
-	handler := middleware.CORSMiddleware(mux)
+	// Apply CORS and request tracing middleware
+	handler := middleware.CORSMiddleware(middleware.RequestMeta(mux))
 
 	// Get current working directory
 	cwd, _ := os.Getwd()
@@ -13,11 +13,26 @@ type Config struct {
 	JWTSecret      string
 	JWTExpiration  time.Duration
 	Environment    string
+
+	// IdempotencyWindow is how long Idempotency-Key responses are replayed
+	IdempotencyWindow time.Duration
+
+	// TrashRetention is how long deleted transactions stay in the trash before being purged
+	TrashRetention time.Duration
+
+	// SMTP server for email notifications; email is disabled when SMTPHost is empty
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
 }
 
 // LoadConfig loads configuration from environment variables with defaults
 func LoadConfig() *Config {
 	jwtExpHours := getEnvAsInt("JWT_EXPIRATION_HOURS", 24)
+	idempotencyHours := getEnvAsInt("IDEMPOTENCY_WINDOW_HOURS", 24)
+	trashDays := getEnvAsInt("TRASH_RETENTION_DAYS", 30)
 	
 	return &Config{
 		ServerPort:     getEnv("SERVER_PORT", "8080"),
@@ -25,6 +40,15 @@ func LoadConfig() *Config {
 		JWTSecret:      getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
 		JWTExpiration:  time.Duration(jwtExpHours) * time.Hour,
 		Environment:    getEnv("ENVIRONMENT", "development"),
+
+		IdempotencyWindow: time.Duration(idempotencyHours) * time.Hour,
+		TrashRetention:    time.Duration(trashDays) * 24 * time.Hour,
+
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", "notifications@myexpress-tracker.local"),
 	}
 }
 
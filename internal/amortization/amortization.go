@@ -0,0 +1,194 @@
+package amortization
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Payment frequencies
+const (
+	Monthly  = "monthly"
+	Biweekly = "biweekly"
+	Weekly   = "weekly"
+)
+
+// MaxTermMonths is the longest loan term accepted (50 years)
+const MaxTermMonths = 600
+
+// ErrNeverPaidOff is returned when the payment does not cover a period's interest
+var ErrNeverPaidOff = errors.New("the payment does not cover the interest, so the loan is never paid off")
+
+// Loan describes the outstanding part of a loan
+type Loan struct {
+	Balance    float64   // Amount still owed
+	AnnualRate float64   // Interest rate in percent per year
+	Frequency  string    // Monthly, Biweekly or Weekly
+	Payment    float64   // Regular payment
+	FirstDate  time.Time // Date of the next payment
+}
+
+// Extra describes payments on top of the regular one
+type Extra struct {
+	PerPayment float64   // Added to every regular payment
+	LumpSums   []LumpSum // One-off payments, applied on the first payment date on or after their date
+}
+
+// LumpSum is a one-off extra payment
+type LumpSum struct {
+	Date   time.Time
+	Amount float64
+}
+
+// Row is one payment in an amortization schedule
+type Row struct {
+	Number    int     `json:"number"`
+	Date      string  `json:"date"` // Date in YYYY-MM-DD format
+	Payment   float64 `json:"payment"`
+	Principal float64 `json:"principal"`
+	Interest  float64 `json:"interest"`
+	Extra     float64 `json:"extra,omitempty"`
+	Balance   float64 `json:"balance"`
+}
+
+// Schedule is the payments that pay off a loan
+type Schedule struct {
+	Rows          []Row   `json:"rows"`
+	Payments      int     `json:"payments"`
+	TotalPaid     float64 `json:"total_paid"`
+	TotalInterest float64 `json:"total_interest"`
+	PayoffDate    string  `json:"payoff_date,omitempty"`
+}
+
+// PeriodsPerYear is the number of payments a year at a frequency
+func PeriodsPerYear(frequency string) int {
+	switch frequency {
+	case Weekly:
+		return 52
+	case Biweekly:
+		return 26
+	default:
+		return 12
+	}
+}
+
+// PeriodicRate is the interest rate charged each period
+func PeriodicRate(annualRate float64, frequency string) float64 {
+	return annualRate / 100 / float64(PeriodsPerYear(frequency))
+}
+
+// Periods is how many payments a term in months takes at a frequency
+func Periods(termMonths int, frequency string) int {
+	periods := int(math.Round(float64(termMonths) * float64(PeriodsPerYear(frequency)) / 12))
+	if periods < 1 {
+		periods = 1
+	}
+	return periods
+}
+
+// PaymentFor is the regular payment that pays off principal over the given
+// number of periods, rounded up to the cent
+func PaymentFor(principal, annualRate float64, frequency string, periods int) float64 {
+	rate := PeriodicRate(annualRate, frequency)
+	if rate == 0 {
+		return math.Ceil(principal/float64(periods)*100) / 100
+	}
+	payment := principal * rate / (1 - math.Pow(1+rate, -float64(periods)))
+	return math.Ceil(math.Round(payment*1e6)/1e4) / 100
+}
+
+// Split divides a payment into interest for one period on the balance and the
+// principal it repays. The principal never exceeds the balance.
+func Split(balance, annualRate float64, frequency string, amount float64) (principal, interest float64) {
+	interest = math.Min(round(balance*PeriodicRate(annualRate, frequency)), amount)
+	principal = math.Min(round(amount-interest), balance)
+	return principal, interest
+}
+
+// DateOf is the date of the payment n periods after first
+func DateOf(first time.Time, frequency string, n int) time.Time {
+	switch frequency {
+	case Weekly:
+		return first.AddDate(0, 0, 7*n)
+	case Biweekly:
+		return first.AddDate(0, 0, 14*n)
+	}
+
+	// Keep the day of month, clamped to the length of shorter months
+	year, month, day := first.Date()
+	target := time.Date(year, month+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	if last := target.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return time.Date(target.Year(), target.Month(), day, 0, 0, 0, 0, time.UTC)
+}
+
+// Build lists the payments that pay off a loan with the given extra payments
+func Build(loan Loan, extra Extra) (*Schedule, error) {
+	schedule := &Schedule{Rows: []Row{}}
+	balance := round(loan.Balance)
+	rate := PeriodicRate(loan.AnnualRate, loan.Frequency)
+	lumpSums := make([]bool, len(extra.LumpSums))
+
+	// Twice the longest term at the loan's frequency stops schedules of
+	// payments that barely cover the interest
+	maxPeriods := Periods(2*MaxTermMonths, loan.Frequency)
+
+	var paid, interestPaid float64
+	for n := 0; balance > 0; n++ {
+		if n == maxPeriods {
+			return nil, ErrNeverPaidOff
+		}
+
+		date := DateOf(loan.FirstDate, loan.Frequency, n)
+		interest := round(balance * rate)
+		if n == 0 && loan.Payment+extra.PerPayment <= interest {
+			return nil, ErrNeverPaidOff
+		}
+
+		extraAmount := extra.PerPayment
+		for i, lumpSum := range extra.LumpSums {
+			if !lumpSums[i] && !lumpSum.Date.After(date) {
+				lumpSums[i] = true
+				extraAmount += lumpSum.Amount
+			}
+		}
+
+		// The last payment only clears what is left
+		payment := loan.Payment
+		if payment > balance+interest {
+			payment = balance + interest
+			extraAmount = 0
+		} else if payment+extraAmount > balance+interest {
+			extraAmount = round(balance + interest - payment)
+		}
+
+		principal := round(payment + extraAmount - interest)
+		balance = round(balance - principal)
+		paid += payment + extraAmount
+		interestPaid += interest
+
+		schedule.Rows = append(schedule.Rows, Row{
+			Number:    n + 1,
+			Date:      date.Format("2006-01-02"),
+			Payment:   round(payment),
+			Principal: principal,
+			Interest:  interest,
+			Extra:     round(extraAmount),
+			Balance:   balance,
+		})
+	}
+
+	schedule.Payments = len(schedule.Rows)
+	schedule.TotalPaid = round(paid)
+	schedule.TotalInterest = round(interestPaid)
+	if schedule.Payments > 0 {
+		schedule.PayoffDate = schedule.Rows[schedule.Payments-1].Date
+	}
+	return schedule, nil
+}
+
+// round rounds an amount to cents
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,75 @@
+package amortization
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildPaysOffOverTerm(t *testing.T) {
+	first := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		balance    float64
+		rate       float64
+		frequency  string
+		termMonths int
+	}{
+		{"monthly 30 years", 300000, 6, Monthly, 360},
+		{"biweekly 30 years", 300000, 6, Biweekly, 360},
+		{"weekly 30 years", 300000, 6, Weekly, 360},
+		{"monthly 50 years", 300000, 6, Monthly, MaxTermMonths},
+		{"biweekly 50 years", 300000, 6, Biweekly, MaxTermMonths},
+		{"weekly 50 years", 300000, 6, Weekly, MaxTermMonths},
+		{"weekly without interest", 5200, 0, Weekly, 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			periods := Periods(tt.termMonths, tt.frequency)
+			loan := Loan{
+				Balance:    tt.balance,
+				AnnualRate: tt.rate,
+				Frequency:  tt.frequency,
+				Payment:    PaymentFor(tt.balance, tt.rate, tt.frequency, periods),
+				FirstDate:  first,
+			}
+
+			schedule, err := Build(loan, Extra{})
+			if err != nil {
+				t.Fatalf("Build: %v", err)
+			}
+			if schedule.Payments > periods {
+				t.Errorf("payments = %d, want at most %d", schedule.Payments, periods)
+			}
+			if schedule.Payments < periods-1 {
+				t.Errorf("payments = %d, want about %d", schedule.Payments, periods)
+			}
+			if last := schedule.Rows[len(schedule.Rows)-1]; last.Balance != 0 {
+				t.Errorf("final balance = %.2f, want 0", last.Balance)
+			}
+		})
+	}
+}
+
+func TestBuildNeverPaidOff(t *testing.T) {
+	first := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		frequency string
+		payment   float64
+	}{
+		{"monthly payment equals interest", Monthly, 1500},
+		{"weekly payment below interest", Weekly, 300},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := Loan{Balance: 300000, AnnualRate: 6, Frequency: tt.frequency, Payment: tt.payment, FirstDate: first}
+			if _, err := Build(loan, Extra{}); err != ErrNeverPaidOff {
+				t.Errorf("Build error = %v, want ErrNeverPaidOff", err)
+			}
+		})
+	}
+}
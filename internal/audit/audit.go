@@ -0,0 +1,106 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+)
+
+// contextKey is the type for audit context keys
+type contextKey string
+
+const (
+	// actorKey is the context key for the acting user
+	actorKey contextKey = "audit_actor"
+	// requestKey is the context key for request metadata
+	requestKey contextKey = "audit_request"
+)
+
+// Meta identifies who made a change and from where
+type Meta struct {
+	ActorID   int64 // 0 for changes made by the system (e.g. scheduled purges)
+	RequestID string
+	IP        string
+}
+
+// request holds the request-scoped part of Meta
+type request struct {
+	id string
+	ip string
+}
+
+// WithActor returns a context that attributes changes to the given user
+func WithActor(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, actorKey, userID)
+}
+
+// WithRequest returns a context carrying the request ID and client IP
+func WithRequest(ctx context.Context, requestID, ip string) context.Context {
+	return context.WithValue(ctx, requestKey, request{id: requestID, ip: ip})
+}
+
+// FromContext collects the audit metadata stored in ctx
+func FromContext(ctx context.Context) Meta {
+	var meta Meta
+	if ctx == nil {
+		return meta
+	}
+	meta.ActorID, _ = ctx.Value(actorKey).(int64)
+	if req, ok := ctx.Value(requestKey).(request); ok {
+		meta.RequestID = req.id
+		meta.IP = req.ip
+	}
+	return meta
+}
+
+// Change is the old and new value of a single field
+type Change struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// volatileFields are left out of snapshots because they change on every write
+var volatileFields = []string{"id", "user_id", "created_at", "updated_at", "deleted_at", "category_name"}
+
+// Snapshot converts a record into a field map for storage and diffing.
+// A nil record yields a nil snapshot.
+func Snapshot(record interface{}) (map[string]interface{}, error) {
+	if record == nil || (reflect.ValueOf(record).Kind() == reflect.Ptr && reflect.ValueOf(record).IsNil()) {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for _, name := range volatileFields {
+		delete(fields, name)
+	}
+	return fields, nil
+}
+
+// Diff returns the fields whose values differ between two snapshots
+func Diff(before, after map[string]interface{}) map[string]Change {
+	names := make(map[string]bool)
+	for name := range before {
+		names[name] = true
+	}
+	for name := range after {
+		names[name] = true
+	}
+
+	changes := make(map[string]Change)
+	for name := range names {
+		oldValue, newValue := before[name], after[name]
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		changes[name] = Change{Old: oldValue, New: newValue}
+	}
+	return changes
+}
@@ -0,0 +1,89 @@
+package bills
+
+import (
+	"time"
+)
+
+// Bill recurrences
+const (
+	Once      = "none"
+	Weekly    = "weekly"
+	Biweekly  = "biweekly"
+	Monthly   = "monthly"
+	Quarterly = "quarterly"
+	Yearly    = "yearly"
+)
+
+// maxOccurrences bounds the occurrences listed for a single bill
+const maxOccurrences = 400
+
+// ValidRecurrence reports whether recurrence is a known recurrence
+func ValidRecurrence(recurrence string) bool {
+	switch recurrence {
+	case Once, Weekly, Biweekly, Monthly, Quarterly, Yearly:
+		return true
+	}
+	return false
+}
+
+// Occurrence is the date of the nth occurrence (counting from 0) of a bill
+// first due on first. Monthly bills keep the day of the month, or fall on the
+// last day of shorter months.
+func Occurrence(first time.Time, recurrence string, n int) time.Time {
+	switch recurrence {
+	case Weekly:
+		return first.AddDate(0, 0, 7*n)
+	case Biweekly:
+		return first.AddDate(0, 0, 14*n)
+	case Monthly:
+		return addMonths(first, n)
+	case Quarterly:
+		return addMonths(first, 3*n)
+	case Yearly:
+		return addMonths(first, 12*n)
+	}
+	return first
+}
+
+// After returns the first occurrence strictly after date, and false when a
+// one-off bill has no further occurrence
+func After(first time.Time, recurrence string, date time.Time) (time.Time, bool) {
+	if recurrence == Once {
+		return time.Time{}, first.After(date)
+	}
+
+	next := first
+	for n := 1; !next.After(date); n++ {
+		next = Occurrence(first, recurrence, n)
+	}
+	return next, true
+}
+
+// Between lists the occurrences from next on that fall within [from, to]
+func Between(first, next time.Time, recurrence string, from, to time.Time) []time.Time {
+	var dates []time.Time
+	date := next
+	for len(dates) < maxOccurrences && !date.After(to) {
+		if !date.Before(from) {
+			dates = append(dates, date)
+		}
+		if recurrence == Once {
+			break
+		}
+		var ok bool
+		if date, ok = After(first, recurrence, date); !ok {
+			break
+		}
+	}
+	return dates
+}
+
+// addMonths moves a date by whole months, clamping the day to the target month
+func addMonths(date time.Time, months int) time.Time {
+	year, month, day := date.Date()
+	target := time.Date(year, month+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	if last := target.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return time.Date(target.Year(), target.Month(), day, 0, 0, 0, 0, time.UTC)
+}
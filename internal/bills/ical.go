@@ -0,0 +1,127 @@
+package bills
+
+import (
+	"fmt"
+	"myexpress-tracker/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Calendar renders a user's unpaid bills as an iCalendar (RFC 5545) feed.
+// Each bill is one all-day event from its next due date, repeating with the
+// bill's recurrence, with a reminder the bill's reminder days before.
+func Calendar(billList []models.Bill, now time.Time) []byte {
+	var b strings.Builder
+	line := func(content string) {
+		b.WriteString(fold(content))
+		b.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//MyExpress Tracker//Bills//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:Bills")
+
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, bill := range billList {
+		if bill.NextDueDate == nil {
+			continue
+		}
+		due, err := time.Parse("2006-01-02", *bill.NextDueDate)
+		if err != nil {
+			continue
+		}
+		first, err := time.Parse("2006-01-02", bill.FirstDueDate)
+		if err != nil {
+			first = due
+		}
+
+		summary := fmt.Sprintf("%s due: %s", bill.Name, strconv.FormatFloat(bill.Amount, 'f', 2, 64))
+		line("BEGIN:VEVENT")
+		line(fmt.Sprintf("UID:bill-%d@myexpress-tracker", bill.ID))
+		line("DTSTAMP:" + stamp)
+		line("DTSTART;VALUE=DATE:" + due.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + due.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + escape(summary))
+		if bill.Notes != "" {
+			line("DESCRIPTION:" + escape(bill.Notes))
+		}
+		if bill.CategoryName != "" {
+			line("CATEGORIES:" + escape(bill.CategoryName))
+		}
+		if rule := recurrenceRule(first, bill.Recurrence); rule != "" {
+			line("RRULE:" + rule)
+		}
+		if bill.RemindDays > 0 {
+			line("BEGIN:VALARM")
+			line("ACTION:DISPLAY")
+			line("DESCRIPTION:" + escape(summary))
+			line(fmt.Sprintf("TRIGGER:-P%dD", bill.RemindDays))
+			line("END:VALARM")
+		}
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+	return []byte(b.String())
+}
+
+// recurrenceRule is the RRULE for a recurrence. Days of the month past the
+// 28th pick the last existing day up to the anchor day, like Occurrence does.
+func recurrenceRule(first time.Time, recurrence string) string {
+	monthDay := ""
+	if day := first.Day(); day > 28 {
+		days := make([]string, 0, 4)
+		for d := 28; d <= day; d++ {
+			days = append(days, strconv.Itoa(d))
+		}
+		monthDay = ";BYMONTHDAY=" + strings.Join(days, ",") + ";BYSETPOS=-1"
+	}
+
+	switch recurrence {
+	case Weekly:
+		return "FREQ=WEEKLY"
+	case Biweekly:
+		return "FREQ=WEEKLY;INTERVAL=2"
+	case Monthly:
+		return "FREQ=MONTHLY" + monthDay
+	case Quarterly:
+		return "FREQ=MONTHLY;INTERVAL=3" + monthDay
+	case Yearly:
+		if monthDay != "" {
+			return fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d", int(first.Month())) + monthDay
+		}
+		return "FREQ=YEARLY"
+	}
+	return ""
+}
+
+// escape escapes text for an iCalendar property value
+func escape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(text)
+}
+
+// fold splits a content line into lines of at most 75 octets, continuing each
+// with a leading space, without breaking UTF-8 sequences
+func fold(content string) string {
+	const limit = 75
+	if len(content) <= limit {
+		return content
+	}
+
+	var b strings.Builder
+	width := 0
+	for _, r := range content {
+		size := len(string(r))
+		if width+size > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
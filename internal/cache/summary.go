@@ -0,0 +1,68 @@
+package cache
+
+import "sync"
+
+// Entry is a rendered dashboard summary for a single user
+type Entry struct {
+	Body []byte // Encoded JSON payload
+	ETag string // Strong validator derived from Body
+	Day  string // Date (YYYY-MM-DD) the summary was computed for
+}
+
+// SummaryCache stores per-user dashboard summaries.
+// Version is read before computing a summary and passed back to Set so that
+// a summary computed concurrently with an invalidation is never stored.
+type SummaryCache interface {
+	Get(userID int64) (*Entry, bool)
+	Version(userID int64) uint64
+	Set(userID int64, version uint64, entry *Entry)
+	Invalidate(userID int64)
+}
+
+// MemoryCache is an in-process SummaryCache
+type MemoryCache struct {
+	mu       sync.RWMutex
+	entries  map[int64]*Entry
+	versions map[int64]uint64
+}
+
+// NewMemoryCache creates an empty in-process summary cache
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		entries:  make(map[int64]*Entry),
+		versions: make(map[int64]uint64),
+	}
+}
+
+// Get returns the cached summary for a user
+func (c *MemoryCache) Get(userID int64) (*Entry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.entries[userID]
+	return entry, ok
+}
+
+// Version returns the current invalidation counter for a user
+func (c *MemoryCache) Version(userID int64) uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.versions[userID]
+}
+
+// Set stores a summary unless the user was invalidated since version was read
+func (c *MemoryCache) Set(userID int64, version uint64, entry *Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.versions[userID] != version {
+		return
+	}
+	c.entries[userID] = entry
+}
+
+// Invalidate drops the cached summary for a user
+func (c *MemoryCache) Invalidate(userID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, userID)
+	c.versions[userID]++
+}
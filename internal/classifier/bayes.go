@@ -0,0 +1,154 @@
+package classifier
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Example is a labelled expense used for training or evaluation
+type Example struct {
+	CategoryID  int64
+	Description string
+	Amount      float64
+}
+
+// Prediction is a ranked category with its posterior probability
+type Prediction struct {
+	CategoryID int64
+	Confidence float64
+}
+
+// Model is a multinomial naive Bayes classifier over description tokens
+// and an amount bucket, with add-one smoothing
+type Model struct {
+	docs        int
+	classDocs   map[int64]int
+	classTokens map[int64]int
+	tokenCounts map[int64]map[string]int
+	vocabulary  map[string]int
+}
+
+// NewModel creates an empty model
+func NewModel() *Model {
+	return &Model{
+		classDocs:   make(map[int64]int),
+		classTokens: make(map[int64]int),
+		tokenCounts: make(map[int64]map[string]int),
+		vocabulary:  make(map[string]int),
+	}
+}
+
+// Train builds a model from examples
+func Train(examples []Example) *Model {
+	model := NewModel()
+	for _, example := range examples {
+		model.Add(example)
+	}
+	return model
+}
+
+// Add updates the model with one example
+func (m *Model) Add(example Example) {
+	m.docs++
+	m.classDocs[example.CategoryID]++
+	if m.tokenCounts[example.CategoryID] == nil {
+		m.tokenCounts[example.CategoryID] = make(map[string]int)
+	}
+	for _, feature := range Features(example.Description, example.Amount) {
+		m.tokenCounts[example.CategoryID][feature]++
+		m.classTokens[example.CategoryID]++
+		m.vocabulary[feature]++
+	}
+}
+
+// Size returns the number of examples the model was trained on
+func (m *Model) Size() int {
+	return m.docs
+}
+
+// Predict ranks categories for a description and amount, most likely first
+func (m *Model) Predict(description string, amount float64) []Prediction {
+	if m.docs == 0 {
+		return nil
+	}
+
+	// Only features seen in training carry evidence; without a single
+	// known description token there is nothing to base a prediction on
+	var features []string
+	knownWord := false
+	for _, feature := range Features(description, amount) {
+		if m.vocabulary[feature] == 0 {
+			continue
+		}
+		features = append(features, feature)
+		if !strings.HasPrefix(feature, "amount:") {
+			knownWord = true
+		}
+	}
+	if !knownWord {
+		return nil
+	}
+
+	vocabularySize := float64(len(m.vocabulary) + 1)
+
+	scores := make(map[int64]float64, len(m.classDocs))
+	best := math.Inf(-1)
+	for categoryID, docs := range m.classDocs {
+		score := math.Log(float64(docs) / float64(m.docs))
+		denominator := float64(m.classTokens[categoryID]) + vocabularySize
+		for _, feature := range features {
+			score += math.Log((float64(m.tokenCounts[categoryID][feature]) + 1) / denominator)
+		}
+		scores[categoryID] = score
+		best = math.Max(best, score)
+	}
+
+	// Normalize log scores into probabilities
+	total := 0.0
+	for categoryID, score := range scores {
+		scores[categoryID] = math.Exp(score - best)
+		total += scores[categoryID]
+	}
+
+	predictions := make([]Prediction, 0, len(scores))
+	for categoryID, score := range scores {
+		predictions = append(predictions, Prediction{CategoryID: categoryID, Confidence: score / total})
+	}
+	sort.Slice(predictions, func(i, j int) bool {
+		if predictions[i].Confidence != predictions[j].Confidence {
+			return predictions[i].Confidence > predictions[j].Confidence
+		}
+		return predictions[i].CategoryID < predictions[j].CategoryID
+	})
+
+	return predictions
+}
+
+// Features extracts description tokens and an amount bucket
+func Features(description string, amount float64) []string {
+	words := strings.FieldsFunc(strings.ToLower(description), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	features := make([]string, 0, len(words)+1)
+	for _, word := range words {
+		if len(word) >= 2 {
+			features = append(features, word)
+		}
+	}
+	if amount > 0 {
+		features = append(features, fmt.Sprintf("amount:%d", amountBucket(amount)))
+	}
+	return features
+}
+
+// amountBucket groups amounts on a logarithmic scale (<1, 1-2, 2-4, 4-8, ...)
+func amountBucket(amount float64) int {
+	if amount < 1 {
+		return 0
+	}
+	return int(math.Log2(amount)) + 1
+}
@@ -0,0 +1,55 @@
+package classifier
+
+// Evaluation summarizes accuracy on a held-out set
+type Evaluation struct {
+	Train    int     `json:"train"`
+	Test     int     `json:"test"`
+	Top1     int     `json:"top1_correct"`
+	Top3     int     `json:"top3_correct"`
+	Accuracy float64 `json:"accuracy"`
+	Top3Rate float64 `json:"top3_accuracy"`
+}
+
+// Evaluate trains on the oldest examples and tests on the newest
+// holdout fraction, mirroring how suggestions are used in practice
+func Evaluate(examples []Example, holdout float64) Evaluation {
+	split := len(examples) - int(float64(len(examples))*holdout)
+	if split < 1 || split >= len(examples) {
+		return Evaluation{Train: len(examples)}
+	}
+
+	model := Train(examples[:split])
+	result := Evaluation{Train: split, Test: len(examples) - split}
+
+	for _, example := range examples[split:] {
+		predictions := model.Predict(example.Description, example.Amount)
+		for rank, prediction := range predictions {
+			if rank >= 3 {
+				break
+			}
+			if prediction.CategoryID == example.CategoryID {
+				if rank == 0 {
+					result.Top1++
+				}
+				result.Top3++
+				break
+			}
+		}
+	}
+
+	result.Accuracy = float64(result.Top1) / float64(result.Test)
+	result.Top3Rate = float64(result.Top3) / float64(result.Test)
+	return result
+}
+
+// Add accumulates another evaluation into this one
+func (e *Evaluation) Add(other Evaluation) {
+	e.Train += other.Train
+	e.Test += other.Test
+	e.Top1 += other.Top1
+	e.Top3 += other.Top3
+	if e.Test > 0 {
+		e.Accuracy = float64(e.Top1) / float64(e.Test)
+		e.Top3Rate = float64(e.Top3) / float64(e.Test)
+	}
+}
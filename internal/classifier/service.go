@@ -0,0 +1,133 @@
+package classifier
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// MinExamples is the history a user needs before suggestions are made
+const MinExamples = 10
+
+// Service keeps a trained model per user, built lazily from their expense history
+type Service struct {
+	db *sql.DB
+
+	mu     sync.Mutex
+	models map[int64]*Model
+}
+
+// NewService creates a new classifier service
+func NewService(db *sql.DB) *Service {
+	return &Service{
+		db:     db,
+		models: make(map[int64]*Model),
+	}
+}
+
+// Suggest ranks expense categories for a description and amount
+func (s *Service) Suggest(userID int64, description string, amount float64) ([]Prediction, error) {
+	model, err := s.model(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if model.Size() < MinExamples {
+		return nil, nil
+	}
+	return model.Predict(description, amount), nil
+}
+
+// Learn adds a newly created expense to the user's model if it is loaded
+func (s *Service) Learn(userID int64, example Example) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if model, ok := s.models[userID]; ok {
+		model.Add(example)
+	}
+}
+
+// LearnExpense adds a stored expense to the user's model if it is loaded
+func (s *Service) LearnExpense(userID, expenseID int64) error {
+	s.mu.Lock()
+	_, loaded := s.models[userID]
+	s.mu.Unlock()
+	if !loaded {
+		return nil
+	}
+
+	var example Example
+	err := s.db.QueryRow(
+		`SELECT category_id, COALESCE(description, ''), amount FROM expense WHERE id = ? AND user_id = ? AND household_id IS NULL AND deleted_at IS NULL`,
+		expenseID, userID,
+	).Scan(&example.CategoryID, &example.Description, &example.Amount)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load expense: %w", err)
+	}
+
+	s.Learn(userID, example)
+	return nil
+}
+
+// Forget drops a user's model so it is retrained on next use
+func (s *Service) Forget(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.models, userID)
+}
+
+// model returns the user's model, training it from history if needed
+func (s *Service) model(userID int64) (*Model, error) {
+	s.mu.Lock()
+	model, ok := s.models[userID]
+	s.mu.Unlock()
+	if ok {
+		return model, nil
+	}
+
+	examples, err := LoadExamples(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	model = Train(examples)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.models[userID]; ok {
+		return existing, nil
+	}
+	s.models[userID] = model
+	return model, nil
+}
+
+// LoadExamples loads a user's expenses with descriptions in chronological order
+func LoadExamples(db *sql.DB, userID int64) ([]Example, error) {
+	query := `
+		SELECT category_id, description, amount
+		FROM expense
+		WHERE user_id = ? AND household_id IS NULL AND deleted_at IS NULL AND COALESCE(description, '') != ''
+		ORDER BY expense_date, id
+	`
+
+	rows, err := db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expenses: %w", err)
+	}
+	defer rows.Close()
+
+	var examples []Example
+	for rows.Next() {
+		var example Example
+		if err := rows.Scan(&example.CategoryID, &example.Description, &example.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan expense: %w", err)
+		}
+		examples = append(examples, example)
+	}
+
+	return examples, nil
+}
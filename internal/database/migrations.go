@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"fmt"
 )
 
@@ -68,6 +69,138 @@ func (db *DB) RunMigrations() error {
 		`CREATE INDEX IF NOT EXISTS idx_expense_user_id ON expense(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_expense_date ON expense(expense_date)`,
 		`CREATE INDEX IF NOT EXISTS idx_expense_category ON expense(category_id)`,
+
+		// Monthly rollup table, maintained by the repositories on every write
+		`CREATE TABLE IF NOT EXISTS monthly_rollup (
+			user_id INTEGER NOT NULL,
+			month TEXT NOT NULL,
+			type TEXT NOT NULL CHECK(type IN ('income', 'expense')),
+			category_id INTEGER NOT NULL,
+			total REAL NOT NULL DEFAULT 0,
+			count INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (user_id, month, type, category_id),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE RESTRICT
+		)`,
+
+		// Insights table, filled by the insights engine
+		`CREATE TABLE IF NOT EXISTS insights (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			kind TEXT NOT NULL CHECK(kind IN ('large_expense', 'category_spike', 'new_merchant')),
+			dedupe_key TEXT NOT NULL,
+			title TEXT NOT NULL,
+			message TEXT NOT NULL,
+			expense_id INTEGER,
+			category_id INTEGER,
+			amount REAL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			dismissed_at DATETIME,
+			UNIQUE (user_id, dedupe_key),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (expense_id) REFERENCES expense(id) ON DELETE CASCADE,
+			FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_insights_user_id ON insights(user_id, created_at)`,
+
+		// Auto-categorization rules for expenses
+		`CREATE TABLE IF NOT EXISTS category_rules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			priority INTEGER NOT NULL DEFAULT 0,
+			enabled INTEGER NOT NULL DEFAULT 1,
+			description_contains TEXT NOT NULL DEFAULT '',
+			description_regex TEXT NOT NULL DEFAULT '',
+			min_amount REAL,
+			max_amount REAL,
+			account TEXT NOT NULL DEFAULT '',
+			weekday INTEGER CHECK(weekday BETWEEN 0 AND 6),
+			set_category_id INTEGER,
+			set_tags TEXT NOT NULL DEFAULT '',
+			set_description TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (set_category_id) REFERENCES categories(id) ON DELETE RESTRICT
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_category_rules_user_id ON category_rules(user_id, priority)`,
+
+		// Idempotency keys for create endpoints
+		`CREATE TABLE IF NOT EXISTS idempotency_keys (
+			user_id INTEGER NOT NULL,
+			idempotency_key TEXT NOT NULL,
+			fingerprint TEXT NOT NULL,
+			completed INTEGER NOT NULL DEFAULT 0,
+			status INTEGER NOT NULL DEFAULT 0,
+			content_type TEXT NOT NULL DEFAULT '',
+			response BLOB,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, idempotency_key),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys(created_at)`,
+
+		// Append-only audit trail of changes to a user's records
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			actor_id INTEGER,
+			entity TEXT NOT NULL,
+			entity_id INTEGER NOT NULL,
+			action TEXT NOT NULL,
+			before_json TEXT,
+			after_json TEXT,
+			changes TEXT NOT NULL DEFAULT '{}',
+			request_id TEXT NOT NULL DEFAULT '',
+			ip TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			household_id INTEGER,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_user_id ON audit_log(user_id, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity, entity_id)`,
+		auditAppendOnlyTrigger,
+		// Entries can only be deleted along with their user, by the cascade
+		`CREATE TRIGGER IF NOT EXISTS audit_log_no_delete
+			BEFORE DELETE ON audit_log
+			WHEN EXISTS (SELECT 1 FROM users WHERE id = OLD.user_id)
+			BEGIN
+				SELECT RAISE(ABORT, 'audit log is append-only');
+			END`,
+
+		// Shared household ledgers and their members
+		`CREATE TABLE IF NOT EXISTS households (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS household_members (
+			household_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			role TEXT NOT NULL CHECK(role IN ('owner', 'editor', 'viewer')),
+			joined_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (household_id, user_id),
+			FOREIGN KEY (household_id) REFERENCES households(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_household_members_user_id ON household_members(user_id)`,
+		`CREATE TABLE IF NOT EXISTS household_invitations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			household_id INTEGER NOT NULL,
+			invited_by INTEGER NOT NULL,
+			invitee_id INTEGER NOT NULL,
+			role TEXT NOT NULL CHECK(role IN ('editor', 'viewer')),
+			status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'accepted', 'declined', 'revoked')),
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			responded_at DATETIME,
+			FOREIGN KEY (household_id) REFERENCES households(id) ON DELETE CASCADE,
+			FOREIGN KEY (invited_by) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (invitee_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_household_invitations_invitee ON household_invitations(invitee_id, status)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_household_invitations_pending
+			ON household_invitations(household_id, invitee_id) WHERE status = 'pending'`,
 	}
 
 	for _, migration := range migrations {
@@ -76,14 +209,658 @@ func (db *DB) RunMigrations() error {
 		}
 	}
 
+	// Columns added after the initial schema
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"income", "tags", "TEXT NOT NULL DEFAULT ''"},
+		{"income", "account", "TEXT NOT NULL DEFAULT ''"},
+		{"expense", "tags", "TEXT NOT NULL DEFAULT ''"},
+		{"expense", "account", "TEXT NOT NULL DEFAULT ''"},
+		{"income", "deleted_at", "DATETIME"},
+		{"expense", "deleted_at", "DATETIME"},
+		{"income", "household_id", "INTEGER REFERENCES households(id) ON DELETE CASCADE"},
+		{"expense", "household_id", "INTEGER REFERENCES households(id) ON DELETE CASCADE"},
+	}
+
+	for _, col := range columns {
+		if err := db.addColumnIfMissing(col.table, col.column, col.definition); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	// Indexes on added columns
+	indexes := []string{
+		`CREATE INDEX IF NOT EXISTS idx_income_deleted_at ON income(deleted_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_expense_deleted_at ON expense(deleted_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_income_household_id ON income(household_id, income_date)`,
+		`CREATE INDEX IF NOT EXISTS idx_expense_household_id ON expense(household_id, expense_date)`,
+	}
+
+	for _, index := range indexes {
+		if _, err := db.Exec(index); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	// Split line items and the per-category view built on top of them
+	splits := []string{
+		`CREATE TABLE IF NOT EXISTS expense_splits (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			expense_id INTEGER NOT NULL,
+			category_id INTEGER NOT NULL,
+			amount REAL NOT NULL CHECK(amount > 0),
+			note TEXT NOT NULL DEFAULT '',
+			position INTEGER NOT NULL DEFAULT 0,
+			FOREIGN KEY (expense_id) REFERENCES expense(id) ON DELETE CASCADE,
+			FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE RESTRICT
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_expense_splits_expense_id ON expense_splits(expense_id, position)`,
+		`CREATE INDEX IF NOT EXISTS idx_expense_splits_category_id ON expense_splits(category_id)`,
+		// expense_lines has one row per category an expense counts towards:
+		// the expense itself when it is not split, otherwise each split.
+		// It is recreated on every start so it picks up new expense columns.
+		`DROP VIEW IF EXISTS expense_lines`,
+		`CREATE VIEW expense_lines AS
+			SELECT e.id AS expense_id, e.user_id, e.household_id, e.category_id, e.amount, e.expense_date,
+				e.description, '' AS note, e.deleted_at
+			FROM expense e
+			WHERE NOT EXISTS (SELECT 1 FROM expense_splits s WHERE s.expense_id = e.id)
+			UNION ALL
+			SELECT e.id, e.user_id, e.household_id, s.category_id, s.amount, e.expense_date,
+				e.description, s.note, e.deleted_at
+			FROM expense_splits s
+			JOIN expense e ON s.expense_id = e.id`,
+	}
+
+	for _, statement := range splits {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	// Let households add categories whose names other ledgers also use
+	if err := db.scopeCategoriesToHouseholds(); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
+	// Let users add personal categories, each name unique within its owner's ledger
+	if err := db.addColumnIfMissing("categories", "user_id", "INTEGER REFERENCES users(id) ON DELETE CASCADE"); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	categoryIndexes := []string{
+		`DROP INDEX IF EXISTS idx_categories_ledger_name`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_owner_name ON categories(COALESCE(household_id, 0), COALESCE(user_id, 0), name)`,
+	}
+	for _, statement := range categoryIndexes {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	// Let household members read each other's changes to shared records
+	if err := db.scopeAuditToHouseholds(); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_audit_log_household_id ON audit_log(household_id, created_at)`); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
+	// Bills shared with other users or contacts, and settle-up payments
+	sharing := []string{
+		`CREATE TABLE IF NOT EXISTS contacts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			email TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_contacts_user_name ON contacts(user_id, name COLLATE NOCASE)`,
+		`CREATE TABLE IF NOT EXISTS shared_bills (
+			expense_id INTEGER PRIMARY KEY,
+			method TEXT NOT NULL CHECK(method IN ('equal', 'percentage', 'exact')),
+			total REAL NOT NULL CHECK(total > 0),
+			paid_by_user_id INTEGER,
+			paid_by_contact_id INTEGER,
+			FOREIGN KEY (expense_id) REFERENCES expense(id) ON DELETE CASCADE,
+			FOREIGN KEY (paid_by_user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (paid_by_contact_id) REFERENCES contacts(id) ON DELETE RESTRICT
+		)`,
+		`CREATE TABLE IF NOT EXISTS bill_participants (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			expense_id INTEGER NOT NULL,
+			user_id INTEGER,
+			contact_id INTEGER,
+			percent REAL,
+			amount REAL NOT NULL CHECK(amount > 0),
+			position INTEGER NOT NULL DEFAULT 0,
+			CHECK((user_id IS NULL) != (contact_id IS NULL)),
+			FOREIGN KEY (expense_id) REFERENCES shared_bills(expense_id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (contact_id) REFERENCES contacts(id) ON DELETE RESTRICT
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_bill_participants_expense_id ON bill_participants(expense_id, position)`,
+		`CREATE INDEX IF NOT EXISTS idx_bill_participants_user_id ON bill_participants(user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_bill_participants_contact_id ON bill_participants(contact_id)`,
+		`CREATE TABLE IF NOT EXISTS settlements (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			recorded_by INTEGER NOT NULL,
+			from_user_id INTEGER,
+			from_contact_id INTEGER,
+			to_user_id INTEGER,
+			to_contact_id INTEGER,
+			amount REAL NOT NULL CHECK(amount > 0),
+			settled_on DATE NOT NULL,
+			note TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			CHECK((from_user_id IS NULL) != (from_contact_id IS NULL)),
+			CHECK((to_user_id IS NULL) != (to_contact_id IS NULL)),
+			FOREIGN KEY (recorded_by) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (from_user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (from_contact_id) REFERENCES contacts(id) ON DELETE RESTRICT,
+			FOREIGN KEY (to_user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (to_contact_id) REFERENCES contacts(id) ON DELETE RESTRICT
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_settlements_recorded_by ON settlements(recorded_by, settled_on)`,
+		`CREATE INDEX IF NOT EXISTS idx_settlements_from_user_id ON settlements(from_user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_settlements_to_user_id ON settlements(to_user_id)`,
+	}
+
+	for _, statement := range sharing {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	// Savings goals and the contributions made towards them
+	goals := []string{
+		`CREATE TABLE IF NOT EXISTS savings_goals (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			target_amount REAL NOT NULL CHECK(target_amount > 0),
+			target_date DATE,
+			start_date DATE NOT NULL,
+			account TEXT NOT NULL DEFAULT '',
+			category_id INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			CHECK(account = '' OR category_id IS NULL),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE SET NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_savings_goals_user_id ON savings_goals(user_id)`,
+		`CREATE TABLE IF NOT EXISTS goal_contributions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			goal_id INTEGER NOT NULL,
+			amount REAL NOT NULL CHECK(amount != 0),
+			contributed_on DATE NOT NULL,
+			note TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (goal_id) REFERENCES savings_goals(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_goal_contributions_goal_id ON goal_contributions(goal_id, contributed_on)`,
+	}
+
+	for _, statement := range goals {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	// Loans and the payments that pay them off
+	loans := []string{
+		`CREATE TABLE IF NOT EXISTS loans (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			lender TEXT NOT NULL DEFAULT '',
+			principal REAL NOT NULL CHECK(principal > 0),
+			annual_rate REAL NOT NULL CHECK(annual_rate >= 0),
+			term_months INTEGER NOT NULL CHECK(term_months > 0),
+			frequency TEXT NOT NULL CHECK(frequency IN ('monthly', 'biweekly', 'weekly')),
+			start_date DATE NOT NULL,
+			payment_amount REAL NOT NULL CHECK(payment_amount > 0),
+			category_id INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE SET NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_loans_user_id ON loans(user_id)`,
+		`CREATE TABLE IF NOT EXISTS loan_payments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			loan_id INTEGER NOT NULL,
+			payment_date DATE NOT NULL,
+			amount REAL NOT NULL CHECK(amount > 0),
+			principal REAL NOT NULL,
+			interest REAL NOT NULL,
+			balance REAL NOT NULL,
+			expense_id INTEGER,
+			note TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (loan_id) REFERENCES loans(id) ON DELETE CASCADE,
+			FOREIGN KEY (expense_id) REFERENCES expense(id) ON DELETE SET NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_loan_payments_loan_id ON loan_payments(loan_id, payment_date)`,
+	}
+
+	for _, statement := range loans {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	// Bills with due dates, their payments and calendar feed tokens
+	bills := []string{
+		`CREATE TABLE IF NOT EXISTS bills (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			amount REAL NOT NULL CHECK(amount > 0),
+			category_id INTEGER NOT NULL,
+			account TEXT NOT NULL DEFAULT '',
+			first_due_date DATE NOT NULL,
+			next_due_date DATE,
+			recurrence TEXT NOT NULL DEFAULT 'none' CHECK(recurrence IN ('none', 'weekly', 'biweekly', 'monthly', 'quarterly', 'yearly')),
+			remind_days INTEGER NOT NULL DEFAULT 3 CHECK(remind_days >= 0),
+			notes TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE RESTRICT
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_bills_user_id ON bills(user_id, next_due_date)`,
+		`CREATE TABLE IF NOT EXISTS bill_payments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			bill_id INTEGER NOT NULL,
+			due_date DATE NOT NULL,
+			paid_on DATE NOT NULL,
+			amount REAL NOT NULL CHECK(amount > 0),
+			expense_id INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (bill_id) REFERENCES bills(id) ON DELETE CASCADE,
+			FOREIGN KEY (expense_id) REFERENCES expense(id) ON DELETE SET NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_bill_payments_bill_id ON bill_payments(bill_id, due_date)`,
+		`CREATE TABLE IF NOT EXISTS calendar_feeds (
+			user_id INTEGER PRIMARY KEY,
+			token_hash TEXT NOT NULL UNIQUE,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+	}
+
+	for _, statement := range bills {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	// Notifications, their deliveries and per-user channel preferences
+	notifications := []string{
+		`CREATE TABLE IF NOT EXISTS notifications (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			event TEXT NOT NULL,
+			dedupe_key TEXT NOT NULL,
+			title TEXT NOT NULL,
+			message TEXT NOT NULL,
+			link TEXT NOT NULL DEFAULT '',
+			in_inbox INTEGER NOT NULL DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			read_at DATETIME,
+			UNIQUE (user_id, dedupe_key),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id, in_inbox, created_at)`,
+		`CREATE TABLE IF NOT EXISTS notification_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			notification_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			channel TEXT NOT NULL CHECK(channel IN ('email', 'webhook')),
+			digest INTEGER NOT NULL DEFAULT 0,
+			status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'sent', 'failed')),
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at DATETIME,
+			last_error TEXT NOT NULL DEFAULT '',
+			sent_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (notification_id) REFERENCES notifications(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_notification_deliveries_due ON notification_deliveries(status, next_attempt_at)`,
+		`CREATE TABLE IF NOT EXISTS notification_preferences (
+			user_id INTEGER NOT NULL,
+			event TEXT NOT NULL,
+			in_app INTEGER NOT NULL DEFAULT 1,
+			email INTEGER NOT NULL DEFAULT 0,
+			webhook INTEGER NOT NULL DEFAULT 0,
+			digest INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (user_id, event),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS notification_settings (
+			user_id INTEGER PRIMARY KEY,
+			email TEXT NOT NULL DEFAULT '',
+			webhook_url TEXT NOT NULL DEFAULT '',
+			digest_hour INTEGER NOT NULL DEFAULT 8 CHECK(digest_hour BETWEEN 0 AND 23),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+	}
+
+	for _, statement := range notifications {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	// Outgoing webhooks, their deliveries and each delivery attempt
+	webhooks := []string{
+		`CREATE TABLE IF NOT EXISTS webhooks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			url TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			events TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			active INTEGER NOT NULL DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON webhooks(user_id)`,
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			webhook_id INTEGER NOT NULL,
+			event_id TEXT NOT NULL,
+			event TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'sent', 'failed')),
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			completed_at DATETIME,
+			FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at)`,
+		`CREATE TABLE IF NOT EXISTS webhook_attempts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			delivery_id INTEGER NOT NULL,
+			status_code INTEGER NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT '',
+			response_body TEXT NOT NULL DEFAULT '',
+			duration_ms INTEGER NOT NULL DEFAULT 0,
+			attempted_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (delivery_id) REFERENCES webhook_deliveries(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_attempts_delivery_id ON webhook_attempts(delivery_id)`,
+	}
+
+	for _, statement := range webhooks {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	// Change feed for offline sync. Triggers keep the latest version of every
+	// personal record, category and user's settings, and give each personal
+	// record a UUID, so no write path can bypass the feed.
+	syncTables := []string{
+		`CREATE TABLE IF NOT EXISTS sync_changes (
+			version INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER,
+			entity TEXT NOT NULL,
+			entity_id INTEGER NOT NULL,
+			changed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (entity, entity_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_sync_changes_user_id ON sync_changes(user_id, version)`,
+		`CREATE TABLE IF NOT EXISTS sync_ids (
+			user_id INTEGER NOT NULL,
+			entity TEXT NOT NULL,
+			entity_id INTEGER NOT NULL,
+			uuid TEXT NOT NULL,
+			PRIMARY KEY (entity, entity_id),
+			UNIQUE (user_id, uuid)
+		)`,
+		// Category triggers are recreated on every start so they pick up the
+		// owner column: defaults go to every user, personal categories to their owner
+		`DROP TRIGGER IF EXISTS sync_category_insert`,
+		`CREATE TRIGGER sync_category_insert AFTER INSERT ON categories WHEN NEW.household_id IS NULL
+			BEGIN
+				INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id) VALUES (NEW.user_id, 'category', NEW.id);
+			END`,
+		`DROP TRIGGER IF EXISTS sync_category_update`,
+		`CREATE TRIGGER sync_category_update AFTER UPDATE ON categories WHEN NEW.household_id IS NULL
+			BEGIN
+				INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id) VALUES (NEW.user_id, 'category', NEW.id);
+			END`,
+		`DROP TRIGGER IF EXISTS sync_category_delete`,
+		`CREATE TRIGGER sync_category_delete AFTER DELETE ON categories WHEN OLD.household_id IS NULL
+			BEGIN
+				INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id) VALUES (OLD.user_id, 'category', OLD.id);
+			END`,
+		`CREATE TRIGGER IF NOT EXISTS sync_settings_insert AFTER INSERT ON users
+			BEGIN
+				INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id) VALUES (NEW.id, 'settings', NEW.id);
+			END`,
+		`CREATE TRIGGER IF NOT EXISTS sync_settings_update AFTER UPDATE OF currency, theme ON users
+			BEGIN
+				INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id) VALUES (NEW.id, 'settings', NEW.id);
+			END`,
+	}
+	for _, table := range []string{"income", "expense"} {
+		syncTables = append(syncTables,
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS sync_%[1]s_insert AFTER INSERT ON %[1]s WHEN NEW.household_id IS NULL
+				BEGIN
+					INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id) VALUES (NEW.user_id, '%[1]s', NEW.id);
+					INSERT OR IGNORE INTO sync_ids (user_id, entity, entity_id, uuid) VALUES (NEW.user_id, '%[1]s', NEW.id, %[2]s);
+				END`, table, uuidSQL),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS sync_%[1]s_update AFTER UPDATE ON %[1]s WHEN NEW.household_id IS NULL
+				BEGIN
+					INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id) VALUES (NEW.user_id, '%[1]s', NEW.id);
+				END`, table),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS sync_%[1]s_delete AFTER DELETE ON %[1]s WHEN OLD.household_id IS NULL
+				BEGIN
+					INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id) VALUES (OLD.user_id, '%[1]s', OLD.id);
+				END`, table),
+		)
+	}
+
+	for _, statement := range syncTables {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	// Statement imports. Previews are staged with their rows until committed,
+	// and every imported bank transaction id is remembered per account so the
+	// same transaction is never imported twice.
+	imports := []string{
+		`CREATE TABLE IF NOT EXISTS imports (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			format TEXT NOT NULL,
+			filename TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'preview',
+			rows TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			committed_at DATETIME,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_imports_user_id ON imports(user_id, created_at)`,
+		`CREATE TABLE IF NOT EXISTS import_ids (
+			user_id INTEGER NOT NULL,
+			source TEXT NOT NULL,
+			external_id TEXT NOT NULL,
+			entity TEXT NOT NULL,
+			entity_id INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, source, external_id),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+	}
+
+	for _, statement := range imports {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	if err := db.addColumnIfMissing("imports", "household_id", "INTEGER REFERENCES households(id) ON DELETE CASCADE"); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
 	// Insert default categories
 	if err := db.insertDefaultCategories(); err != nil {
 		return fmt.Errorf("failed to insert default categories: %w", err)
 	}
 
+	// Populate rollups for databases created before they existed
+	if err := db.backfillRollups(); err != nil {
+		return fmt.Errorf("failed to backfill rollups: %w", err)
+	}
+
+	// Add records written before the change feed existed to it
+	if err := db.backfillSync(); err != nil {
+		return fmt.Errorf("failed to backfill sync changes: %w", err)
+	}
+
 	return nil
 }
 
+// scopeCategoriesToHouseholds rebuilds the categories table with a household_id
+// column, replacing the global unique name with one unique per ledger. SQLite
+// cannot drop a constraint in place, so the table is copied with foreign keys
+// off on a dedicated connection.
+func (db *DB) scopeCategoriesToHouseholds() error {
+	exists, err := db.hasColumn("categories", "household_id")
+	if err != nil || exists {
+		return err
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`CREATE TABLE categories_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			type TEXT NOT NULL CHECK(type IN ('income', 'expense')),
+			household_id INTEGER REFERENCES households(id) ON DELETE CASCADE,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`INSERT INTO categories_new (id, name, type, created_at) SELECT id, name, type, created_at FROM categories`,
+		`DROP TABLE categories`,
+		`ALTER TABLE categories_new RENAME TO categories`,
+		`CREATE UNIQUE INDEX idx_categories_ledger_name ON categories(COALESCE(household_id, 0), name)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// auditAppendOnlyTrigger refuses changes to audit entries
+const auditAppendOnlyTrigger = `CREATE TRIGGER IF NOT EXISTS audit_log_append_only
+			BEFORE UPDATE ON audit_log
+			BEGIN
+				SELECT RAISE(ABORT, 'audit log is append-only');
+			END`
+
+// scopeAuditToHouseholds adds the household_id column to audit entries and
+// fills it in from the income and expense records they describe. The column
+// has no foreign key: entries outlive their household, which the append-only
+// triggers would otherwise stop from being deleted.
+func (db *DB) scopeAuditToHouseholds() error {
+	exists, err := db.hasColumn("audit_log", "household_id")
+	if err != nil || exists {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`ALTER TABLE audit_log ADD COLUMN household_id INTEGER`,
+		`DROP TRIGGER IF EXISTS audit_log_append_only`,
+		`UPDATE audit_log SET household_id = (SELECT household_id FROM income WHERE income.id = audit_log.entity_id)
+			WHERE entity = 'income'`,
+		`UPDATE audit_log SET household_id = (SELECT household_id FROM expense WHERE expense.id = audit_log.entity_id)
+			WHERE entity = 'expense'`,
+		auditAppendOnlyTrigger,
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// hasColumn reports whether a table has a column
+func (db *DB) hasColumn(table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var defaultValue interface{}
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &defaultValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
+
+// addColumnIfMissing adds a column to an existing table unless it is already there
+func (db *DB) addColumnIfMissing(table, column, definition string) error {
+	exists, err := db.hasColumn(table, column)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
 // insertDefaultCategories adds default income and expense categories
 func (db *DB) insertDefaultCategories() error {
 	categories := []struct {
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"fmt"
+)
+
+// RebuildRollups recomputes the monthly_rollup table from the raw
+// personal income and expense rows, ignoring trashed ones and household
+// records. Split expenses count towards each of their split categories. It is safe to run at any time.
+func (db *DB) RebuildRollups() error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`DELETE FROM monthly_rollup`,
+		`INSERT INTO monthly_rollup (user_id, month, type, category_id, total, count)
+			SELECT user_id, substr(income_date, 1, 7), 'income', category_id, SUM(amount), COUNT(*)
+			FROM income
+			WHERE deleted_at IS NULL AND household_id IS NULL
+			GROUP BY user_id, substr(income_date, 1, 7), category_id`,
+		`INSERT INTO monthly_rollup (user_id, month, type, category_id, total, count)
+			SELECT user_id, substr(expense_date, 1, 7), 'expense', category_id, SUM(amount), COUNT(*)
+			FROM expense_lines
+			WHERE deleted_at IS NULL AND household_id IS NULL
+			GROUP BY user_id, substr(expense_date, 1, 7), category_id`,
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("failed to rebuild rollups: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// backfillRollups rebuilds the rollups when the table is empty but
+// transactions already exist (first start after upgrading)
+func (db *DB) backfillRollups() error {
+	var rollups, records int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM monthly_rollup`).Scan(&rollups); err != nil {
+		return err
+	}
+	if rollups > 0 {
+		return nil
+	}
+
+	err := db.QueryRow(`SELECT (SELECT COUNT(*) FROM income) + (SELECT COUNT(*) FROM expense)`).Scan(&records)
+	if err != nil {
+		return err
+	}
+	if records == 0 {
+		return nil
+	}
+
+	return db.RebuildRollups()
+}
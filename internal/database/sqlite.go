@@ -9,6 +9,13 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// connectionOptions are applied to every pooled connection. Writes run in
+// transactions that also maintain rollup tables, so they take the write lock up
+// front and wait on contention. Foreign keys are per connection in SQLite, so
+// enabling them here, rather than with a PRAGMA on one connection, makes
+// ON DELETE cascades apply whichever connection runs the delete.
+const connectionOptions = "?_busy_timeout=5000&_txlock=immediate&_foreign_keys=on"
+
 // DB wraps the database connection
 type DB struct {
 	*sql.DB
@@ -23,7 +30,7 @@ func InitDB(dbPath string) (*DB, error) {
 	}
 
 	// Open database connection
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open("sqlite3", dbPath+connectionOptions)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -33,11 +40,6 @@ func InitDB(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	// Enable foreign keys
-	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
-		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
-	}
-
 	return &DB{db}, nil
 }
 
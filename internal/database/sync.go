@@ -0,0 +1,50 @@
+package database
+
+import (
+	"fmt"
+)
+
+// uuidSQL is an SQL expression for a random version 4 UUID
+const uuidSQL = `lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' ||
+	substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))`
+
+// backfillSync adds the records, categories and settings that have no entry
+// in the change feed, and UUIDs for personal records without one. It only
+// touches rows that are missing, so it is safe to run on every start.
+func (db *DB) backfillSync() error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`INSERT INTO sync_changes (user_id, entity, entity_id)
+			SELECT user_id, 'category', id FROM categories
+			WHERE household_id IS NULL
+			  AND NOT EXISTS (SELECT 1 FROM sync_changes WHERE entity = 'category' AND entity_id = categories.id)`,
+		`INSERT INTO sync_changes (user_id, entity, entity_id)
+			SELECT id, 'settings', id FROM users
+			WHERE NOT EXISTS (SELECT 1 FROM sync_changes WHERE entity = 'settings' AND entity_id = users.id)`,
+	}
+	for _, table := range []string{"income", "expense"} {
+		statements = append(statements,
+			fmt.Sprintf(`INSERT INTO sync_changes (user_id, entity, entity_id)
+				SELECT user_id, '%[1]s', id FROM %[1]s
+				WHERE household_id IS NULL
+				  AND NOT EXISTS (SELECT 1 FROM sync_changes WHERE entity = '%[1]s' AND entity_id = %[1]s.id)`, table),
+			fmt.Sprintf(`INSERT INTO sync_ids (user_id, entity, entity_id, uuid)
+				SELECT user_id, '%[1]s', id, %[2]s FROM %[1]s
+				WHERE household_id IS NULL
+				  AND NOT EXISTS (SELECT 1 FROM sync_ids WHERE entity = '%[1]s' AND entity_id = %[1]s.id)`, table, uuidSQL),
+		)
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,153 @@
+package duplicates
+
+import (
+	"math"
+	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/repository"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// WindowDays is how far apart two expenses may be dated and still be duplicates
+	WindowDays = 3
+	// minSimilarity is the description token overlap needed to call two expenses duplicates
+	minSimilarity = 0.5
+)
+
+// Detector finds expenses that look like duplicates of each other
+type Detector struct {
+	expenseRepo *repository.ExpenseRepository
+}
+
+// NewDetector creates a new duplicate detector
+func NewDetector(expenseRepo *repository.ExpenseRepository) *Detector {
+	return &Detector{expenseRepo: expenseRepo}
+}
+
+// Candidates returns stored expenses that look like duplicates of expense
+// (same amount, dated within WindowDays, similar description)
+func (d *Detector) Candidates(userID int64, expense *models.Expense) ([]models.Expense, error) {
+	nearby, err := d.expenseRepo.GetByAmountNearDate(userID, expense.Amount, expense.ExpenseDate, WindowDays, expense.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []models.Expense
+	for _, other := range nearby {
+		if Similar(expense.Description, other.Description) {
+			candidates = append(candidates, other)
+		}
+	}
+	return candidates, nil
+}
+
+// Groups clusters expenses into sets of suspected duplicates. Only groups
+// with at least two members are returned, largest amounts first.
+func Groups(expenses []models.Expense) [][]models.Expense {
+	sorted := append([]models.Expense(nil), expenses...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Amount != sorted[j].Amount {
+			return sorted[i].Amount < sorted[j].Amount
+		}
+		return sorted[i].ExpenseDate < sorted[j].ExpenseDate
+	})
+
+	parent := make([]int, len(sorted))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range sorted {
+		for j := i + 1; j < len(sorted) && sameAmount(sorted[i].Amount, sorted[j].Amount); j++ {
+			if Duplicates(&sorted[i], &sorted[j]) {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	byRoot := make(map[int][]models.Expense)
+	var roots []int
+	for i := range sorted {
+		root := find(i)
+		if _, ok := byRoot[root]; !ok {
+			roots = append(roots, root)
+		}
+		byRoot[root] = append(byRoot[root], sorted[i])
+	}
+
+	var groups [][]models.Expense
+	for _, root := range roots {
+		if len(byRoot[root]) > 1 {
+			groups = append(groups, byRoot[root])
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i][0].Amount > groups[j][0].Amount
+	})
+	return groups
+}
+
+// Duplicates reports whether two expenses look like the same transaction
+func Duplicates(a, b *models.Expense) bool {
+	if !sameAmount(a.Amount, b.Amount) {
+		return false
+	}
+
+	dateA, errA := time.Parse("2006-01-02", repository.DateOnly(a.ExpenseDate))
+	dateB, errB := time.Parse("2006-01-02", repository.DateOnly(b.ExpenseDate))
+	if errA != nil || errB != nil {
+		return false
+	}
+	if math.Abs(dateA.Sub(dateB).Hours()) > WindowDays*24 {
+		return false
+	}
+
+	return Similar(a.Description, b.Description)
+}
+
+// Similar reports whether two descriptions share enough tokens. Two empty
+// descriptions are considered similar.
+func Similar(a, b string) bool {
+	tokensA, tokensB := tokens(a), tokens(b)
+	if len(tokensA) == 0 && len(tokensB) == 0 {
+		return true
+	}
+	if len(tokensA) == 0 || len(tokensB) == 0 {
+		return false
+	}
+
+	shared := 0
+	for token := range tokensA {
+		if tokensB[token] {
+			shared++
+		}
+	}
+	union := len(tokensA) + len(tokensB) - shared
+	return float64(shared)/float64(union) >= minSimilarity
+}
+
+// tokens splits a description into a set of lowercase words
+func tokens(description string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(description), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		set[word] = true
+	}
+	return set
+}
+
+// sameAmount compares amounts to the cent
+func sameAmount(a, b float64) bool {
+	return math.Abs(a-b) < 0.005
+}
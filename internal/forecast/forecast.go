@@ -0,0 +1,421 @@
+package forecast
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"myexpress-tracker/internal/bills"
+	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/repository"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// historyMonths is how far back recurring transactions are searched
+	historyMonths = 6
+	// minRecurringMonths is how many distinct months a transaction must appear in
+	minRecurringMonths = 3
+	// maxRecurringVariation is the largest amount spread (relative to the median) still considered recurring
+	maxRecurringVariation = 0.25
+	// discretionaryDays is the window used for average daily spending
+	discretionaryDays = 90
+)
+
+// Service projects a user's balance forward in time
+type Service struct {
+	db *sql.DB
+}
+
+// NewService creates a new forecasting service
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+// transaction is a single historical or scheduled income/expense row
+type transaction struct {
+	Type         string
+	CategoryID   int64
+	CategoryName string
+	Amount       float64
+	Description  string
+	Date         time.Time
+}
+
+// Project builds a day-by-day balance projection for the next days, starting after today
+func (s *Service) Project(userID int64, days int, today time.Time) (*models.Forecast, error) {
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	end := today.AddDate(0, 0, days)
+
+	balance, err := s.balanceAsOf(userID, today)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := s.transactions(userID, today.AddDate(0, -historyMonths, 0), today)
+	if err != nil {
+		return nil, err
+	}
+
+	scheduled, err := s.transactions(userID, today.AddDate(0, 0, 1), end)
+	if err != nil {
+		return nil, err
+	}
+
+	upcomingBills, err := s.billOccurrences(userID, today, end)
+	if err != nil {
+		return nil, err
+	}
+
+	recurring, recurringKeys := detectRecurring(history, today)
+	discretionary := averageDiscretionary(history, recurringKeys, today)
+
+	forecast := &models.Forecast{
+		StartingBalance:    round2(balance),
+		Recurring:          recurring,
+		DailyDiscretionary: make(map[string]float64),
+		Days:               []models.ForecastDay{},
+		LowestBalance:      round2(balance),
+		LowestBalanceDate:  today.Format("2006-01-02"),
+	}
+
+	dailySpend := 0.0
+	for name, amount := range discretionary {
+		forecast.DailyDiscretionary[name] = round2(amount)
+		dailySpend += amount
+	}
+
+	// Index known future-dated records by day and by month
+	scheduledByDay := make(map[string][]transaction)
+	scheduledByMonth := make(map[string][]transaction)
+	for _, tx := range scheduled {
+		day := tx.Date.Format("2006-01-02")
+		scheduledByDay[day] = append(scheduledByDay[day], tx)
+		scheduledByMonth[monthKey(tx.Date)] = append(scheduledByMonth[monthKey(tx.Date)], tx)
+	}
+
+	// Bills count as scheduled too, so a bill's payments inferred as recurring aren't added twice
+	billsByDay := make(map[string][]transaction)
+	for _, tx := range upcomingBills {
+		day := tx.Date.Format("2006-01-02")
+		billsByDay[day] = append(billsByDay[day], tx)
+		scheduledByMonth[monthKey(tx.Date)] = append(scheduledByMonth[monthKey(tx.Date)], tx)
+	}
+
+	for day := today.AddDate(0, 0, 1); !day.After(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		projected := models.ForecastDay{Date: date, Events: []models.ForecastEvent{}}
+
+		for _, tx := range scheduledByDay[date] {
+			projected.Events = append(projected.Events, eventFor(tx.Type, tx.CategoryName, tx.Description, tx.Amount, "scheduled"))
+		}
+
+		for _, tx := range billsByDay[date] {
+			projected.Events = append(projected.Events, eventFor(tx.Type, tx.CategoryName, tx.Description, tx.Amount, "bill"))
+		}
+
+		for _, schedule := range recurring {
+			if dueOn(schedule.DayOfMonth, day) && !alreadyScheduled(scheduledByMonth[monthKey(day)], schedule) {
+				projected.Events = append(projected.Events, eventFor(schedule.Type, schedule.CategoryName, schedule.Description, schedule.Amount, "recurring"))
+			}
+		}
+
+		for _, event := range projected.Events {
+			if event.Type == "income" {
+				projected.Income += event.Amount
+			} else {
+				projected.Expense += event.Amount
+			}
+		}
+		projected.Discretionary = round2(dailySpend)
+		projected.Expense += dailySpend
+
+		balance += projected.Income - projected.Expense
+		projected.Income = round2(projected.Income)
+		projected.Expense = round2(projected.Expense)
+		projected.Balance = round2(balance)
+
+		if projected.Balance < forecast.LowestBalance {
+			forecast.LowestBalance = projected.Balance
+			forecast.LowestBalanceDate = date
+		}
+
+		forecast.Days = append(forecast.Days, projected)
+	}
+
+	forecast.EndingBalance = round2(balance)
+	return forecast, nil
+}
+
+// balanceAsOf returns all income minus all expense dated on or before the given day
+func (s *Service) balanceAsOf(userID int64, day time.Time) (float64, error) {
+	date := day.Format("2006-01-02")
+
+	var balance float64
+	err := s.db.QueryRow(`
+		SELECT
+			(SELECT COALESCE(SUM(amount), 0) FROM income WHERE user_id = ? AND household_id IS NULL AND income_date <= ? AND deleted_at IS NULL) -
+			(SELECT COALESCE(SUM(amount), 0) FROM expense WHERE user_id = ? AND household_id IS NULL AND expense_date <= ? AND deleted_at IS NULL)
+	`, userID, date, userID, date).Scan(&balance)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get balance: %w", err)
+	}
+
+	return balance, nil
+}
+
+// transactions loads income and expense rows dated within [from, to], with
+// split expenses broken into one row per split
+func (s *Service) transactions(userID int64, from, to time.Time) ([]transaction, error) {
+	query := `
+		SELECT 'income', i.category_id, c.name, i.amount, COALESCE(i.description, ''), i.income_date
+		FROM income i
+		JOIN categories c ON i.category_id = c.id
+		WHERE i.user_id = ? AND i.household_id IS NULL AND i.income_date >= ? AND i.income_date <= ? AND i.deleted_at IS NULL
+		UNION ALL
+		SELECT 'expense', l.category_id, c.name, l.amount, COALESCE(l.description, ''), l.expense_date
+		FROM expense_lines l
+		JOIN categories c ON l.category_id = c.id
+		WHERE l.user_id = ? AND l.household_id IS NULL AND l.expense_date >= ? AND l.expense_date <= ? AND l.deleted_at IS NULL
+	`
+
+	fromDate, toDate := from.Format("2006-01-02"), to.Format("2006-01-02")
+	rows, err := s.db.Query(query, userID, fromDate, toDate, userID, fromDate, toDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var transactions []transaction
+	for rows.Next() {
+		var tx transaction
+		var date string
+		if err := rows.Scan(&tx.Type, &tx.CategoryID, &tx.CategoryName, &tx.Amount, &tx.Description, &date); err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		parsed, err := time.Parse("2006-01-02", repository.DateOnly(date))
+		if err != nil {
+			continue
+		}
+		tx.Date = parsed
+		transactions = append(transactions, tx)
+	}
+
+	return transactions, nil
+}
+
+// billOccurrences lists the unpaid due dates of the user's bills through end.
+// Paid occurrences are already behind each bill's next due date. Overdue ones
+// are still owed, so they are moved to the first projected day.
+func (s *Service) billOccurrences(userID int64, today, end time.Time) ([]transaction, error) {
+	rows, err := s.db.Query(`
+		SELECT b.category_id, COALESCE(c.name, ''), b.name, b.amount, b.first_due_date, b.next_due_date, b.recurrence
+		FROM bills b
+		LEFT JOIN categories c ON b.category_id = c.id
+		WHERE b.user_id = ? AND b.next_due_date IS NOT NULL AND b.next_due_date <= ?
+	`, userID, end.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bills: %w", err)
+	}
+	defer rows.Close()
+
+	tomorrow := today.AddDate(0, 0, 1)
+	var occurrences []transaction
+	for rows.Next() {
+		var categoryID int64
+		var categoryName, name, firstDue, nextDue, recurrence string
+		var amount float64
+		if err := rows.Scan(&categoryID, &categoryName, &name, &amount, &firstDue, &nextDue, &recurrence); err != nil {
+			return nil, fmt.Errorf("failed to scan bill: %w", err)
+		}
+		first, err := time.Parse("2006-01-02", repository.DateOnly(firstDue))
+		if err != nil {
+			continue
+		}
+		next, err := time.Parse("2006-01-02", repository.DateOnly(nextDue))
+		if err != nil {
+			continue
+		}
+
+		for _, due := range bills.Between(first, next, recurrence, next, end) {
+			if due.Before(tomorrow) {
+				due = tomorrow
+			}
+			occurrences = append(occurrences, transaction{
+				Type:         "expense",
+				CategoryID:   categoryID,
+				CategoryName: categoryName,
+				Amount:       amount,
+				Description:  name,
+				Date:         due,
+			})
+		}
+	}
+
+	return occurrences, nil
+}
+
+// recurringKey identifies transactions that repeat
+func recurringKey(tx transaction) string {
+	description := strings.ToLower(strings.TrimSpace(tx.Description))
+	if description == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s|%d|%s", tx.Type, tx.CategoryID, description)
+}
+
+// detectRecurring infers monthly schedules from transactions that repeat with a stable amount
+func detectRecurring(history []transaction, today time.Time) ([]models.RecurringSchedule, map[string]bool) {
+	groups := make(map[string][]transaction)
+	for _, tx := range history {
+		if key := recurringKey(tx); key != "" {
+			groups[key] = append(groups[key], tx)
+		}
+	}
+
+	var schedules []models.RecurringSchedule
+	keys := make(map[string]bool)
+
+	for key, txs := range groups {
+		months := make(map[string]bool)
+		amounts := make([]float64, 0, len(txs))
+		daysOfMonth := make([]float64, 0, len(txs))
+		lastSeen := txs[0].Date
+		for _, tx := range txs {
+			months[monthKey(tx.Date)] = true
+			amounts = append(amounts, tx.Amount)
+			daysOfMonth = append(daysOfMonth, float64(tx.Date.Day()))
+			if tx.Date.After(lastSeen) {
+				lastSeen = tx.Date
+			}
+		}
+
+		// Must repeat across months, roughly once a month, and still be active
+		if len(months) < minRecurringMonths || len(txs) > len(months)+1 {
+			continue
+		}
+		if today.Sub(lastSeen) > 45*24*time.Hour {
+			continue
+		}
+
+		amount := median(amounts)
+		if amount <= 0 || (maxOf(amounts)-minOf(amounts))/amount > maxRecurringVariation {
+			continue
+		}
+
+		keys[key] = true
+		schedules = append(schedules, models.RecurringSchedule{
+			Type:         txs[0].Type,
+			CategoryID:   txs[0].CategoryID,
+			CategoryName: txs[0].CategoryName,
+			Description:  txs[0].Description,
+			Amount:       round2(amount),
+			DayOfMonth:   int(median(daysOfMonth)),
+			Occurrences:  len(txs),
+		})
+	}
+
+	sort.Slice(schedules, func(i, j int) bool {
+		if schedules[i].DayOfMonth != schedules[j].DayOfMonth {
+			return schedules[i].DayOfMonth < schedules[j].DayOfMonth
+		}
+		return schedules[i].Description < schedules[j].Description
+	})
+
+	if schedules == nil {
+		schedules = []models.RecurringSchedule{}
+	}
+	return schedules, keys
+}
+
+// averageDiscretionary returns average daily non-recurring spending per category
+func averageDiscretionary(history []transaction, recurringKeys map[string]bool, today time.Time) map[string]float64 {
+	since := today.AddDate(0, 0, -discretionaryDays)
+	totals := make(map[string]float64)
+
+	for _, tx := range history {
+		if tx.Type != "expense" || !tx.Date.After(since) || recurringKeys[recurringKey(tx)] {
+			continue
+		}
+		totals[tx.CategoryName] += tx.Amount
+	}
+
+	for name, total := range totals {
+		totals[name] = total / discretionaryDays
+	}
+	return totals
+}
+
+// dueOn reports whether a monthly schedule falls on day, clamping to the month's last day
+func dueOn(dayOfMonth int, day time.Time) bool {
+	lastDay := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if dayOfMonth > lastDay {
+		dayOfMonth = lastDay
+	}
+	return day.Day() == dayOfMonth
+}
+
+// alreadyScheduled reports whether a recurring item was already entered for that month
+func alreadyScheduled(scheduled []transaction, schedule models.RecurringSchedule) bool {
+	for _, tx := range scheduled {
+		if tx.Type == schedule.Type && tx.CategoryID == schedule.CategoryID &&
+			strings.EqualFold(strings.TrimSpace(tx.Description), strings.TrimSpace(schedule.Description)) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventFor builds a projected event
+func eventFor(typ, category, description string, amount float64, source string) models.ForecastEvent {
+	return models.ForecastEvent{
+		Type:         typ,
+		CategoryName: category,
+		Description:  description,
+		Amount:       round2(amount),
+		Source:       source,
+	}
+}
+
+// monthKey formats a date as YYYY-MM
+func monthKey(t time.Time) string {
+	return t.Format("2006-01")
+}
+
+// median returns the middle value of values
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n == 0 {
+		return 0
+	}
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// minOf returns the smallest of values
+func minOf(values []float64) float64 {
+	result := math.Inf(1)
+	for _, v := range values {
+		result = math.Min(result, v)
+	}
+	return result
+}
+
+// maxOf returns the largest of values
+func maxOf(values []float64) float64 {
+	result := math.Inf(-1)
+	for _, v := range values {
+		result = math.Max(result, v)
+	}
+	return result
+}
+
+// round2 rounds a value to two decimal places
+func round2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
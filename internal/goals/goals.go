@@ -0,0 +1,87 @@
+package goals
+
+import (
+	"math"
+	"myexpress-tracker/internal/models"
+	"time"
+)
+
+// Progress works out how far along a goal is on a given day from the amounts
+// contributed and saved through its linked account or category
+func Progress(goal *models.SavingsGoal, contributed, linked float64, today time.Time) *models.GoalProgress {
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+
+	saved := round(contributed + linked)
+	progress := &models.GoalProgress{
+		Contributed: round(contributed),
+		Linked:      round(linked),
+		Saved:       saved,
+		Remaining:   round(math.Max(goal.TargetAmount-saved, 0)),
+		Percent:     round(math.Max(saved, 0) / goal.TargetAmount * 100),
+		Status:      models.GoalInProgress,
+	}
+
+	if saved >= goal.TargetAmount {
+		progress.Status = models.GoalCompleted
+	}
+	if goal.TargetDate == nil {
+		return progress
+	}
+
+	target, err := time.Parse("2006-01-02", *goal.TargetDate)
+	if err != nil {
+		return progress
+	}
+
+	months := monthsUntil(today, target)
+	progress.MonthsLeft = &months
+	if progress.Status == models.GoalCompleted {
+		return progress
+	}
+
+	if months == 0 {
+		// Past the target date: everything left is due now
+		progress.Status = models.GoalOverdue
+		progress.RequiredMonthly = &progress.Remaining
+		return progress
+	}
+
+	required := round(progress.Remaining / float64(months))
+	progress.RequiredMonthly = &required
+
+	// Compare with a steady pace from the start date to the target date
+	expected := goal.TargetAmount
+	if start, err := time.Parse("2006-01-02", goal.StartDate); err == nil && target.After(start) {
+		elapsed := math.Max(today.Sub(start).Hours(), 0)
+		expected = goal.TargetAmount * math.Min(elapsed/target.Sub(start).Hours(), 1)
+	}
+	if saved >= round(expected) {
+		progress.Status = models.GoalOnTrack
+	} else {
+		progress.Status = models.GoalBehind
+	}
+
+	return progress
+}
+
+// monthsUntil counts the monthly contributions left before target, including
+// the current month. It is 0 once target has passed.
+func monthsUntil(today, target time.Time) int {
+	if target.Before(today) {
+		return 0
+	}
+
+	months := (target.Year()-today.Year())*12 + int(target.Month()-today.Month())
+	if target.Day() >= today.Day() {
+		months++
+	}
+	if months < 1 {
+		months = 1
+	}
+	return months
+}
+
+// round rounds an amount to cents
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"myexpress-tracker/internal/middleware"
+	"myexpress-tracker/internal/repository"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AuditHandler handles change history and audit log requests
+type AuditHandler struct {
+	auditRepo *repository.AuditRepository
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(auditRepo *repository.AuditRepository) *AuditHandler {
+	return &AuditHandler{
+		auditRepo: auditRepo,
+	}
+}
+
+// GetHistory retrieves the change history of a single income or expense record
+// in the ledger, including changes made by other household members
+func (h *AuditHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	// Path format: /api/{income|expense}/{id}/history
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 4 || pathParts[3] != "history" {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+
+	entity := pathParts[1]
+	if entity != "income" && entity != "expense" {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+
+	entityID, err := strconv.ParseInt(pathParts[2], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid id"}`, http.StatusBadRequest)
+		return
+	}
+
+	entries, err := h.auditRepo.GetByEntity(ledgerFromRequest(r, userID), entity, entityID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch history"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if len(entries) == 0 {
+		http.Error(w, `{"error":"no history found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// GetAuditLog retrieves the audit log of the personal or household ledger with
+// optional filters
+func (h *AuditHandler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	// Parse query parameters
+	query := r.URL.Query()
+	filters := make(map[string]interface{})
+
+	for _, name := range []string{"entity", "action", "request_id", "start_date", "end_date"} {
+		if value := query.Get(name); value != "" {
+			filters[name] = value
+		}
+	}
+
+	for _, name := range []string{"entity_id", "actor_id"} {
+		if value := query.Get(name); value != "" {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				http.Error(w, `{"error":"invalid `+name+`"}`, http.StatusBadRequest)
+				return
+			}
+			filters[name] = id
+		}
+	}
+
+	for _, name := range []string{"limit", "offset"} {
+		if value := query.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				http.Error(w, `{"error":"invalid `+name+`"}`, http.StatusBadRequest)
+				return
+			}
+			filters[name] = n
+		}
+	}
+
+	entries, err := h.auditRepo.GetByLedger(ledgerFromRequest(r, userID), filters)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch audit log"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
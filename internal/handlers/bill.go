@@ -0,0 +1,747 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"myexpress-tracker/internal/bills"
+	"myexpress-tracker/internal/middleware"
+	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/repository"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultRemindDays is how many days before its due date a bill is due soon
+	defaultRemindDays = 3
+	// maxRemindDays caps a bill's reminder window
+	maxRemindDays = 60
+	// maxUpcomingDays caps the window of the upcoming bills listing
+	maxUpcomingDays = 366
+)
+
+// BillHandler handles bill, bill payment and calendar feed requests
+type BillHandler struct {
+	billRepo     *repository.BillRepository
+	expenseRepo  *repository.ExpenseRepository
+	categoryRepo *repository.CategoryRepository
+}
+
+// NewBillHandler creates a new bill handler
+func NewBillHandler(billRepo *repository.BillRepository, expenseRepo *repository.ExpenseRepository, categoryRepo *repository.CategoryRepository) *BillHandler {
+	return &BillHandler{
+		billRepo:     billRepo,
+		expenseRepo:  expenseRepo,
+		categoryRepo: categoryRepo,
+	}
+}
+
+// PayBillRequest marks a bill's next due date as paid
+type PayBillRequest struct {
+	DueDate string  `json:"due_date"` // Optional; must match the bill's next due date
+	PaidOn  string  `json:"paid_on"`  // Defaults to today
+	Amount  float64 `json:"amount"`   // Defaults to the bill's amount
+	Account string  `json:"account"`  // Defaults to the bill's account
+}
+
+// PayBillResponse is a bill after paying it, with the payment and its expense
+type PayBillResponse struct {
+	Bill    *models.Bill        `json:"bill"`
+	Payment *models.BillPayment `json:"payment"`
+	Expense *models.Expense     `json:"expense"`
+}
+
+// CalendarFeedResponse describes a user's calendar feed. The token and URL are
+// only returned when the token is created.
+type CalendarFeedResponse struct {
+	Enabled   bool       `json:"enabled"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	Token     string     `json:"token,omitempty"`
+	URL       string     `json:"url,omitempty"`
+}
+
+// GetBills retrieves the user's bills with their status, optionally only those
+// with ?status=upcoming|due_soon|overdue|paid
+func (h *BillHandler) GetBills(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", models.BillUpcoming, models.BillDueSoon, models.BillOverdue, models.BillPaid:
+	default:
+		http.Error(w, `{"error":"status must be upcoming, due_soon, overdue or paid"}`, http.StatusBadRequest)
+		return
+	}
+
+	billList, err := h.billRepo.GetByUser(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch bills"}`, http.StatusInternalServerError)
+		return
+	}
+
+	today := startOfDay(time.Now())
+	filtered := []models.Bill{}
+	for i := range billList {
+		attachBillStatus(&billList[i], today)
+		if status == "" || billList[i].Status == status {
+			filtered = append(filtered, billList[i])
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(filtered)
+}
+
+// GetBill retrieves a bill with its status
+func (h *BillHandler) GetBill(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	billID, ok := billIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	h.writeBill(w, billID, userID, http.StatusOK)
+}
+
+// CreateBill creates a new bill
+func (h *BillHandler) CreateBill(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	bill := models.Bill{RemindDays: defaultRemindDays}
+	if err := json.NewDecoder(r.Body).Decode(&bill); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if !h.validateBill(w, &bill, userID) {
+		return
+	}
+
+	bill.UserID = userID
+
+	if err := h.billRepo.Create(&bill); err != nil {
+		http.Error(w, `{"error":"failed to create bill"}`, http.StatusInternalServerError)
+		return
+	}
+
+	h.writeBill(w, bill.ID, userID, http.StatusCreated)
+}
+
+// UpdateBill updates a bill. Payments already recorded are kept.
+func (h *BillHandler) UpdateBill(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	billID, ok := billIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	bill := models.Bill{RemindDays: defaultRemindDays}
+	if err := json.NewDecoder(r.Body).Decode(&bill); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if !h.validateBill(w, &bill, userID) {
+		return
+	}
+
+	bill.ID = billID
+	bill.UserID = userID
+
+	if err := h.billRepo.Update(&bill); err != nil {
+		http.Error(w, `{"error":"failed to update bill"}`, http.StatusInternalServerError)
+		return
+	}
+
+	h.writeBill(w, billID, userID, http.StatusOK)
+}
+
+// DeleteBill deletes a bill and its payments
+func (h *BillHandler) DeleteBill(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	billID, ok := billIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.billRepo.Delete(billID, userID); err != nil {
+		http.Error(w, `{"error":"failed to delete bill"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "bill deleted successfully"})
+}
+
+// PayBill marks a bill's next due date as paid, recording the payment as an
+// expense in the bill's category and moving the bill on to its next occurrence
+func (h *BillHandler) PayBill(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	bill, ok := h.findBill(w, r, userID)
+	if !ok {
+		return
+	}
+
+	var req PayBillRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if req.Amount == 0 {
+		req.Amount = bill.Amount
+	}
+	if req.Amount < 0 {
+		http.Error(w, `{"error":"amount must be more than zero"}`, http.StatusBadRequest)
+		return
+	}
+	if req.PaidOn == "" {
+		req.PaidOn = time.Now().Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", req.PaidOn); err != nil {
+		http.Error(w, `{"error":"paid_on must be YYYY-MM-DD"}`, http.StatusBadRequest)
+		return
+	}
+	req.Account = strings.TrimSpace(req.Account)
+	if req.Account == "" {
+		req.Account = bill.Account
+	}
+
+	// Paying a bill that was paid in the meantime would record a second expense
+	if bill.NextDueDate == nil || (req.DueDate != "" && req.DueDate != *bill.NextDueDate) {
+		http.Error(w, `{"error":"`+repository.ErrBillNotDue.Error()+`"}`, http.StatusConflict)
+		return
+	}
+
+	expense := &models.Expense{
+		UserID:      userID,
+		CategoryID:  bill.CategoryID,
+		Amount:      req.Amount,
+		Description: bill.Name,
+		ExpenseDate: req.PaidOn,
+		Account:     req.Account,
+	}
+	payment := models.BillPayment{
+		PaidOn: req.PaidOn,
+		Amount: req.Amount,
+	}
+
+	err := h.expenseRepo.CreateBillPayment(r.Context(), bill, &payment, expense)
+	if err == repository.ErrBillNotDue {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to record bill payment"}`, http.StatusInternalServerError)
+		return
+	}
+
+	bill, err = h.billRepo.GetByID(bill.ID, userID)
+	if err != nil || bill == nil {
+		http.Error(w, `{"error":"failed to fetch bill"}`, http.StatusInternalServerError)
+		return
+	}
+	attachBillStatus(bill, startOfDay(time.Now()))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(PayBillResponse{Bill: bill, Payment: &payment, Expense: expense})
+}
+
+// GetPayments retrieves the payments of a bill
+func (h *BillHandler) GetPayments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	bill, ok := h.findBill(w, r, userID)
+	if !ok {
+		return
+	}
+
+	payments, err := h.billRepo.GetPayments(bill.ID, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch bill payments"}`, http.StatusInternalServerError)
+		return
+	}
+	if payments == nil {
+		payments = []models.BillPayment{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payments)
+}
+
+// DeletePayment deletes a payment from a bill, making its due date unpaid again
+// when it was the latest one. The expense recorded for it is kept.
+func (h *BillHandler) DeletePayment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	bill, ok := h.findBill(w, r, userID)
+	if !ok {
+		return
+	}
+
+	// /api/bills/{id}/payments/{payment_id}
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 5 {
+		http.Error(w, `{"error":"payment id required"}`, http.StatusBadRequest)
+		return
+	}
+	paymentID, err := strconv.ParseInt(pathParts[4], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid payment id"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.billRepo.DeletePayment(paymentID, bill); err != nil {
+		http.Error(w, `{"error":"failed to delete bill payment"}`, http.StatusInternalServerError)
+		return
+	}
+
+	h.writeBill(w, bill.ID, userID, http.StatusOK)
+}
+
+// GetUpcoming lists the unpaid due dates of the user's bills from today through
+// the next ?days=30 days, soonest first
+func (h *BillHandler) GetUpcoming(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	days := 30
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxUpcomingDays {
+			http.Error(w, fmt.Sprintf(`{"error":"days must be between 1 and %d"}`, maxUpcomingDays), http.StatusBadRequest)
+			return
+		}
+		days = parsed
+	}
+
+	billList, err := h.billRepo.GetByUser(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch bills"}`, http.StatusInternalServerError)
+		return
+	}
+
+	today := startOfDay(time.Now())
+	until := today.AddDate(0, 0, days)
+	occurrences := []models.BillOccurrence{}
+	for _, bill := range billList {
+		if bill.NextDueDate == nil {
+			continue
+		}
+		first, err := time.Parse("2006-01-02", bill.FirstDueDate)
+		if err != nil {
+			continue
+		}
+		next, err := time.Parse("2006-01-02", *bill.NextDueDate)
+		if err != nil {
+			continue
+		}
+
+		for _, due := range bills.Between(first, next, bill.Recurrence, today, until) {
+			status := models.BillUpcoming
+			if int(due.Sub(today).Hours()/24) <= bill.RemindDays {
+				status = models.BillDueSoon
+			}
+			occurrences = append(occurrences, models.BillOccurrence{
+				BillID:       bill.ID,
+				Name:         bill.Name,
+				Amount:       bill.Amount,
+				DueDate:      due.Format("2006-01-02"),
+				CategoryName: bill.CategoryName,
+				Status:       status,
+			})
+		}
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].DueDate < occurrences[j].DueDate
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(occurrences)
+}
+
+// GetOverdue lists the user's bills whose next due date has passed, most
+// overdue first
+func (h *BillHandler) GetOverdue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	billList, err := h.billRepo.GetByUser(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch bills"}`, http.StatusInternalServerError)
+		return
+	}
+
+	today := startOfDay(time.Now())
+	overdue := []models.Bill{}
+	for i := range billList {
+		attachBillStatus(&billList[i], today)
+		if billList[i].Status == models.BillOverdue {
+			overdue = append(overdue, billList[i])
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(overdue)
+}
+
+// GetCalendarFeed reports whether the user has a calendar feed
+func (h *BillHandler) GetCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	createdAt, err := h.billRepo.GetFeed(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch calendar feed"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CalendarFeedResponse{Enabled: createdAt != nil, CreatedAt: createdAt})
+}
+
+// CreateCalendarFeed creates the user's calendar feed token, replacing and so
+// revoking any previous one. The feed URL is only shown in this response.
+func (h *BillHandler) CreateCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, `{"error":"failed to create calendar feed"}`, http.StatusInternalServerError)
+		return
+	}
+	token := hex.EncodeToString(b)
+
+	if err := h.billRepo.SetFeedToken(userID, feedTokenHash(token)); err != nil {
+		http.Error(w, `{"error":"failed to create calendar feed"}`, http.StatusInternalServerError)
+		return
+	}
+
+	createdAt, err := h.billRepo.GetFeed(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch calendar feed"}`, http.StatusInternalServerError)
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CalendarFeedResponse{
+		Enabled:   true,
+		CreatedAt: createdAt,
+		Token:     token,
+		URL:       fmt.Sprintf("%s://%s/api/calendar/%s.ics", scheme, r.Host, token),
+	})
+}
+
+// DeleteCalendarFeed revokes the user's calendar feed token
+func (h *BillHandler) DeleteCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.billRepo.DeleteFeedToken(userID); err != nil {
+		http.Error(w, `{"error":"calendar feed not found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "calendar feed revoked successfully"})
+}
+
+// ServeCalendar serves a user's unpaid bills as an iCalendar feed at
+// /api/calendar/{token}.ics. The token in the URL is the only credential, so
+// calendar apps can subscribe without logging in.
+func (h *BillHandler) ServeCalendar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/calendar/"), ".ics")
+	if token == "" || strings.Contains(token, "/") {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+
+	userID, err := h.billRepo.UserByFeedToken(feedTokenHash(token))
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch calendar feed"}`, http.StatusInternalServerError)
+		return
+	}
+	if userID == 0 {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+
+	billList, err := h.billRepo.GetByUser(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch bills"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="bills.ics"`)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(bills.Calendar(billList, time.Now()))
+}
+
+// validateBill checks a bill and fills in defaults, writing an error response
+// on failure
+func (h *BillHandler) validateBill(w http.ResponseWriter, bill *models.Bill, userID int64) bool {
+	bill.Name = strings.TrimSpace(bill.Name)
+	bill.Account = strings.TrimSpace(bill.Account)
+	bill.Notes = strings.TrimSpace(bill.Notes)
+	if bill.Name == "" || bill.Amount <= 0 || bill.CategoryID == 0 || bill.FirstDueDate == "" {
+		http.Error(w, `{"error":"name, amount (>0), category_id and first_due_date are required"}`, http.StatusBadRequest)
+		return false
+	}
+
+	if _, err := time.Parse("2006-01-02", bill.FirstDueDate); err != nil {
+		http.Error(w, `{"error":"first_due_date must be YYYY-MM-DD"}`, http.StatusBadRequest)
+		return false
+	}
+
+	if bill.Recurrence == "" {
+		bill.Recurrence = bills.Once
+	}
+	if !bills.ValidRecurrence(bill.Recurrence) {
+		http.Error(w, `{"error":"recurrence must be none, weekly, biweekly, monthly, quarterly or yearly"}`, http.StatusBadRequest)
+		return false
+	}
+
+	if bill.RemindDays < 0 || bill.RemindDays > maxRemindDays {
+		http.Error(w, fmt.Sprintf(`{"error":"remind_days must be between 0 and %d"}`, maxRemindDays), http.StatusBadRequest)
+		return false
+	}
+
+	// Bills are personal, so payments go to a personal expense category
+	category, err := h.categoryRepo.GetByID(bill.CategoryID, repository.Personal(userID))
+	if err != nil || category == nil || category.Type != "expense" {
+		http.Error(w, `{"error":"invalid expense category"}`, http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+// findBill loads the bill named in the path, writing an error response when it
+// cannot be found
+func (h *BillHandler) findBill(w http.ResponseWriter, r *http.Request, userID int64) (*models.Bill, bool) {
+	billID, ok := billIDFromPath(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	bill, err := h.billRepo.GetByID(billID, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch bill"}`, http.StatusInternalServerError)
+		return nil, false
+	}
+	if bill == nil {
+		http.Error(w, `{"error":"bill not found"}`, http.StatusNotFound)
+		return nil, false
+	}
+
+	return bill, true
+}
+
+// writeBill responds with a stored bill and its status
+func (h *BillHandler) writeBill(w http.ResponseWriter, billID, userID int64, status int) {
+	bill, err := h.billRepo.GetByID(billID, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch bill"}`, http.StatusInternalServerError)
+		return
+	}
+	if bill == nil {
+		http.Error(w, `{"error":"bill not found"}`, http.StatusNotFound)
+		return
+	}
+
+	attachBillStatus(bill, startOfDay(time.Now()))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(bill)
+}
+
+// attachBillStatus works out whether a bill is paid, overdue, due soon or
+// upcoming on a day
+func attachBillStatus(bill *models.Bill, today time.Time) {
+	if bill.NextDueDate == nil {
+		bill.Status = models.BillPaid
+		return
+	}
+	due, err := time.Parse("2006-01-02", *bill.NextDueDate)
+	if err != nil {
+		return
+	}
+
+	days := int(due.Sub(today).Hours() / 24)
+	bill.DaysUntilDue = &days
+	switch {
+	case days < 0:
+		bill.Status = models.BillOverdue
+	case days <= bill.RemindDays:
+		bill.Status = models.BillDueSoon
+	default:
+		bill.Status = models.BillUpcoming
+	}
+}
+
+// startOfDay is midnight UTC on t's calendar day, to compare with parsed dates
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// feedTokenHash hashes a calendar feed token for storage, so a leaked
+// database does not expose working feed URLs
+func feedTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// billIDFromPath extracts the bill id from /api/bills/{id}[/...]
+func billIDFromPath(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 3 {
+		http.Error(w, `{"error":"bill id required"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	billID, err := strconv.ParseInt(pathParts[2], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid bill id"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	return billID, true
+}
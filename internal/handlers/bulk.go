@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"myexpress-tracker/internal/middleware"
+	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/repository"
+	"net/http"
+	"time"
+)
+
+// BulkHandler handles bulk operations on income and expense records
+type BulkHandler struct {
+	incomeRepo   *repository.IncomeRepository
+	expenseRepo  *repository.ExpenseRepository
+	categoryRepo *repository.CategoryRepository
+}
+
+// NewBulkHandler creates a new bulk handler
+func NewBulkHandler(incomeRepo *repository.IncomeRepository, expenseRepo *repository.ExpenseRepository, categoryRepo *repository.CategoryRepository) *BulkHandler {
+	return &BulkHandler{
+		incomeRepo:   incomeRepo,
+		expenseRepo:  expenseRepo,
+		categoryRepo: categoryRepo,
+	}
+}
+
+// BulkIncome updates or deletes many income records at once
+func (h *BulkHandler) BulkIncome(w http.ResponseWriter, r *http.Request) {
+	h.handle(w, r, "income")
+}
+
+// BulkExpenses updates or deletes many expense records at once
+func (h *BulkHandler) BulkExpenses(w http.ResponseWriter, r *http.Request) {
+	h.handle(w, r, "expense")
+}
+
+// handle validates a bulk request and runs it against the repository for recordType
+func (h *BulkHandler) handle(w http.ResponseWriter, r *http.Request, recordType string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req models.BulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if !h.validate(w, &req, recordType, userID) {
+		return
+	}
+
+	var response *models.BulkResponse
+	var err error
+	if recordType == "income" {
+		response, err = h.incomeRepo.Bulk(r.Context(), userID, &req)
+	} else {
+		response, err = h.expenseRepo.Bulk(r.Context(), userID, &req)
+	}
+	if err == repository.ErrTooManyBulkItems {
+		http.Error(w, fmt.Sprintf(`{"error":"filter matches more than %d records"}`, repository.MaxBulkItems), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"bulk operation failed"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if response.Failed > 0 && !req.DryRun {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// validate checks a bulk request, writing an error response on failure
+func (h *BulkHandler) validate(w http.ResponseWriter, req *models.BulkRequest, recordType string, userID int64) bool {
+	if req.Action != models.BulkActionUpdate && req.Action != models.BulkActionDelete {
+		http.Error(w, `{"error":"action must be update or delete"}`, http.StatusBadRequest)
+		return false
+	}
+
+	if (len(req.IDs) == 0) == (req.Filter == nil) {
+		http.Error(w, `{"error":"provide either ids or filter"}`, http.StatusBadRequest)
+		return false
+	}
+
+	if len(req.IDs) > repository.MaxBulkItems {
+		http.Error(w, fmt.Sprintf(`{"error":"at most %d ids are allowed"}`, repository.MaxBulkItems), http.StatusBadRequest)
+		return false
+	}
+
+	if req.Action == models.BulkActionDelete {
+		return true
+	}
+
+	set := req.Set
+	if set.CategoryID == nil && set.Tags == nil && set.Date == nil && set.Account == nil {
+		http.Error(w, `{"error":"set must change at least one of category_id, tags, date or account"}`, http.StatusBadRequest)
+		return false
+	}
+
+	if set.CategoryID != nil {
+		category, err := h.categoryRepo.GetByID(*set.CategoryID, repository.Personal(userID))
+		if err != nil || category == nil || category.Type != recordType {
+			http.Error(w, `{"error":"invalid `+recordType+` category"}`, http.StatusBadRequest)
+			return false
+		}
+	}
+
+	if set.Date != nil {
+		if _, err := time.Parse("2006-01-02", *set.Date); err != nil {
+			http.Error(w, `{"error":"date must be YYYY-MM-DD"}`, http.StatusBadRequest)
+			return false
+		}
+	}
+
+	return true
+}
@@ -2,8 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"myexpress-tracker/internal/middleware"
+	"myexpress-tracker/internal/models"
 	"myexpress-tracker/internal/repository"
 	"net/http"
+	"strings"
 )
 
 // CategoryHandler handles category requests
@@ -18,22 +21,30 @@ func NewCategoryHandler(categoryRepo *repository.CategoryRepository) *CategoryHa
 	}
 }
 
-// GetCategories retrieves all categories or filtered by type
+// GetCategories retrieves all categories or filtered by type. The defaults
+// come with the user's own categories, or with a selected household's.
 func (h *CategoryHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
 		return
 	}
 
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
 	categoryType := r.URL.Query().Get("type")
+	ledger := ledgerFromRequest(r, userID)
 	
 	var categories interface{}
 	var err error
 	
 	if categoryType != "" && (categoryType == "income" || categoryType == "expense") {
-		categories, err = h.categoryRepo.GetByType(categoryType)
+		categories, err = h.categoryRepo.GetByType(categoryType, ledger)
 	} else {
-		categories, err = h.categoryRepo.GetAll()
+		categories, err = h.categoryRepo.GetAll(ledger)
 	}
 
 	if err != nil {
@@ -44,3 +55,52 @@ func (h *CategoryHandler) GetCategories(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(categories)
 }
+
+// CreateCategory adds a custom category to the user's personal ledger or to
+// the selected household
+func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	ledger := ledgerFromRequest(r, userID)
+
+	var category models.Category
+	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" || (category.Type != "income" && category.Type != "expense") {
+		http.Error(w, `{"error":"name and type (income or expense) are required"}`, http.StatusBadRequest)
+		return
+	}
+
+	category.HouseholdID = ledger.HouseholdRef()
+
+	err := h.categoryRepo.Create(&category, userID)
+	if err == repository.ErrForbidden {
+		http.Error(w, `{"error":"your household role cannot add categories"}`, http.StatusForbidden)
+		return
+	}
+	if err == repository.ErrDuplicateName {
+		http.Error(w, `{"error":"a category with that name already exists"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to create category"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(category)
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"encoding/json"
+	"myexpress-tracker/internal/middleware"
+	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/repository"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ContactHandler handles the named contacts a user shares bills with
+type ContactHandler struct {
+	contactRepo *repository.ContactRepository
+}
+
+// NewContactHandler creates a new contact handler
+func NewContactHandler(contactRepo *repository.ContactRepository) *ContactHandler {
+	return &ContactHandler{contactRepo: contactRepo}
+}
+
+// GetContacts retrieves the user's contacts
+func (h *ContactHandler) GetContacts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	contacts, err := h.contactRepo.GetByUser(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch contacts"}`, http.StatusInternalServerError)
+		return
+	}
+	if contacts == nil {
+		contacts = []models.Contact{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(contacts)
+}
+
+// CreateContact creates a new contact
+func (h *ContactHandler) CreateContact(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var contact models.Contact
+	if err := json.NewDecoder(r.Body).Decode(&contact); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	contact.Name = strings.TrimSpace(contact.Name)
+	contact.Email = strings.TrimSpace(contact.Email)
+	if contact.Name == "" {
+		http.Error(w, `{"error":"name is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	contact.UserID = userID
+
+	err := h.contactRepo.Create(&contact)
+	if err == repository.ErrDuplicateName {
+		http.Error(w, `{"error":"a contact with this name already exists"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to create contact"}`, http.StatusInternalServerError)
+		return
+	}
+
+	h.writeContact(w, contact.ID, userID, http.StatusCreated)
+}
+
+// UpdateContact renames a contact or changes its email
+func (h *ContactHandler) UpdateContact(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	contactID, ok := contactIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var contact models.Contact
+	if err := json.NewDecoder(r.Body).Decode(&contact); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	contact.Name = strings.TrimSpace(contact.Name)
+	contact.Email = strings.TrimSpace(contact.Email)
+	if contact.Name == "" {
+		http.Error(w, `{"error":"name is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	contact.ID = contactID
+	contact.UserID = userID
+
+	err := h.contactRepo.Update(&contact)
+	if err == repository.ErrDuplicateName {
+		http.Error(w, `{"error":"a contact with this name already exists"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to update contact"}`, http.StatusInternalServerError)
+		return
+	}
+
+	h.writeContact(w, contactID, userID, http.StatusOK)
+}
+
+// DeleteContact deletes a contact that no shared bill or settlement refers to
+func (h *ContactHandler) DeleteContact(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	contactID, ok := contactIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.contactRepo.Delete(contactID, userID)
+	if err == repository.ErrContactInUse {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to delete contact"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "contact deleted successfully"})
+}
+
+// writeContact responds with the stored contact
+func (h *ContactHandler) writeContact(w http.ResponseWriter, contactID, userID int64, status int) {
+	contact, err := h.contactRepo.GetByID(contactID, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch contact"}`, http.StatusInternalServerError)
+		return
+	}
+	if contact == nil {
+		http.Error(w, `{"error":"contact not found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(contact)
+}
+
+// contactIDFromPath extracts the contact id from /api/contacts/{id}
+func contactIDFromPath(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 3 {
+		http.Error(w, `{"error":"contact id required"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	contactID, err := strconv.ParseInt(pathParts[len(pathParts)-1], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid contact id"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	return contactID, true
+}
@@ -1,22 +1,29 @@
 package handlers
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"myexpress-tracker/internal/cache"
 	"myexpress-tracker/internal/middleware"
 	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/repository"
 	"net/http"
+	"strings"
 	"time"
 )
 
 // DashboardHandler handles dashboard requests
 type DashboardHandler struct {
-	db *sql.DB
+	db       *sql.DB
+	cache    cache.SummaryCache
+	goalRepo *repository.GoalRepository
 }
 
 // NewDashboardHandler creates a new dashboard handler
-func NewDashboardHandler(db *sql.DB) *DashboardHandler {
-	return &DashboardHandler{db: db}
+func NewDashboardHandler(db *sql.DB, summaryCache cache.SummaryCache, goalRepo *repository.GoalRepository) *DashboardHandler {
+	return &DashboardHandler{db: db, cache: summaryCache, goalRepo: goalRepo}
 }
 
 // GetDashboard retrieves dashboard summary data
@@ -32,6 +39,23 @@ func (h *DashboardHandler) GetDashboard(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Get today's date
+	today := time.Now().Format("2006-01-02")
+	currentMonth := time.Now().Format("2006-01")
+
+	// Household dashboards are computed from the shared records on every request
+	if householdID := middleware.GetHouseholdIDFromContext(r); householdID != 0 {
+		h.getHouseholdDashboard(w, householdID, today, currentMonth)
+		return
+	}
+
+	// Serve from cache while no writes happened since it was computed
+	if entry, ok := h.cache.Get(userID); ok && entry.Day == today {
+		writeSummary(w, r, entry)
+		return
+	}
+	version := h.cache.Version(userID)
+
 	summary := models.DashboardSummary{
 		CategoryBreakdown: models.CategoryBreakdown{
 			IncomeByCategory:  make(map[string]float64),
@@ -39,47 +63,43 @@ func (h *DashboardHandler) GetDashboard(w http.ResponseWriter, r *http.Request)
 		},
 	}
 
-	// Get today's date
-	today := time.Now().Format("2006-01-02")
-	currentMonth := time.Now().Format("2006-01")
-
 	// Total income
-	err := h.db.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM income WHERE user_id = ?`, userID).Scan(&summary.TotalIncome)
+	err := h.db.QueryRow(`SELECT COALESCE(SUM(total), 0) FROM monthly_rollup WHERE user_id = ? AND type = 'income'`, userID).Scan(&summary.TotalIncome)
 	if err != nil {
 		http.Error(w, `{"error":"failed to fetch total income"}`, http.StatusInternalServerError)
 		return
 	}
 
 	// Total expense
-	err = h.db.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM expense WHERE user_id = ?`, userID).Scan(&summary.TotalExpense)
+	err = h.db.QueryRow(`SELECT COALESCE(SUM(total), 0) FROM monthly_rollup WHERE user_id = ? AND type = 'expense'`, userID).Scan(&summary.TotalExpense)
 	if err != nil {
 		http.Error(w, `{"error":"failed to fetch total expense"}`, http.StatusInternalServerError)
 		return
 	}
 
 	// Today's income
-	err = h.db.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM income WHERE user_id = ? AND income_date = ?`, userID, today).Scan(&summary.TodayIncome)
+	err = h.db.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM income WHERE user_id = ? AND household_id IS NULL AND income_date = ? AND deleted_at IS NULL`, userID, today).Scan(&summary.TodayIncome)
 	if err != nil {
 		http.Error(w, `{"error":"failed to fetch today income"}`, http.StatusInternalServerError)
 		return
 	}
 
 	// Today's expense
-	err = h.db.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM expense WHERE user_id = ? AND expense_date = ?`, userID, today).Scan(&summary.TodayExpense)
+	err = h.db.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM expense WHERE user_id = ? AND household_id IS NULL AND expense_date = ? AND deleted_at IS NULL`, userID, today).Scan(&summary.TodayExpense)
 	if err != nil {
 		http.Error(w, `{"error":"failed to fetch today expense"}`, http.StatusInternalServerError)
 		return
 	}
 
 	// Monthly income
-	err = h.db.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM income WHERE user_id = ? AND strftime('%Y-%m', income_date) = ?`, userID, currentMonth).Scan(&summary.MonthlyIncome)
+	err = h.db.QueryRow(`SELECT COALESCE(SUM(total), 0) FROM monthly_rollup WHERE user_id = ? AND type = 'income' AND month = ?`, userID, currentMonth).Scan(&summary.MonthlyIncome)
 	if err != nil {
 		http.Error(w, `{"error":"failed to fetch monthly income"}`, http.StatusInternalServerError)
 		return
 	}
 
 	// Monthly expense
-	err = h.db.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM expense WHERE user_id = ? AND strftime('%Y-%m', expense_date) = ?`, userID, currentMonth).Scan(&summary.MonthlyExpense)
+	err = h.db.QueryRow(`SELECT COALESCE(SUM(total), 0) FROM monthly_rollup WHERE user_id = ? AND type = 'expense' AND month = ?`, userID, currentMonth).Scan(&summary.MonthlyExpense)
 	if err != nil {
 		http.Error(w, `{"error":"failed to fetch monthly expense"}`, http.StatusInternalServerError)
 		return
@@ -111,8 +131,59 @@ func (h *DashboardHandler) GetDashboard(w http.ResponseWriter, r *http.Request)
 	}
 	summary.CategoryBreakdown.ExpenseByCategory = expenseByCategory
 
+	// Savings goal progress; cached entries expire daily, so statuses stay current
+	goalList, err := h.goalRepo.GetByUser(userID)
+	if err == nil {
+		err = attachGoalProgress(h.goalRepo, goalList, time.Now())
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch savings goals"}`, http.StatusInternalServerError)
+		return
+	}
+	summary.Goals = goalList
+
+	body, err := json.Marshal(summary)
+	if err != nil {
+		http.Error(w, `{"error":"failed to encode dashboard"}`, http.StatusInternalServerError)
+		return
+	}
+	sum := sha256.Sum256(body)
+	entry := &cache.Entry{
+		Body: append(body, '\n'),
+		ETag: `"` + hex.EncodeToString(sum[:16]) + `"`,
+		Day:  today,
+	}
+	h.cache.Set(userID, version, entry)
+
+	writeSummary(w, r, entry)
+}
+
+// writeSummary writes a cached summary, honouring If-None-Match
+func writeSummary(w http.ResponseWriter, r *http.Request, entry *cache.Entry) {
+	w.Header().Set("ETag", entry.ETag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	if etagMatches(r.Header.Get("If-None-Match"), entry.ETag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(summary)
+	w.Write(entry.Body)
+}
+
+// etagMatches reports whether an If-None-Match or If-Match header matches the given ETag
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
 }
 
 // getDailyData retrieves daily income and expense for the last N days
@@ -130,8 +201,8 @@ func (h *DashboardHandler) getDailyData(userID int64, days int) ([]models.DailyD
 			COALESCE(SUM(i.amount), 0) as income,
 			COALESCE(SUM(e.amount), 0) as expense
 		FROM dates d
-		LEFT JOIN income i ON i.user_id = ? AND i.income_date = d.date
-		LEFT JOIN expense e ON e.user_id = ? AND e.expense_date = d.date
+		LEFT JOIN income i ON i.user_id = ? AND i.household_id IS NULL AND i.income_date = d.date AND i.deleted_at IS NULL
+		LEFT JOIN expense e ON e.user_id = ? AND e.household_id IS NULL AND e.expense_date = d.date AND e.deleted_at IS NULL
 		GROUP BY d.date
 		ORDER BY d.date
 	`
@@ -156,30 +227,196 @@ func (h *DashboardHandler) getDailyData(userID int64, days int) ([]models.DailyD
 
 // getCategoryBreakdown retrieves spending/income breakdown by category
 func (h *DashboardHandler) getCategoryBreakdown(userID int64, categoryType string) (map[string]float64, error) {
-	var query string
-	if categoryType == "income" {
-		query = `
-			SELECT c.name, COALESCE(SUM(i.amount), 0) as total
-			FROM categories c
-			LEFT JOIN income i ON i.category_id = c.id AND i.user_id = ?
-			WHERE c.type = 'income'
-			GROUP BY c.id, c.name
-			HAVING total > 0
-			ORDER BY total DESC
-		`
-	} else {
-		query = `
-			SELECT c.name, COALESCE(SUM(e.amount), 0) as total
-			FROM categories c
-			LEFT JOIN expense e ON e.category_id = c.id AND e.user_id = ?
-			WHERE c.type = 'expense'
-			GROUP BY c.id, c.name
-			HAVING total > 0
-			ORDER BY total DESC
-		`
-	}
-
-	rows, err := h.db.Query(query, userID)
+	query := `
+		SELECT c.name, SUM(r.total) as total
+		FROM monthly_rollup r
+		JOIN categories c ON r.category_id = c.id
+		WHERE r.user_id = ? AND r.type = ?
+		GROUP BY c.id, c.name
+		HAVING total > 0
+		ORDER BY total DESC
+	`
+
+	rows, err := h.db.Query(query, userID, categoryType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	breakdown := make(map[string]float64)
+	for rows.Next() {
+		var name string
+		var total float64
+		if err := rows.Scan(&name, &total); err != nil {
+			return nil, err
+		}
+		breakdown[name] = total
+	}
+
+	return breakdown, nil
+}
+
+// getHouseholdDashboard writes the summary of a household's shared records,
+// attributing income and expense to the members who recorded them
+func (h *DashboardHandler) getHouseholdDashboard(w http.ResponseWriter, householdID int64, today, currentMonth string) {
+	summary := models.DashboardSummary{
+		CategoryBreakdown: models.CategoryBreakdown{
+			IncomeByCategory:  make(map[string]float64),
+			ExpenseByCategory: make(map[string]float64),
+		},
+	}
+
+	members, err := h.getMemberSummaries(householdID, currentMonth)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch member totals"}`, http.StatusInternalServerError)
+		return
+	}
+	summary.Members = members
+
+	for _, member := range members {
+		summary.TotalIncome += member.TotalIncome
+		summary.TotalExpense += member.TotalExpense
+		summary.MonthlyIncome += member.MonthlyIncome
+		summary.MonthlyExpense += member.MonthlyExpense
+	}
+	summary.Balance = summary.TotalIncome - summary.TotalExpense
+
+	// Today's income
+	err = h.db.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM income WHERE household_id = ? AND income_date = ? AND deleted_at IS NULL`, householdID, today).Scan(&summary.TodayIncome)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch today income"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// Today's expense
+	err = h.db.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM expense WHERE household_id = ? AND expense_date = ? AND deleted_at IS NULL`, householdID, today).Scan(&summary.TodayExpense)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch today expense"}`, http.StatusInternalServerError)
+		return
+	}
+
+	dailyData, err := h.getHouseholdDailyData(householdID, 30)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch daily data"}`, http.StatusInternalServerError)
+		return
+	}
+	summary.DailyData = dailyData
+
+	incomeByCategory, err := h.getHouseholdBreakdown(householdID, `
+		SELECT c.name, SUM(i.amount) AS total
+		FROM income i
+		JOIN categories c ON i.category_id = c.id
+		WHERE i.household_id = ? AND i.deleted_at IS NULL
+		GROUP BY c.id, c.name
+		HAVING total > 0`)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch income breakdown"}`, http.StatusInternalServerError)
+		return
+	}
+	summary.CategoryBreakdown.IncomeByCategory = incomeByCategory
+
+	expenseByCategory, err := h.getHouseholdBreakdown(householdID, `
+		SELECT c.name, SUM(l.amount) AS total
+		FROM expense_lines l
+		JOIN categories c ON l.category_id = c.id
+		WHERE l.household_id = ? AND l.deleted_at IS NULL
+		GROUP BY c.id, c.name
+		HAVING total > 0`)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch expense breakdown"}`, http.StatusInternalServerError)
+		return
+	}
+	summary.CategoryBreakdown.ExpenseByCategory = expenseByCategory
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// getMemberSummaries totals a household's records per member. People who
+// have left the household still appear while records they added remain.
+func (h *DashboardHandler) getMemberSummaries(householdID int64, currentMonth string) ([]models.MemberSummary, error) {
+	query := `
+		SELECT u.id, u.username,
+			COALESCE((SELECT SUM(amount) FROM income
+				WHERE household_id = ? AND user_id = u.id AND deleted_at IS NULL), 0),
+			COALESCE((SELECT SUM(amount) FROM expense
+				WHERE household_id = ? AND user_id = u.id AND deleted_at IS NULL), 0),
+			COALESCE((SELECT SUM(amount) FROM income
+				WHERE household_id = ? AND user_id = u.id AND deleted_at IS NULL AND substr(income_date, 1, 7) = ?), 0),
+			COALESCE((SELECT SUM(amount) FROM expense
+				WHERE household_id = ? AND user_id = u.id AND deleted_at IS NULL AND substr(expense_date, 1, 7) = ?), 0)
+		FROM users u
+		WHERE u.id IN (
+			SELECT user_id FROM household_members WHERE household_id = ?
+			UNION SELECT user_id FROM income WHERE household_id = ? AND deleted_at IS NULL
+			UNION SELECT user_id FROM expense WHERE household_id = ? AND deleted_at IS NULL
+		)
+		ORDER BY u.username
+	`
+
+	rows, err := h.db.Query(query,
+		householdID, householdID, householdID, currentMonth, householdID, currentMonth,
+		householdID, householdID, householdID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []models.MemberSummary
+	for rows.Next() {
+		var member models.MemberSummary
+		if err := rows.Scan(&member.UserID, &member.Username, &member.TotalIncome, &member.TotalExpense, &member.MonthlyIncome, &member.MonthlyExpense); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// getHouseholdDailyData retrieves a household's daily income and expense for the last N days
+func (h *DashboardHandler) getHouseholdDailyData(householdID int64, days int) ([]models.DailyData, error) {
+	query := `
+		WITH RECURSIVE dates(date) AS (
+			SELECT date('now', '-' || ? || ' days')
+			UNION ALL
+			SELECT date(date, '+1 day')
+			FROM dates
+			WHERE date < date('now')
+		)
+		SELECT
+			d.date,
+			COALESCE((SELECT SUM(amount) FROM income
+				WHERE household_id = ? AND income_date = d.date AND deleted_at IS NULL), 0),
+			COALESCE((SELECT SUM(amount) FROM expense
+				WHERE household_id = ? AND expense_date = d.date AND deleted_at IS NULL), 0)
+		FROM dates d
+		ORDER BY d.date
+	`
+
+	rows, err := h.db.Query(query, days-1, householdID, householdID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dailyData []models.DailyData
+	for rows.Next() {
+		var data models.DailyData
+		if err := rows.Scan(&data.Date, &data.Income, &data.Expense); err != nil {
+			return nil, err
+		}
+		dailyData = append(dailyData, data)
+	}
+
+	return dailyData, nil
+}
+
+// getHouseholdBreakdown runs a per-category total query for a household
+func (h *DashboardHandler) getHouseholdBreakdown(householdID int64, query string) (map[string]float64, error) {
+	rows, err := h.db.Query(query, householdID)
 	if err != nil {
 		return nil, err
 	}
@@ -2,28 +2,79 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
+	"myexpress-tracker/internal/classifier"
+	"myexpress-tracker/internal/duplicates"
 	"myexpress-tracker/internal/middleware"
 	"myexpress-tracker/internal/models"
 	"myexpress-tracker/internal/repository"
+	"myexpress-tracker/internal/rules"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
+const (
+	// maxSuggestions is the number of ranked categories returned
+	maxSuggestions = 3
+	// autoCategoryConfidence is the confidence needed to assign a suggested category
+	autoCategoryConfidence = 0.6
+	// maxSplits is the most line items a single expense can be split into
+	maxSplits = 50
+	// maxParticipants is the most people a bill can be shared with
+	maxParticipants = 50
+)
+
+// expenseInputErrors are repository errors caused by the request body. Their
+// messages are safe to return to the client.
+var expenseInputErrors = map[error]bool{
+	repository.ErrSplitTotal:           true,
+	repository.ErrShareTotal:           true,
+	repository.ErrParticipantNotFound:  true,
+	repository.ErrDuplicateParticipant: true,
+	repository.ErrPayerNotParticipant:  true,
+	repository.ErrSharedAmount:         true,
+	repository.ErrSharedHousehold:      true,
+}
+
 // ExpenseHandler handles expense requests
 type ExpenseHandler struct {
 	expenseRepo  *repository.ExpenseRepository
 	categoryRepo *repository.CategoryRepository
+	ruleEngine   *rules.Engine
+	classifier   *classifier.Service
+	duplicates   *duplicates.Detector
 }
 
 // NewExpenseHandler creates a new expense handler
-func NewExpenseHandler(expenseRepo *repository.ExpenseRepository, categoryRepo *repository.CategoryRepository) *ExpenseHandler {
+func NewExpenseHandler(expenseRepo *repository.ExpenseRepository, categoryRepo *repository.CategoryRepository, ruleEngine *rules.Engine, classifierService *classifier.Service, duplicateDetector *duplicates.Detector) *ExpenseHandler {
 	return &ExpenseHandler{
 		expenseRepo:  expenseRepo,
 		categoryRepo: categoryRepo,
+		ruleEngine:   ruleEngine,
+		classifier:   classifierService,
+		duplicates:   duplicateDetector,
 	}
 }
 
+// CreateExpenseResponse is the created expense plus how its category was
+// chosen and any existing expenses it may duplicate
+type CreateExpenseResponse struct {
+	models.Expense
+	CategorySuggested  bool                        `json:"category_suggested,omitempty"`
+	Suggestions        []models.CategorySuggestion `json:"suggestions,omitempty"`
+	Warning            string                      `json:"warning,omitempty"`
+	PossibleDuplicates []models.Expense            `json:"possible_duplicates,omitempty"`
+}
+
+// MergeExpensesRequest represents a duplicate merge request
+type MergeExpensesRequest struct {
+	KeepID       int64   `json:"keep_id"`
+	DuplicateIDs []int64 `json:"duplicate_ids"`
+}
+
 // CreateExpense creates a new expense record
 func (h *ExpenseHandler) CreateExpense(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -43,29 +94,249 @@ func (h *ExpenseHandler) CreateExpense(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ledger := ledgerFromRequest(r, userID)
+	if !h.validateSplits(w, &expense, ledger) || !validateShared(w, &expense, ledger.HouseholdID) {
+		return
+	}
+
+	// Apply the user's categorization rules (fills category only when missing)
+	if _, err := h.ruleEngine.Apply(userID, &expense); err != nil {
+		http.Error(w, `{"error":"failed to apply rules"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// Fall back to a learned suggestion when neither the client nor a rule chose a category
+	response := CreateExpenseResponse{}
+	if expense.CategoryID == 0 && expense.Description != "" {
+		suggestions, err := h.suggest(userID, expense.Description, expense.Amount)
+		if err != nil {
+			http.Error(w, `{"error":"failed to suggest category"}`, http.StatusInternalServerError)
+			return
+		}
+		response.Suggestions = suggestions
+
+		if len(suggestions) > 0 && suggestions[0].Confidence >= autoCategoryConfidence {
+			expense.CategoryID = suggestions[0].CategoryID
+			response.CategorySuggested = true
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":       "category_id is required; no confident suggestion",
+				"suggestions": suggestions,
+			})
+			return
+		}
+	}
+
 	// Validate input
-	if expense.CategoryID == 0 || expense.Amount <= 0 || expense.ExpenseDate == "" {
-		http.Error(w, `{"error":"category_id, amount (>0), and expense_date are required"}`, http.StatusBadRequest)
+	if expense.CategoryID == 0 || (expense.Amount <= 0 && !isShared(&expense)) || expense.ExpenseDate == "" {
+		http.Error(w, `{"error":"category_id (or a matching rule), amount (>0), and expense_date are required"}`, http.StatusBadRequest)
 		return
 	}
 
 	// Verify category exists and is expense type
-	category, err := h.categoryRepo.GetByID(expense.CategoryID)
+	category, err := h.categoryRepo.GetByID(expense.CategoryID, ledger)
 	if err != nil || category == nil || category.Type != "expense" {
 		http.Error(w, `{"error":"invalid expense category"}`, http.StatusBadRequest)
 		return
 	}
 
 	expense.UserID = userID
+	expense.HouseholdID = ledger.HouseholdRef()
 
-	if err := h.expenseRepo.Create(&expense); err != nil {
+	err = h.expenseRepo.Create(r.Context(), &expense)
+	if err == repository.ErrForbidden {
+		http.Error(w, `{"error":"your household role cannot add records"}`, http.StatusForbidden)
+		return
+	}
+	if expenseInputErrors[err] {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
 		http.Error(w, `{"error":"failed to create expense"}`, http.StatusInternalServerError)
 		return
 	}
 
+	response.Expense = expense
+
+	// Warn about likely double entries among personal expenses; the expense is still created
+	if expense.HouseholdID == nil {
+		candidates, err := h.duplicates.Candidates(userID, &expense)
+		if err == nil && len(candidates) > 0 {
+			response.Warning = "possible duplicate expense"
+			response.PossibleDuplicates = candidates
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(expense)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetDuplicates lists groups of suspected duplicate expenses across the user's history
+func (h *ExpenseHandler) GetDuplicates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	expenses, err := h.expenseRepo.GetByUser(repository.Personal(userID), map[string]interface{}{})
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch expenses"}`, http.StatusInternalServerError)
+		return
+	}
+
+	groups := duplicates.Groups(expenses)
+	if groups == nil {
+		groups = [][]models.Expense{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"groups": groups})
+}
+
+// MergeExpenses keeps one expense and deletes its duplicates, merging their tags
+func (h *ExpenseHandler) MergeExpenses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req MergeExpensesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if req.KeepID == 0 || len(req.DuplicateIDs) == 0 {
+		http.Error(w, `{"error":"keep_id and duplicate_ids are required"}`, http.StatusBadRequest)
+		return
+	}
+
+	keep, err := h.expenseRepo.GetByID(req.KeepID, repository.Personal(userID))
+	if err != nil || keep == nil {
+		http.Error(w, `{"error":"expense not found"}`, http.StatusNotFound)
+		return
+	}
+
+	seen := map[int64]bool{req.KeepID: true}
+	for _, id := range req.DuplicateIDs {
+		if seen[id] {
+			http.Error(w, `{"error":"duplicate_ids must be distinct and exclude keep_id"}`, http.StatusBadRequest)
+			return
+		}
+		seen[id] = true
+
+		duplicate, err := h.expenseRepo.GetByID(id, repository.Personal(userID))
+		if err != nil || duplicate == nil {
+			http.Error(w, `{"error":"expense not found"}`, http.StatusNotFound)
+			return
+		}
+
+		keep.Tags = rules.MergeTags(keep.Tags, duplicate.Tags)
+		if keep.Description == "" {
+			keep.Description = duplicate.Description
+		}
+		if keep.Account == "" {
+			keep.Account = duplicate.Account
+		}
+	}
+
+	if err := h.expenseRepo.Merge(r.Context(), keep, req.DuplicateIDs); err != nil {
+		http.Error(w, `{"error":"failed to merge expenses"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keep)
+}
+
+// SuggestCategory ranks expense categories for a description and amount
+func (h *ExpenseHandler) SuggestCategory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	description := r.URL.Query().Get("description")
+	if description == "" {
+		http.Error(w, `{"error":"description is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	var amount float64
+	if value := r.URL.Query().Get("amount"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 {
+			http.Error(w, `{"error":"invalid amount"}`, http.StatusBadRequest)
+			return
+		}
+		amount = parsed
+	}
+
+	suggestions, err := h.suggest(userID, description, amount)
+	if err != nil {
+		http.Error(w, `{"error":"failed to suggest category"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(suggestions)
+}
+
+// suggest returns the top ranked expense categories with their names
+func (h *ExpenseHandler) suggest(userID int64, description string, amount float64) ([]models.CategorySuggestion, error) {
+	predictions, err := h.classifier.Suggest(userID, description, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	categories, err := h.categoryRepo.GetByType("expense", repository.Personal(userID))
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int64]string, len(categories))
+	for _, category := range categories {
+		names[category.ID] = category.Name
+	}
+
+	suggestions := []models.CategorySuggestion{}
+	for _, prediction := range predictions {
+		name, ok := names[prediction.CategoryID]
+		if !ok {
+			continue
+		}
+		suggestions = append(suggestions, models.CategorySuggestion{
+			CategoryID:   prediction.CategoryID,
+			CategoryName: name,
+			Confidence:   math.Round(prediction.Confidence*1000) / 1000,
+		})
+		if len(suggestions) == maxSuggestions {
+			break
+		}
+	}
+
+	return suggestions, nil
 }
 
 // GetExpenses retrieves expense records with optional filters
@@ -102,7 +373,7 @@ func (h *ExpenseHandler) GetExpenses(w http.ResponseWriter, r *http.Request) {
 		filters["end_date"] = endDate
 	}
 
-	expenses, err := h.expenseRepo.GetByUser(userID, filters)
+	expenses, err := h.expenseRepo.GetByUser(ledgerFromRequest(r, userID), filters)
 	if err != nil {
 		http.Error(w, `{"error":"failed to fetch expenses"}`, http.StatusInternalServerError)
 		return
@@ -112,9 +383,9 @@ func (h *ExpenseHandler) GetExpenses(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(expenses)
 }
 
-// UpdateExpense updates an existing expense record
-func (h *ExpenseHandler) UpdateExpense(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPut {
+// GetExpense retrieves a single expense record along with its ETag
+func (h *ExpenseHandler) GetExpense(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
 		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
 		return
 	}
@@ -125,50 +396,310 @@ func (h *ExpenseHandler) UpdateExpense(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get expense ID from URL path
-	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-	if len(pathParts) < 3 {
-		http.Error(w, `{"error":"expense id required"}`, http.StatusBadRequest)
+	expenseID, ok := expenseIDFromPath(w, r)
+	if !ok {
 		return
 	}
 
-	expenseID, err := strconv.ParseInt(pathParts[len(pathParts)-1], 10, 64)
-	if err != nil {
-		http.Error(w, `{"error":"invalid expense id"}`, http.StatusBadRequest)
+	h.writeExpense(w, expenseID, ledgerFromRequest(r, userID), http.StatusOK)
+}
+
+// UpdateExpense updates an existing expense record. When an If-Match header
+// is sent the update only succeeds if the record is unchanged.
+func (h *ExpenseHandler) UpdateExpense(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
 		return
 	}
 
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	expenseID, ok := expenseIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	ledger := ledgerFromRequest(r, userID)
+
 	var expense models.Expense
 	if err := json.NewDecoder(r.Body).Decode(&expense); err != nil {
 		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
 		return
 	}
 
-	// Validate input
-	if expense.CategoryID == 0 || expense.Amount <= 0 || expense.ExpenseDate == "" {
-		http.Error(w, `{"error":"category_id, amount (>0), and expense_date are required"}`, http.StatusBadRequest)
+	if !h.validateExpense(w, &expense, ledger) {
 		return
 	}
 
-	// Verify category exists and is expense type
-	category, err := h.categoryRepo.GetByID(expense.CategoryID)
-	if err != nil || category == nil || category.Type != "expense" {
-		http.Error(w, `{"error":"invalid expense category"}`, http.StatusBadRequest)
+	expense.ID = expenseID
+	expense.UserID = userID
+	expense.HouseholdID = ledger.HouseholdRef()
+
+	var err error
+	if r.Header.Get("If-Match") == "" {
+		err = h.expenseRepo.Update(r.Context(), &expense)
+	} else {
+		current, getErr := h.expenseRepo.GetByID(expenseID, ledger)
+		if getErr != nil || current == nil {
+			http.Error(w, `{"error":"expense not found"}`, http.StatusNotFound)
+			return
+		}
+		if !ifMatch(w, r, current.UpdatedAt) {
+			return
+		}
+		err = h.expenseRepo.UpdateIfUnmodified(r.Context(), &expense, current.UpdatedAt)
+	}
+	if err == repository.ErrModified {
+		http.Error(w, `{"error":"expense was modified by another request"}`, http.StatusPreconditionFailed)
+		return
+	}
+	if expenseInputErrors[err] {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to update expense"}`, http.StatusInternalServerError)
+		return
+	}
+
+	h.writeExpense(w, expenseID, ledger, http.StatusOK)
+}
+
+// PatchExpense partially updates an expense record from a JSON Merge Patch.
+// The merged record is validated as a whole, and the write only succeeds if
+// the record has not changed since it was read (or since the If-Match ETag).
+func (h *ExpenseHandler) PatchExpense(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	expenseID, ok := expenseIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	ledger := ledgerFromRequest(r, userID)
+
+	patch, err := readMergePatch(r)
+	if err != nil {
+		http.Error(w, `{"error":"merge patch must be a JSON object"}`, http.StatusBadRequest)
+		return
+	}
+
+	current, err := h.expenseRepo.GetByID(expenseID, ledger)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch expense"}`, http.StatusInternalServerError)
+		return
+	}
+	if current == nil {
+		http.Error(w, `{"error":"expense not found"}`, http.StatusNotFound)
+		return
+	}
+
+	if !ifMatch(w, r, current.UpdatedAt) {
+		return
+	}
+
+	var expense models.Expense
+	if err := applyMergePatch(current, patch, &expense); err != nil {
+		http.Error(w, `{"error":"patch contains invalid field values"}`, http.StatusBadRequest)
+		return
+	}
+
+	// "splits": null removes the splits rather than keeping the current ones
+	if value, ok := patch["splits"]; ok && value == nil {
+		expense.Splits = []models.ExpenseSplit{}
+	}
+
+	// "shared": null stops sharing; a new amount alone must match the current share
+	value, sharedPatched := patch["shared"]
+	if sharedPatched && value == nil {
+		expense.Shared = &models.SharedBill{}
+	} else if _, ok := patch["amount"]; ok && !sharedPatched {
+		expense.Shared = nil
+	}
+
+	if !h.validateExpense(w, &expense, ledger) {
 		return
 	}
 
 	expense.ID = expenseID
 	expense.UserID = userID
+	expense.HouseholdID = ledger.HouseholdRef()
 
-	if err := h.expenseRepo.Update(&expense); err != nil {
+	err = h.expenseRepo.UpdateIfUnmodified(r.Context(), &expense, current.UpdatedAt)
+	if err == repository.ErrModified {
+		http.Error(w, `{"error":"expense was modified by another request"}`, http.StatusPreconditionFailed)
+		return
+	}
+	if expenseInputErrors[err] {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
 		http.Error(w, `{"error":"failed to update expense"}`, http.StatusInternalServerError)
 		return
 	}
 
+	h.writeExpense(w, expenseID, ledger, http.StatusOK)
+}
+
+// validateExpense checks the fields of a full expense record in a ledger, writing an error response on failure
+func (h *ExpenseHandler) validateExpense(w http.ResponseWriter, expense *models.Expense, ledger repository.Ledger) bool {
+	if !h.validateSplits(w, expense, ledger) || !validateShared(w, expense, ledger.HouseholdID) {
+		return false
+	}
+
+	if expense.CategoryID == 0 || (expense.Amount <= 0 && !isShared(expense)) || expense.ExpenseDate == "" {
+		http.Error(w, `{"error":"category_id, amount (>0), and expense_date are required"}`, http.StatusBadRequest)
+		return false
+	}
+
+	if _, err := time.Parse("2006-01-02", expense.ExpenseDate); err != nil {
+		http.Error(w, `{"error":"expense_date must be YYYY-MM-DD"}`, http.StatusBadRequest)
+		return false
+	}
+
+	// Verify category exists, is usable in the ledger and is expense type
+	category, err := h.categoryRepo.GetByID(expense.CategoryID, ledger)
+	if err != nil || category == nil || category.Type != "expense" {
+		http.Error(w, `{"error":"invalid expense category"}`, http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+// validateSplits checks the line items of a split expense, writing an error
+// response on failure. A split expense without a category_id takes one from
+// its splits; the repository then points it at the largest split.
+func (h *ExpenseHandler) validateSplits(w http.ResponseWriter, expense *models.Expense, ledger repository.Ledger) bool {
+	if len(expense.Splits) == 0 {
+		return true
+	}
+
+	if len(expense.Splits) > maxSplits {
+		http.Error(w, fmt.Sprintf(`{"error":"an expense can have at most %d splits"}`, maxSplits), http.StatusBadRequest)
+		return false
+	}
+
+	for _, split := range expense.Splits {
+		if split.Amount <= 0 {
+			http.Error(w, `{"error":"every split needs an amount (>0)"}`, http.StatusBadRequest)
+			return false
+		}
+
+		category, err := h.categoryRepo.GetByID(split.CategoryID, ledger)
+		if err != nil || category == nil || category.Type != "expense" {
+			http.Error(w, `{"error":"invalid expense category in splits"}`, http.StatusBadRequest)
+			return false
+		}
+	}
+
+	if expense.CategoryID == 0 {
+		expense.CategoryID = expense.Splits[0].CategoryID
+	}
+	return true
+}
+
+// validateShared checks how a shared expense divides its bill, writing an error
+// response on failure. The repository works out everyone's share and sets the
+// expense amount to the user's own share.
+func validateShared(w http.ResponseWriter, expense *models.Expense, householdID int64) bool {
+	if !isShared(expense) {
+		return true
+	}
+
+	bill := expense.Shared
+	if householdID != 0 {
+		http.Error(w, `{"error":"household expenses cannot be shared"}`, http.StatusBadRequest)
+		return false
+	}
+
+	if len(bill.Participants) > maxParticipants {
+		http.Error(w, fmt.Sprintf(`{"error":"a bill can be shared with at most %d people"}`, maxParticipants), http.StatusBadRequest)
+		return false
+	}
+
+	if bill.Total <= 0 {
+		http.Error(w, `{"error":"shared.total (>0) is required"}`, http.StatusBadRequest)
+		return false
+	}
+
+	if bill.Method == "" {
+		bill.Method = models.ShareEqual
+	}
+	for _, participant := range bill.Participants {
+		switch bill.Method {
+		case models.ShareEqual:
+		case models.SharePercentage:
+			if participant.Percent == nil || *participant.Percent <= 0 || *participant.Percent >= 100 {
+				http.Error(w, `{"error":"every participant needs a percent between 0 and 100"}`, http.StatusBadRequest)
+				return false
+			}
+		case models.ShareExact:
+			if participant.Amount <= 0 {
+				http.Error(w, `{"error":"every participant needs an amount (>0)"}`, http.StatusBadRequest)
+				return false
+			}
+		default:
+			http.Error(w, `{"error":"shared.method must be equal, percentage or exact"}`, http.StatusBadRequest)
+			return false
+		}
+	}
+
+	return true
+}
+
+// isShared reports whether an expense shares its bill with anyone
+func isShared(expense *models.Expense) bool {
+	return expense.Shared != nil && len(expense.Shared.Participants) > 0
+}
+
+// writeExpense responds with the stored expense record and its ETag
+func (h *ExpenseHandler) writeExpense(w http.ResponseWriter, expenseID int64, ledger repository.Ledger, status int) {
+	expense, err := h.expenseRepo.GetByID(expenseID, ledger)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch expense"}`, http.StatusInternalServerError)
+		return
+	}
+	if expense == nil {
+		http.Error(w, `{"error":"expense not found"}`, http.StatusNotFound)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", recordETag(expense.UpdatedAt))
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(expense)
 }
 
+// expenseIDFromPath parses the expense ID from /api/expense/{id}
+func expenseIDFromPath(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 3 {
+		http.Error(w, `{"error":"expense id required"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	expenseID, err := strconv.ParseInt(pathParts[2], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid expense id"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	return expenseID, true
+}
+
 // DeleteExpense deletes an expense record
 func (h *ExpenseHandler) DeleteExpense(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
@@ -195,12 +726,12 @@ func (h *ExpenseHandler) DeleteExpense(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.expenseRepo.Delete(expenseID, userID); err != nil {
+	if err := h.expenseRepo.Delete(r.Context(), expenseID, ledgerFromRequest(r, userID)); err != nil {
 		http.Error(w, `{"error":"failed to delete expense"}`, http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "expense deleted successfully"})
+	json.NewEncoder(w).Encode(map[string]string{"message": "expense moved to trash"})
 }
@@ -3,8 +3,10 @@ package handlers
 import (
 	"database/sql"
 	"fmt"
+	"myexpress-tracker/internal/importer"
 	"myexpress-tracker/internal/middleware"
 	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/repository"
 	"net/http"
 	"time"
 
@@ -147,13 +149,94 @@ func (h *ExportHandler) ExportToPDF(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// ExportToQIF exports income and expense records as a QIF file, with the
+// categories they use and the line items of split expenses
+func (h *ExportHandler) ExportToQIF(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	startDate := r.URL.Query().Get("start_date")
+	endDate := r.URL.Query().Get("end_date")
+
+	if startDate == "" {
+		startDate = time.Now().AddDate(0, -1, 0).Format("2006-01-02") // Last month
+	}
+	if endDate == "" {
+		endDate = time.Now().Format("2006-01-02") // Today
+	}
+
+	incomes, err := h.getIncomesForExport(userID, startDate, endDate)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch income data"}`, http.StatusInternalServerError)
+		return
+	}
+
+	expenses, err := h.getExpenseTransactionsForQIF(userID, startDate, endDate)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch expense data"}`, http.StatusInternalServerError)
+		return
+	}
+
+	incomeCategories := make(map[string]bool)
+	transactions := make([]importer.Transaction, 0, len(incomes)+len(expenses))
+	for _, income := range incomes {
+		incomeCategories[income.CategoryName] = true
+		transactions = append(transactions, importer.Transaction{
+			Date:        income.IncomeDate,
+			Amount:      income.Amount,
+			Description: income.Description,
+			Memo:        income.Tags,
+			Account:     income.Account,
+			Category:    income.CategoryName,
+		})
+	}
+
+	expenseCategories := make(map[string]bool)
+	for _, expense := range expenses {
+		expenseCategories[expense.Category] = true
+		for _, split := range expense.Splits {
+			expenseCategories[split.Category] = true
+		}
+		if len(expense.Splits) > 0 {
+			expense.Category = ""
+		}
+		transactions = append(transactions, expense)
+	}
+
+	var categories []importer.QIFCategory
+	for name := range incomeCategories {
+		categories = append(categories, importer.QIFCategory{Name: name, Income: true})
+	}
+	for name := range expenseCategories {
+		if name != "" && !incomeCategories[name] {
+			categories = append(categories, importer.QIFCategory{Name: name})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/qif")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=transactions_%s_to_%s.qif", startDate, endDate))
+
+	if err := importer.WriteQIF(w, categories, transactions); err != nil {
+		http.Error(w, `{"error":"failed to generate QIF"}`, http.StatusInternalServerError)
+		return
+	}
+}
+
 // getIncomesForExport retrieves income data for export
 func (h *ExportHandler) getIncomesForExport(userID int64, startDate, endDate string) ([]models.Income, error) {
 	query := `
-		SELECT i.id, i.user_id, i.category_id, i.amount, i.description, i.income_date, i.created_at, i.updated_at, c.name
+		SELECT i.id, i.user_id, i.category_id, i.amount, i.description, i.income_date, i.tags, i.account, i.created_at, i.updated_at, c.name
 		FROM income i
 		JOIN categories c ON i.category_id = c.id
-		WHERE i.user_id = ? AND i.income_date >= ? AND i.income_date <= ?
+		WHERE i.user_id = ? AND i.household_id IS NULL AND i.income_date >= ? AND i.income_date <= ? AND i.deleted_at IS NULL
 		ORDER BY i.income_date DESC
 	`
 
@@ -168,24 +251,27 @@ func (h *ExportHandler) getIncomesForExport(userID int64, startDate, endDate str
 		var income models.Income
 		if err := rows.Scan(
 			&income.ID, &income.UserID, &income.CategoryID, &income.Amount, &income.Description,
-			&income.IncomeDate, &income.CreatedAt, &income.UpdatedAt, &income.CategoryName,
+			&income.IncomeDate, &income.Tags, &income.Account, &income.CreatedAt, &income.UpdatedAt, &income.CategoryName,
 		); err != nil {
 			return nil, err
 		}
+		income.IncomeDate = repository.DateOnly(income.IncomeDate)
 		incomes = append(incomes, income)
 	}
 
 	return incomes, nil
 }
 
-// getExpensesForExport retrieves expense data for export
+// getExpensesForExport retrieves expense data for export. Split expenses
+// produce one row per split so each amount is listed under its own category.
 func (h *ExportHandler) getExpensesForExport(userID int64, startDate, endDate string) ([]models.Expense, error) {
 	query := `
-		SELECT e.id, e.user_id, e.category_id, e.amount, e.description, e.expense_date, e.created_at, e.updated_at, c.name
-		FROM expense e
-		JOIN categories c ON e.category_id = c.id
-		WHERE e.user_id = ? AND e.expense_date >= ? AND e.expense_date <= ?
-		ORDER BY e.expense_date DESC
+		SELECT e.id, e.user_id, l.category_id, l.amount, e.description, l.note, e.expense_date, e.tags, e.account, e.created_at, e.updated_at, c.name
+		FROM expense_lines l
+		JOIN expense e ON l.expense_id = e.id
+		JOIN categories c ON l.category_id = c.id
+		WHERE e.user_id = ? AND e.household_id IS NULL AND e.expense_date >= ? AND e.expense_date <= ? AND e.deleted_at IS NULL
+		ORDER BY e.expense_date DESC, e.id
 	`
 
 	rows, err := h.db.Query(query, userID, startDate, endDate)
@@ -197,14 +283,68 @@ func (h *ExportHandler) getExpensesForExport(userID int64, startDate, endDate st
 	var expenses []models.Expense
 	for rows.Next() {
 		var expense models.Expense
+		var note string
 		if err := rows.Scan(
-			&expense.ID, &expense.UserID, &expense.CategoryID, &expense.Amount, &expense.Description,
-			&expense.ExpenseDate, &expense.CreatedAt, &expense.UpdatedAt, &expense.CategoryName,
+			&expense.ID, &expense.UserID, &expense.CategoryID, &expense.Amount, &expense.Description, &note,
+			&expense.ExpenseDate, &expense.Tags, &expense.Account, &expense.CreatedAt, &expense.UpdatedAt, &expense.CategoryName,
 		); err != nil {
 			return nil, err
 		}
+		expense.ExpenseDate = repository.DateOnly(expense.ExpenseDate)
+		if note != "" {
+			expense.Description += " - " + note
+		}
 		expenses = append(expenses, expense)
 	}
 
 	return expenses, nil
 }
+
+// getExpenseTransactionsForQIF retrieves expenses for a QIF export as money
+// out, with the line items of split expenses in their stored order
+func (h *ExportHandler) getExpenseTransactionsForQIF(userID int64, startDate, endDate string) ([]importer.Transaction, error) {
+	query := `
+		SELECT e.id, e.amount, e.description, e.expense_date, e.tags, e.account, c.name,
+			COALESCE(sc.name, ''), COALESCE(s.amount, 0), COALESCE(s.note, '')
+		FROM expense e
+		JOIN categories c ON e.category_id = c.id
+		LEFT JOIN expense_splits s ON s.expense_id = e.id
+		LEFT JOIN categories sc ON s.category_id = sc.id
+		WHERE e.user_id = ? AND e.household_id IS NULL AND e.expense_date >= ? AND e.expense_date <= ? AND e.deleted_at IS NULL
+		ORDER BY e.expense_date, e.id, s.position
+	`
+
+	rows, err := h.db.Query(query, userID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []importer.Transaction
+	lastID := int64(0)
+	for rows.Next() {
+		var id int64
+		var transaction importer.Transaction
+		var split importer.Split
+		if err := rows.Scan(
+			&id, &transaction.Amount, &transaction.Description, &transaction.Date, &transaction.Memo, &transaction.Account,
+			&transaction.Category, &split.Category, &split.Amount, &split.Memo,
+		); err != nil {
+			return nil, err
+		}
+
+		if id != lastID {
+			transaction.Amount = -transaction.Amount
+			transaction.Date = repository.DateOnly(transaction.Date)
+			transactions = append(transactions, transaction)
+			lastID = id
+		}
+		if split.Category != "" {
+			split.Amount = -split.Amount
+			last := &transactions[len(transactions)-1]
+			last.Splits = append(last.Splits, split)
+		}
+	}
+
+	return transactions, rows.Err()
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"myexpress-tracker/internal/forecast"
+	"myexpress-tracker/internal/middleware"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultForecastDays = 30
+	minForecastDays     = 30
+	maxForecastDays     = 180
+)
+
+// ForecastHandler handles cash-flow forecast requests
+type ForecastHandler struct {
+	forecastService *forecast.Service
+}
+
+// NewForecastHandler creates a new forecast handler
+func NewForecastHandler(forecastService *forecast.Service) *ForecastHandler {
+	return &ForecastHandler{
+		forecastService: forecastService,
+	}
+}
+
+// GetForecast projects the user's balance day by day
+func (h *ForecastHandler) GetForecast(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	days := defaultForecastDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < minForecastDays || n > maxForecastDays {
+			http.Error(w, `{"error":"days must be between 30 and 180"}`, http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	result, err := h.forecastService.Project(userID, days, time.Now())
+	if err != nil {
+		http.Error(w, `{"error":"failed to build forecast"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"encoding/json"
+	"myexpress-tracker/internal/goals"
+	"myexpress-tracker/internal/middleware"
+	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/repository"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GoalHandler handles savings goal requests
+type GoalHandler struct {
+	goalRepo     *repository.GoalRepository
+	categoryRepo *repository.CategoryRepository
+}
+
+// NewGoalHandler creates a new savings goal handler
+func NewGoalHandler(goalRepo *repository.GoalRepository, categoryRepo *repository.CategoryRepository) *GoalHandler {
+	return &GoalHandler{
+		goalRepo:     goalRepo,
+		categoryRepo: categoryRepo,
+	}
+}
+
+// GetGoals retrieves the user's savings goals with their progress
+func (h *GoalHandler) GetGoals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	goalList, err := h.goalRepo.GetByUser(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch savings goals"}`, http.StatusInternalServerError)
+		return
+	}
+	if goalList == nil {
+		goalList = []models.SavingsGoal{}
+	}
+	if err := attachGoalProgress(h.goalRepo, goalList, time.Now()); err != nil {
+		http.Error(w, `{"error":"failed to calculate goal progress"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(goalList)
+}
+
+// GetGoal retrieves a savings goal with its progress
+func (h *GoalHandler) GetGoal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	goalID, ok := goalIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	h.writeGoal(w, goalID, userID, http.StatusOK)
+}
+
+// CreateGoal creates a new savings goal
+func (h *GoalHandler) CreateGoal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var goal models.SavingsGoal
+	if err := json.NewDecoder(r.Body).Decode(&goal); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if !h.validateGoal(w, &goal, userID) {
+		return
+	}
+
+	goal.UserID = userID
+
+	if err := h.goalRepo.Create(&goal); err != nil {
+		http.Error(w, `{"error":"failed to create savings goal"}`, http.StatusInternalServerError)
+		return
+	}
+
+	h.writeGoal(w, goal.ID, userID, http.StatusCreated)
+}
+
+// UpdateGoal updates a savings goal
+func (h *GoalHandler) UpdateGoal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	goalID, ok := goalIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	current, err := h.goalRepo.GetByID(goalID, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch savings goal"}`, http.StatusInternalServerError)
+		return
+	}
+	if current == nil {
+		http.Error(w, `{"error":"savings goal not found"}`, http.StatusNotFound)
+		return
+	}
+
+	var goal models.SavingsGoal
+	if err := json.NewDecoder(r.Body).Decode(&goal); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	// The start date stays put unless the request moves it
+	if goal.StartDate == "" {
+		goal.StartDate = current.StartDate
+	}
+
+	if !h.validateGoal(w, &goal, userID) {
+		return
+	}
+
+	goal.ID = goalID
+	goal.UserID = userID
+
+	if err := h.goalRepo.Update(&goal); err != nil {
+		http.Error(w, `{"error":"failed to update savings goal"}`, http.StatusInternalServerError)
+		return
+	}
+
+	h.writeGoal(w, goalID, userID, http.StatusOK)
+}
+
+// DeleteGoal deletes a savings goal and its contributions
+func (h *GoalHandler) DeleteGoal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	goalID, ok := goalIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.goalRepo.Delete(goalID, userID); err != nil {
+		http.Error(w, `{"error":"failed to delete savings goal"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "savings goal deleted successfully"})
+}
+
+// GetContributions retrieves the contributions to a savings goal
+func (h *GoalHandler) GetContributions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	goalID, ok := goalIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	goal, err := h.goalRepo.GetByID(goalID, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch savings goal"}`, http.StatusInternalServerError)
+		return
+	}
+	if goal == nil {
+		http.Error(w, `{"error":"savings goal not found"}`, http.StatusNotFound)
+		return
+	}
+
+	contributions, err := h.goalRepo.GetContributions(goalID, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch goal contributions"}`, http.StatusInternalServerError)
+		return
+	}
+	if contributions == nil {
+		contributions = []models.GoalContribution{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(contributions)
+}
+
+// AddContribution records money set aside for (or, when negative, taken from) a savings goal
+func (h *GoalHandler) AddContribution(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	goalID, ok := goalIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var contribution models.GoalContribution
+	if err := json.NewDecoder(r.Body).Decode(&contribution); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if contribution.Amount == 0 {
+		http.Error(w, `{"error":"amount is required and cannot be zero"}`, http.StatusBadRequest)
+		return
+	}
+	if contribution.ContributedOn == "" {
+		contribution.ContributedOn = time.Now().Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", contribution.ContributedOn); err != nil {
+		http.Error(w, `{"error":"contributed_on must be YYYY-MM-DD"}`, http.StatusBadRequest)
+		return
+	}
+
+	goal, err := h.goalRepo.GetByID(goalID, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch savings goal"}`, http.StatusInternalServerError)
+		return
+	}
+	if goal == nil {
+		http.Error(w, `{"error":"savings goal not found"}`, http.StatusNotFound)
+		return
+	}
+
+	contribution.GoalID = goalID
+
+	if err := h.goalRepo.AddContribution(&contribution, userID); err != nil {
+		http.Error(w, `{"error":"failed to record contribution"}`, http.StatusInternalServerError)
+		return
+	}
+
+	h.writeGoal(w, goalID, userID, http.StatusCreated)
+}
+
+// DeleteContribution deletes a contribution from a savings goal
+func (h *GoalHandler) DeleteContribution(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	// /api/goals/{id}/contributions/{contribution_id}
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 5 {
+		http.Error(w, `{"error":"contribution id required"}`, http.StatusBadRequest)
+		return
+	}
+	goalID, err := strconv.ParseInt(pathParts[2], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid goal id"}`, http.StatusBadRequest)
+		return
+	}
+	contributionID, err := strconv.ParseInt(pathParts[4], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid contribution id"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.goalRepo.DeleteContribution(contributionID, goalID, userID); err != nil {
+		http.Error(w, `{"error":"failed to delete contribution"}`, http.StatusInternalServerError)
+		return
+	}
+
+	h.writeGoal(w, goalID, userID, http.StatusOK)
+}
+
+// validateGoal checks a savings goal, writing an error response on failure
+func (h *GoalHandler) validateGoal(w http.ResponseWriter, goal *models.SavingsGoal, userID int64) bool {
+	goal.Name = strings.TrimSpace(goal.Name)
+	goal.Account = strings.TrimSpace(goal.Account)
+	if goal.Name == "" || goal.TargetAmount <= 0 {
+		http.Error(w, `{"error":"name and target_amount (>0) are required"}`, http.StatusBadRequest)
+		return false
+	}
+
+	if goal.StartDate == "" {
+		goal.StartDate = time.Now().Format("2006-01-02")
+	}
+	start, err := time.Parse("2006-01-02", goal.StartDate)
+	if err != nil {
+		http.Error(w, `{"error":"start_date must be YYYY-MM-DD"}`, http.StatusBadRequest)
+		return false
+	}
+
+	if goal.TargetDate != nil {
+		target, err := time.Parse("2006-01-02", *goal.TargetDate)
+		if err != nil {
+			http.Error(w, `{"error":"target_date must be YYYY-MM-DD"}`, http.StatusBadRequest)
+			return false
+		}
+		if !target.After(start) {
+			http.Error(w, `{"error":"target_date must be after start_date"}`, http.StatusBadRequest)
+			return false
+		}
+	}
+
+	if goal.Account != "" && goal.CategoryID != nil {
+		http.Error(w, `{"error":"link a goal to an account or a category, not both"}`, http.StatusBadRequest)
+		return false
+	}
+
+	// Goals are personal, so only personal categories can be linked
+	if goal.CategoryID != nil {
+		category, err := h.categoryRepo.GetByID(*goal.CategoryID, repository.Personal(userID))
+		if err != nil || category == nil {
+			http.Error(w, `{"error":"invalid category"}`, http.StatusBadRequest)
+			return false
+		}
+	}
+
+	return true
+}
+
+// writeGoal responds with a stored savings goal and its progress
+func (h *GoalHandler) writeGoal(w http.ResponseWriter, goalID, userID int64, status int) {
+	goal, err := h.goalRepo.GetByID(goalID, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch savings goal"}`, http.StatusInternalServerError)
+		return
+	}
+	if goal == nil {
+		http.Error(w, `{"error":"savings goal not found"}`, http.StatusNotFound)
+		return
+	}
+
+	goalList := []models.SavingsGoal{*goal}
+	if err := attachGoalProgress(h.goalRepo, goalList, time.Now()); err != nil {
+		http.Error(w, `{"error":"failed to calculate goal progress"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(goalList[0])
+}
+
+// attachGoalProgress works out the progress of each goal as of now
+func attachGoalProgress(goalRepo *repository.GoalRepository, goalList []models.SavingsGoal, now time.Time) error {
+	today := now.Format("2006-01-02")
+	for i := range goalList {
+		contributed, linked, err := goalRepo.Totals(&goalList[i], today)
+		if err != nil {
+			return err
+		}
+		goalList[i].Progress = goals.Progress(&goalList[i], contributed, linked, now)
+	}
+	return nil
+}
+
+// goalIDFromPath extracts the goal id from /api/goals/{id}[/contributions]
+func goalIDFromPath(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 3 {
+		http.Error(w, `{"error":"goal id required"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	goalID, err := strconv.ParseInt(pathParts[2], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid goal id"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	return goalID, true
+}
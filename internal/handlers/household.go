@@ -0,0 +1,511 @@
+package handlers
+
+import (
+	"encoding/json"
+	"myexpress-tracker/internal/middleware"
+	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/repository"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// HouseholdHandler handles household, membership and invitation requests
+type HouseholdHandler struct {
+	householdRepo *repository.HouseholdRepository
+}
+
+// NewHouseholdHandler creates a new household handler
+func NewHouseholdHandler(householdRepo *repository.HouseholdRepository) *HouseholdHandler {
+	return &HouseholdHandler{
+		householdRepo: householdRepo,
+	}
+}
+
+// HouseholdRequest is the body for creating or renaming a household
+type HouseholdRequest struct {
+	Name string `json:"name"`
+}
+
+// RoleRequest is the body for changing a member's role
+type RoleRequest struct {
+	Role string `json:"role"`
+}
+
+// InvitationRequest is the body for inviting a user by email or username
+type InvitationRequest struct {
+	Invitee string `json:"invitee"`
+	Role    string `json:"role"`
+}
+
+// ledgerFromRequest returns the ledger a request works with: the household
+// selected by the X-Household-ID header, or the user's personal ledger
+func ledgerFromRequest(r *http.Request, userID int64) repository.Ledger {
+	return repository.Ledger{
+		UserID:      userID,
+		HouseholdID: middleware.GetHouseholdIDFromContext(r),
+	}
+}
+
+// writeHouseholdError maps household repository errors to responses
+func writeHouseholdError(w http.ResponseWriter, err error, fallback string) {
+	switch err {
+	case repository.ErrNotMember:
+		http.Error(w, `{"error":"household not found"}`, http.StatusNotFound)
+	case repository.ErrForbidden:
+		http.Error(w, `{"error":"your household role does not allow this"}`, http.StatusForbidden)
+	case repository.ErrLastOwner:
+		http.Error(w, `{"error":"a household needs at least one owner"}`, http.StatusConflict)
+	case repository.ErrAlreadyMember:
+		http.Error(w, `{"error":"user is already a member"}`, http.StatusConflict)
+	case repository.ErrAlreadyInvited:
+		http.Error(w, `{"error":"user already has a pending invitation"}`, http.StatusConflict)
+	case repository.ErrInviteeNotFound:
+		http.Error(w, `{"error":"no user with that email or username"}`, http.StatusNotFound)
+	case repository.ErrInvitationNotFound:
+		http.Error(w, `{"error":"invitation not found"}`, http.StatusNotFound)
+	default:
+		http.Error(w, `{"error":"`+fallback+`"}`, http.StatusInternalServerError)
+	}
+}
+
+// GetHouseholds lists the households the user belongs to
+func (h *HouseholdHandler) GetHouseholds(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	households, err := h.householdRepo.GetByUser(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch households"}`, http.StatusInternalServerError)
+		return
+	}
+	if households == nil {
+		households = []models.Household{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(households)
+}
+
+// CreateHousehold creates a household with the user as its owner
+func (h *HouseholdHandler) CreateHousehold(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req HouseholdRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, `{"error":"name is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	household := models.Household{Name: req.Name}
+	if err := h.householdRepo.Create(&household, userID); err != nil {
+		http.Error(w, `{"error":"failed to create household"}`, http.StatusInternalServerError)
+		return
+	}
+
+	h.writeHousehold(w, household.ID, userID, http.StatusCreated)
+}
+
+// GetHousehold returns a household with its members
+func (h *HouseholdHandler) GetHousehold(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	householdID, ok := householdIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	h.writeHousehold(w, householdID, userID, http.StatusOK)
+}
+
+// RenameHousehold renames a household (owners only)
+func (h *HouseholdHandler) RenameHousehold(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	householdID, ok := householdIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req HouseholdRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, `{"error":"name is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.householdRepo.Rename(householdID, userID, req.Name); err != nil {
+		writeHouseholdError(w, err, "failed to rename household")
+		return
+	}
+
+	h.writeHousehold(w, householdID, userID, http.StatusOK)
+}
+
+// DeleteHousehold deletes a household and all of its records (owners only)
+func (h *HouseholdHandler) DeleteHousehold(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	householdID, ok := householdIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.householdRepo.Delete(r.Context(), householdID, userID); err != nil {
+		writeHouseholdError(w, err, "failed to delete household")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "household deleted"})
+}
+
+// UpdateMember changes a member's role (owners only)
+func (h *HouseholdHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	householdID, memberID, ok := householdChildFromPath(w, r, "members")
+	if !ok {
+		return
+	}
+
+	var req RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if !models.ValidRole(req.Role) {
+		http.Error(w, `{"error":"role must be owner, editor or viewer"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.householdRepo.SetRole(householdID, memberID, userID, req.Role); err != nil {
+		writeHouseholdError(w, err, "failed to update member")
+		return
+	}
+
+	h.writeHousehold(w, householdID, userID, http.StatusOK)
+}
+
+// RemoveMember removes a member from a household. Owners can remove anyone;
+// any member can remove themselves to leave.
+func (h *HouseholdHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	householdID, memberID, ok := householdChildFromPath(w, r, "members")
+	if !ok {
+		return
+	}
+
+	if err := h.householdRepo.RemoveMember(householdID, memberID, userID); err != nil {
+		writeHouseholdError(w, err, "failed to remove member")
+		return
+	}
+
+	message := "member removed"
+	if memberID == userID {
+		message = "you left the household"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+// CreateInvitation invites a user by email or username (owners only)
+func (h *HouseholdHandler) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	householdID, ok := householdIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req InvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	req.Invitee = strings.TrimSpace(req.Invitee)
+	if req.Invitee == "" {
+		http.Error(w, `{"error":"invitee (email or username) is required"}`, http.StatusBadRequest)
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.RoleEditor
+	}
+	if req.Role != models.RoleEditor && req.Role != models.RoleViewer {
+		http.Error(w, `{"error":"role must be editor or viewer"}`, http.StatusBadRequest)
+		return
+	}
+
+	invitation, err := h.householdRepo.Invite(householdID, userID, req.Invitee, req.Role)
+	if err != nil {
+		writeHouseholdError(w, err, "failed to create invitation")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invitation)
+}
+
+// GetInvitations lists a household's invitations (owners only)
+func (h *HouseholdHandler) GetInvitations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	householdID, ok := householdIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.householdRepo.GetInvitations(householdID, userID)
+	if err != nil {
+		writeHouseholdError(w, err, "failed to fetch invitations")
+		return
+	}
+	if invitations == nil {
+		invitations = []models.HouseholdInvitation{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invitations)
+}
+
+// RevokeInvitation withdraws a pending invitation (owners only)
+func (h *HouseholdHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	householdID, invitationID, ok := householdChildFromPath(w, r, "invitations")
+	if !ok {
+		return
+	}
+
+	if err := h.householdRepo.Revoke(householdID, invitationID, userID); err != nil {
+		writeHouseholdError(w, err, "failed to revoke invitation")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "invitation revoked"})
+}
+
+// GetPendingInvitations lists the invitations waiting for the user's answer
+func (h *HouseholdHandler) GetPendingInvitations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	invitations, err := h.householdRepo.GetPendingForUser(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch invitations"}`, http.StatusInternalServerError)
+		return
+	}
+	if invitations == nil {
+		invitations = []models.HouseholdInvitation{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invitations)
+}
+
+// RespondInvitation accepts or declines an invitation addressed to the user
+func (h *HouseholdHandler) RespondInvitation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	// Path format: /api/invitations/{id}/accept or /api/invitations/{id}/decline
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 4 || (pathParts[3] != "accept" && pathParts[3] != "decline") {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+
+	invitationID, err := strconv.ParseInt(pathParts[2], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid invitation id"}`, http.StatusBadRequest)
+		return
+	}
+
+	invitation, err := h.householdRepo.Respond(invitationID, userID, pathParts[3] == "accept")
+	if err != nil {
+		writeHouseholdError(w, err, "failed to respond to invitation")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invitation)
+}
+
+// writeHousehold responds with a household and its members as seen by userID
+func (h *HouseholdHandler) writeHousehold(w http.ResponseWriter, householdID, userID int64, status int) {
+	household, err := h.householdRepo.GetByID(householdID, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch household"}`, http.StatusInternalServerError)
+		return
+	}
+	if household == nil {
+		http.Error(w, `{"error":"household not found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(household)
+}
+
+// householdIDFromPath parses the household ID from /api/households/{id}/...
+func householdIDFromPath(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 3 {
+		http.Error(w, `{"error":"household id required"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	householdID, err := strconv.ParseInt(pathParts[2], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid household id"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	return householdID, true
+}
+
+// householdChildFromPath parses the household ID and child ID from
+// /api/households/{id}/{collection}/{childID}
+func householdChildFromPath(w http.ResponseWriter, r *http.Request, collection string) (int64, int64, bool) {
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 5 || pathParts[3] != collection {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return 0, 0, false
+	}
+
+	householdID, ok := householdIDFromPath(w, r)
+	if !ok {
+		return 0, 0, false
+	}
+
+	childID, err := strconv.ParseInt(pathParts[4], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid `+strings.TrimSuffix(collection, "s")+` id"}`, http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	return householdID, childID, true
+}
@@ -0,0 +1,772 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"myexpress-tracker/internal/classifier"
+	"myexpress-tracker/internal/duplicates"
+	"myexpress-tracker/internal/importer"
+	"myexpress-tracker/internal/middleware"
+	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/repository"
+	"myexpress-tracker/internal/rules"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxImportSize caps the size of an uploaded statement file
+	maxImportSize = 5 << 20
+	// maxImportRows caps the transactions in one import
+	maxImportRows = 5000
+)
+
+// ImportHandler handles statement file imports
+type ImportHandler struct {
+	importRepo   *repository.ImportRepository
+	incomeRepo   *repository.IncomeRepository
+	expenseRepo  *repository.ExpenseRepository
+	categoryRepo *repository.CategoryRepository
+	ruleEngine   *rules.Engine
+	classifier   *classifier.Service
+	duplicates   *duplicates.Detector
+}
+
+// NewImportHandler creates a new import handler
+func NewImportHandler(importRepo *repository.ImportRepository, incomeRepo *repository.IncomeRepository, expenseRepo *repository.ExpenseRepository, categoryRepo *repository.CategoryRepository, ruleEngine *rules.Engine, classifierService *classifier.Service, duplicateDetector *duplicates.Detector) *ImportHandler {
+	return &ImportHandler{
+		importRepo:   importRepo,
+		incomeRepo:   incomeRepo,
+		expenseRepo:  expenseRepo,
+		categoryRepo: categoryRepo,
+		ruleEngine:   ruleEngine,
+		classifier:   classifierService,
+		duplicates:   duplicateDetector,
+	}
+}
+
+// CreateImport parses an uploaded statement file into a preview. Nothing is
+// recorded until the preview is committed.
+func (h *ImportHandler) CreateImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	ledger := ledgerFromRequest(r, userID)
+
+	data, filename, ok := readImportFile(w, r)
+	if !ok {
+		return
+	}
+
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "qfx" {
+		format = models.ImportOFX
+	}
+	if format == "" {
+		format = importer.Detect(filename, data)
+	}
+	if format != models.ImportOFX && format != models.ImportQIF {
+		http.Error(w, `{"error":"unknown file format; set format to ofx, qfx or qif"}`, http.StatusBadRequest)
+		return
+	}
+
+	options := importer.Options{DateOrder: strings.ToLower(r.URL.Query().Get("date_format"))}
+	if options.DateOrder != "" && options.DateOrder != "mdy" && options.DateOrder != "dmy" {
+		http.Error(w, `{"error":"date_format must be mdy or dmy"}`, http.StatusBadRequest)
+		return
+	}
+
+	transactions, err := importer.Parse(format, data, options)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid file: " + err.Error()})
+		return
+	}
+	if len(transactions) == 0 {
+		http.Error(w, `{"error":"file has no transactions"}`, http.StatusBadRequest)
+		return
+	}
+	if len(transactions) > maxImportRows {
+		http.Error(w, fmt.Sprintf(`{"error":"file has more than %d transactions"}`, maxImportRows), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.preview(ledger, transactions, strings.TrimSpace(r.URL.Query().Get("account")))
+	if err != nil {
+		http.Error(w, `{"error":"failed to preview import"}`, http.StatusInternalServerError)
+		return
+	}
+
+	imp := &models.Import{
+		UserID:      userID,
+		HouseholdID: ledger.HouseholdRef(),
+		Format:      format,
+		Filename:    filename,
+		Status:      models.ImportPreview,
+		Rows:        rows,
+	}
+	if err := h.importRepo.Create(imp); err != nil {
+		http.Error(w, `{"error":"failed to create import"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(imp)
+}
+
+// readImportFile reads the uploaded file from a multipart form's file field
+// or, for any other content type, from the raw request body
+func readImportFile(w http.ResponseWriter, r *http.Request) ([]byte, string, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	var data []byte
+	filename := r.URL.Query().Get("filename")
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, formErr := r.FormFile("file")
+		if formErr == nil {
+			defer file.Close()
+			filename = header.Filename
+			data, err = io.ReadAll(file)
+		} else {
+			err = formErr
+		}
+	} else {
+		data, err = io.ReadAll(r.Body)
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf(`{"error":"file is larger than %d MB"}`, maxImportSize>>20), http.StatusRequestEntityTooLarge)
+		return nil, "", false
+	}
+	if err != nil || len(data) == 0 {
+		http.Error(w, `{"error":"upload a file in the request body or a file form field"}`, http.StatusBadRequest)
+		return nil, "", false
+	}
+
+	return data, filename, true
+}
+
+// preview maps transactions to import rows. Rows already imported by bank
+// transaction id are duplicates. Categories named in the file are matched to
+// the ledger's categories; other expenses are categorized by the user's rules,
+// then by a confident suggestion, and checked for likely duplicates.
+func (h *ImportHandler) preview(ledger repository.Ledger, transactions []importer.Transaction, account string) ([]models.ImportRow, error) {
+	userID := ledger.UserID
+	ruleSet, err := h.ruleEngine.Load(userID)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := h.loadCategories(ledger)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]models.ImportRow, 0, len(transactions))
+	seen := make(map[string]bool)
+	for i, transaction := range transactions {
+		row := models.ImportRow{
+			Index:       i,
+			Type:        "expense",
+			Date:        transaction.Date,
+			Amount:      math.Abs(transaction.Amount),
+			Description: transaction.Description,
+			Memo:        transaction.Memo,
+			Account:     transaction.Account,
+			Category:    transaction.Category,
+			ExternalID:  transaction.ExternalID,
+			Source:      transaction.Source,
+			Status:      models.ImportRowNew,
+		}
+		if transaction.Amount > 0 {
+			row.Type = "income"
+		}
+		if account != "" {
+			row.Account = account
+		}
+
+		if row.Amount == 0 {
+			row.Status = models.ImportRowSkipped
+			row.Error = "amount is zero"
+			rows = append(rows, row)
+			continue
+		}
+
+		if row.ExternalID != "" {
+			key := row.Source + "\x00" + row.ExternalID
+			existing, err := h.importRepo.Imported(userID, row.Source, row.ExternalID)
+			if err != nil {
+				return nil, err
+			}
+			if seen[key] || existing != 0 {
+				row.Status = models.ImportRowDuplicate
+				row.DuplicateOf = existing
+			}
+			seen[key] = true
+		}
+
+		if len(transaction.Splits) > 0 {
+			if row.Type == "expense" && importSplitsFit(transaction) {
+				for _, split := range transaction.Splits {
+					row.Splits = append(row.Splits, models.ImportSplit{
+						Category:   split.Category,
+						CategoryID: categories.find(split.Category, row.Type),
+						Amount:     -split.Amount,
+						Memo:       split.Memo,
+					})
+				}
+				row.Category = ""
+			} else if row.Category == "" {
+				// Income has no line items, and splits that do not add up
+				// cannot be kept, so the largest split names the category
+				row.Category = largestImportSplit(transaction.Splits).Category
+			}
+		}
+		if len(row.Splits) == 0 {
+			row.CategoryID = categories.find(row.Category, row.Type)
+		}
+		if row.CategoryID != 0 || (len(row.Splits) > 0 && importSplitsCategorized(row.Splits)) {
+			row.CategorySource = models.ImportCategoryFile
+		}
+
+		if row.Type == "expense" {
+			expense := models.Expense{
+				UserID:      userID,
+				CategoryID:  row.CategoryID,
+				Amount:      row.Amount,
+				Description: row.Description,
+				ExpenseDate: row.Date,
+				Account:     row.Account,
+				Splits:      importExpenseSplits(row.Splits),
+			}
+			ruleSet.Apply(&expense, false)
+			if expense.CategoryID != row.CategoryID {
+				row.CategorySource = models.ImportCategoryRule
+			} else if expense.CategoryID == 0 && len(expense.Splits) == 0 && expense.Description != "" {
+				predictions, err := h.classifier.Suggest(userID, expense.Description, expense.Amount)
+				if err != nil {
+					return nil, err
+				}
+				if len(predictions) > 0 && predictions[0].Confidence >= autoCategoryConfidence {
+					expense.CategoryID = predictions[0].CategoryID
+					row.CategorySource = models.ImportCategorySuggestion
+				}
+			}
+			row.CategoryID = expense.CategoryID
+			row.Description = expense.Description
+			row.Tags = expense.Tags
+
+			// Only the personal ledger is checked for likely duplicates
+			if row.Status == models.ImportRowNew && ledger.HouseholdID == 0 {
+				candidates, err := h.duplicates.Candidates(userID, &expense)
+				if err != nil {
+					return nil, err
+				}
+				if len(candidates) > 0 {
+					row.Status = models.ImportRowPossibleDuplicate
+					row.DuplicateOf = candidates[0].ID
+				}
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// importCategoryIndex finds a ledger's categories by type and lowercased name
+type importCategoryIndex map[string]int64
+
+// loadCategories indexes the categories available to a ledger
+func (h *ImportHandler) loadCategories(ledger repository.Ledger) (importCategoryIndex, error) {
+	categories, err := h.categoryRepo.GetAll(ledger)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(importCategoryIndex, len(categories))
+	for _, category := range categories {
+		index[category.Type+":"+strings.ToLower(category.Name)] = category.ID
+	}
+	return index, nil
+}
+
+// find returns the category a file's category name maps to, or 0. A
+// subcategory such as Food:Groceries matches its full name first, then
+// Groceries, then Food.
+func (index importCategoryIndex) find(name, recordType string) int64 {
+	if name == "" {
+		return 0
+	}
+
+	names := []string{name}
+	if parts := strings.Split(name, ":"); len(parts) > 1 {
+		names = append(names, parts[len(parts)-1], parts[0])
+	}
+	for _, candidate := range names {
+		if id := index[recordType+":"+strings.ToLower(strings.TrimSpace(candidate))]; id != 0 {
+			return id
+		}
+	}
+	return 0
+}
+
+// importSplitsFit reports whether an outgoing transaction's splits can be
+// kept as the line items of an expense: all money out, adding up to the
+// amount and no more than an expense can hold
+func importSplitsFit(transaction importer.Transaction) bool {
+	if len(transaction.Splits) > maxSplits {
+		return false
+	}
+
+	total := 0.0
+	for _, split := range transaction.Splits {
+		if split.Amount >= 0 {
+			return false
+		}
+		total += split.Amount
+	}
+	return math.Abs(total-transaction.Amount) < 0.005
+}
+
+// largestImportSplit returns the split with the largest amount either way
+func largestImportSplit(splits []importer.Split) importer.Split {
+	largest := splits[0]
+	for _, split := range splits[1:] {
+		if math.Abs(split.Amount) > math.Abs(largest.Amount) {
+			largest = split
+		}
+	}
+	return largest
+}
+
+// importSplitsCategorized reports whether every split has a category
+func importSplitsCategorized(splits []models.ImportSplit) bool {
+	for _, split := range splits {
+		if split.CategoryID == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// importExpenseSplits converts an import row's splits to expense splits
+func importExpenseSplits(splits []models.ImportSplit) []models.ExpenseSplit {
+	if len(splits) == 0 {
+		return nil
+	}
+
+	expenseSplits := make([]models.ExpenseSplit, len(splits))
+	for i, split := range splits {
+		expenseSplits[i] = models.ExpenseSplit{
+			CategoryID: split.CategoryID,
+			Amount:     split.Amount,
+			Note:       split.Memo,
+		}
+	}
+	return expenseSplits
+}
+
+// GetImports lists the user's imports without their rows
+func (h *ImportHandler) GetImports(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	imports, err := h.importRepo.GetByUser(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch imports"}`, http.StatusInternalServerError)
+		return
+	}
+	if imports == nil {
+		imports = []models.Import{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(imports)
+}
+
+// GetImport returns an import with its rows
+func (h *ImportHandler) GetImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	importID, ok := importIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	imp, err := h.importRepo.GetByID(importID, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch import"}`, http.StatusInternalServerError)
+		return
+	}
+	if imp == nil {
+		http.Error(w, `{"error":"import not found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(imp)
+}
+
+// CommitImport creates the income and expense records of a previewed import.
+// Duplicates and, unless included, possible duplicates are skipped. Each row
+// succeeds or fails on its own.
+func (h *ImportHandler) CommitImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	importID, ok := importIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.ImportCommitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	imp, err := h.importRepo.GetByID(importID, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch import"}`, http.StatusInternalServerError)
+		return
+	}
+	if imp == nil {
+		http.Error(w, `{"error":"import not found"}`, http.StatusNotFound)
+		return
+	}
+	if imp.Status != models.ImportPreview {
+		http.Error(w, `{"error":"import was already committed"}`, http.StatusConflict)
+		return
+	}
+
+	if !h.applyChoices(w, imp, &req) {
+		return
+	}
+	if req.CreateCategories && !h.createMissingCategories(w, imp) {
+		return
+	}
+	if err := h.resolveCategories(imp, &req); err != nil {
+		http.Error(w, `{"error":"failed to commit import"}`, http.StatusInternalServerError)
+		return
+	}
+
+	claimed, err := h.importRepo.Claim(importID, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to commit import"}`, http.StatusInternalServerError)
+		return
+	}
+	if !claimed {
+		http.Error(w, `{"error":"import was already committed"}`, http.StatusConflict)
+		return
+	}
+
+	for i := range imp.Rows {
+		row := &imp.Rows[i]
+		if row.Status != models.ImportRowNew {
+			continue
+		}
+
+		ctx := r.Context()
+		if row.ExternalID != "" {
+			ctx = repository.WithExternalID(ctx, row.Source, row.ExternalID)
+		}
+
+		if row.Type == "income" {
+			income := models.Income{
+				UserID:      userID,
+				HouseholdID: imp.HouseholdID,
+				CategoryID:  row.CategoryID,
+				Amount:      row.Amount,
+				Description: row.Description,
+				IncomeDate:  row.Date,
+				Tags:        row.Tags,
+				Account:     row.Account,
+			}
+			err = h.incomeRepo.Create(ctx, &income)
+			row.RecordID = income.ID
+		} else {
+			expense := models.Expense{
+				UserID:      userID,
+				HouseholdID: imp.HouseholdID,
+				CategoryID:  row.CategoryID,
+				Amount:      row.Amount,
+				Description: row.Description,
+				ExpenseDate: row.Date,
+				Tags:        row.Tags,
+				Account:     row.Account,
+				Splits:      importExpenseSplits(row.Splits),
+			}
+			err = h.expenseRepo.Create(ctx, &expense)
+			row.RecordID = expense.ID
+			row.CategoryID = expense.CategoryID
+		}
+
+		switch {
+		case err == repository.ErrAlreadyImported:
+			row.Status = models.ImportRowDuplicate
+		case err == repository.ErrForbidden:
+			row.Status = models.ImportRowFailed
+			row.Error = "your household role cannot add records"
+		case expenseInputErrors[err]:
+			row.Status = models.ImportRowFailed
+			row.Error = err.Error()
+		case err != nil:
+			row.Status = models.ImportRowFailed
+			row.Error = "failed to create record"
+		default:
+			row.Status = models.ImportRowImported
+		}
+	}
+
+	if err := h.importRepo.Complete(imp); err != nil {
+		http.Error(w, `{"error":"failed to commit import"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(imp)
+}
+
+// applyChoices validates a commit request and applies its choices to the
+// import's rows, leaving the rows to create with status new. A category chosen
+// for a split row replaces its splits. It writes an error response on failure.
+func (h *ImportHandler) applyChoices(w http.ResponseWriter, imp *models.Import, req *models.ImportCommitRequest) bool {
+	ledger := importLedger(imp)
+	categoryTypes := make(map[int64]string)
+	validCategory := func(categoryID int64, recordType string) bool {
+		if _, ok := categoryTypes[categoryID]; !ok {
+			category, err := h.categoryRepo.GetByID(categoryID, ledger)
+			if err != nil || category == nil {
+				return false
+			}
+			categoryTypes[categoryID] = category.Type
+		}
+		return categoryTypes[categoryID] == recordType
+	}
+
+	if req.DefaultIncomeCategoryID != 0 && !validCategory(req.DefaultIncomeCategoryID, "income") {
+		http.Error(w, `{"error":"invalid default income category"}`, http.StatusBadRequest)
+		return false
+	}
+	if req.DefaultExpenseCategoryID != 0 && !validCategory(req.DefaultExpenseCategoryID, "expense") {
+		http.Error(w, `{"error":"invalid default expense category"}`, http.StatusBadRequest)
+		return false
+	}
+
+	skip := make(map[int]bool)
+	for _, choice := range req.Rows {
+		if choice.Index < 0 || choice.Index >= len(imp.Rows) {
+			http.Error(w, `{"error":"row `+strconv.Itoa(choice.Index)+` does not exist"}`, http.StatusBadRequest)
+			return false
+		}
+
+		row := &imp.Rows[choice.Index]
+		if choice.CategoryID != nil {
+			if !validCategory(*choice.CategoryID, row.Type) {
+				http.Error(w, `{"error":"row `+strconv.Itoa(choice.Index)+` needs a valid `+row.Type+` category"}`, http.StatusBadRequest)
+				return false
+			}
+			row.CategoryID = *choice.CategoryID
+			row.CategorySource = models.ImportCategoryChosen
+			row.Splits = nil
+		}
+		if choice.Skip != nil {
+			skip[choice.Index] = *choice.Skip
+		}
+	}
+
+	for i := range imp.Rows {
+		row := &imp.Rows[i]
+		if row.Status != models.ImportRowNew && row.Status != models.ImportRowPossibleDuplicate {
+			continue
+		}
+
+		skipped, chosen := skip[i]
+		if !chosen {
+			skipped = row.Status == models.ImportRowPossibleDuplicate && !req.IncludePossibleDuplicates
+		}
+		if skipped {
+			row.Status = models.ImportRowSkipped
+			continue
+		}
+		row.Status = models.ImportRowNew
+	}
+
+	return true
+}
+
+// createMissingCategories adds the categories named in the file that the rows
+// to create need to the import's ledger. A name taken by a category of the
+// other type is left alone. It writes an error response on failure.
+func (h *ImportHandler) createMissingCategories(w http.ResponseWriter, imp *models.Import) bool {
+	for _, missing := range repository.MissingImportCategories(imp.Rows) {
+		category := models.Category{Name: missing.Name, Type: missing.Type, HouseholdID: imp.HouseholdID}
+		err := h.categoryRepo.Create(&category, imp.UserID)
+		if err == repository.ErrForbidden {
+			http.Error(w, `{"error":"your household role cannot add categories"}`, http.StatusForbidden)
+			return false
+		}
+		if err != nil && err != repository.ErrDuplicateName {
+			http.Error(w, `{"error":"failed to create category"}`, http.StatusInternalServerError)
+			return false
+		}
+	}
+	return true
+}
+
+// resolveCategories fills in the categories of the rows to create: the file's
+// category when it now exists, otherwise the request's default. Rows left
+// without a category fail.
+func (h *ImportHandler) resolveCategories(imp *models.Import, req *models.ImportCommitRequest) error {
+	categories, err := h.loadCategories(importLedger(imp))
+	if err != nil {
+		return err
+	}
+
+	for i := range imp.Rows {
+		row := &imp.Rows[i]
+		if row.Status != models.ImportRowNew {
+			continue
+		}
+
+		defaultID := req.DefaultExpenseCategoryID
+		if row.Type == "income" {
+			defaultID = req.DefaultIncomeCategoryID
+		}
+
+		if len(row.Splits) > 0 {
+			for j := range row.Splits {
+				split := &row.Splits[j]
+				if split.CategoryID == 0 {
+					split.CategoryID = categories.find(split.Category, row.Type)
+				}
+				if split.CategoryID == 0 {
+					split.CategoryID = defaultID
+				}
+				if split.CategoryID == 0 {
+					row.Status = models.ImportRowFailed
+					row.Error = missingCategoryError(split.Category)
+				}
+			}
+			continue
+		}
+
+		if row.CategoryID == 0 {
+			if row.CategoryID = categories.find(row.Category, row.Type); row.CategoryID != 0 {
+				row.CategorySource = models.ImportCategoryFile
+			} else if row.CategoryID = defaultID; row.CategoryID != 0 {
+				row.CategorySource = models.ImportCategoryDefault
+			}
+		}
+		if row.CategoryID == 0 {
+			row.Status = models.ImportRowFailed
+			row.Error = missingCategoryError(row.Category)
+		}
+	}
+
+	return nil
+}
+
+// missingCategoryError explains why a row has no category
+func missingCategoryError(name string) string {
+	if name == "" {
+		return "category_id is required"
+	}
+	return fmt.Sprintf("category %q does not exist", name)
+}
+
+// importLedger returns the ledger an import belongs to: a household, or the
+// personal ledger of the user who uploaded it
+func importLedger(imp *models.Import) repository.Ledger {
+	if imp.HouseholdID == nil {
+		return repository.Personal(imp.UserID)
+	}
+	return repository.Ledger{UserID: imp.UserID, HouseholdID: *imp.HouseholdID}
+}
+
+// DeleteImport discards an import preview, or the record of a committed
+// import. Records created by a committed import are kept.
+func (h *ImportHandler) DeleteImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	importID, ok := importIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.importRepo.Delete(importID, userID); err != nil {
+		http.Error(w, `{"error":"import not found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "import deleted successfully"})
+}
+
+// importIDFromPath extracts the import id from /api/imports/{id}[/...]
+func importIDFromPath(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 3 {
+		http.Error(w, `{"error":"import id required"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	importID, err := strconv.ParseInt(pathParts[2], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid import id"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	return importID, true
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // IncomeHandler handles income requests
@@ -50,15 +51,22 @@ func (h *IncomeHandler) CreateIncome(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Verify category exists and is income type
-	category, err := h.categoryRepo.GetByID(income.CategoryID)
+	ledger := ledgerFromRequest(r, userID)
+	category, err := h.categoryRepo.GetByID(income.CategoryID, ledger)
 	if err != nil || category == nil || category.Type != "income" {
 		http.Error(w, `{"error":"invalid income category"}`, http.StatusBadRequest)
 		return
 	}
 
 	income.UserID = userID
+	income.HouseholdID = ledger.HouseholdRef()
 
-	if err := h.incomeRepo.Create(&income); err != nil {
+	err = h.incomeRepo.Create(r.Context(), &income)
+	if err == repository.ErrForbidden {
+		http.Error(w, `{"error":"your household role cannot add records"}`, http.StatusForbidden)
+		return
+	}
+	if err != nil {
 		http.Error(w, `{"error":"failed to create income"}`, http.StatusInternalServerError)
 		return
 	}
@@ -102,7 +110,7 @@ func (h *IncomeHandler) GetIncomes(w http.ResponseWriter, r *http.Request) {
 		filters["end_date"] = endDate
 	}
 
-	incomes, err := h.incomeRepo.GetByUser(userID, filters)
+	incomes, err := h.incomeRepo.GetByUser(ledgerFromRequest(r, userID), filters)
 	if err != nil {
 		http.Error(w, `{"error":"failed to fetch incomes"}`, http.StatusInternalServerError)
 		return
@@ -112,9 +120,9 @@ func (h *IncomeHandler) GetIncomes(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(incomes)
 }
 
-// UpdateIncome updates an existing income record
-func (h *IncomeHandler) UpdateIncome(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPut {
+// GetIncome retrieves a single income record along with its ETag
+func (h *IncomeHandler) GetIncome(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
 		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
 		return
 	}
@@ -125,18 +133,33 @@ func (h *IncomeHandler) UpdateIncome(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get income ID from URL path
-	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-	if len(pathParts) < 3 {
-		http.Error(w, `{"error":"income id required"}`, http.StatusBadRequest)
+	incomeID, ok := incomeIDFromPath(w, r)
+	if !ok {
 		return
 	}
 
-	incomeID, err := strconv.ParseInt(pathParts[len(pathParts)-1], 10, 64)
-	if err != nil {
-		http.Error(w, `{"error":"invalid income id"}`, http.StatusBadRequest)
+	h.writeIncome(w, incomeID, ledgerFromRequest(r, userID), http.StatusOK)
+}
+
+// UpdateIncome updates an existing income record. When an If-Match header
+// is sent the update only succeeds if the record is unchanged.
+func (h *IncomeHandler) UpdateIncome(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	incomeID, ok := incomeIDFromPath(w, r)
+	if !ok {
 		return
 	}
+	ledger := ledgerFromRequest(r, userID)
 
 	var income models.Income
 	if err := json.NewDecoder(r.Body).Decode(&income); err != nil {
@@ -144,31 +167,165 @@ func (h *IncomeHandler) UpdateIncome(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate input
-	if income.CategoryID == 0 || income.Amount <= 0 || income.IncomeDate == "" {
-		http.Error(w, `{"error":"category_id, amount (>0), and income_date are required"}`, http.StatusBadRequest)
+	if !h.validateIncome(w, &income, ledger) {
 		return
 	}
 
-	// Verify category exists and is income type
-	category, err := h.categoryRepo.GetByID(income.CategoryID)
-	if err != nil || category == nil || category.Type != "income" {
-		http.Error(w, `{"error":"invalid income category"}`, http.StatusBadRequest)
+	income.ID = incomeID
+	income.UserID = userID
+	income.HouseholdID = ledger.HouseholdRef()
+
+	var err error
+	if r.Header.Get("If-Match") == "" {
+		err = h.incomeRepo.Update(r.Context(), &income)
+	} else {
+		current, getErr := h.incomeRepo.GetByID(incomeID, ledger)
+		if getErr != nil || current == nil {
+			http.Error(w, `{"error":"income not found"}`, http.StatusNotFound)
+			return
+		}
+		if !ifMatch(w, r, current.UpdatedAt) {
+			return
+		}
+		err = h.incomeRepo.UpdateIfUnmodified(r.Context(), &income, current.UpdatedAt)
+	}
+	if err == repository.ErrModified {
+		http.Error(w, `{"error":"income was modified by another request"}`, http.StatusPreconditionFailed)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to update income"}`, http.StatusInternalServerError)
+		return
+	}
+
+	h.writeIncome(w, incomeID, ledger, http.StatusOK)
+}
+
+// PatchIncome partially updates an income record from a JSON Merge Patch.
+// The merged record is validated as a whole, and the write only succeeds if
+// the record has not changed since it was read (or since the If-Match ETag).
+func (h *IncomeHandler) PatchIncome(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	incomeID, ok := incomeIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	ledger := ledgerFromRequest(r, userID)
+
+	patch, err := readMergePatch(r)
+	if err != nil {
+		http.Error(w, `{"error":"merge patch must be a JSON object"}`, http.StatusBadRequest)
+		return
+	}
+
+	current, err := h.incomeRepo.GetByID(incomeID, ledger)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch income"}`, http.StatusInternalServerError)
+		return
+	}
+	if current == nil {
+		http.Error(w, `{"error":"income not found"}`, http.StatusNotFound)
+		return
+	}
+
+	if !ifMatch(w, r, current.UpdatedAt) {
+		return
+	}
+
+	var income models.Income
+	if err := applyMergePatch(current, patch, &income); err != nil {
+		http.Error(w, `{"error":"patch contains invalid field values"}`, http.StatusBadRequest)
+		return
+	}
+
+	if !h.validateIncome(w, &income, ledger) {
 		return
 	}
 
 	income.ID = incomeID
 	income.UserID = userID
+	income.HouseholdID = ledger.HouseholdRef()
 
-	if err := h.incomeRepo.Update(&income); err != nil {
+	err = h.incomeRepo.UpdateIfUnmodified(r.Context(), &income, current.UpdatedAt)
+	if err == repository.ErrModified {
+		http.Error(w, `{"error":"income was modified by another request"}`, http.StatusPreconditionFailed)
+		return
+	}
+	if err != nil {
 		http.Error(w, `{"error":"failed to update income"}`, http.StatusInternalServerError)
 		return
 	}
 
+	h.writeIncome(w, incomeID, ledger, http.StatusOK)
+}
+
+// validateIncome checks the fields of a full income record in a ledger, writing an error response on failure
+func (h *IncomeHandler) validateIncome(w http.ResponseWriter, income *models.Income, ledger repository.Ledger) bool {
+	if income.CategoryID == 0 || income.Amount <= 0 || income.IncomeDate == "" {
+		http.Error(w, `{"error":"category_id, amount (>0), and income_date are required"}`, http.StatusBadRequest)
+		return false
+	}
+
+	if _, err := time.Parse("2006-01-02", income.IncomeDate); err != nil {
+		http.Error(w, `{"error":"income_date must be YYYY-MM-DD"}`, http.StatusBadRequest)
+		return false
+	}
+
+	// Verify category exists, is usable in the ledger and is income type
+	category, err := h.categoryRepo.GetByID(income.CategoryID, ledger)
+	if err != nil || category == nil || category.Type != "income" {
+		http.Error(w, `{"error":"invalid income category"}`, http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+// writeIncome responds with the stored income record and its ETag
+func (h *IncomeHandler) writeIncome(w http.ResponseWriter, incomeID int64, ledger repository.Ledger, status int) {
+	income, err := h.incomeRepo.GetByID(incomeID, ledger)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch income"}`, http.StatusInternalServerError)
+		return
+	}
+	if income == nil {
+		http.Error(w, `{"error":"income not found"}`, http.StatusNotFound)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", recordETag(income.UpdatedAt))
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(income)
 }
 
+// incomeIDFromPath parses the income ID from /api/income/{id}
+func incomeIDFromPath(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 3 {
+		http.Error(w, `{"error":"income id required"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	incomeID, err := strconv.ParseInt(pathParts[2], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid income id"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	return incomeID, true
+}
+
 // DeleteIncome deletes an income record
 func (h *IncomeHandler) DeleteIncome(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
@@ -195,12 +352,12 @@ func (h *IncomeHandler) DeleteIncome(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.incomeRepo.Delete(incomeID, userID); err != nil {
+	if err := h.incomeRepo.Delete(r.Context(), incomeID, ledgerFromRequest(r, userID)); err != nil {
 		http.Error(w, `{"error":"failed to delete income"}`, http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "income deleted successfully"})
+	json.NewEncoder(w).Encode(map[string]string{"message": "income moved to trash"})
 }
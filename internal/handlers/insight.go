@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"myexpress-tracker/internal/middleware"
+	"myexpress-tracker/internal/repository"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// InsightHandler handles insight feed requests
+type InsightHandler struct {
+	insightRepo *repository.InsightRepository
+}
+
+// NewInsightHandler creates a new insight handler
+func NewInsightHandler(insightRepo *repository.InsightRepository) *InsightHandler {
+	return &InsightHandler{
+		insightRepo: insightRepo,
+	}
+}
+
+// GetInsights retrieves the user's insight feed
+func (h *InsightHandler) GetInsights(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	includeDismissed := r.URL.Query().Get("include_dismissed") == "true"
+
+	insights, err := h.insightRepo.GetByUser(userID, includeDismissed)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch insights"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(insights)
+}
+
+// DismissInsight hides an insight from the feed
+func (h *InsightHandler) DismissInsight(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	// Path format: /api/insights/{id}/dismiss
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 4 || pathParts[3] != "dismiss" {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+
+	insightID, err := strconv.ParseInt(pathParts[2], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid insight id"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.insightRepo.Dismiss(insightID, userID); err != nil {
+		http.Error(w, `{"error":"insight not found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "insight dismissed"})
+}
@@ -0,0 +1,666 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"myexpress-tracker/internal/amortization"
+	"myexpress-tracker/internal/middleware"
+	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/repository"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxLoanTermMonths is the longest loan term accepted
+	maxLoanTermMonths = amortization.MaxTermMonths
+	// maxLumpSums caps the one-off payments in a what-if simulation
+	maxLumpSums = 100
+)
+
+// LoanHandler handles loan, loan payment and amortization requests
+type LoanHandler struct {
+	loanRepo     *repository.LoanRepository
+	expenseRepo  *repository.ExpenseRepository
+	categoryRepo *repository.CategoryRepository
+}
+
+// NewLoanHandler creates a new loan handler
+func NewLoanHandler(loanRepo *repository.LoanRepository, expenseRepo *repository.ExpenseRepository, categoryRepo *repository.CategoryRepository) *LoanHandler {
+	return &LoanHandler{
+		loanRepo:     loanRepo,
+		expenseRepo:  expenseRepo,
+		categoryRepo: categoryRepo,
+	}
+}
+
+// LoanPaymentRequest records a payment on a loan
+type LoanPaymentRequest struct {
+	Amount        float64 `json:"amount"`       // Defaults to the loan's regular payment
+	PaymentDate   string  `json:"payment_date"` // Defaults to today
+	Note          string  `json:"note"`
+	RecordExpense bool    `json:"record_expense"` // Also add the payment as an expense in the loan's category
+	Account       string  `json:"account"`        // Account of the recorded expense
+}
+
+// WhatIfRequest describes extra payments to simulate on a loan
+type WhatIfRequest struct {
+	ExtraPayment float64 `json:"extra_payment"` // Added to every regular payment
+	LumpSums     []struct {
+		Date   string  `json:"date"`
+		Amount float64 `json:"amount"`
+	} `json:"lump_sums"`
+}
+
+// WhatIfResponse compares a loan's remaining schedule with and without extra payments
+type WhatIfResponse struct {
+	Baseline      *amortization.Schedule `json:"baseline"`
+	Scenario      *amortization.Schedule `json:"scenario"`
+	InterestSaved float64                `json:"interest_saved"`
+	PaymentsSaved int                    `json:"payments_saved"`
+}
+
+// GetLoans retrieves the user's loans with their status
+func (h *LoanHandler) GetLoans(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	loans, err := h.loanRepo.GetByUser(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch loans"}`, http.StatusInternalServerError)
+		return
+	}
+	if loans == nil {
+		loans = []models.Loan{}
+	}
+	for i := range loans {
+		if err := h.attachStatus(&loans[i]); err != nil {
+			http.Error(w, `{"error":"failed to calculate loan status"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(loans)
+}
+
+// GetLoan retrieves a loan with its status
+func (h *LoanHandler) GetLoan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	loanID, ok := loanIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	h.writeLoan(w, loanID, userID, http.StatusOK)
+}
+
+// CreateLoan creates a new loan
+func (h *LoanHandler) CreateLoan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var loan models.Loan
+	if err := json.NewDecoder(r.Body).Decode(&loan); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if !h.validateLoan(w, &loan, userID) {
+		return
+	}
+
+	loan.UserID = userID
+
+	if err := h.loanRepo.Create(&loan); err != nil {
+		http.Error(w, `{"error":"failed to create loan"}`, http.StatusInternalServerError)
+		return
+	}
+
+	h.writeLoan(w, loan.ID, userID, http.StatusCreated)
+}
+
+// UpdateLoan updates a loan's terms
+func (h *LoanHandler) UpdateLoan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	loanID, ok := loanIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var loan models.Loan
+	if err := json.NewDecoder(r.Body).Decode(&loan); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if !h.validateLoan(w, &loan, userID) {
+		return
+	}
+
+	loan.ID = loanID
+	loan.UserID = userID
+
+	if err := h.loanRepo.Update(&loan); err != nil {
+		http.Error(w, `{"error":"failed to update loan"}`, http.StatusInternalServerError)
+		return
+	}
+
+	h.writeLoan(w, loanID, userID, http.StatusOK)
+}
+
+// DeleteLoan deletes a loan and its payments
+func (h *LoanHandler) DeleteLoan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	loanID, ok := loanIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.loanRepo.Delete(loanID, userID); err != nil {
+		http.Error(w, `{"error":"failed to delete loan"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "loan deleted successfully"})
+}
+
+// GetSchedule retrieves the amortization schedule of the rest of a loan, or of
+// the whole loan as originally agreed with ?original=true
+func (h *LoanHandler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	loan, ok := h.findLoan(w, r, userID)
+	if !ok {
+		return
+	}
+
+	var remaining amortization.Loan
+	if r.URL.Query().Get("original") == "true" {
+		start, _ := time.Parse("2006-01-02", loan.StartDate)
+		remaining = amortization.Loan{
+			Balance:    loan.Principal,
+			AnnualRate: loan.AnnualRate,
+			Frequency:  loan.Frequency,
+			Payment:    loan.PaymentAmount,
+			FirstDate:  start,
+		}
+	} else {
+		var err error
+		remaining, err = h.remainingLoan(loan)
+		if err != nil {
+			http.Error(w, `{"error":"failed to calculate loan balance"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	schedule, err := amortization.Build(remaining, amortization.Extra{})
+	if err != nil {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedule)
+}
+
+// WhatIf simulates paying the rest of a loan off faster with extra payments
+func (h *LoanHandler) WhatIf(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	loan, ok := h.findLoan(w, r, userID)
+	if !ok {
+		return
+	}
+
+	var req WhatIfRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if req.ExtraPayment < 0 {
+		http.Error(w, `{"error":"extra_payment cannot be negative"}`, http.StatusBadRequest)
+		return
+	}
+	if len(req.LumpSums) > maxLumpSums {
+		http.Error(w, fmt.Sprintf(`{"error":"at most %d lump_sums can be simulated"}`, maxLumpSums), http.StatusBadRequest)
+		return
+	}
+
+	extra := amortization.Extra{PerPayment: req.ExtraPayment}
+	for _, lumpSum := range req.LumpSums {
+		date, err := time.Parse("2006-01-02", lumpSum.Date)
+		if err != nil || lumpSum.Amount <= 0 {
+			http.Error(w, `{"error":"each lump sum needs a date (YYYY-MM-DD) and an amount (>0)"}`, http.StatusBadRequest)
+			return
+		}
+		extra.LumpSums = append(extra.LumpSums, amortization.LumpSum{Date: date, Amount: lumpSum.Amount})
+	}
+
+	remaining, err := h.remainingLoan(loan)
+	if err != nil {
+		http.Error(w, `{"error":"failed to calculate loan balance"}`, http.StatusInternalServerError)
+		return
+	}
+
+	baseline, err := amortization.Build(remaining, amortization.Extra{})
+	if err != nil {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+	scenario, err := amortization.Build(remaining, extra)
+	if err != nil {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+
+	response := WhatIfResponse{
+		Baseline:      baseline,
+		Scenario:      scenario,
+		InterestSaved: round2(baseline.TotalInterest - scenario.TotalInterest),
+		PaymentsSaved: baseline.Payments - scenario.Payments,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetPayments retrieves the payments recorded on a loan
+func (h *LoanHandler) GetPayments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	loan, ok := h.findLoan(w, r, userID)
+	if !ok {
+		return
+	}
+
+	payments, err := h.loanRepo.GetPayments(loan.ID, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch loan payments"}`, http.StatusInternalServerError)
+		return
+	}
+	if payments == nil {
+		payments = []models.LoanPayment{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payments)
+}
+
+// AddPayment records a payment on a loan, split into principal and interest,
+// and optionally adds it as an expense
+func (h *LoanHandler) AddPayment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	loan, ok := h.findLoan(w, r, userID)
+	if !ok {
+		return
+	}
+
+	var req LoanPaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if req.Amount == 0 {
+		req.Amount = loan.PaymentAmount
+	}
+	if req.Amount < 0 {
+		http.Error(w, `{"error":"amount must be more than zero"}`, http.StatusBadRequest)
+		return
+	}
+	if req.PaymentDate == "" {
+		req.PaymentDate = time.Now().Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", req.PaymentDate); err != nil {
+		http.Error(w, `{"error":"payment_date must be YYYY-MM-DD"}`, http.StatusBadRequest)
+		return
+	}
+	if req.RecordExpense && loan.CategoryID == nil {
+		http.Error(w, `{"error":"set the loan's category_id to record payments as expenses"}`, http.StatusBadRequest)
+		return
+	}
+
+	payment := models.LoanPayment{
+		PaymentDate: req.PaymentDate,
+		Amount:      req.Amount,
+		Note:        strings.TrimSpace(req.Note),
+	}
+
+	var err error
+	if req.RecordExpense {
+		expense := &models.Expense{
+			UserID:      userID,
+			CategoryID:  *loan.CategoryID,
+			Amount:      req.Amount,
+			Description: loan.Name + " payment",
+			ExpenseDate: req.PaymentDate,
+			Account:     strings.TrimSpace(req.Account),
+		}
+		err = h.expenseRepo.CreateLoanPayment(r.Context(), loan, &payment, expense)
+	} else {
+		err = h.loanRepo.AddPayment(loan, &payment)
+	}
+	if err == repository.ErrOverpayment {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to record loan payment"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(payment)
+}
+
+// DeletePayment deletes a payment from a loan. An expense recorded for it is kept.
+func (h *LoanHandler) DeletePayment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	// /api/loans/{id}/payments/{payment_id}
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 5 {
+		http.Error(w, `{"error":"payment id required"}`, http.StatusBadRequest)
+		return
+	}
+	loanID, err := strconv.ParseInt(pathParts[2], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid loan id"}`, http.StatusBadRequest)
+		return
+	}
+	paymentID, err := strconv.ParseInt(pathParts[4], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid payment id"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.loanRepo.DeletePayment(paymentID, loanID, userID); err != nil {
+		http.Error(w, `{"error":"failed to delete loan payment"}`, http.StatusInternalServerError)
+		return
+	}
+
+	h.writeLoan(w, loanID, userID, http.StatusOK)
+}
+
+// validateLoan checks a loan's terms and fills in defaults, writing an error
+// response on failure
+func (h *LoanHandler) validateLoan(w http.ResponseWriter, loan *models.Loan, userID int64) bool {
+	loan.Name = strings.TrimSpace(loan.Name)
+	loan.Lender = strings.TrimSpace(loan.Lender)
+	if loan.Name == "" || loan.Principal <= 0 || loan.StartDate == "" {
+		http.Error(w, `{"error":"name, principal (>0) and start_date are required"}`, http.StatusBadRequest)
+		return false
+	}
+
+	start, err := time.Parse("2006-01-02", loan.StartDate)
+	if err != nil {
+		http.Error(w, `{"error":"start_date must be YYYY-MM-DD"}`, http.StatusBadRequest)
+		return false
+	}
+
+	if loan.AnnualRate < 0 || loan.AnnualRate > 100 {
+		http.Error(w, `{"error":"annual_rate must be between 0 and 100"}`, http.StatusBadRequest)
+		return false
+	}
+
+	if loan.TermMonths <= 0 || loan.TermMonths > maxLoanTermMonths {
+		http.Error(w, fmt.Sprintf(`{"error":"term_months must be between 1 and %d"}`, maxLoanTermMonths), http.StatusBadRequest)
+		return false
+	}
+
+	switch loan.Frequency {
+	case "":
+		loan.Frequency = amortization.Monthly
+	case amortization.Monthly, amortization.Biweekly, amortization.Weekly:
+	default:
+		http.Error(w, `{"error":"frequency must be monthly, biweekly or weekly"}`, http.StatusBadRequest)
+		return false
+	}
+
+	if loan.PaymentAmount < 0 {
+		http.Error(w, `{"error":"payment_amount cannot be negative"}`, http.StatusBadRequest)
+		return false
+	}
+	if loan.PaymentAmount == 0 {
+		periods := amortization.Periods(loan.TermMonths, loan.Frequency)
+		loan.PaymentAmount = amortization.PaymentFor(loan.Principal, loan.AnnualRate, loan.Frequency, periods)
+	}
+
+	// A custom payment must be enough to ever pay the loan off
+	_, err = amortization.Build(amortization.Loan{
+		Balance:    loan.Principal,
+		AnnualRate: loan.AnnualRate,
+		Frequency:  loan.Frequency,
+		Payment:    loan.PaymentAmount,
+		FirstDate:  start,
+	}, amortization.Extra{})
+	if err != nil {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return false
+	}
+
+	// Loans are personal, so repayments go to a personal expense category
+	if loan.CategoryID != nil {
+		category, err := h.categoryRepo.GetByID(*loan.CategoryID, repository.Personal(userID))
+		if err != nil || category == nil || category.Type != "expense" {
+			http.Error(w, `{"error":"invalid expense category"}`, http.StatusBadRequest)
+			return false
+		}
+	}
+
+	return true
+}
+
+// findLoan loads the loan named in the path, writing an error response when it
+// cannot be found
+func (h *LoanHandler) findLoan(w http.ResponseWriter, r *http.Request, userID int64) (*models.Loan, bool) {
+	loanID, ok := loanIDFromPath(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	loan, err := h.loanRepo.GetByID(loanID, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch loan"}`, http.StatusInternalServerError)
+		return nil, false
+	}
+	if loan == nil {
+		http.Error(w, `{"error":"loan not found"}`, http.StatusNotFound)
+		return nil, false
+	}
+
+	return loan, true
+}
+
+// remainingLoan describes what is left of a loan after the payments recorded so
+// far, due from the first scheduled payment date after the latest payment
+func (h *LoanHandler) remainingLoan(loan *models.Loan) (amortization.Loan, error) {
+	principalPaid, _, _, lastDate, err := h.loanRepo.Paid(loan.ID)
+	if err != nil {
+		return amortization.Loan{}, err
+	}
+
+	next, _ := time.Parse("2006-01-02", loan.StartDate)
+	if last, err := time.Parse("2006-01-02", lastDate); err == nil {
+		first := next
+		for n := 1; !next.After(last); n++ {
+			next = amortization.DateOf(first, loan.Frequency, n)
+		}
+	}
+
+	return amortization.Loan{
+		Balance:    round2(loan.Principal - principalPaid),
+		AnnualRate: loan.AnnualRate,
+		Frequency:  loan.Frequency,
+		Payment:    loan.PaymentAmount,
+		FirstDate:  next,
+	}, nil
+}
+
+// attachStatus works out where a loan stands after the payments recorded so far
+func (h *LoanHandler) attachStatus(loan *models.Loan) error {
+	principalPaid, interestPaid, payments, _, err := h.loanRepo.Paid(loan.ID)
+	if err != nil {
+		return err
+	}
+
+	remaining, err := h.remainingLoan(loan)
+	if err != nil {
+		return err
+	}
+
+	status := &models.LoanStatus{
+		Balance:       remaining.Balance,
+		PrincipalPaid: principalPaid,
+		InterestPaid:  interestPaid,
+		PaymentsMade:  payments,
+	}
+
+	// Terms changed after payments were recorded may no longer pay the rest off
+	if schedule, err := amortization.Build(remaining, amortization.Extra{}); err == nil && schedule.Payments > 0 {
+		status.NextPaymentDate = schedule.Rows[0].Date
+		status.RemainingPayments = schedule.Payments
+		status.RemainingInterest = schedule.TotalInterest
+		status.PayoffDate = schedule.PayoffDate
+	}
+
+	loan.Status = status
+	return nil
+}
+
+// writeLoan responds with a stored loan and its status
+func (h *LoanHandler) writeLoan(w http.ResponseWriter, loanID, userID int64, status int) {
+	loan, err := h.loanRepo.GetByID(loanID, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch loan"}`, http.StatusInternalServerError)
+		return
+	}
+	if loan == nil {
+		http.Error(w, `{"error":"loan not found"}`, http.StatusNotFound)
+		return
+	}
+
+	if err := h.attachStatus(loan); err != nil {
+		http.Error(w, `{"error":"failed to calculate loan status"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(loan)
+}
+
+// loanIDFromPath extracts the loan id from /api/loans/{id}[/...]
+func loanIDFromPath(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 3 {
+		http.Error(w, `{"error":"loan id required"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	loanID, err := strconv.ParseInt(pathParts[2], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid loan id"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	return loanID, true
+}
@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"myexpress-tracker/internal/middleware"
+	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/notify"
+	"myexpress-tracker/internal/repository"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultNotificationLimit is how many notifications are listed by default
+	defaultNotificationLimit = 50
+	// maxNotificationLimit caps the notifications and deliveries listed at once
+	maxNotificationLimit = 200
+)
+
+// NotificationHandler handles notification inbox, preference and settings requests
+type NotificationHandler struct {
+	notificationRepo *repository.NotificationRepository
+	notifyService    *notify.Service
+}
+
+// NewNotificationHandler creates a new notification handler
+func NewNotificationHandler(notificationRepo *repository.NotificationRepository, notifyService *notify.Service) *NotificationHandler {
+	return &NotificationHandler{
+		notificationRepo: notificationRepo,
+		notifyService:    notifyService,
+	}
+}
+
+// TestNotificationRequest picks the channels to send a test notification on
+type TestNotificationRequest struct {
+	Channels []string `json:"channels"` // Defaults to every channel
+}
+
+// GetNotifications retrieves the user's inbox, newest first. ?unread=true
+// lists only unread notifications and ?limit= caps the list.
+func (h *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	limit, ok := notificationLimit(w, r)
+	if !ok {
+		return
+	}
+
+	notifications, err := h.notificationRepo.GetByUser(userID, r.URL.Query().Get("unread") == "true", limit)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch notifications"}`, http.StatusInternalServerError)
+		return
+	}
+	if notifications == nil {
+		notifications = []models.Notification{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(notifications)
+}
+
+// GetUnreadCount counts the unread notifications in the user's inbox
+func (h *NotificationHandler) GetUnreadCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	count, err := h.notificationRepo.UnreadCount(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to count notifications"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"unread": count})
+}
+
+// MarkRead marks a notification as read
+func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	// Path format: /api/notifications/{id}/read
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 4 || pathParts[3] != "read" {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+
+	notificationID, err := strconv.ParseInt(pathParts[2], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid notification id"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.notificationRepo.MarkRead(notificationID, userID); err != nil {
+		http.Error(w, `{"error":"notification not found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "notification marked read"})
+}
+
+// MarkAllRead marks every notification in the user's inbox as read
+func (h *NotificationHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	marked, err := h.notificationRepo.MarkAllRead(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to mark notifications read"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"marked": marked})
+}
+
+// GetPreferences retrieves the user's channels for every event type
+func (h *NotificationHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	h.writePreferences(w, userID)
+}
+
+// UpdatePreferences sets the user's channels for the event types given.
+// Event types left out keep their preferences.
+func (h *NotificationHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var preferences []models.NotificationPreference
+	if err := json.NewDecoder(r.Body).Decode(&preferences); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, preference := range preferences {
+		if !isNotificationEvent(preference.Event) {
+			http.Error(w, fmt.Sprintf(`{"error":"event must be one of %s"}`, strings.Join(notify.Events, ", ")), http.StatusBadRequest)
+			return
+		}
+		if seen[preference.Event] {
+			http.Error(w, `{"error":"each event can only be listed once"}`, http.StatusBadRequest)
+			return
+		}
+		seen[preference.Event] = true
+
+		if preference.Email && !h.notifyService.Available(models.ChannelEmail) {
+			http.Error(w, `{"error":"email notifications are not configured on this server"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.notificationRepo.SavePreferences(userID, preferences); err != nil {
+		http.Error(w, `{"error":"failed to save notification preferences"}`, http.StatusInternalServerError)
+		return
+	}
+
+	h.writePreferences(w, userID)
+}
+
+// GetSettings retrieves where the user's notifications are delivered
+func (h *NotificationHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	settings, err := h.notificationRepo.GetSettings(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch notification settings"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// UpdateSettings sets where the user's notifications are delivered
+func (h *NotificationHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var settings models.NotificationSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	settings.Email = strings.TrimSpace(settings.Email)
+	settings.WebhookURL = strings.TrimSpace(settings.WebhookURL)
+	if settings.Email != "" {
+		if address, err := mail.ParseAddress(settings.Email); err != nil || address.Address != settings.Email {
+			http.Error(w, `{"error":"invalid email address"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	if settings.WebhookURL != "" && !validateTargetURL(w, r, "webhook_url", settings.WebhookURL) {
+		return
+	}
+	if settings.DigestHour < 0 || settings.DigestHour > 23 {
+		http.Error(w, `{"error":"digest_hour must be between 0 and 23"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.notificationRepo.SaveSettings(userID, &settings); err != nil {
+		http.Error(w, `{"error":"failed to save notification settings"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// GetDeliveries lists the user's recent email and webhook deliveries with
+// their status, attempts and last error
+func (h *NotificationHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	limit, ok := notificationLimit(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries, err := h.notificationRepo.GetDeliveries(userID, limit)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch notification deliveries"}`, http.StatusInternalServerError)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []models.NotificationDelivery{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// SendTest sends a test notification right away on the chosen channels,
+// ignoring preferences and digest mode
+func (h *NotificationHandler) SendTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req TestNotificationRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	chosen := len(req.Channels) > 0
+	if !chosen {
+		req.Channels = []string{models.ChannelInApp, models.ChannelEmail, models.ChannelWebhook}
+	}
+
+	preference := models.NotificationPreference{Event: models.NotifyTest}
+	for _, channel := range req.Channels {
+		switch channel {
+		case models.ChannelInApp:
+			preference.InApp = true
+		case models.ChannelEmail:
+			if chosen && !h.notifyService.Available(channel) {
+				http.Error(w, `{"error":"email notifications are not configured on this server"}`, http.StatusBadRequest)
+				return
+			}
+			preference.Email = h.notifyService.Available(channel)
+		case models.ChannelWebhook:
+			preference.Webhook = true
+		default:
+			http.Error(w, `{"error":"channels must be in_app, email or webhook"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	now := time.Now()
+	notification := models.Notification{
+		UserID:    userID,
+		Event:     models.NotifyTest,
+		DedupeKey: fmt.Sprintf("test:%d", now.UnixNano()),
+		Title:     "Test notification",
+		Message:   "Notifications are working. Sent " + now.Format(time.RFC1123) + ".",
+	}
+	if _, err := h.notifyService.Send(&notification, preference); err != nil {
+		http.Error(w, `{"error":"failed to send test notification"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(notification)
+}
+
+// writePreferences responds with the user's preferences for every event type
+func (h *NotificationHandler) writePreferences(w http.ResponseWriter, userID int64) {
+	preferences, err := h.notifyService.Preferences(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch notification preferences"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preferences)
+}
+
+// isNotificationEvent reports whether event is an event type with preferences
+func isNotificationEvent(event string) bool {
+	for _, known := range notify.Events {
+		if event == known {
+			return true
+		}
+	}
+	return false
+}
+
+// notificationLimit parses ?limit=, writing an error response when it is invalid
+func notificationLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return defaultNotificationLimit, true
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxNotificationLimit {
+		http.Error(w, fmt.Sprintf(`{"error":"limit must be between 1 and %d"}`, maxNotificationLimit), http.StatusBadRequest)
+		return 0, false
+	}
+	return limit, true
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxPatchBody bounds the size of a merge patch document
+const maxPatchBody = 1 << 20
+
+// readOnlyFields are ignored when present in a merge patch
+var readOnlyFields = []string{"id", "user_id", "created_at", "updated_at", "deleted_at", "category_name"}
+
+// recordETag derives a record's ETag from its updated_at timestamp
+func recordETag(updatedAt time.Time) string {
+	return fmt.Sprintf(`"%d"`, updatedAt.UnixNano())
+}
+
+// ifMatch checks an If-Match header against a record's ETag, writing 412
+// Precondition Failed when it does not match. A missing header always passes.
+func ifMatch(w http.ResponseWriter, r *http.Request, updatedAt time.Time) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" || etagMatches(header, recordETag(updatedAt)) {
+		return true
+	}
+
+	w.Header().Set("ETag", recordETag(updatedAt))
+	http.Error(w, `{"error":"record has changed; fetch it again and retry"}`, http.StatusPreconditionFailed)
+	return false
+}
+
+// readMergePatch reads a JSON Merge Patch (RFC 7386) document from the request body
+func readMergePatch(r *http.Request) (map[string]interface{}, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPatchBody))
+	if err != nil {
+		return nil, err
+	}
+
+	var patch map[string]interface{}
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		return nil, errors.New("merge patch must be a JSON object")
+	}
+
+	for _, name := range readOnlyFields {
+		delete(patch, name)
+	}
+	return patch, nil
+}
+
+// applyMergePatch applies a merge patch to original and decodes the result into out
+func applyMergePatch(original interface{}, patch map[string]interface{}, out interface{}) error {
+	data, err := json.Marshal(original)
+	if err != nil {
+		return err
+	}
+
+	var target map[string]interface{}
+	if err := json.Unmarshal(data, &target); err != nil {
+		return err
+	}
+
+	merged, err := json.Marshal(mergePatch(target, patch))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(merged, out)
+}
+
+// mergePatch implements the RFC 7386 merge algorithm: objects merge
+// recursively, null removes a member and any other value replaces it
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = mergePatch(targetObject[name], value)
+	}
+	return targetObject
+}
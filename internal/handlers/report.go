@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"myexpress-tracker/internal/middleware"
+	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/repository"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultTrendMonths = 12
+	maxTrendMonths     = 60
+	maxBiggestMovers   = 5
+)
+
+// ReportHandler handles reporting requests
+type ReportHandler struct {
+	rollupRepo *repository.RollupRepository
+}
+
+// NewReportHandler creates a new report handler
+func NewReportHandler(rollupRepo *repository.RollupRepository) *ReportHandler {
+	return &ReportHandler{
+		rollupRepo: rollupRepo,
+	}
+}
+
+// GetTrends returns monthly totals for the last N months with
+// month-over-month and year-over-year comparisons
+func (h *ReportHandler) GetTrends(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	months := defaultTrendMonths
+	if value := r.URL.Query().Get("months"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxTrendMonths {
+			http.Error(w, `{"error":"months must be between 1 and 60"}`, http.StatusBadRequest)
+			return
+		}
+		months = n
+	}
+
+	// Load twelve extra months so the oldest month still has a YoY baseline
+	now := time.Now()
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	window := make([]string, months)
+	for i := range window {
+		window[i] = current.AddDate(0, i-months+1, 0).Format("2006-01")
+	}
+	from := current.AddDate(0, -months-11, 0).Format("2006-01")
+
+	rollups, err := h.rollupRepo.GetRange(userID, from, window[months-1])
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch report data"}`, http.StatusInternalServerError)
+		return
+	}
+
+	report := buildTrendReport(rollups, window)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// buildTrendReport aggregates rollups into the trend report for the given months
+func buildTrendReport(rollups []models.MonthlyRollup, window []string) models.TrendReport {
+	type categoryKey struct {
+		id  int64
+		typ string
+	}
+
+	income := make(map[string]float64)
+	expense := make(map[string]float64)
+	byCategory := make(map[categoryKey]map[string]float64)
+	names := make(map[categoryKey]string)
+
+	for _, rollup := range rollups {
+		if rollup.Type == "income" {
+			income[rollup.Month] += rollup.Total
+		} else {
+			expense[rollup.Month] += rollup.Total
+		}
+
+		key := categoryKey{rollup.CategoryID, rollup.Type}
+		if byCategory[key] == nil {
+			byCategory[key] = make(map[string]float64)
+		}
+		byCategory[key][rollup.Month] += rollup.Total
+		names[key] = rollup.CategoryName
+	}
+
+	report := models.TrendReport{
+		Months:        []models.MonthlyTrend{},
+		Categories:    []models.CategoryTrend{},
+		BiggestMovers: []models.CategoryMover{},
+	}
+
+	for _, month := range window {
+		prev, lastYear := shiftMonth(month, -1), shiftMonth(month, -12)
+		net := income[month] - expense[month]
+		report.Months = append(report.Months, models.MonthlyTrend{
+			Month:      month,
+			Income:     income[month],
+			Expense:    expense[month],
+			Net:        net,
+			IncomeMoM:  delta(income[month], income[prev]),
+			ExpenseMoM: delta(expense[month], expense[prev]),
+			NetMoM:     delta(net, income[prev]-expense[prev]),
+			IncomeYoY:  delta(income[month], income[lastYear]),
+			ExpenseYoY: delta(expense[month], expense[lastYear]),
+			NetYoY:     delta(net, income[lastYear]-expense[lastYear]),
+		})
+	}
+
+	// Movers compare the last two complete months, since the current month is
+	// still partial. Both are within the twelve months loaded before the window.
+	latest := shiftMonth(window[len(window)-1], -1)
+	previous := shiftMonth(latest, -1)
+
+	for key, totals := range byCategory {
+		trend := models.CategoryTrend{
+			CategoryID:   key.id,
+			CategoryName: names[key],
+			Type:         key.typ,
+		}
+
+		active := false
+		for _, month := range window {
+			if totals[month] != 0 {
+				active = true
+			}
+			trend.Months = append(trend.Months, models.CategoryMonth{
+				Month: month,
+				Total: totals[month],
+				MoM:   delta(totals[month], totals[shiftMonth(month, -1)]),
+				YoY:   delta(totals[month], totals[shiftMonth(month, -12)]),
+			})
+		}
+		if active {
+			report.Categories = append(report.Categories, trend)
+		}
+
+		if key.typ == "expense" && totals[latest] != totals[previous] {
+			report.BiggestMovers = append(report.BiggestMovers, models.CategoryMover{
+				CategoryID:    key.id,
+				CategoryName:  names[key],
+				Month:         latest,
+				PreviousMonth: previous,
+				Current:       totals[latest],
+				Previous:      totals[previous],
+				Change:        delta(totals[latest], totals[previous]),
+			})
+		}
+	}
+
+	sort.Slice(report.Categories, func(i, j int) bool {
+		if report.Categories[i].Type != report.Categories[j].Type {
+			return report.Categories[i].Type < report.Categories[j].Type
+		}
+		return report.Categories[i].CategoryName < report.Categories[j].CategoryName
+	})
+
+	sort.Slice(report.BiggestMovers, func(i, j int) bool {
+		return math.Abs(report.BiggestMovers[i].Change.Amount) > math.Abs(report.BiggestMovers[j].Change.Amount)
+	})
+	if len(report.BiggestMovers) > maxBiggestMovers {
+		report.BiggestMovers = report.BiggestMovers[:maxBiggestMovers]
+	}
+
+	return report
+}
+
+// shiftMonth moves a YYYY-MM month by n months
+func shiftMonth(month string, n int) string {
+	t, err := time.Parse("2006-01", month)
+	if err != nil {
+		return month
+	}
+	return t.AddDate(0, n, 0).Format("2006-01")
+}
+
+// delta computes the change of current against previous
+func delta(current, previous float64) models.TrendDelta {
+	change := models.TrendDelta{Amount: round2(current - previous)}
+	if previous != 0 {
+		percent := round2((current - previous) / math.Abs(previous) * 100)
+		change.Percent = &percent
+	}
+	return change
+}
+
+// round2 rounds a value to two decimal places
+func round2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
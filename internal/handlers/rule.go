@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"encoding/json"
+	"myexpress-tracker/internal/middleware"
+	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/repository"
+	"myexpress-tracker/internal/rules"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxRulePreview caps the matches returned when testing a rule
+const maxRulePreview = 100
+
+// RuleHandler handles categorization rule requests
+type RuleHandler struct {
+	ruleRepo     *repository.RuleRepository
+	expenseRepo  *repository.ExpenseRepository
+	categoryRepo *repository.CategoryRepository
+}
+
+// NewRuleHandler creates a new rule handler
+func NewRuleHandler(ruleRepo *repository.RuleRepository, expenseRepo *repository.ExpenseRepository, categoryRepo *repository.CategoryRepository) *RuleHandler {
+	return &RuleHandler{
+		ruleRepo:     ruleRepo,
+		expenseRepo:  expenseRepo,
+		categoryRepo: categoryRepo,
+	}
+}
+
+// ApplyRulesRequest represents a bulk re-apply request
+type ApplyRulesRequest struct {
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	DryRun    bool   `json:"dry_run"`
+}
+
+// ApplyRulesResponse reports the outcome of a bulk re-apply
+type ApplyRulesResponse struct {
+	Matched int  `json:"matched"`
+	Updated int  `json:"updated"`
+	Failed  int  `json:"failed"`
+	DryRun  bool `json:"dry_run"`
+}
+
+// GetRules retrieves the user's rules in evaluation order
+func (h *RuleHandler) GetRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	ruleList, err := h.ruleRepo.GetByUser(userID, false)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch rules"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ruleList)
+}
+
+// CreateRule creates a new rule
+func (h *RuleHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	rule := models.CategoryRule{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if !h.validateRule(w, &rule, userID) {
+		return
+	}
+
+	rule.UserID = userID
+
+	if err := h.ruleRepo.Create(r.Context(), &rule); err != nil {
+		http.Error(w, `{"error":"failed to create rule"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
+
+// UpdateRule updates an existing rule
+func (h *RuleHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	ruleID, ok := ruleIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	// Omitting enabled keeps the rule enabled, as on create
+	rule := models.CategoryRule{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if !h.validateRule(w, &rule, userID) {
+		return
+	}
+
+	rule.ID = ruleID
+	rule.UserID = userID
+
+	if err := h.ruleRepo.Update(r.Context(), &rule); err != nil {
+		http.Error(w, `{"error":"failed to update rule"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rule)
+}
+
+// DeleteRule deletes a rule
+func (h *RuleHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	ruleID, ok := ruleIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.ruleRepo.Delete(r.Context(), ruleID, userID); err != nil {
+		http.Error(w, `{"error":"failed to delete rule"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "rule deleted successfully"})
+}
+
+// TestRule previews which existing expenses an (unsaved) rule would match and how they would change
+func (h *RuleHandler) TestRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var rule models.CategoryRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	if rule.Name == "" {
+		rule.Name = "preview"
+	}
+
+	if !h.validateRule(w, &rule, userID) {
+		return
+	}
+
+	set, err := rules.Compile([]models.CategoryRule{rule})
+	if err != nil {
+		http.Error(w, `{"error":"invalid rule"}`, http.StatusBadRequest)
+		return
+	}
+
+	expenses, err := h.expenseRepo.GetByUser(repository.Personal(userID), map[string]interface{}{})
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch expenses"}`, http.StatusInternalServerError)
+		return
+	}
+
+	matches := []models.RuleMatch{}
+	total := 0
+	for _, expense := range expenses {
+		if !set.Matches(&expense) {
+			continue
+		}
+		total++
+		if len(matches) >= maxRulePreview {
+			continue
+		}
+
+		after := expense
+		changed := set.Apply(&after, true)
+		matches = append(matches, models.RuleMatch{Expense: expense, After: after, Changed: changed})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total_matches": total,
+		"matches":       matches,
+	})
+}
+
+// ApplyRules re-applies all enabled rules to existing expenses, overriding their categories
+func (h *RuleHandler) ApplyRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req ApplyRulesRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	ruleList, err := h.ruleRepo.GetByUser(userID, true)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch rules"}`, http.StatusInternalServerError)
+		return
+	}
+
+	set, err := rules.Compile(ruleList)
+	if err != nil {
+		http.Error(w, `{"error":"a stored rule is invalid"}`, http.StatusInternalServerError)
+		return
+	}
+
+	filters := map[string]interface{}{
+		"start_date": req.StartDate,
+		"end_date":   req.EndDate,
+	}
+	expenses, err := h.expenseRepo.GetByUser(repository.Personal(userID), filters)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch expenses"}`, http.StatusInternalServerError)
+		return
+	}
+
+	response := ApplyRulesResponse{DryRun: req.DryRun}
+	for _, expense := range expenses {
+		if !set.Matches(&expense) {
+			continue
+		}
+		response.Matched++
+
+		if !set.Apply(&expense, true) || req.DryRun {
+			continue
+		}
+
+		if err := h.expenseRepo.Update(r.Context(), &expense); err != nil {
+			response.Failed++
+			continue
+		}
+		response.Updated++
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// validateRule validates a rule and its target category, writing an error response on failure
+func (h *RuleHandler) validateRule(w http.ResponseWriter, rule *models.CategoryRule, userID int64) bool {
+	if err := rules.Validate(rule); err != nil {
+		body, _ := json.Marshal(map[string]string{"error": err.Error()})
+		http.Error(w, string(body), http.StatusBadRequest)
+		return false
+	}
+
+	if rule.SetCategoryID != nil {
+		category, err := h.categoryRepo.GetByID(*rule.SetCategoryID, repository.Personal(userID))
+		if err != nil || category == nil || category.Type != "expense" {
+			http.Error(w, `{"error":"invalid expense category"}`, http.StatusBadRequest)
+			return false
+		}
+	}
+
+	return true
+}
+
+// ruleIDFromPath parses the rule ID from /api/rules/{id}
+func ruleIDFromPath(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 3 {
+		http.Error(w, `{"error":"rule id required"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	ruleID, err := strconv.ParseInt(pathParts[len(pathParts)-1], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid rule id"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	return ruleID, true
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"encoding/json"
+	"myexpress-tracker/internal/middleware"
+	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/repository"
+	"myexpress-tracker/internal/settleup"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SettlementHandler handles settle-up payments, balances and bills shared with the user
+type SettlementHandler struct {
+	settlementRepo *repository.SettlementRepository
+}
+
+// NewSettlementHandler creates a new settlement handler
+func NewSettlementHandler(settlementRepo *repository.SettlementRepository) *SettlementHandler {
+	return &SettlementHandler{settlementRepo: settlementRepo}
+}
+
+// GetSettlements retrieves the settlements the user recorded or took part in
+func (h *SettlementHandler) GetSettlements(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	settlements, err := h.settlementRepo.GetByUser(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch settlements"}`, http.StatusInternalServerError)
+		return
+	}
+	if settlements == nil {
+		settlements = []models.Settlement{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settlements)
+}
+
+// CreateSettlement records a payment that settles (part of) a debt
+func (h *SettlementHandler) CreateSettlement(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var settlement models.Settlement
+	if err := json.NewDecoder(r.Body).Decode(&settlement); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if settlement.Amount <= 0 {
+		http.Error(w, `{"error":"amount (>0) is required"}`, http.StatusBadRequest)
+		return
+	}
+	if settlement.From == nil && settlement.To == nil {
+		http.Error(w, `{"error":"from or to is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	if settlement.SettledOn == "" {
+		settlement.SettledOn = time.Now().Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", settlement.SettledOn); err != nil {
+		http.Error(w, `{"error":"settled_on must be YYYY-MM-DD"}`, http.StatusBadRequest)
+		return
+	}
+
+	settlement.RecordedBy = userID
+
+	err := h.settlementRepo.Create(&settlement)
+	if err == repository.ErrSettlementParties || err == repository.ErrParticipantNotFound {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to record settlement"}`, http.StatusInternalServerError)
+		return
+	}
+
+	created, err := h.settlementRepo.GetByID(settlement.ID, userID)
+	if err != nil || created == nil {
+		http.Error(w, `{"error":"failed to fetch settlement"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// DeleteSettlement deletes a settlement the user recorded
+func (h *SettlementHandler) DeleteSettlement(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 3 {
+		http.Error(w, `{"error":"settlement id required"}`, http.StatusBadRequest)
+		return
+	}
+
+	settlementID, err := strconv.ParseInt(pathParts[len(pathParts)-1], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid settlement id"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.settlementRepo.Delete(settlementID, userID); err != nil {
+		http.Error(w, `{"error":"failed to delete settlement"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "settlement deleted successfully"})
+}
+
+// GetBalances reports who owes the user and whom the user owes, with the
+// fewest payments that would settle everything up
+func (h *SettlementHandler) GetBalances(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	debts, err := h.settlementRepo.Debts(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to calculate balances"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settleup.Summarize(userID, debts))
+}
+
+// GetSharedWithMe lists the bills other users shared with the user and the user's share of each
+func (h *SettlementHandler) GetSharedWithMe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	bills, err := h.settlementRepo.SharedWith(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch shared expenses"}`, http.StatusInternalServerError)
+		return
+	}
+	if bills == nil {
+		bills = []models.SharedWithMe{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bills)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"myexpress-tracker/internal/middleware"
+	"myexpress-tracker/internal/stream"
+	"net/http"
+	"time"
+)
+
+const (
+	// heartbeatInterval is how often an idle stream sends a comment to keep
+	// proxies from closing it
+	heartbeatInterval = 15 * time.Second
+	// retryInterval is how long browsers wait before reconnecting, in milliseconds
+	retryInterval = 3000
+)
+
+// StreamHandler handles server-sent event streams of a user's changes
+type StreamHandler struct {
+	broker *stream.Broker
+}
+
+// NewStreamHandler creates a new stream handler
+func NewStreamHandler(broker *stream.Broker) *StreamHandler {
+	return &StreamHandler{broker: broker}
+}
+
+// Stream sends the user's change and summary events as server-sent events
+// until the client disconnects. A client that reconnects with Last-Event-ID
+// is sent the events it missed, or a reset event when they are no longer
+// known. Every connection then gets the current totals.
+func (h *StreamHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, `{"error":"streaming not supported"}`, http.StatusInternalServerError)
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	sub := h.broker.Subscribe(userID, lastEventID)
+	defer sub.Cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", retryInterval)
+	if sub.Reset {
+		writeEvent(w, stream.Event{Type: stream.EventReset, Data: []byte("{}")})
+	}
+	for _, event := range sub.Replay {
+		writeEvent(w, event)
+	}
+	flusher.Flush()
+
+	if err := h.broker.PublishSummary(userID); err != nil {
+		log.Printf("stream: failed to publish summary for user %d: %v", userID, err)
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				// Too far behind; the client reconnects and resumes
+				return
+			}
+			writeEvent(w, event)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes an event in the server-sent events format. Events without
+// an ID leave the client's last event ID unchanged.
+func writeEvent(w http.ResponseWriter, event stream.Event) {
+	if event.ID != "" {
+		fmt.Fprintf(w, "id: %s\n", event.ID)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, event.Data)
+}
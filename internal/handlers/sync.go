@@ -0,0 +1,635 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"myexpress-tracker/internal/middleware"
+	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/repository"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultSyncLimit is the page size of the change feed when none is given
+	defaultSyncLimit = 500
+	// maxSyncLimit caps the page size of the change feed
+	maxSyncLimit = 1000
+	// maxSyncPush caps the changes in one push
+	maxSyncPush = 500
+)
+
+// uuidPattern matches a UUID in its canonical textual form
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// SyncHandler handles the change feed and batch pushes of offline clients
+type SyncHandler struct {
+	syncRepo     *repository.SyncRepository
+	incomeRepo   *repository.IncomeRepository
+	expenseRepo  *repository.ExpenseRepository
+	categoryRepo *repository.CategoryRepository
+	userRepo     *repository.UserRepository
+}
+
+// NewSyncHandler creates a new sync handler
+func NewSyncHandler(syncRepo *repository.SyncRepository, incomeRepo *repository.IncomeRepository, expenseRepo *repository.ExpenseRepository, categoryRepo *repository.CategoryRepository, userRepo *repository.UserRepository) *SyncHandler {
+	return &SyncHandler{
+		syncRepo:     syncRepo,
+		incomeRepo:   incomeRepo,
+		expenseRepo:  expenseRepo,
+		categoryRepo: categoryRepo,
+		userRepo:     userRepo,
+	}
+}
+
+// GetChanges returns the changes to the user's personal income and expenses,
+// the categories and their settings after the ?since token, oldest first.
+// Without a token it returns everything, leaving out tombstones.
+func (h *SyncHandler) GetChanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var since int64
+	if token := r.URL.Query().Get("since"); token != "" {
+		var err error
+		since, err = strconv.ParseInt(token, 10, 64)
+		if err != nil || since < 0 {
+			http.Error(w, `{"error":"since must be a token from an earlier response"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := defaultSyncLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxSyncLimit {
+			http.Error(w, fmt.Sprintf(`{"error":"limit must be between 1 and %d"}`, maxSyncLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries, err := h.syncRepo.Changes(userID, since, limit+1)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch changes"}`, http.StatusInternalServerError)
+		return
+	}
+
+	feed := models.SyncFeed{Changes: []models.SyncChange{}, Token: strconv.FormatInt(since, 10)}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		feed.HasMore = true
+	}
+
+	for _, entry := range entries {
+		data, err := h.load(userID, entry.Entity, entry.EntityID)
+		if err != nil {
+			http.Error(w, `{"error":"failed to fetch changes"}`, http.StatusInternalServerError)
+			return
+		}
+		feed.Token = strconv.FormatInt(entry.Version, 10)
+
+		// A client syncing from scratch has nothing to delete
+		if data == nil && since == 0 {
+			continue
+		}
+
+		feed.Changes = append(feed.Changes, models.SyncChange{
+			Entity:  entry.Entity,
+			ID:      entry.EntityID,
+			UUID:    entry.UUID,
+			Version: entry.Version,
+			Deleted: data == nil,
+			Data:    data,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(feed)
+}
+
+// Push applies a batch of changes made on a client. Each change succeeds or
+// fails on its own, and changes to records modified on the server since the
+// client's base_version are resolved by the on_conflict rule.
+func (h *SyncHandler) Push(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req models.SyncPushRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Changes) == 0 {
+		http.Error(w, `{"error":"changes are required"}`, http.StatusBadRequest)
+		return
+	}
+	if len(req.Changes) > maxSyncPush {
+		http.Error(w, fmt.Sprintf(`{"error":"a push can have at most %d changes"}`, maxSyncPush), http.StatusBadRequest)
+		return
+	}
+	if req.OnConflict == "" {
+		req.OnConflict = models.SyncServerWins
+	}
+	if !validResolution(req.OnConflict) {
+		http.Error(w, `{"error":"on_conflict must be server_wins or client_wins"}`, http.StatusBadRequest)
+		return
+	}
+
+	response := models.SyncPushResponse{Results: make([]models.SyncPushResult, 0, len(req.Changes))}
+	for _, change := range req.Changes {
+		result := h.apply(r.Context(), userID, change, req.OnConflict)
+
+		switch result.Status {
+		case models.SyncApplied:
+			response.Applied++
+		case models.SyncUnchanged:
+			response.Unchanged++
+		case models.SyncConflict:
+			response.Conflicts++
+		default:
+			response.Failed++
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// apply applies one pushed change
+func (h *SyncHandler) apply(ctx context.Context, userID int64, change models.SyncPushChange, onConflict string) models.SyncPushResult {
+	change.UUID = strings.ToLower(strings.TrimSpace(change.UUID))
+	result := models.SyncPushResult{Entity: change.Entity, UUID: change.UUID, ID: change.ID}
+
+	if change.OnConflict == "" {
+		change.OnConflict = onConflict
+	}
+	if !validResolution(change.OnConflict) {
+		return failed(result, "on_conflict must be server_wins or client_wins")
+	}
+	if change.Op != models.SyncUpsert && change.Op != models.SyncDelete {
+		return failed(result, "op must be upsert or delete")
+	}
+
+	var err error
+	switch change.Entity {
+	case models.SyncIncome, models.SyncExpense:
+		result, err = h.applyRecord(ctx, userID, change, result)
+	case models.SyncSettings:
+		result, err = h.applySettings(userID, change, result)
+	default:
+		return failed(result, "entity must be income, expense or settings")
+	}
+	if err != nil {
+		return failed(result, "internal error")
+	}
+
+	return result
+}
+
+// applyRecord applies a pushed change to an income or expense record
+func (h *SyncHandler) applyRecord(ctx context.Context, userID int64, change models.SyncPushChange, result models.SyncPushResult) (models.SyncPushResult, error) {
+	if change.UUID != "" && !uuidPattern.MatchString(change.UUID) {
+		return failed(result, "uuid must be a UUID"), nil
+	}
+
+	id := change.ID
+	if change.UUID != "" {
+		entity, uuidID, err := h.syncRepo.ByUUID(userID, change.UUID)
+		if err != nil {
+			return result, err
+		}
+		if entity != "" && entity != change.Entity {
+			return failed(result, "uuid names a "+entity+" record"), nil
+		}
+		if entity != "" && change.ID != 0 && change.ID != uuidID {
+			return failed(result, "uuid and id name different records"), nil
+		}
+		if entity != "" {
+			id = uuidID
+		}
+	}
+
+	// Records the server has never seen are created
+	if id == 0 {
+		if change.Op == models.SyncDelete {
+			return with(result, models.SyncUnchanged), nil
+		}
+		if change.UUID == "" {
+			return failed(result, "uuid is required for new records"), nil
+		}
+		return h.createRecord(ctx, userID, change, result)
+	}
+	result.ID = id
+
+	state, err := h.syncRepo.State(change.Entity, id, userID)
+	if err != nil {
+		return result, err
+	}
+	version, err := h.syncRepo.Version(change.Entity, id)
+	if err != nil {
+		return result, err
+	}
+	result.Version = version
+
+	if change.Op == models.SyncDelete {
+		if state != repository.RecordLive {
+			return h.finish(with(result, models.SyncUnchanged))
+		}
+		if version > change.BaseVersion && change.OnConflict == models.SyncServerWins {
+			return h.conflict(userID, result, models.SyncModified)
+		}
+		if err := h.deleteRecord(ctx, userID, change.Entity, id); err != nil {
+			return result, err
+		}
+		return h.finish(with(result, models.SyncApplied))
+	}
+
+	record, message := h.parseRecord(userID, change.Entity, change.Data)
+	if message != "" {
+		return failed(result, message), nil
+	}
+
+	switch state {
+	case repository.RecordGone:
+		if change.OnConflict == models.SyncServerWins {
+			return h.conflict(userID, result, models.SyncDeleted)
+		}
+		result.ID = 0
+		return h.createRecord(ctx, userID, change, result)
+	case repository.RecordTrashed:
+		if change.OnConflict == models.SyncServerWins {
+			return h.conflict(userID, result, models.SyncDeleted)
+		}
+		if err := h.restoreRecord(ctx, userID, change.Entity, id); err != nil {
+			return result, err
+		}
+	}
+
+	current, updatedAt, err := h.current(userID, change.Entity, id)
+	if err != nil {
+		return result, err
+	}
+	if current == nil {
+		return h.conflict(userID, result, models.SyncDeleted)
+	}
+	if sameRecord(current, record) && state == repository.RecordLive {
+		return h.finish(with(result, models.SyncUnchanged))
+	}
+	if version > change.BaseVersion && change.OnConflict == models.SyncServerWins {
+		return h.conflict(userID, result, models.SyncModified)
+	}
+
+	err = h.updateRecord(ctx, userID, id, record, updatedAt)
+	if err == repository.ErrModified {
+		return h.conflict(userID, result, models.SyncModified)
+	}
+	if message := inputError(err); message != "" {
+		return failed(result, message), nil
+	}
+	if err != nil {
+		return result, err
+	}
+
+	return h.finish(with(result, models.SyncApplied))
+}
+
+// createRecord creates a pushed record under the client's UUID
+func (h *SyncHandler) createRecord(ctx context.Context, userID int64, change models.SyncPushChange, result models.SyncPushResult) (models.SyncPushResult, error) {
+	record, message := h.parseRecord(userID, change.Entity, change.Data)
+	if message != "" {
+		return failed(result, message), nil
+	}
+
+	if change.UUID == "" {
+		return failed(result, "uuid is required to recreate a deleted record"), nil
+	}
+	ctx = repository.WithClientUUID(ctx, change.UUID)
+
+	var err error
+	switch record := record.(type) {
+	case *models.Income:
+		record.UserID = userID
+		err = h.incomeRepo.Create(ctx, record)
+		result.ID = record.ID
+	case *models.Expense:
+		record.UserID = userID
+		err = h.expenseRepo.Create(ctx, record)
+		result.ID = record.ID
+	}
+	if message := inputError(err); message != "" {
+		return failed(result, message), nil
+	}
+	if err != nil {
+		return result, err
+	}
+
+	return h.finish(with(result, models.SyncApplied))
+}
+
+// applySettings applies pushed settings. Fields left empty are kept.
+func (h *SyncHandler) applySettings(userID int64, change models.SyncPushChange, result models.SyncPushResult) (models.SyncPushResult, error) {
+	result.ID = userID
+	if change.Op != models.SyncUpsert {
+		return failed(result, "settings can only be upserted"), nil
+	}
+
+	var settings models.UserSettings
+	if err := json.Unmarshal(change.Data, &settings); err != nil {
+		return failed(result, "data must be a settings object"), nil
+	}
+
+	current, err := h.load(userID, models.SyncSettings, userID)
+	if err != nil {
+		return result, err
+	}
+	existing, _ := current.(*models.UserSettings)
+	if existing == nil {
+		return failed(result, "user not found"), nil
+	}
+
+	version, err := h.syncRepo.Version(models.SyncSettings, userID)
+	if err != nil {
+		return result, err
+	}
+	result.Version = version
+
+	if (settings.Currency == "" || settings.Currency == existing.Currency) && (settings.Theme == "" || settings.Theme == existing.Theme) {
+		return with(result, models.SyncUnchanged), nil
+	}
+	if version > change.BaseVersion && change.OnConflict == models.SyncServerWins {
+		return h.conflict(userID, result, models.SyncModified)
+	}
+
+	if settings.Currency != "" {
+		if err := h.userRepo.UpdateCurrency(userID, settings.Currency); err != nil {
+			return result, err
+		}
+	}
+	if settings.Theme != "" {
+		if err := h.userRepo.UpdateTheme(userID, settings.Theme); err != nil {
+			return result, err
+		}
+	}
+
+	return h.finish(with(result, models.SyncApplied))
+}
+
+// load retrieves the current state of a feed entry's record, or nil when it
+// was deleted
+func (h *SyncHandler) load(userID int64, entity string, id int64) (interface{}, error) {
+	switch entity {
+	case models.SyncIncome:
+		income, err := h.incomeRepo.GetByID(id, repository.Personal(userID))
+		if err != nil || income == nil {
+			return nil, err
+		}
+		return income, nil
+	case models.SyncExpense:
+		expense, err := h.expenseRepo.GetByID(id, repository.Personal(userID))
+		if err != nil || expense == nil {
+			return nil, err
+		}
+		return expense, nil
+	case models.SyncCategory:
+		category, err := h.categoryRepo.GetByID(id, repository.Personal(userID))
+		if err != nil || category == nil {
+			return nil, err
+		}
+		return category, nil
+	case models.SyncSettings:
+		user, err := h.userRepo.GetByID(id)
+		if err != nil || user == nil {
+			return nil, err
+		}
+		return &models.UserSettings{Currency: user.Currency, Theme: user.Theme}, nil
+	}
+
+	return nil, nil
+}
+
+// current loads a live income or expense record with its updated_at
+func (h *SyncHandler) current(userID int64, entity string, id int64) (interface{}, time.Time, error) {
+	record, err := h.load(userID, entity, id)
+	if err != nil || record == nil {
+		return nil, time.Time{}, err
+	}
+
+	switch record := record.(type) {
+	case *models.Income:
+		return record, record.UpdatedAt, nil
+	case *models.Expense:
+		return record, record.UpdatedAt, nil
+	}
+	return nil, time.Time{}, nil
+}
+
+// parseRecord decodes and validates a pushed income or expense record,
+// returning a message for the client when it is invalid
+func (h *SyncHandler) parseRecord(userID int64, entity string, data json.RawMessage) (interface{}, string) {
+	if entity == models.SyncIncome {
+		var income models.Income
+		if err := json.Unmarshal(data, &income); err != nil {
+			return nil, "data must be an income record"
+		}
+		income.HouseholdID = nil
+		if income.CategoryID == 0 || income.Amount <= 0 || income.IncomeDate == "" {
+			return nil, "category_id, amount (>0), and income_date are required"
+		}
+		if _, err := time.Parse("2006-01-02", income.IncomeDate); err != nil {
+			return nil, "income_date must be YYYY-MM-DD"
+		}
+		if !h.categoryIs(userID, income.CategoryID, "income") {
+			return nil, "invalid income category"
+		}
+		return &income, ""
+	}
+
+	var expense models.Expense
+	if err := json.Unmarshal(data, &expense); err != nil {
+		return nil, "data must be an expense record"
+	}
+	// Shared bills are changed through the expense API; pushes keep them as they are
+	expense.HouseholdID = nil
+	expense.Shared = nil
+
+	if len(expense.Splits) > maxSplits {
+		return nil, fmt.Sprintf("an expense can have at most %d splits", maxSplits)
+	}
+	for _, split := range expense.Splits {
+		if split.Amount <= 0 {
+			return nil, "every split needs an amount (>0)"
+		}
+		if !h.categoryIs(userID, split.CategoryID, "expense") {
+			return nil, "invalid expense category in splits"
+		}
+	}
+	if expense.CategoryID == 0 && len(expense.Splits) > 0 {
+		expense.CategoryID = expense.Splits[0].CategoryID
+	}
+
+	if expense.CategoryID == 0 || expense.Amount <= 0 || expense.ExpenseDate == "" {
+		return nil, "category_id, amount (>0), and expense_date are required"
+	}
+	if _, err := time.Parse("2006-01-02", expense.ExpenseDate); err != nil {
+		return nil, "expense_date must be YYYY-MM-DD"
+	}
+	if !h.categoryIs(userID, expense.CategoryID, "expense") {
+		return nil, "invalid expense category"
+	}
+	return &expense, ""
+}
+
+// categoryIs reports whether a category usable in the user's personal ledger
+// exists with a type
+func (h *SyncHandler) categoryIs(userID, id int64, categoryType string) bool {
+	category, err := h.categoryRepo.GetByID(id, repository.Personal(userID))
+	return err == nil && category != nil && category.Type == categoryType
+}
+
+// updateRecord writes a pushed record over a live one, unless it changed
+// since updatedAt
+func (h *SyncHandler) updateRecord(ctx context.Context, userID, id int64, record interface{}, updatedAt time.Time) error {
+	switch record := record.(type) {
+	case *models.Income:
+		record.ID, record.UserID = id, userID
+		return h.incomeRepo.UpdateIfUnmodified(ctx, record, updatedAt)
+	case *models.Expense:
+		record.ID, record.UserID = id, userID
+		return h.expenseRepo.UpdateIfUnmodified(ctx, record, updatedAt)
+	}
+	return nil
+}
+
+// deleteRecord moves a personal income or expense record to the trash
+func (h *SyncHandler) deleteRecord(ctx context.Context, userID int64, entity string, id int64) error {
+	if entity == models.SyncIncome {
+		return h.incomeRepo.Delete(ctx, id, repository.Personal(userID))
+	}
+	return h.expenseRepo.Delete(ctx, id, repository.Personal(userID))
+}
+
+// restoreRecord moves a personal income or expense record out of the trash
+func (h *SyncHandler) restoreRecord(ctx context.Context, userID int64, entity string, id int64) error {
+	if entity == models.SyncIncome {
+		return h.incomeRepo.Restore(ctx, id, repository.Personal(userID))
+	}
+	return h.expenseRepo.Restore(ctx, id, repository.Personal(userID))
+}
+
+// conflict reports a conflict along with the server's copy of the record
+func (h *SyncHandler) conflict(userID int64, result models.SyncPushResult, reason string) (models.SyncPushResult, error) {
+	result.Status = models.SyncConflict
+	result.Conflict = reason
+
+	server, err := h.load(userID, result.Entity, result.ID)
+	if err != nil {
+		return result, err
+	}
+	result.Server = server
+
+	return h.finish(result)
+}
+
+// finish fills in the record's UUID and latest version
+func (h *SyncHandler) finish(result models.SyncPushResult) (models.SyncPushResult, error) {
+	var err error
+	if result.Entity != models.SyncSettings {
+		if result.UUID, err = h.syncRepo.UUID(result.Entity, result.ID); err != nil {
+			return result, err
+		}
+	}
+	result.Version, err = h.syncRepo.Version(result.Entity, result.ID)
+	return result, err
+}
+
+// sameRecord reports whether a pushed record matches the server's copy
+func sameRecord(current, pushed interface{}) bool {
+	switch current := current.(type) {
+	case *models.Income:
+		income, ok := pushed.(*models.Income)
+		return ok && current.CategoryID == income.CategoryID && cents(current.Amount) == cents(income.Amount) &&
+			current.Description == income.Description && current.IncomeDate == income.IncomeDate &&
+			current.Tags == income.Tags && current.Account == income.Account
+	case *models.Expense:
+		expense, ok := pushed.(*models.Expense)
+		if !ok || current.CategoryID != expense.CategoryID || cents(current.Amount) != cents(expense.Amount) ||
+			current.Description != expense.Description || current.ExpenseDate != expense.ExpenseDate ||
+			current.Tags != expense.Tags || current.Account != expense.Account {
+			return false
+		}
+		// Splits left out of a push are kept
+		if expense.Splits == nil {
+			return true
+		}
+		if len(current.Splits) != len(expense.Splits) {
+			return false
+		}
+		for i, split := range expense.Splits {
+			if current.Splits[i].CategoryID != split.CategoryID || cents(current.Splits[i].Amount) != cents(split.Amount) || current.Splits[i].Note != split.Note {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// cents converts an amount to whole cents for comparison
+func cents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// inputError returns the message of a repository error caused by the pushed
+// data, or "" for any other error
+func inputError(err error) string {
+	if err == nil {
+		return ""
+	}
+	if expenseInputErrors[err] || errors.Is(err, repository.ErrUUIDTaken) {
+		return err.Error()
+	}
+	return ""
+}
+
+// validResolution reports whether on_conflict names a resolution rule
+func validResolution(onConflict string) bool {
+	return onConflict == models.SyncServerWins || onConflict == models.SyncClientWins
+}
+
+// with sets a result's status
+func with(result models.SyncPushResult, status string) models.SyncPushResult {
+	result.Status = status
+	return result
+}
+
+// failed marks a result as failed with a message for the client
+func failed(result models.SyncPushResult, message string) models.SyncPushResult {
+	result.Status = models.SyncFailed
+	result.Error = message
+	return result
+}
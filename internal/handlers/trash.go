@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/json"
+	"myexpress-tracker/internal/middleware"
+	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/repository"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TrashHandler handles trash requests for deleted income and expenses
+type TrashHandler struct {
+	incomeRepo  *repository.IncomeRepository
+	expenseRepo *repository.ExpenseRepository
+	retention   time.Duration
+}
+
+// NewTrashHandler creates a new trash handler
+func NewTrashHandler(incomeRepo *repository.IncomeRepository, expenseRepo *repository.ExpenseRepository, retention time.Duration) *TrashHandler {
+	return &TrashHandler{
+		incomeRepo:  incomeRepo,
+		expenseRepo: expenseRepo,
+		retention:   retention,
+	}
+}
+
+// TrashResponse lists a user's trashed records
+type TrashResponse struct {
+	Income        []models.Income  `json:"income"`
+	Expense       []models.Expense `json:"expense"`
+	RetentionDays int              `json:"retention_days"`
+}
+
+// GetTrash lists the user's trashed income and expenses
+func (h *TrashHandler) GetTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	incomes, err := h.incomeRepo.GetTrash(ledgerFromRequest(r, userID))
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch trash"}`, http.StatusInternalServerError)
+		return
+	}
+
+	expenses, err := h.expenseRepo.GetTrash(ledgerFromRequest(r, userID))
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch trash"}`, http.StatusInternalServerError)
+		return
+	}
+
+	response := TrashResponse{
+		Income:        incomes,
+		Expense:       expenses,
+		RetentionDays: int(h.retention / (24 * time.Hour)),
+	}
+	if response.Income == nil {
+		response.Income = []models.Income{}
+	}
+	if response.Expense == nil {
+		response.Expense = []models.Expense{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// EmptyTrash permanently deletes everything in the user's trash
+func (h *TrashHandler) EmptyTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	incomes, err := h.incomeRepo.EmptyTrash(r.Context(), ledgerFromRequest(r, userID))
+	if err != nil {
+		http.Error(w, `{"error":"failed to empty trash"}`, http.StatusInternalServerError)
+		return
+	}
+
+	expenses, err := h.expenseRepo.EmptyTrash(r.Context(), ledgerFromRequest(r, userID))
+	if err != nil {
+		http.Error(w, `{"error":"failed to empty trash"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"purged": incomes + expenses})
+}
+
+// RestoreItem moves a trashed record back into the user's transactions
+func (h *TrashHandler) RestoreItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	// Path format: /api/trash/{type}/{id}/restore
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 5 || pathParts[4] != "restore" {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+
+	recordType, id, ok := trashItemFromPath(w, pathParts)
+	if !ok {
+		return
+	}
+
+	var err error
+	if recordType == "income" {
+		err = h.incomeRepo.Restore(r.Context(), id, ledgerFromRequest(r, userID))
+	} else {
+		err = h.expenseRepo.Restore(r.Context(), id, ledgerFromRequest(r, userID))
+	}
+	if err != nil {
+		http.Error(w, `{"error":"item not found in trash"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": recordType + " restored successfully"})
+}
+
+// PurgeItem permanently deletes a single trashed record
+func (h *TrashHandler) PurgeItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	// Path format: /api/trash/{type}/{id}
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 4 {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+
+	recordType, id, ok := trashItemFromPath(w, pathParts)
+	if !ok {
+		return
+	}
+
+	var err error
+	if recordType == "income" {
+		err = h.incomeRepo.Purge(r.Context(), id, ledgerFromRequest(r, userID))
+	} else {
+		err = h.expenseRepo.Purge(r.Context(), id, ledgerFromRequest(r, userID))
+	}
+	if err != nil {
+		http.Error(w, `{"error":"item not found in trash"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": recordType + " permanently deleted"})
+}
+
+// trashItemFromPath parses the record type and ID from /api/trash/{type}/{id}/...
+func trashItemFromPath(w http.ResponseWriter, pathParts []string) (string, int64, bool) {
+	recordType := pathParts[2]
+	if recordType != "income" && recordType != "expense" {
+		http.Error(w, `{"error":"type must be income or expense"}`, http.StatusBadRequest)
+		return "", 0, false
+	}
+
+	id, err := strconv.ParseInt(pathParts[3], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid id"}`, http.StatusBadRequest)
+		return "", 0, false
+	}
+
+	return recordType, id, true
+}
@@ -0,0 +1,526 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"myexpress-tracker/internal/middleware"
+	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/netguard"
+	"myexpress-tracker/internal/repository"
+	"myexpress-tracker/internal/webhooks"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxWebhooks caps the webhooks a user can register
+const maxWebhooks = 10
+
+// WebhookHandler handles webhook endpoint, delivery log and redelivery requests
+type WebhookHandler struct {
+	webhookRepo *repository.WebhookRepository
+	dispatcher  *webhooks.Dispatcher
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(webhookRepo *repository.WebhookRepository, dispatcher *webhooks.Dispatcher) *WebhookHandler {
+	return &WebhookHandler{
+		webhookRepo: webhookRepo,
+		dispatcher:  dispatcher,
+	}
+}
+
+// GetEvents lists the event types webhooks can subscribe to
+func (h *WebhookHandler) GetEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhooks.Events)
+}
+
+// GetWebhooks retrieves the user's webhooks
+func (h *WebhookHandler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	list, err := h.webhookRepo.GetByUser(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch webhooks"}`, http.StatusInternalServerError)
+		return
+	}
+	if list == nil {
+		list = []models.Webhook{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// GetWebhook retrieves a webhook
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	webhook, ok := h.findWebhook(w, r, userID)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// CreateWebhook registers a webhook. Its signing secret is only shown in this
+// response.
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	webhook := models.Webhook{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if !validateWebhook(w, r, &webhook) {
+		return
+	}
+
+	existing, err := h.webhookRepo.GetByUser(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch webhooks"}`, http.StatusInternalServerError)
+		return
+	}
+	if len(existing) >= maxWebhooks {
+		http.Error(w, fmt.Sprintf(`{"error":"a user can have at most %d webhooks"}`, maxWebhooks), http.StatusConflict)
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		http.Error(w, `{"error":"failed to create webhook"}`, http.StatusInternalServerError)
+		return
+	}
+
+	webhook.UserID = userID
+	webhook.Secret = secret
+
+	if err := h.webhookRepo.Create(&webhook); err != nil {
+		http.Error(w, `{"error":"failed to create webhook"}`, http.StatusInternalServerError)
+		return
+	}
+
+	h.writeWebhook(w, webhook.ID, userID, secret, http.StatusCreated)
+}
+
+// UpdateWebhook changes a webhook's URL, description, events or active flag
+func (h *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	webhookID, ok := webhookIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	webhook := models.Webhook{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if !validateWebhook(w, r, &webhook) {
+		return
+	}
+
+	webhook.ID = webhookID
+	webhook.UserID = userID
+
+	if err := h.webhookRepo.Update(&webhook); err != nil {
+		http.Error(w, `{"error":"webhook not found"}`, http.StatusNotFound)
+		return
+	}
+
+	h.writeWebhook(w, webhookID, userID, "", http.StatusOK)
+}
+
+// DeleteWebhook deletes a webhook and its delivery log
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	webhookID, ok := webhookIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.webhookRepo.Delete(webhookID, userID); err != nil {
+		http.Error(w, `{"error":"webhook not found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "webhook deleted successfully"})
+}
+
+// RotateSecret replaces a webhook's signing secret, returning the new one
+func (h *WebhookHandler) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	webhookID, ok := webhookIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		http.Error(w, `{"error":"failed to rotate webhook secret"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.webhookRepo.RotateSecret(webhookID, userID, secret); err != nil {
+		http.Error(w, `{"error":"webhook not found"}`, http.StatusNotFound)
+		return
+	}
+
+	h.writeWebhook(w, webhookID, userID, secret, http.StatusOK)
+}
+
+// Ping sends a test event to a webhook right away and returns the delivery
+// with the endpoint's response
+func (h *WebhookHandler) Ping(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	webhook, ok := h.findWebhook(w, r, userID)
+	if !ok {
+		return
+	}
+
+	secret, err := h.webhookRepo.GetSecret(webhook.ID, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch webhook"}`, http.StatusInternalServerError)
+		return
+	}
+
+	delivery, err := h.dispatcher.Ping(webhook, secret)
+	if err != nil || delivery == nil {
+		http.Error(w, `{"error":"failed to ping webhook"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// GetDeliveries retrieves a webhook's recent deliveries, optionally only those
+// with ?status=pending|sent|failed
+func (h *WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	webhook, ok := h.findWebhook(w, r, userID)
+	if !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", models.DeliveryPending, models.DeliverySent, models.DeliveryFailed:
+	default:
+		http.Error(w, `{"error":"status must be pending, sent or failed"}`, http.StatusBadRequest)
+		return
+	}
+
+	limit, ok := notificationLimit(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries, err := h.webhookRepo.GetDeliveries(webhook.ID, userID, status, limit)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch webhook deliveries"}`, http.StatusInternalServerError)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// GetDelivery retrieves a delivery with every attempt made at it
+func (h *WebhookHandler) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	webhookID, deliveryID, ok := deliveryIDsFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	h.writeDelivery(w, deliveryID, webhookID, userID, http.StatusOK)
+}
+
+// Redeliver sends a delivery again, with the same payload and event ID and a
+// fresh set of retries
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	webhookID, deliveryID, ok := deliveryIDsFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.dispatcher.Redeliver(deliveryID, webhookID, userID); err != nil {
+		http.Error(w, `{"error":"webhook delivery not found"}`, http.StatusNotFound)
+		return
+	}
+
+	h.writeDelivery(w, deliveryID, webhookID, userID, http.StatusAccepted)
+}
+
+// validateWebhook checks a webhook's URL and events, writing an error response
+// on failure
+func validateWebhook(w http.ResponseWriter, r *http.Request, webhook *models.Webhook) bool {
+	webhook.URL = strings.TrimSpace(webhook.URL)
+	webhook.Description = strings.TrimSpace(webhook.Description)
+
+	if !validateTargetURL(w, r, "url", webhook.URL) {
+		return false
+	}
+
+	if len(webhook.Events) == 0 {
+		http.Error(w, `{"error":"subscribe to at least one event"}`, http.StatusBadRequest)
+		return false
+	}
+
+	seen := make(map[string]bool)
+	events := make([]string, 0, len(webhook.Events))
+	for _, event := range webhook.Events {
+		if !isWebhookEvent(event) {
+			http.Error(w, fmt.Sprintf(`{"error":"events must be among %s"}`, strings.Join(webhooks.Events, ", ")), http.StatusBadRequest)
+			return false
+		}
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+	webhook.Events = events
+
+	return true
+}
+
+// isWebhookEvent reports whether event is an event type webhooks can subscribe to
+func isWebhookEvent(event string) bool {
+	for _, known := range webhooks.Events {
+		if event == known {
+			return true
+		}
+	}
+	return false
+}
+
+// findWebhook loads the webhook named in the path, writing an error response
+// when it cannot be found
+func (h *WebhookHandler) findWebhook(w http.ResponseWriter, r *http.Request, userID int64) (*models.Webhook, bool) {
+	webhookID, ok := webhookIDFromPath(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	webhook, err := h.webhookRepo.GetByID(webhookID, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch webhook"}`, http.StatusInternalServerError)
+		return nil, false
+	}
+	if webhook == nil {
+		http.Error(w, `{"error":"webhook not found"}`, http.StatusNotFound)
+		return nil, false
+	}
+
+	return webhook, true
+}
+
+// writeWebhook responds with a stored webhook, including its secret when one
+// is given
+func (h *WebhookHandler) writeWebhook(w http.ResponseWriter, webhookID, userID int64, secret string, status int) {
+	webhook, err := h.webhookRepo.GetByID(webhookID, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch webhook"}`, http.StatusInternalServerError)
+		return
+	}
+	if webhook == nil {
+		http.Error(w, `{"error":"webhook not found"}`, http.StatusNotFound)
+		return
+	}
+	webhook.Secret = secret
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// writeDelivery responds with a stored delivery and its attempts
+func (h *WebhookHandler) writeDelivery(w http.ResponseWriter, deliveryID, webhookID, userID int64, status int) {
+	delivery, err := h.webhookRepo.GetDelivery(deliveryID, webhookID, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch webhook delivery"}`, http.StatusInternalServerError)
+		return
+	}
+	if delivery == nil {
+		http.Error(w, `{"error":"webhook delivery not found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// webhookIDFromPath extracts the webhook id from /api/webhooks/{id}[/...]
+func webhookIDFromPath(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 3 {
+		http.Error(w, `{"error":"webhook id required"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	webhookID, err := strconv.ParseInt(pathParts[2], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid webhook id"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	return webhookID, true
+}
+
+// deliveryIDsFromPath extracts the webhook and delivery ids from
+// /api/webhooks/{id}/deliveries/{delivery_id}[/...]
+func deliveryIDsFromPath(w http.ResponseWriter, r *http.Request) (int64, int64, bool) {
+	webhookID, ok := webhookIDFromPath(w, r)
+	if !ok {
+		return 0, 0, false
+	}
+
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 5 {
+		http.Error(w, `{"error":"delivery id required"}`, http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	deliveryID, err := strconv.ParseInt(pathParts[4], 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"invalid delivery id"}`, http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	return webhookID, deliveryID, true
+}
+
+// validateTargetURL checks a URL the server will post to on the user's behalf.
+// It must be http or https and resolve to public addresses only, so users
+// cannot reach the server's own network. It writes an error response on
+// failure.
+func validateTargetURL(w http.ResponseWriter, r *http.Request, field, target string) bool {
+	err := netguard.CheckURL(r.Context(), target)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, netguard.ErrBlockedAddress):
+		http.Error(w, `{"error":"`+field+` must point to a public address"}`, http.StatusBadRequest)
+	case errors.Is(err, netguard.ErrUnresolved):
+		http.Error(w, `{"error":"`+field+` host could not be resolved"}`, http.StatusBadRequest)
+	default:
+		http.Error(w, `{"error":"`+field+` must be an http or https URL"}`, http.StatusBadRequest)
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrUnknownFormat is returned for files in a format no parser reads
+var ErrUnknownFormat = errors.New("unknown file format")
+
+// Transaction is one transaction read from a statement file
+type Transaction struct {
+	Date        string  // YYYY-MM-DD
+	Amount      float64 // Positive for money in, negative for money out
+	Description string
+	Memo        string
+	ExternalID  string // The bank's id for the transaction, unique within Source
+	Source      string // Identifies the account the transaction was posted to
+	Account     string // A readable name for that account, e.g. "Checking 1234"
+	Category    string // The file's category, e.g. "Food:Groceries"
+	Splits      []Split
+}
+
+// Split is one line item of a split transaction
+type Split struct {
+	Category string
+	Amount   float64 // Signed like the transaction's amount
+	Memo     string
+}
+
+// Options tune how a file is read
+type Options struct {
+	DateOrder string // "mdy" or "dmy" for QIF dates; detected from the file when empty
+}
+
+// Detect works out a file's format from its name or, failing that, its
+// content. It returns "" when the format is not recognised.
+func Detect(filename string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".ofx", ".qfx":
+		return "ofx"
+	case ".qif":
+		return "qif"
+	}
+
+	head := data
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	head = bytes.ToUpper(head)
+	if bytes.Contains(head, []byte("OFXHEADER")) || bytes.Contains(head, []byte("<OFX>")) {
+		return "ofx"
+	}
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if bytes.HasPrefix(trimmed, []byte("!TYPE:")) || bytes.HasPrefix(trimmed, []byte("!ACCOUNT")) || bytes.HasPrefix(trimmed, []byte("!OPTION:")) {
+		return "qif"
+	}
+	return ""
+}
+
+// Parse reads the transactions of a file in the given format
+func Parse(format string, data []byte, options Options) ([]Transaction, error) {
+	switch format {
+	case "ofx":
+		return ParseOFX(data)
+	case "qif":
+		return ParseQIF(data, options)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// windows1252 maps the bytes 0x80-0x9F of Windows-1252 to their runes; the
+// other bytes are the same as in Latin-1
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// toUTF8 returns data unchanged when it is valid UTF-8 and otherwise decodes
+// it as Windows-1252, which older bank exports use
+func toUTF8(data []byte) string {
+	if utf8.Valid(data) {
+		return string(data)
+	}
+
+	var b strings.Builder
+	b.Grow(len(data) + len(data)/8)
+	for _, c := range data {
+		if c >= 0x80 && c < 0xA0 {
+			b.WriteRune(windows1252[c-0x80])
+		} else {
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String()
+}
+
+// roundCents rounds an amount to the cent
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
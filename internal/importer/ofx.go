@@ -0,0 +1,249 @@
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ofxAccountTypes names the OFX bank account types
+var ofxAccountTypes = map[string]string{
+	"CHECKING":   "Checking",
+	"SAVINGS":    "Savings",
+	"MONEYMRKT":  "Money market",
+	"CREDITLINE": "Credit line",
+	"CD":         "CD",
+}
+
+// ofxNode is an element of an OFX document. OFX 1.x files are SGML and leave
+// the elements that hold a value unclosed, so a node has either a value or
+// children.
+type ofxNode struct {
+	name     string
+	value    string
+	children []*ofxNode
+	parent   *ofxNode
+}
+
+// ParseOFX reads the transactions of the bank and credit card statements in
+// an OFX or QFX file, in SGML (version 1) or XML (version 2) form
+func ParseOFX(data []byte) ([]Transaction, error) {
+	root, err := parseOFXTree(toUTF8(data))
+	if err != nil {
+		return nil, err
+	}
+
+	statements := root.findAll("STMTRS", "CCSTMTRS")
+	if len(statements) == 0 {
+		return nil, errors.New("no bank or credit card statement found")
+	}
+
+	transactions := []Transaction{}
+	for _, statement := range statements {
+		source, account := ofxAccount(statement)
+		for _, node := range statement.findAll("STMTTRN") {
+			transaction, err := ofxTransaction(node)
+			if err != nil {
+				return nil, err
+			}
+			transaction.Source = source
+			transaction.Account = account
+			transactions = append(transactions, transaction)
+		}
+	}
+	return transactions, nil
+}
+
+// parseOFXTree builds the element tree of an OFX document, skipping the
+// headers before the OFX element. A value ends its element, and a closing
+// tag closes every element still open inside the element it names, so the
+// same code reads both SGML and XML files.
+func parseOFXTree(text string) (*ofxNode, error) {
+	start := strings.Index(strings.ToUpper(text), "<OFX>")
+	if start < 0 {
+		return nil, errors.New("not an OFX file")
+	}
+	text = text[start:]
+
+	root := &ofxNode{}
+	current := root
+	for {
+		open := strings.IndexByte(text, '<')
+		if open < 0 {
+			break
+		}
+		if value := strings.TrimSpace(text[:open]); value != "" && current != root && len(current.children) == 0 {
+			current.value = html.UnescapeString(value)
+			current = current.parent
+		}
+
+		end := strings.IndexByte(text[open:], '>')
+		if end < 0 {
+			return nil, errors.New("invalid OFX file: unterminated tag")
+		}
+		tag := strings.TrimSpace(text[open+1 : open+end])
+		text = text[open+end+1:]
+
+		switch {
+		case tag == "", strings.HasPrefix(tag, "?"), strings.HasPrefix(tag, "!"):
+			// Processing instructions and comments
+		case strings.HasPrefix(tag, "/"):
+			name := strings.ToUpper(strings.TrimSpace(tag[1:]))
+			for node := current; node != root; node = node.parent {
+				if node.name == name {
+					current = node.parent
+					break
+				}
+			}
+		default:
+			selfClosing := strings.HasSuffix(tag, "/")
+			name := strings.ToUpper(strings.Fields(strings.TrimSuffix(tag, "/"))[0])
+			node := &ofxNode{name: name, parent: current}
+			current.children = append(current.children, node)
+			if !selfClosing {
+				current = node
+			}
+		}
+	}
+
+	return root, nil
+}
+
+// find returns the first element named name below n, depth first
+func (n *ofxNode) find(name string) *ofxNode {
+	for _, child := range n.children {
+		if child.name == name {
+			return child
+		}
+		if found := child.find(name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// findAll returns the elements below n with any of the names, in document
+// order. It does not look inside the elements it returns.
+func (n *ofxNode) findAll(names ...string) []*ofxNode {
+	var found []*ofxNode
+	for _, child := range n.children {
+		matched := false
+		for _, name := range names {
+			if child.name == name {
+				matched = true
+				break
+			}
+		}
+		if matched {
+			found = append(found, child)
+		} else {
+			found = append(found, child.findAll(names...)...)
+		}
+	}
+	return found
+}
+
+// text returns the value of the first element named name below n
+func (n *ofxNode) text(name string) string {
+	if found := n.find(name); found != nil {
+		return found.value
+	}
+	return ""
+}
+
+// ofxAccount identifies the account of a statement. The source is a hash,
+// so full account numbers are not stored.
+func ofxAccount(statement *ofxNode) (source, account string) {
+	kind := "Credit card"
+	from := statement.find("CCACCTFROM")
+	if from == nil {
+		from = statement.find("BANKACCTFROM")
+		kind = ofxAccountTypes[strings.ToUpper(statement.text("ACCTTYPE"))]
+		if kind == "" {
+			kind = "Account"
+		}
+	}
+	if from == nil {
+		return "ofx", ""
+	}
+
+	number := from.text("ACCTID")
+	sum := sha256.Sum256([]byte(from.text("BANKID") + ":" + number))
+	source = "ofx:" + hex.EncodeToString(sum[:8])
+
+	if len(number) > 4 {
+		number = number[len(number)-4:]
+	}
+	return source, strings.TrimSpace(kind + " " + number)
+}
+
+// ofxTransaction reads one STMTTRN element
+func ofxTransaction(node *ofxNode) (Transaction, error) {
+	fitID := node.text("FITID")
+
+	posted := node.text("DTPOSTED")
+	if posted == "" {
+		posted = node.text("DTUSER")
+	}
+	date, err := ofxDate(posted)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("transaction %q: invalid date %q", fitID, posted)
+	}
+
+	amount, err := ofxAmount(node.text("TRNAMT"))
+	if err != nil {
+		return Transaction{}, fmt.Errorf("transaction %q: invalid amount %q", fitID, node.text("TRNAMT"))
+	}
+
+	description := node.text("NAME")
+	memo := node.text("MEMO")
+	if description == "" {
+		description, memo = memo, ""
+	}
+	if description == "" && node.text("CHECKNUM") != "" {
+		description = "Check " + node.text("CHECKNUM")
+	}
+
+	return Transaction{
+		Date:        date,
+		Amount:      amount,
+		Description: description,
+		Memo:        memo,
+		ExternalID:  fitID,
+	}, nil
+}
+
+// ofxDate converts an OFX date such as 20261001120000.000[-5:EST] to
+// YYYY-MM-DD. The time and time zone are dropped.
+func ofxDate(value string) (string, error) {
+	if len(value) < 8 {
+		return "", errors.New("date too short")
+	}
+	date, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return "", err
+	}
+	return date.Format("2006-01-02"), nil
+}
+
+// ofxAmount parses an OFX amount, which may use a comma as the decimal separator
+func ofxAmount(value string) (float64, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "+")
+	if !strings.Contains(value, ".") {
+		value = strings.Replace(value, ",", ".", 1)
+	}
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return 0, errors.New("amount is not a number")
+	}
+	return roundCents(amount), nil
+}
@@ -0,0 +1,276 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+)
+
+const sgmlStatement = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+SECURITY:NONE
+ENCODING:USASCII
+CHARSET:1252
+
+<OFX>
+<SIGNONMSGSRSV1>
+<SONRS>
+<STATUS>
+<CODE>0
+<SEVERITY>INFO
+</STATUS>
+<DTSERVER>20261031120000
+<LANGUAGE>ENG
+</SONRS>
+</SIGNONMSGSRSV1>
+<BANKMSGSRSV1>
+<STMTTRNRS>
+<TRNUID>1
+<STMTRS>
+<CURDEF>USD
+<BANKACCTFROM>
+<BANKID>021000021
+<ACCTID>9876543210
+<ACCTTYPE>CHECKING
+</BANKACCTFROM>
+<BANKTRANLIST>
+<DTSTART>20261001
+<DTEND>20261031
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20261003120000.000[-5:EST]
+<TRNAMT>-12.50
+<FITID>A1
+<NAME>Coffee &amp; Co
+<MEMO>Card 1234
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20261005
+<TRNAMT>+1500.00
+<FITID>A2
+<NAME>Payroll
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS>
+</STMTTRNRS>
+</BANKMSGSRSV1>
+</OFX>
+`
+
+const xmlStatement = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="211" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+  <CREDITCARDMSGSRSV1>
+    <CCSTMTTRNRS>
+      <TRNUID>1</TRNUID>
+      <CCSTMTRS>
+        <CURDEF>EUR</CURDEF>
+        <CCACCTFROM>
+          <ACCTID>4111111111114321</ACCTID>
+        </CCACCTFROM>
+        <BANKTRANLIST>
+          <STMTTRN>
+            <TRNTYPE>DEBIT</TRNTYPE>
+            <DTUSER>20261002</DTUSER>
+            <TRNAMT>-7,25</TRNAMT>
+            <FITID>C1</FITID>
+            <MEMO>Bakery</MEMO>
+          </STMTTRN>
+          <STMTTRN>
+            <TRNTYPE>CHECK</TRNTYPE>
+            <DTPOSTED>20261004</DTPOSTED>
+            <TRNAMT>-40.00</TRNAMT>
+            <FITID>C2</FITID>
+            <CHECKNUM>1042</CHECKNUM>
+          </STMTTRN>
+          <STMTTRN>
+            <TRNTYPE>DEBIT</TRNTYPE>
+            <DTPOSTED>20261006</DTPOSTED>
+            <TRNAMT>-3.10</TRNAMT>
+            <NAME>Parking</NAME>
+          </STMTTRN>
+        </BANKTRANLIST>
+      </CCSTMTRS>
+    </CCSTMTTRNRS>
+  </CREDITCARDMSGSRSV1>
+</OFX>
+`
+
+func TestParseOFX(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		account string
+		want    []Transaction
+	}{
+		{
+			name:    "SGML bank statement",
+			data:    sgmlStatement,
+			account: "Checking 3210",
+			want: []Transaction{
+				{Date: "2026-10-03", Amount: -12.5, Description: "Coffee & Co", Memo: "Card 1234", ExternalID: "A1"},
+				{Date: "2026-10-05", Amount: 1500, Description: "Payroll", ExternalID: "A2"},
+			},
+		},
+		{
+			name:    "XML credit card statement",
+			data:    xmlStatement,
+			account: "Credit card 4321",
+			want: []Transaction{
+				{Date: "2026-10-02", Amount: -7.25, Description: "Bakery", ExternalID: "C1"},
+				{Date: "2026-10-04", Amount: -40, Description: "Check 1042", ExternalID: "C2"},
+				{Date: "2026-10-06", Amount: -3.1, Description: "Parking"},
+			},
+		},
+		{
+			name: "SGML on one line with a duplicate FITID",
+			data: `<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS><BANKACCTFROM><BANKID>1<ACCTID>55<ACCTTYPE>SAVINGS</BANKACCTFROM>` +
+				`<BANKTRANLIST><STMTTRN><DTPOSTED>20261001<TRNAMT>10<FITID>X<NAME>Interest</STMTTRN>` +
+				`<STMTTRN><DTPOSTED>20261001<TRNAMT>10<FITID>X<NAME>Interest</STMTTRN></BANKTRANLIST></STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>`,
+			account: "Savings 55",
+			want: []Transaction{
+				{Date: "2026-10-01", Amount: 10, Description: "Interest", ExternalID: "X"},
+				{Date: "2026-10-01", Amount: 10, Description: "Interest", ExternalID: "X"},
+			},
+		},
+		{
+			name: "Windows-1252 text",
+			data: "<OFX><STMTRS><BANKACCTFROM><ACCTID>77<ACCTTYPE>OTHER</BANKACCTFROM>" +
+				"<STMTTRN><DTPOSTED>20261001<TRNAMT>-1<FITID>W<NAME>Joe\x92s Diner</STMTTRN></STMTRS></OFX>",
+			account: "Account 77",
+			want: []Transaction{
+				{Date: "2026-10-01", Amount: -1, Description: "Joe’s Diner", ExternalID: "W"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOFX([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("ParseOFX: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d transactions, want %d", len(got), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(got[i].Source, "ofx:") {
+					t.Errorf("transaction %d: source = %q, want an ofx: hash", i, got[i].Source)
+				}
+				want.Source = got[i].Source
+				want.Account = tt.account
+				if !sameTransaction(got[i], want) {
+					t.Errorf("transaction %d = %+v, want %+v", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseOFXSourceHidesAccountNumber(t *testing.T) {
+	got, err := ParseOFX([]byte(sgmlStatement))
+	if err != nil {
+		t.Fatalf("ParseOFX: %v", err)
+	}
+	other, err := ParseOFX([]byte(strings.Replace(sgmlStatement, "9876543210", "9876543211", 1)))
+	if err != nil {
+		t.Fatalf("ParseOFX: %v", err)
+	}
+
+	if strings.Contains(got[0].Source, "9876543210") {
+		t.Errorf("source %q contains the account number", got[0].Source)
+	}
+	if got[0].Source == other[0].Source {
+		t.Errorf("accounts 9876543210 and 9876543211 share source %q", got[0].Source)
+	}
+}
+
+func TestParseOFXErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"not OFX", "!Type:Bank\nD10/01/2026\nT-1\n^\n"},
+		{"no statement", "<OFX><SIGNONMSGSRSV1><SONRS><STATUS><CODE>0</STATUS></SONRS></SIGNONMSGSRSV1></OFX>"},
+		{"unterminated tag", "<OFX><STMTRS><STMTTRN><TRNAMT"},
+		{"invalid date", "<OFX><STMTRS><STMTTRN><DTPOSTED>2026<TRNAMT>-1<FITID>A</STMTTRN></STMTRS></OFX>"},
+		{"missing date", "<OFX><STMTRS><STMTTRN><TRNAMT>-1<FITID>A</STMTTRN></STMTRS></OFX>"},
+		{"invalid amount", "<OFX><STMTRS><STMTTRN><DTPOSTED>20261001<TRNAMT>abc<FITID>A</STMTTRN></STMTRS></OFX>"},
+		{"amount not a number", "<OFX><STMTRS><STMTTRN><DTPOSTED>20261001<TRNAMT>NaN<FITID>A</STMTTRN></STMTRS></OFX>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseOFX([]byte(tt.data)); err == nil {
+				t.Error("ParseOFX succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestOFXAmount(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"-12.50", -12.5},
+		{"+3", 3},
+		{" 42.005 ", 42.01},
+		{"1,5", 1.5},
+		{"-7,25", -7.25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ofxAmount(tt.value)
+			if err != nil {
+				t.Fatalf("ofxAmount(%q): %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("ofxAmount(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		data     string
+		want     string
+	}{
+		{"ofx extension", "statement.OFX", "", "ofx"},
+		{"qfx extension", "statement.qfx", "", "ofx"},
+		{"qif extension", "export.qif", "", "qif"},
+		{"OFX header", "", "OFXHEADER:100\nDATA:OFXSGML\n", "ofx"},
+		{"XML OFX", "", "<?xml version=\"1.0\"?>\n<ofx>", "ofx"},
+		{"QIF with byte order mark", "", "\xef\xbb\xbf\r\n!Type:Bank\n", "qif"},
+		{"QIF account list", "", "!Account\nNChecking\n^\n", "qif"},
+		{"CSV", "export.csv", "date,amount\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(tt.filename, []byte(tt.data)); got != tt.want {
+				t.Errorf("Detect = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// sameTransaction compares two transactions field by field, splits included
+func sameTransaction(a, b Transaction) bool {
+	if a.Date != b.Date || a.Amount != b.Amount || a.Description != b.Description || a.Memo != b.Memo ||
+		a.ExternalID != b.ExternalID || a.Source != b.Source || a.Account != b.Account || a.Category != b.Category ||
+		len(a.Splits) != len(b.Splits) {
+		return false
+	}
+	for i := range a.Splits {
+		if a.Splits[i] != b.Splits[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,413 @@
+package importer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// qifSections are the QIF account types whose transactions are read
+var qifSections = map[string]bool{
+	"bank":  true,
+	"cash":  true,
+	"ccard": true,
+}
+
+// qifDecimalComma matches an amount written with a decimal comma, e.g. 12,50
+var qifDecimalComma = regexp.MustCompile(`,\d{1,2}$`)
+
+// qifRecord is a transaction as written in the file, before its date and
+// amount are interpreted
+type qifRecord struct {
+	line        int
+	date        string
+	amount      string
+	payee       string
+	memo        string
+	number      string
+	category    string
+	account     string
+	splits      []qifSplit
+	hasSplitAmt bool
+}
+
+// qifSplit is a split line as written in the file
+type qifSplit struct {
+	category string
+	amount   string
+	memo     string
+}
+
+// ParseQIF reads the transactions of the bank, cash and credit card sections
+// of a QIF file. Sections of other types, such as investments or the category
+// list, are skipped.
+func ParseQIF(data []byte, options Options) ([]Transaction, error) {
+	text := strings.TrimPrefix(toUTF8(data), "\ufeff")
+
+	var records []qifRecord
+	var current qifRecord
+	var pending bool
+	section := ""
+	account := ""
+	inAccount := false
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" {
+			continue
+		}
+
+		if entry[0] == '!' {
+			header := strings.ToLower(entry)
+			switch {
+			case header == "!account":
+				inAccount = true
+				section = ""
+			case strings.HasPrefix(header, "!type:"):
+				inAccount = false
+				section = strings.TrimSpace(strings.TrimPrefix(header, "!type:"))
+			}
+			// Options such as !Option:AutoSwitch change nothing here
+			if pending && (current.date != "" || current.amount != "") {
+				records = append(records, current)
+			}
+			current, pending = qifRecord{}, false
+			continue
+		}
+
+		code, value := entry[0], strings.TrimSpace(entry[1:])
+
+		if inAccount {
+			if code == 'N' {
+				account = value
+			}
+			continue
+		}
+		if !qifSections[section] {
+			continue
+		}
+
+		if !pending {
+			current = qifRecord{line: line, account: account}
+			pending = true
+		}
+
+		switch code {
+		case 'D':
+			current.date = value
+		case 'T', 'U':
+			if current.amount == "" || code == 'T' {
+				current.amount = value
+			}
+		case 'P':
+			current.payee = value
+		case 'M':
+			current.memo = value
+		case 'N':
+			current.number = value
+		case 'L':
+			current.category = value
+		case 'S':
+			current.splits = append(current.splits, qifSplit{category: value})
+		case 'E':
+			if len(current.splits) == 0 {
+				current.splits = append(current.splits, qifSplit{})
+			}
+			current.splits[len(current.splits)-1].memo = value
+		case '$':
+			if len(current.splits) == 0 {
+				current.splits = append(current.splits, qifSplit{})
+			}
+			current.splits[len(current.splits)-1].amount = value
+			current.hasSplitAmt = true
+		case '^':
+			records = append(records, current)
+			current, pending = qifRecord{}, false
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read QIF file: %w", err)
+	}
+	if pending && (current.date != "" || current.amount != "") {
+		records = append(records, current)
+	}
+
+	if len(records) == 0 && section == "" && account == "" {
+		return nil, errors.New("no bank, cash or credit card section found")
+	}
+
+	dayFirst, err := qifDayFirst(records, options.DateOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := make([]Transaction, 0, len(records))
+	for _, record := range records {
+		transaction, err := qifTransaction(record, dayFirst)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, nil
+}
+
+// qifTransaction interprets a record
+func qifTransaction(record qifRecord, dayFirst bool) (Transaction, error) {
+	date, err := qifDate(record.date, dayFirst)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("line %d: invalid date %q", record.line, record.date)
+	}
+	amount, err := qifAmount(record.amount)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("line %d: invalid amount %q", record.line, record.amount)
+	}
+
+	description, memo := record.payee, record.memo
+	if description == "" {
+		description, memo = memo, ""
+	}
+	if _, err := strconv.Atoi(record.number); err == nil && description == "" {
+		description = "Check " + record.number
+	}
+
+	transaction := Transaction{
+		Date:        date,
+		Amount:      amount,
+		Description: description,
+		Memo:        memo,
+		Account:     record.account,
+		Category:    qifCategory(record.category),
+	}
+
+	if record.hasSplitAmt {
+		for _, split := range record.splits {
+			splitAmount, err := qifAmount(split.amount)
+			if err != nil {
+				return Transaction{}, fmt.Errorf("line %d: invalid split amount %q", record.line, split.amount)
+			}
+			transaction.Splits = append(transaction.Splits, Split{
+				Category: qifCategory(split.category),
+				Amount:   splitAmount,
+				Memo:     split.memo,
+			})
+		}
+	}
+
+	return transaction, nil
+}
+
+// qifCategory returns the category of an L or S field without its class.
+// Transfers to other accounts, written [Account], have no category.
+func qifCategory(value string) string {
+	if i := strings.IndexByte(value, '/'); i >= 0 {
+		value = value[:i]
+	}
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "[") || value == "--Split--" {
+		return ""
+	}
+	return value
+}
+
+// qifDateFields splits a QIF date such as 10/3/26, 10/ 3'26, 03.10.2026 or
+// 2026-10-03 into its three numbers. century2000 reports a ' before the
+// year, which Quicken writes for years from 2000.
+func qifDateFields(value string) (fields [3]int, yearFirst, century2000 bool, err error) {
+	value = strings.ReplaceAll(value, " ", "")
+	century2000 = strings.Contains(value, "'")
+	parts := strings.FieldsFunc(value, func(r rune) bool {
+		return r == '/' || r == '-' || r == '.' || r == '\''
+	})
+	if len(parts) != 3 {
+		return fields, false, false, errors.New("date needs three parts")
+	}
+	for i, part := range parts {
+		if fields[i], err = strconv.Atoi(part); err != nil {
+			return fields, false, false, err
+		}
+	}
+	return fields, len(parts[0]) == 4, century2000, nil
+}
+
+// qifDayFirst decides whether the file's dates put the day before the month.
+// Without an explicit order, a first number above 12 means day first and
+// otherwise dates are read month first, as Quicken writes them.
+func qifDayFirst(records []qifRecord, order string) (bool, error) {
+	switch order {
+	case "dmy":
+		return true, nil
+	case "mdy":
+		return false, nil
+	case "":
+	default:
+		return false, errors.New("date order must be mdy or dmy")
+	}
+
+	for _, record := range records {
+		fields, yearFirst, _, err := qifDateFields(record.date)
+		if err == nil && !yearFirst && fields[0] > 12 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// qifDate converts a QIF date to YYYY-MM-DD
+func qifDate(value string, dayFirst bool) (string, error) {
+	fields, yearFirst, century2000, err := qifDateFields(value)
+	if err != nil {
+		return "", err
+	}
+
+	var year, month, day int
+	switch {
+	case yearFirst:
+		year, month, day = fields[0], fields[1], fields[2]
+	case dayFirst:
+		day, month, year = fields[0], fields[1], fields[2]
+	default:
+		month, day, year = fields[0], fields[1], fields[2]
+	}
+	if year < 100 {
+		if century2000 || year < 70 {
+			year += 2000
+		} else {
+			year += 1900
+		}
+	}
+
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if date.Day() != day || int(date.Month()) != month {
+		return "", errors.New("no such date")
+	}
+	return date.Format("2006-01-02"), nil
+}
+
+// qifAmount parses a QIF amount, with thousands separators and either a
+// decimal point or a decimal comma
+func qifAmount(value string) (float64, error) {
+	value = strings.NewReplacer(" ", "", "$", "").Replace(value)
+	switch {
+	case strings.Contains(value, ".") && strings.Contains(value, ","):
+		if strings.LastIndex(value, ",") > strings.LastIndex(value, ".") {
+			value = strings.ReplaceAll(value, ".", "")
+			value = strings.Replace(value, ",", ".", 1)
+		} else {
+			value = strings.ReplaceAll(value, ",", "")
+		}
+	case qifDecimalComma.MatchString(value):
+		value = strings.Replace(value, ",", ".", 1)
+	default:
+		value = strings.ReplaceAll(value, ",", "")
+	}
+
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return 0, errors.New("amount is not a number")
+	}
+	return roundCents(amount), nil
+}
+
+// QIFCategory is a category listed in a QIF file
+type QIFCategory struct {
+	Name   string
+	Income bool
+}
+
+// WriteQIF writes transactions as a QIF file. The categories are listed
+// first, then the transactions of each account in a Bank section, oldest
+// first. Transactions without an account come first, in a section of their own.
+func WriteQIF(w io.Writer, categories []QIFCategory, transactions []Transaction) error {
+	b := bufio.NewWriter(w)
+
+	if len(categories) > 0 {
+		sorted := append([]QIFCategory(nil), categories...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+		b.WriteString("!Type:Cat\n")
+		for _, category := range sorted {
+			kind := "E"
+			if category.Income {
+				kind = "I"
+			}
+			fmt.Fprintf(b, "N%s\n%s\n^\n", qifCategoryText(category.Name), kind)
+		}
+	}
+
+	byAccount := make(map[string][]Transaction)
+	var accounts []string
+	for _, transaction := range transactions {
+		if _, ok := byAccount[transaction.Account]; !ok {
+			accounts = append(accounts, transaction.Account)
+		}
+		byAccount[transaction.Account] = append(byAccount[transaction.Account], transaction)
+	}
+	sort.Strings(accounts)
+
+	for _, account := range accounts {
+		if account != "" {
+			fmt.Fprintf(b, "!Account\nN%s\nTBank\n^\n", qifText(account))
+		}
+		b.WriteString("!Type:Bank\n")
+
+		list := byAccount[account]
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Date < list[j].Date })
+		for _, transaction := range list {
+			writeQIFTransaction(b, transaction)
+		}
+	}
+
+	return b.Flush()
+}
+
+// writeQIFTransaction writes one transaction record
+func writeQIFTransaction(b *bufio.Writer, transaction Transaction) {
+	date, err := time.Parse("2006-01-02", transaction.Date)
+	if err == nil {
+		fmt.Fprintf(b, "D%s\n", date.Format("01/02/2006"))
+	}
+	fmt.Fprintf(b, "T%.2f\n", transaction.Amount)
+	if transaction.Description != "" {
+		fmt.Fprintf(b, "P%s\n", qifText(transaction.Description))
+	}
+	if transaction.Memo != "" {
+		fmt.Fprintf(b, "M%s\n", qifText(transaction.Memo))
+	}
+	if transaction.Category != "" {
+		fmt.Fprintf(b, "L%s\n", qifCategoryText(transaction.Category))
+	}
+	for _, split := range transaction.Splits {
+		fmt.Fprintf(b, "S%s\n", qifCategoryText(split.Category))
+		if split.Memo != "" {
+			fmt.Fprintf(b, "E%s\n", qifText(split.Memo))
+		}
+		fmt.Fprintf(b, "$%.2f\n", split.Amount)
+	}
+	b.WriteString("^\n")
+}
+
+// qifText keeps a value on one line
+func qifText(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// qifCategoryText writes a category name. A / would start a class and
+// brackets would make it a transfer, so they are replaced.
+func qifCategoryText(name string) string {
+	return strings.NewReplacer("/", "-", "[", "(", "]", ")").Replace(qifText(name))
+}
@@ -0,0 +1,231 @@
+package importer
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseQIF(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		order string
+		want  []Transaction
+	}{
+		{
+			name: "bank section",
+			data: "!Type:Bank\n" +
+				"D10/03/2026\nT-42.10\nPGrocer\nMWeekly shop\nLFood:Groceries/Vacation\n^\n" +
+				"D10/05/2026\nT-100.00\nN1042\nL[Savings]\n^\n",
+			want: []Transaction{
+				{Date: "2026-10-03", Amount: -42.1, Description: "Grocer", Memo: "Weekly shop", Category: "Food:Groceries"},
+				{Date: "2026-10-05", Amount: -100, Description: "Check 1042"},
+			},
+		},
+		{
+			name: "memo used when there is no payee",
+			data: "!Type:Cash\nD10/03/2026\nU-3.00\nMBus fare\n^\n",
+			want: []Transaction{
+				{Date: "2026-10-03", Amount: -3, Description: "Bus fare"},
+			},
+		},
+		{
+			name: "month first when no day is above 12",
+			data: "!Type:Bank\nD03/10/2026\nT-1\n^\nD04/11/2026\nT-1\n^\n",
+			want: []Transaction{
+				{Date: "2026-03-10", Amount: -1},
+				{Date: "2026-04-11", Amount: -1},
+			},
+		},
+		{
+			name: "day first when a day is above 12",
+			data: "!Type:Bank\nD03/10/2026\nT-1\n^\nD25/10/2026\nT-1\n^\n",
+			want: []Transaction{
+				{Date: "2026-10-03", Amount: -1},
+				{Date: "2026-10-25", Amount: -1},
+			},
+		},
+		{
+			name:  "explicit day first",
+			data:  "!Type:Bank\nD03.10.2026\nT-1\n^\n",
+			order: "dmy",
+			want: []Transaction{
+				{Date: "2026-10-03", Amount: -1},
+			},
+		},
+		{
+			name:  "explicit month first overrides detection",
+			data:  "!Type:Bank\nD10/03/2026\nT-1\n^\n",
+			order: "mdy",
+			want: []Transaction{
+				{Date: "2026-10-03", Amount: -1},
+			},
+		},
+		{
+			name: "Quicken and year-first dates",
+			data: "!Type:Bank\nD10/ 3'26\nT-1\n^\nD12/31/99\nT-1\n^\nD1/2/05\nT-1\n^\nD2026-10-03\nT-1\n^\n",
+			want: []Transaction{
+				{Date: "2026-10-03", Amount: -1},
+				{Date: "1999-12-31", Amount: -1},
+				{Date: "2005-01-02", Amount: -1},
+				{Date: "2026-10-03", Amount: -1},
+			},
+		},
+		{
+			name: "decimal comma and thousands separators",
+			data: "!Type:Bank\nD25/10/2026\nT-12,50\n^\nD25/10/2026\nT1.234,56\n^\nD25/10/2026\nT1,234.56\n^\nD25/10/2026\nT$-5.00\n^\n",
+			want: []Transaction{
+				{Date: "2026-10-25", Amount: -12.5},
+				{Date: "2026-10-25", Amount: 1234.56},
+				{Date: "2026-10-25", Amount: 1234.56},
+				{Date: "2026-10-25", Amount: -5},
+			},
+		},
+		{
+			name: "split lines",
+			data: "!Type:CCard\n" +
+				"D10/03/2026\nT-60.00\nPMarket\nL--Split--\n" +
+				"SFood:Groceries\nEFruit\n$-45.00\n" +
+				"SHousehold/Home\n$-15.00\n^\n",
+			want: []Transaction{
+				{Date: "2026-10-03", Amount: -60, Description: "Market", Splits: []Split{
+					{Category: "Food:Groceries", Amount: -45, Memo: "Fruit"},
+					{Category: "Household", Amount: -15},
+				}},
+			},
+		},
+		{
+			name: "split categories without amounts are dropped",
+			data: "!Type:Bank\nD10/03/2026\nT-60.00\nSFood\nSHousehold\n^\n",
+			want: []Transaction{
+				{Date: "2026-10-03", Amount: -60},
+			},
+		},
+		{
+			name: "account list and skipped sections",
+			data: "!Type:Cat\nNFood\nE\n^\n" +
+				"!Account\nNEveryday Checking\nTBank\n^\n" +
+				"!Type:Bank\nD10/03/2026\nT-1\nPShop\n^\n" +
+				"!Type:Invst\nD10/04/2026\nNBuy\nYACME\nT-500\n^\n" +
+				"!Account\nNVisa\nTCCard\n^\n" +
+				"!Type:CCard\nD10/05/2026\nT-2\nPCafe\n",
+			want: []Transaction{
+				{Date: "2026-10-03", Amount: -1, Description: "Shop", Account: "Everyday Checking"},
+				{Date: "2026-10-05", Amount: -2, Description: "Cafe", Account: "Visa"},
+			},
+		},
+		{
+			name: "byte order mark and CRLF",
+			data: "\xef\xbb\xbf!Type:Bank\r\nD10/03/2026\r\nT-1\r\nPCafé\r\n^\r\n",
+			want: []Transaction{
+				{Date: "2026-10-03", Amount: -1, Description: "Café"},
+			},
+		},
+		{
+			name: "Windows-1252 text",
+			data: "!Type:Bank\nD10/03/2026\nT-1\nPCaf\xe9\n^\n",
+			want: []Transaction{
+				{Date: "2026-10-03", Amount: -1, Description: "Café"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseQIF([]byte(tt.data), Options{DateOrder: tt.order})
+			if err != nil {
+				t.Fatalf("ParseQIF: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d transactions, want %d", len(got), len(tt.want))
+			}
+			for i := range tt.want {
+				if !sameTransaction(got[i], tt.want[i]) {
+					t.Errorf("transaction %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseQIFErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		order string
+	}{
+		{"no section", "D10/03/2026\nT-1\n^\n", ""},
+		{"invalid date order", "!Type:Bank\nD10/03/2026\nT-1\n^\n", "ymd"},
+		{"no such date", "!Type:Bank\nD02/30/2026\nT-1\n^\n", ""},
+		{"day first date read month first", "!Type:Bank\nD25/10/2026\nT-1\n^\n", "mdy"},
+		{"date with two parts", "!Type:Bank\nD10/2026\nT-1\n^\n", ""},
+		{"invalid amount", "!Type:Bank\nD10/03/2026\nTabc\n^\n", ""},
+		{"invalid split amount", "!Type:Bank\nD10/03/2026\nT-1\nSFood\n$x\n^\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseQIF([]byte(tt.data), Options{DateOrder: tt.order}); err == nil {
+				t.Error("ParseQIF succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestQIFAmount(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"-42.10", -42.1},
+		{"12,50", 12.5},
+		{"-0,5", -0.5},
+		{"1,234", 1234},
+		{"1,234.56", 1234.56},
+		{"1.234,56", 1234.56},
+		{"1 234,56", 1234.56},
+		{"$-5.00", -5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := qifAmount(tt.value)
+			if err != nil {
+				t.Fatalf("qifAmount(%q): %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("qifAmount(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteQIFRoundTrip(t *testing.T) {
+	transactions := []Transaction{
+		{Date: "2026-10-05", Amount: -2, Description: "Cafe", Account: "Visa", Category: "Food"},
+		{Date: "2026-10-03", Amount: -60, Description: "Market", Memo: "Saturday", Account: "Checking", Splits: []Split{
+			{Category: "Food:Groceries", Amount: -45, Memo: "Fruit"},
+			{Category: "Home", Amount: -15},
+		}},
+		{Date: "2026-10-01", Amount: 1500, Description: "Payroll", Category: "Salary"},
+	}
+	want := []Transaction{transactions[2], transactions[1], transactions[0]}
+
+	var buf bytes.Buffer
+	categories := []QIFCategory{{Name: "Salary", Income: true}, {Name: "Food"}}
+	if err := WriteQIF(&buf, categories, transactions); err != nil {
+		t.Fatalf("WriteQIF: %v", err)
+	}
+	got, err := ParseQIF(buf.Bytes(), Options{})
+	if err != nil {
+		t.Fatalf("ParseQIF: %v\n%s", err, buf.String())
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d transactions, want %d\n%s", len(got), len(want), buf.String())
+	}
+	for i := range want {
+		if !sameTransaction(got[i], want[i]) {
+			t.Errorf("transaction %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
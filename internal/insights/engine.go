@@ -0,0 +1,346 @@
+package insights
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/repository"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	// recentDays is how far back new expenses are examined on each run
+	recentDays = 30
+	// historyDays is how much history backs the statistics
+	historyDays = 365
+	// minCategorySamples is the history needed before judging an expense as large
+	minCategorySamples = 5
+	// largeExpenseSigmas is how many standard deviations above the mean counts as large
+	largeExpenseSigmas = 3.0
+	// largeExpenseRatio is the minimum multiple of the category mean for a large expense
+	largeExpenseRatio = 2.0
+	// spikeTrailingMonths is the number of months averaged as the spike baseline
+	spikeTrailingMonths = 3
+	// spikeRatio is the minimum multiple of the trailing average for a spike
+	spikeRatio = 1.5
+	// spikeMinIncrease is the smallest absolute increase reported as a spike
+	spikeMinIncrease = 20.0
+	// minMerchantHistory is how many earlier expenses a user needs before new merchants are flagged
+	minMerchantHistory = 10
+)
+
+// Engine detects unusual spending and records it as insights
+type Engine struct {
+	db          *sql.DB
+	insightRepo *repository.InsightRepository
+
+	mu      sync.Mutex
+	pending map[int64]bool
+	queue   chan int64
+}
+
+// NewEngine creates a new insights engine
+func NewEngine(db *sql.DB, insightRepo *repository.InsightRepository) *Engine {
+	return &Engine{
+		db:          db,
+		insightRepo: insightRepo,
+		pending:     make(map[int64]bool),
+		queue:       make(chan int64, 256),
+	}
+}
+
+// Start launches the background worker and the nightly run
+func (e *Engine) Start() {
+	go e.worker()
+	go e.nightly()
+}
+
+// Trigger schedules an analysis of a user's data. Repeated triggers
+// for a user that is already queued are coalesced.
+func (e *Engine) Trigger(userID int64) {
+	e.mu.Lock()
+	if e.pending[userID] {
+		e.mu.Unlock()
+		return
+	}
+	e.pending[userID] = true
+	e.mu.Unlock()
+
+	select {
+	case e.queue <- userID:
+	default:
+		// Queue full; the nightly run will catch up
+		e.mu.Lock()
+		delete(e.pending, userID)
+		e.mu.Unlock()
+	}
+}
+
+// worker analyzes queued users one at a time
+func (e *Engine) worker() {
+	for userID := range e.queue {
+		e.mu.Lock()
+		delete(e.pending, userID)
+		e.mu.Unlock()
+
+		if err := e.Analyze(userID, time.Now()); err != nil {
+			log.Printf("insights: analysis failed for user %d: %v", userID, err)
+		}
+	}
+}
+
+// nightly analyzes every user shortly after midnight
+func (e *Engine) nightly() {
+	for {
+		now := time.Now()
+		next := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 5, 0, 0, now.Location())
+		time.Sleep(next.Sub(now))
+
+		if err := e.AnalyzeAll(time.Now()); err != nil {
+			log.Printf("insights: nightly run failed: %v", err)
+		}
+	}
+}
+
+// AnalyzeAll runs the detectors for every user
+func (e *Engine) AnalyzeAll(now time.Time) error {
+	rows, err := e.db.Query(`SELECT id FROM users`)
+	if err != nil {
+		return fmt.Errorf("failed to query users: %w", err)
+	}
+
+	var userIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan user: %w", err)
+		}
+		userIDs = append(userIDs, id)
+	}
+	rows.Close()
+
+	for _, userID := range userIDs {
+		if err := e.Analyze(userID, now); err != nil {
+			log.Printf("insights: analysis failed for user %d: %v", userID, err)
+		}
+	}
+
+	return nil
+}
+
+// expenseRow is the subset of an expense the detectors need
+type expenseRow struct {
+	ID           int64
+	CategoryID   int64
+	CategoryName string
+	Amount       float64
+	Description  string
+	Date         string
+}
+
+// Analyze runs all detectors for a user and stores any new insights
+func (e *Engine) Analyze(userID int64, now time.Time) error {
+	since := now.AddDate(0, 0, -historyDays).Format("2006-01-02")
+	expenses, err := e.loadExpenses(userID, since)
+	if err != nil {
+		return err
+	}
+
+	recentSince := now.AddDate(0, 0, -recentDays).Format("2006-01-02")
+
+	var found []models.Insight
+	found = append(found, detectLargeExpenses(userID, expenses, recentSince)...)
+	found = append(found, detectNewMerchants(userID, expenses, recentSince)...)
+
+	spikes, err := e.detectCategorySpikes(userID, now)
+	if err != nil {
+		return err
+	}
+	found = append(found, spikes...)
+
+	for i := range found {
+		if _, err := e.insightRepo.Create(&found[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadExpenses loads a user's expenses since a date in chronological order,
+// with split expenses broken into one row per split
+func (e *Engine) loadExpenses(userID int64, since string) ([]expenseRow, error) {
+	query := `
+		SELECT l.expense_id, l.category_id, c.name, l.amount, COALESCE(l.description, ''), l.expense_date
+		FROM expense_lines l
+		JOIN categories c ON l.category_id = c.id
+		WHERE l.user_id = ? AND l.household_id IS NULL AND l.expense_date >= ? AND l.deleted_at IS NULL
+		ORDER BY l.expense_date, l.expense_id
+	`
+
+	rows, err := e.db.Query(query, userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expenses: %w", err)
+	}
+	defer rows.Close()
+
+	var expenses []expenseRow
+	for rows.Next() {
+		var row expenseRow
+		if err := rows.Scan(&row.ID, &row.CategoryID, &row.CategoryName, &row.Amount, &row.Description, &row.Date); err != nil {
+			return nil, fmt.Errorf("failed to scan expense: %w", err)
+		}
+		row.Date = repository.DateOnly(row.Date)
+		expenses = append(expenses, row)
+	}
+
+	return expenses, nil
+}
+
+// detectLargeExpenses flags recent expenses far above their category's earlier history
+func detectLargeExpenses(userID int64, expenses []expenseRow, recentSince string) []models.Insight {
+	var found []models.Insight
+	history := make(map[int64][]float64)
+
+	for _, expense := range expenses {
+		samples := history[expense.CategoryID]
+		history[expense.CategoryID] = append(samples, expense.Amount)
+
+		if expense.Date < recentSince || len(samples) < minCategorySamples {
+			continue
+		}
+
+		mean, std := meanStd(samples)
+		if expense.Amount <= mean+largeExpenseSigmas*std || expense.Amount < largeExpenseRatio*mean {
+			continue
+		}
+
+		expenseID, categoryID := expense.ID, expense.CategoryID
+		found = append(found, models.Insight{
+			UserID:     userID,
+			Kind:       "large_expense",
+			DedupeKey:  fmt.Sprintf("large_expense:%d", expense.ID),
+			Title:      fmt.Sprintf("Unusually large %s expense", expense.CategoryName),
+			Message:    fmt.Sprintf("%.2f on %s is %.1fx your usual %s expense of %.2f.", expense.Amount, expense.Date, expense.Amount/mean, expense.CategoryName, mean),
+			ExpenseID:  &expenseID,
+			CategoryID: &categoryID,
+			Amount:     expense.Amount,
+		})
+	}
+
+	return found
+}
+
+// detectNewMerchants flags recent expenses whose description has never been seen before
+func detectNewMerchants(userID int64, expenses []expenseRow, recentSince string) []models.Insight {
+	var found []models.Insight
+	seen := make(map[string]bool)
+
+	for i, expense := range expenses {
+		merchant := MerchantKey(expense.Description)
+		if merchant == "" {
+			continue
+		}
+		isNew := !seen[merchant]
+		seen[merchant] = true
+
+		if !isNew || expense.Date < recentSince || i < minMerchantHistory {
+			continue
+		}
+
+		expenseID, categoryID := expense.ID, expense.CategoryID
+		found = append(found, models.Insight{
+			UserID:     userID,
+			Kind:       "new_merchant",
+			DedupeKey:  "new_merchant:" + merchant,
+			Title:      "New merchant",
+			Message:    fmt.Sprintf("First expense at \"%s\": %.2f on %s.", expense.Description, expense.Amount, expense.Date),
+			ExpenseID:  &expenseID,
+			CategoryID: &categoryID,
+			Amount:     expense.Amount,
+		})
+	}
+
+	return found
+}
+
+// detectCategorySpikes compares this month's spending per category with the trailing average
+func (e *Engine) detectCategorySpikes(userID int64, now time.Time) ([]models.Insight, error) {
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	month := current.Format("2006-01")
+	from := current.AddDate(0, -spikeTrailingMonths, 0).Format("2006-01")
+
+	query := `
+		SELECT r.category_id, c.name,
+			SUM(CASE WHEN r.month = ? THEN r.total ELSE 0 END),
+			SUM(CASE WHEN r.month < ? THEN r.total ELSE 0 END)
+		FROM monthly_rollup r
+		JOIN categories c ON r.category_id = c.id
+		WHERE r.user_id = ? AND r.type = 'expense' AND r.month >= ? AND r.month <= ?
+		GROUP BY r.category_id, c.name
+	`
+
+	rows, err := e.db.Query(query, month, month, userID, from, month)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rollups: %w", err)
+	}
+	defer rows.Close()
+
+	var found []models.Insight
+	for rows.Next() {
+		var categoryID int64
+		var name string
+		var currentTotal, trailingTotal float64
+		if err := rows.Scan(&categoryID, &name, &currentTotal, &trailingTotal); err != nil {
+			return nil, fmt.Errorf("failed to scan rollup: %w", err)
+		}
+
+		average := trailingTotal / spikeTrailingMonths
+		if average <= 0 || currentTotal < spikeRatio*average || currentTotal-average < spikeMinIncrease {
+			continue
+		}
+
+		id := categoryID
+		found = append(found, models.Insight{
+			UserID:     userID,
+			Kind:       "category_spike",
+			DedupeKey:  fmt.Sprintf("category_spike:%d:%s", categoryID, month),
+			Title:      fmt.Sprintf("%s spending is up", name),
+			Message:    fmt.Sprintf("%s spending this month (%.2f) is %.0f%% above your %d-month average (%.2f).", name, currentTotal, (currentTotal/average-1)*100, spikeTrailingMonths, average),
+			CategoryID: &id,
+			Amount:     currentTotal,
+		})
+	}
+
+	return found, nil
+}
+
+// MerchantKey normalizes a description into a merchant identifier by
+// lowercasing it and dropping digits and punctuation
+func MerchantKey(description string) string {
+	fields := strings.FieldsFunc(strings.ToLower(description), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	return strings.Join(fields, " ")
+}
+
+// meanStd returns the mean and population standard deviation of values
+func meanStd(values []float64) (float64, float64) {
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
@@ -2,8 +2,11 @@ package middleware
 
 import (
 	"context"
+	"myexpress-tracker/internal/audit"
 	"myexpress-tracker/internal/auth"
+	"myexpress-tracker/internal/models"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
@@ -17,10 +20,24 @@ const (
 	UserEmailKey ContextKey = "user_email"
 	// UsernameKey is the context key for username
 	UsernameKey ContextKey = "username"
+	// HouseholdIDKey is the context key for the household ledger a request works in
+	HouseholdIDKey ContextKey = "household_id"
+	// HouseholdRoleKey is the context key for the user's role in that household
+	HouseholdRoleKey ContextKey = "household_role"
 )
 
-// AuthMiddleware validates JWT tokens and adds user info to context
-func AuthMiddleware(authService *auth.Service) func(http.Handler) http.Handler {
+// HouseholdHeader selects a shared household ledger instead of the personal one
+const HouseholdHeader = "X-Household-ID"
+
+// HouseholdRoles looks up a user's role in a household ("" when not a member)
+type HouseholdRoles interface {
+	Role(householdID, userID int64) (string, error)
+}
+
+// AuthMiddleware validates JWT tokens and adds user info to context. When the
+// X-Household-ID header is set it also checks the user belongs to that household
+// and that viewers only read.
+func AuthMiddleware(authService *auth.Service, households HouseholdRoles) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Get token from Authorization header
@@ -50,6 +67,33 @@ func AuthMiddleware(authService *auth.Service) func(http.Handler) http.Handler {
 			ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
 			ctx = context.WithValue(ctx, UserEmailKey, claims.Email)
 			ctx = context.WithValue(ctx, UsernameKey, claims.Username)
+			ctx = audit.WithActor(ctx, claims.UserID)
+
+			// Resolve the household ledger, if one was selected
+			if header := r.Header.Get(HouseholdHeader); header != "" {
+				householdID, err := strconv.ParseInt(header, 10, 64)
+				if err != nil || householdID <= 0 {
+					http.Error(w, `{"error":"invalid X-Household-ID header"}`, http.StatusBadRequest)
+					return
+				}
+
+				role, err := households.Role(householdID, claims.UserID)
+				if err != nil {
+					http.Error(w, `{"error":"failed to check household membership"}`, http.StatusInternalServerError)
+					return
+				}
+				if role == "" {
+					http.Error(w, `{"error":"you are not a member of this household"}`, http.StatusForbidden)
+					return
+				}
+				if role == models.RoleViewer && r.Method != http.MethodGet && r.Method != http.MethodHead {
+					http.Error(w, `{"error":"viewers cannot make changes in this household"}`, http.StatusForbidden)
+					return
+				}
+
+				ctx = context.WithValue(ctx, HouseholdIDKey, householdID)
+				ctx = context.WithValue(ctx, HouseholdRoleKey, role)
+			}
 
 			// Call next handler with updated context
 			next.ServeHTTP(w, r.WithContext(ctx))
@@ -57,12 +101,26 @@ func AuthMiddleware(authService *auth.Service) func(http.Handler) http.Handler {
 	}
 }
 
+// PersonalOnly rejects requests that select a household ledger, for endpoints
+// that only work with personal records. It goes before AuthMiddleware, so the
+// answer does not depend on the caller's role in the household.
+func PersonalOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(HouseholdHeader) != "" {
+			http.Error(w, `{"error":"this endpoint only works with personal records; leave out the X-Household-ID header"}`, http.StatusBadRequest)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // CORS middleware to handle cross-origin requests
 func CORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-None-Match, If-Match, Idempotency-Key, X-Request-ID, X-Household-ID, Last-Event-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, Idempotent-Replayed, X-Request-ID")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -78,3 +136,10 @@ func GetUserIDFromContext(r *http.Request) (int64, bool) {
 	userID, ok := r.Context().Value(UserIDKey).(int64)
 	return userID, ok
 }
+
+// GetHouseholdIDFromContext returns the household ledger selected for the
+// request, or 0 for the user's personal ledger
+func GetHouseholdIDFromContext(r *http.Request) int64 {
+	householdID, _ := r.Context().Value(HouseholdIDKey).(int64)
+	return householdID
+}
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"myexpress-tracker/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxIdempotencyKeyLength bounds the Idempotency-Key header
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyStore persists request fingerprints and their responses
+type IdempotencyStore interface {
+	Reserve(userID int64, key, fingerprint string, since time.Time) (*models.IdempotencyRecord, bool, error)
+	Complete(userID int64, key string, status int, contentType string, body []byte) error
+	Release(userID int64, key string) error
+}
+
+// Idempotency replays the original response when a request is retried with
+// the same Idempotency-Key within the window. Reusing a key with a different
+// request body is rejected with 422. Requests without the header pass through.
+// It must run after AuthMiddleware.
+func Idempotency(store IdempotencyStore, window time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > maxIdempotencyKeyLength {
+				http.Error(w, `{"error":"Idempotency-Key is too long"}`, http.StatusBadRequest)
+				return
+			}
+
+			userID, ok := GetUserIDFromContext(r)
+			if !ok {
+				http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			// A key reused in another household ledger must not replay
+			target := r.Method + " " + r.URL.Path
+			if householdID := GetHouseholdIDFromContext(r); householdID != 0 {
+				target += " household=" + strconv.FormatInt(householdID, 10)
+			}
+			sum := sha256.Sum256(append([]byte(target+"\n"), body...))
+			fingerprint := hex.EncodeToString(sum[:])
+
+			existing, reserved, err := store.Reserve(userID, key, fingerprint, time.Now().Add(-window))
+			if err != nil {
+				http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+				return
+			}
+
+			if !reserved {
+				switch {
+				case existing.Fingerprint != fingerprint:
+					http.Error(w, `{"error":"Idempotency-Key was already used with a different request"}`, http.StatusUnprocessableEntity)
+				case !existing.Completed:
+					http.Error(w, `{"error":"a request with this Idempotency-Key is still in progress"}`, http.StatusConflict)
+				default:
+					if existing.ContentType != "" {
+						w.Header().Set("Content-Type", existing.ContentType)
+					}
+					w.Header().Set("Idempotent-Replayed", "true")
+					w.WriteHeader(existing.Status)
+					w.Write(existing.Body)
+				}
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			// Server errors are not remembered so the client can retry
+			if recorder.status >= http.StatusInternalServerError {
+				if err := store.Release(userID, key); err != nil {
+					log.Printf("idempotency: failed to release key: %v", err)
+				}
+				return
+			}
+
+			if err := store.Complete(userID, key, recorder.status, recorder.Header().Get("Content-Type"), recorder.body.Bytes()); err != nil {
+				log.Printf("idempotency: failed to store response: %v", err)
+			}
+		})
+	}
+}
+
+// responseRecorder captures the status and body written by a handler
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader records the status code
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
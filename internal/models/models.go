@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
 // User represents a user in the system
 type User struct {
@@ -19,6 +23,8 @@ type Category struct {
 	ID        int64     `json:"id"`
 	Name      string    `json:"name"`
 	Type      string    `json:"type"` // "income" or "expense"
+	HouseholdID *int64  `json:"household_id,omitempty"` // Set for a household's own categories
+	UserID    *int64    `json:"user_id,omitempty"` // Set for a user's own personal categories
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -30,8 +36,12 @@ type Income struct {
 	Amount      float64   `json:"amount"`
 	Description string    `json:"description"`
 	IncomeDate  string    `json:"income_date"` // Date in YYYY-MM-DD format
+	Tags        string    `json:"tags"`    // Comma-separated tags
+	Account     string    `json:"account"` // Free-form account name (e.g. "Visa", "Checking")
+	HouseholdID *int64    `json:"household_id,omitempty"` // Set for records in a shared household ledger
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"` // Set while the record is in the trash
 	
 	// Joined fields
 	CategoryName string `json:"category_name,omitempty"`
@@ -45,13 +55,30 @@ type Expense struct {
 	Amount      float64   `json:"amount"`
 	Description string    `json:"description"`
 	ExpenseDate string    `json:"expense_date"` // Date in YYYY-MM-DD format
+	Tags        string    `json:"tags"`    // Comma-separated tags
+	Account     string    `json:"account"` // Free-form account name (e.g. "Visa", "Checking")
+	HouseholdID *int64    `json:"household_id,omitempty"` // Set for records in a shared household ledger
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"` // Set while the record is in the trash
+	Splits      []ExpenseSplit `json:"splits,omitempty"` // Line items when the expense covers several categories
+	Shared      *SharedBill    `json:"shared,omitempty"` // Set when the expense is a bill shared with others
 	
 	// Joined fields
 	CategoryName string `json:"category_name,omitempty"`
 }
 
+// ExpenseSplit is one line item of a split expense. The amounts of an
+// expense's splits always add up to the expense amount.
+type ExpenseSplit struct {
+	CategoryID int64   `json:"category_id"`
+	Amount     float64 `json:"amount"`
+	Note       string  `json:"note"`
+
+	// Joined fields
+	CategoryName string `json:"category_name,omitempty"`
+}
+
 // DashboardSummary represents dashboard statistics
 type DashboardSummary struct {
 	TotalIncome      float64            `json:"total_income"`
@@ -63,6 +90,18 @@ type DashboardSummary struct {
 	MonthlyExpense   float64            `json:"monthly_expense"`
 	DailyData        []DailyData        `json:"daily_data"`
 	CategoryBreakdown CategoryBreakdown `json:"category_breakdown"`
+	Members          []MemberSummary    `json:"members,omitempty"` // Per-member totals on a household dashboard
+	Goals            []SavingsGoal      `json:"goals,omitempty"`   // Savings goals and their progress on a personal dashboard
+}
+
+// MemberSummary attributes a household's income and expense to one member
+type MemberSummary struct {
+	UserID         int64   `json:"user_id"`
+	Username       string  `json:"username"`
+	TotalIncome    float64 `json:"total_income"`
+	TotalExpense   float64 `json:"total_expense"`
+	MonthlyIncome  float64 `json:"monthly_income"`
+	MonthlyExpense float64 `json:"monthly_expense"`
 }
 
 // DailyData represents income and expense for a specific day
@@ -77,3 +116,913 @@ type CategoryBreakdown struct {
 	IncomeByCategory  map[string]float64 `json:"income_by_category"`
 	ExpenseByCategory map[string]float64 `json:"expense_by_category"`
 }
+
+// MonthlyRollup is one user/month/category bucket of the rollup table
+type MonthlyRollup struct {
+	Month        string  `json:"month"` // Month in YYYY-MM format
+	Type         string  `json:"type"`  // "income" or "expense"
+	CategoryID   int64   `json:"category_id"`
+	CategoryName string  `json:"category_name"`
+	Total        float64 `json:"total"`
+	Count        int     `json:"count"`
+}
+
+// TrendDelta is the change of a value against an earlier period.
+// Percent is nil when the earlier value is zero.
+type TrendDelta struct {
+	Amount  float64  `json:"amount"`
+	Percent *float64 `json:"percent"`
+}
+
+// MonthlyTrend holds income, expense and net for a month with comparisons
+type MonthlyTrend struct {
+	Month      string     `json:"month"`
+	Income     float64    `json:"income"`
+	Expense    float64    `json:"expense"`
+	Net        float64    `json:"net"`
+	IncomeMoM  TrendDelta `json:"income_mom"`
+	ExpenseMoM TrendDelta `json:"expense_mom"`
+	NetMoM     TrendDelta `json:"net_mom"`
+	IncomeYoY  TrendDelta `json:"income_yoy"`
+	ExpenseYoY TrendDelta `json:"expense_yoy"`
+	NetYoY     TrendDelta `json:"net_yoy"`
+}
+
+// CategoryMonth holds a category's total for a month with comparisons
+type CategoryMonth struct {
+	Month string     `json:"month"`
+	Total float64    `json:"total"`
+	MoM   TrendDelta `json:"mom"`
+	YoY   TrendDelta `json:"yoy"`
+}
+
+// CategoryTrend is the monthly history of a single category
+type CategoryTrend struct {
+	CategoryID   int64           `json:"category_id"`
+	CategoryName string          `json:"category_name"`
+	Type         string          `json:"type"`
+	Months       []CategoryMonth `json:"months"`
+}
+
+// CategoryMover is an expense category whose spending changed notably
+type CategoryMover struct {
+	CategoryID    int64      `json:"category_id"`
+	CategoryName  string     `json:"category_name"`
+	Month         string     `json:"month"`          // The last complete month, YYYY-MM
+	PreviousMonth string     `json:"previous_month"` // The month before it
+	Current       float64    `json:"current"`
+	Previous      float64    `json:"previous"`
+	Change        TrendDelta `json:"change"`
+}
+
+// TrendReport is the month-over-month and year-over-year report
+type TrendReport struct {
+	Months        []MonthlyTrend  `json:"months"`
+	Categories    []CategoryTrend `json:"categories"`
+	BiggestMovers []CategoryMover `json:"biggest_movers"`
+}
+
+// RecurringSchedule is a monthly transaction inferred from history
+type RecurringSchedule struct {
+	Type         string  `json:"type"` // "income" or "expense"
+	CategoryID   int64   `json:"category_id"`
+	CategoryName string  `json:"category_name"`
+	Description  string  `json:"description"`
+	Amount       float64 `json:"amount"`
+	DayOfMonth   int     `json:"day_of_month"`
+	Occurrences  int     `json:"occurrences"`
+}
+
+// ForecastEvent is a known or expected transaction on a projected day
+type ForecastEvent struct {
+	Type         string  `json:"type"` // "income" or "expense"
+	CategoryName string  `json:"category_name"`
+	Description  string  `json:"description"`
+	Amount       float64 `json:"amount"`
+	Source       string  `json:"source"` // "scheduled", "bill" or "recurring"
+}
+
+// ForecastDay is the projected activity and balance for one day
+type ForecastDay struct {
+	Date          string          `json:"date"`
+	Income        float64         `json:"income"`
+	Expense       float64         `json:"expense"`
+	Discretionary float64         `json:"discretionary"`
+	Balance       float64         `json:"balance"`
+	Events        []ForecastEvent `json:"events"`
+}
+
+// Forecast is a day-by-day balance projection
+type Forecast struct {
+	StartingBalance    float64             `json:"starting_balance"`
+	EndingBalance      float64             `json:"ending_balance"`
+	LowestBalance      float64             `json:"lowest_balance"`
+	LowestBalanceDate  string              `json:"lowest_balance_date"`
+	DailyDiscretionary map[string]float64  `json:"daily_discretionary"`
+	Recurring          []RecurringSchedule `json:"recurring"`
+	Days               []ForecastDay       `json:"days"`
+}
+
+// Insight is a notable event detected in a user's spending
+type Insight struct {
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"user_id"`
+	Kind        string     `json:"kind"` // "large_expense", "category_spike" or "new_merchant"
+	DedupeKey   string     `json:"-"`
+	Title       string     `json:"title"`
+	Message     string     `json:"message"`
+	ExpenseID   *int64     `json:"expense_id,omitempty"`
+	CategoryID  *int64     `json:"category_id,omitempty"`
+	Amount      float64    `json:"amount"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DismissedAt *time.Time `json:"dismissed_at,omitempty"`
+}
+
+// CategoryRule assigns a category, tags and a cleaned-up description to
+// expenses matching all of its conditions. Empty conditions always match.
+type CategoryRule struct {
+	ID       int64  `json:"id"`
+	UserID   int64  `json:"user_id"`
+	Name     string `json:"name"`
+	Priority int    `json:"priority"` // Higher priority rules are evaluated first
+	Enabled  bool   `json:"enabled"`
+
+	// Conditions
+	DescriptionContains string   `json:"description_contains"` // Case-insensitive substring
+	DescriptionRegex    string   `json:"description_regex"`
+	MinAmount           *float64 `json:"min_amount"`
+	MaxAmount           *float64 `json:"max_amount"`
+	Account             string   `json:"account"`
+	Weekday             *int     `json:"weekday"` // 0 = Sunday ... 6 = Saturday
+
+	// Actions
+	SetCategoryID  *int64 `json:"set_category_id"`
+	SetTags        string `json:"set_tags"`        // Comma-separated tags to add
+	SetDescription string `json:"set_description"` // Replacement description
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RuleMatch shows how a rule would change an existing expense
+type RuleMatch struct {
+	Expense Expense `json:"expense"`
+	After   Expense `json:"after"`
+	Changed bool    `json:"changed"`
+}
+
+// CategorySuggestion is a category predicted from the user's history
+type CategorySuggestion struct {
+	CategoryID   int64   `json:"category_id"`
+	CategoryName string  `json:"category_name"`
+	Confidence   float64 `json:"confidence"`
+}
+
+// IdempotencyRecord is a stored request fingerprint and, once the request
+// finished, its response
+type IdempotencyRecord struct {
+	Fingerprint string
+	Completed   bool
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// AuditEntry is one append-only record of a change to a user's data
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	UserID    int64           `json:"user_id"`
+	ActorID   *int64          `json:"actor_id"` // Nil for changes made by the system
+	ActorName string          `json:"actor_name,omitempty"`
+	Entity    string          `json:"entity"` // "income", "expense" or "rule"
+	EntityID  int64           `json:"entity_id"`
+	Action    string          `json:"action"` // "created", "updated", "deleted", "restored" or "purged"
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	Changes   json.RawMessage `json:"changes"` // Field name -> {"old", "new"}
+	RequestID string          `json:"request_id"`
+	IP        string          `json:"ip"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Bulk actions
+const (
+	BulkActionUpdate = "update"
+	BulkActionDelete = "delete"
+)
+
+// Bulk result statuses
+const (
+	BulkUpdated   = "updated"
+	BulkDeleted   = "deleted"
+	BulkUnchanged = "unchanged"
+	BulkFailed    = "failed"
+)
+
+// BulkRequest selects income or expense records by ID or by filter and
+// describes the change to apply to all of them
+type BulkRequest struct {
+	Action string      `json:"action"` // "update" or "delete"
+	IDs    []int64     `json:"ids"`
+	Filter *BulkFilter `json:"filter"`
+	Set    BulkChanges `json:"set"`
+	DryRun bool        `json:"dry_run"`
+}
+
+// BulkFilter selects records the same way the list endpoints do
+type BulkFilter struct {
+	CategoryID int64  `json:"category_id"`
+	Date       string `json:"date"`
+	StartDate  string `json:"start_date"`
+	EndDate    string `json:"end_date"`
+}
+
+// Map converts the filter into the repositories' filter map
+func (f *BulkFilter) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"category_id": f.CategoryID,
+		"date":        f.Date,
+		"start_date":  f.StartDate,
+		"end_date":    f.EndDate,
+	}
+}
+
+// BulkChanges lists the fields a bulk update sets; nil fields are left alone
+type BulkChanges struct {
+	CategoryID *int64  `json:"category_id"`
+	Tags       *string `json:"tags"`
+	Date       *string `json:"date"`
+	Account    *string `json:"account"`
+}
+
+// BulkResult is the outcome for a single record
+type BulkResult struct {
+	ID     int64  `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BulkResponse reports the outcome of a bulk operation
+type BulkResponse struct {
+	Action    string       `json:"action"`
+	DryRun    bool         `json:"dry_run"`
+	Committed bool         `json:"committed"`
+	Matched   int          `json:"matched"`
+	Changed   int          `json:"changed"`
+	Unchanged int          `json:"unchanged"`
+	Failed    int          `json:"failed"`
+	Results   []BulkResult `json:"results"`
+}
+
+// NewBulkResponse summarizes per-record results
+func NewBulkResponse(req *BulkRequest, results []BulkResult) *BulkResponse {
+	response := &BulkResponse{
+		Action:  req.Action,
+		DryRun:  req.DryRun,
+		Matched: len(results),
+		Results: results,
+	}
+	for _, result := range results {
+		switch result.Status {
+		case BulkUpdated, BulkDeleted:
+			response.Changed++
+		case BulkUnchanged:
+			response.Unchanged++
+		case BulkFailed:
+			response.Failed++
+		}
+	}
+	return response
+}
+
+// Household member roles
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// ValidRole reports whether role is a known household role
+func ValidRole(role string) bool {
+	return role == RoleOwner || role == RoleEditor || role == RoleViewer
+}
+
+// Household is a shared ledger that several users can keep together
+type Household struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role,omitempty"` // The requesting user's role
+	CreatedAt time.Time `json:"created_at"`
+
+	Members []HouseholdMember `json:"members,omitempty"`
+}
+
+// HouseholdMember is a user's membership in a household
+type HouseholdMember struct {
+	HouseholdID int64     `json:"household_id"`
+	UserID      int64     `json:"user_id"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	Role        string    `json:"role"`
+	JoinedAt    time.Time `json:"joined_at"`
+}
+
+// Invitation statuses
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+	InvitationRevoked  = "revoked"
+)
+
+// HouseholdInvitation invites an existing user to join a household
+type HouseholdInvitation struct {
+	ID            int64      `json:"id"`
+	HouseholdID   int64      `json:"household_id"`
+	HouseholdName string     `json:"household_name,omitempty"`
+	InvitedBy     int64      `json:"invited_by"`
+	InviteeID     int64      `json:"invitee_id"`
+	Invitee       string     `json:"invitee,omitempty"` // Invitee's username
+	Role          string     `json:"role"`
+	Status        string     `json:"status"`
+	CreatedAt     time.Time  `json:"created_at"`
+	RespondedAt   *time.Time `json:"responded_at,omitempty"`
+}
+
+// Ways of sharing a bill
+const (
+	ShareEqual      = "equal"
+	SharePercentage = "percentage"
+	ShareExact      = "exact"
+)
+
+// SharedBill describes how a bill is shared between the expense's owner and
+// other people. The expense amount is the owner's own share, so only that
+// share counts towards the owner's totals.
+type SharedBill struct {
+	Method       string            `json:"method"` // equal, percentage or exact
+	Total        float64           `json:"total"`  // The whole bill
+	PaidBy       *Party            `json:"paid_by,omitempty"` // A participant who paid; omitted when the owner paid
+	Participants []BillParticipant `json:"participants"`
+}
+
+// Party is someone money is shared with: a registered user or one of the
+// user's named contacts
+type Party struct {
+	UserID    *int64 `json:"user_id,omitempty"`
+	ContactID *int64 `json:"contact_id,omitempty"`
+	User      string `json:"user,omitempty"` // Username or email, resolved to user_id on write
+	Name      string `json:"name,omitempty"` // Filled in on reads
+}
+
+// Key identifies the party, e.g. "user:2" or "contact:5"
+func (p Party) Key() string {
+	if p.ContactID != nil {
+		return fmt.Sprintf("contact:%d", *p.ContactID)
+	}
+	if p.UserID != nil {
+		return fmt.Sprintf("user:%d", *p.UserID)
+	}
+	return ""
+}
+
+// BillParticipant is someone other than the owner who shares a bill
+type BillParticipant struct {
+	Party
+	Percent *float64 `json:"percent,omitempty"` // The participant's percentage with the percentage method
+	Amount  float64  `json:"amount"`            // The participant's share; given with the exact method
+}
+
+// Contact is a named person, without an account, that a user shares bills with
+type Contact struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Debt is an amount one party owes another
+type Debt struct {
+	From   Party
+	To     Party
+	Amount float64
+}
+
+// Settlement records money paid from one party to another to settle up
+type Settlement struct {
+	ID         int64     `json:"id"`
+	RecordedBy int64     `json:"recorded_by"`
+	From       *Party    `json:"from,omitempty"` // Who paid; omitted on create when it was the user
+	To         *Party    `json:"to,omitempty"`   // Who was paid; omitted on create when it was the user
+	Amount     float64   `json:"amount"`
+	SettledOn  string    `json:"settled_on"` // Date in YYYY-MM-DD format
+	Note       string    `json:"note"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Balance is what one counterparty and the user owe each other overall.
+// A positive amount is owed to the user; a negative amount is owed by the user.
+type Balance struct {
+	Party  Party   `json:"party"`
+	Amount float64 `json:"amount"`
+}
+
+// Transfer is a suggested payment that helps settle up
+type Transfer struct {
+	From   Party   `json:"from"`
+	To     Party   `json:"to"`
+	Amount float64 `json:"amount"`
+}
+
+// BalanceSummary is a user's settle-up overview
+type BalanceSummary struct {
+	Balances    []Balance  `json:"balances"`
+	YouAreOwed  float64    `json:"you_are_owed"`
+	YouOwe      float64    `json:"you_owe"`
+	Suggestions []Transfer `json:"suggestions"` // Fewest payments that settle everyone in the user's shared bills
+}
+
+// SharedWithMe is a bill someone else shared with the user
+type SharedWithMe struct {
+	ExpenseID   int64   `json:"expense_id"`
+	Owner       Party   `json:"owner"`
+	Description string  `json:"description"`
+	ExpenseDate string  `json:"expense_date"`
+	Method      string  `json:"method"`
+	Total       float64 `json:"total"`
+	PaidBy      Party   `json:"paid_by"`
+	MyShare     float64 `json:"my_share"`
+}
+
+// Savings goal statuses
+const (
+	GoalCompleted  = "completed"   // Saved at least the target amount
+	GoalOnTrack    = "on_track"    // Saved at least as much as a steady pace would by today
+	GoalBehind     = "behind"      // Saved less than a steady pace would by today
+	GoalOverdue    = "overdue"     // Target date passed before reaching the target
+	GoalInProgress = "in_progress" // No target date to measure the pace against
+)
+
+// SavingsGoal is an amount a user is saving towards. Money counts towards it
+// through contributions and, when linked, through the user's records in an
+// account or category from the start date on.
+type SavingsGoal struct {
+	ID           int64         `json:"id"`
+	UserID       int64         `json:"user_id"`
+	Name         string        `json:"name"`
+	TargetAmount float64       `json:"target_amount"`
+	TargetDate   *string       `json:"target_date,omitempty"` // Date in YYYY-MM-DD format
+	StartDate    string        `json:"start_date"`            // Date in YYYY-MM-DD format; defaults to the creation date
+	Account      string        `json:"account,omitempty"`     // Linked account: income into it adds, expenses from it subtract
+	CategoryID   *int64        `json:"category_id,omitempty"` // Linked category: its income or expenses add
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	Progress     *GoalProgress `json:"progress,omitempty"`
+
+	// Joined fields
+	CategoryName string `json:"category_name,omitempty"`
+}
+
+// GoalContribution is money set aside for a savings goal; a negative amount is a withdrawal
+type GoalContribution struct {
+	ID            int64     `json:"id"`
+	GoalID        int64     `json:"goal_id"`
+	Amount        float64   `json:"amount"`
+	ContributedOn string    `json:"contributed_on"` // Date in YYYY-MM-DD format
+	Note          string    `json:"note"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// GoalProgress is how far along a savings goal is
+type GoalProgress struct {
+	Contributed     float64  `json:"contributed"` // From contributions
+	Linked          float64  `json:"linked"`      // From the linked account or category
+	Saved           float64  `json:"saved"`
+	Remaining       float64  `json:"remaining"`
+	Percent         float64  `json:"percent"`
+	MonthsLeft      *int     `json:"months_left,omitempty"`
+	RequiredMonthly *float64 `json:"required_monthly,omitempty"` // Monthly saving needed to reach the target on time
+	Status          string   `json:"status"`
+}
+
+// Loan is money a user owes and pays back in regular instalments
+type Loan struct {
+	ID            int64       `json:"id"`
+	UserID        int64       `json:"user_id"`
+	Name          string      `json:"name"`
+	Lender        string      `json:"lender"`
+	Principal     float64     `json:"principal"`
+	AnnualRate    float64     `json:"annual_rate"` // Interest rate in percent per year
+	TermMonths    int         `json:"term_months"`
+	Frequency     string      `json:"frequency"`      // "monthly", "biweekly" or "weekly"
+	StartDate     string      `json:"start_date"`     // Date of the first payment in YYYY-MM-DD format
+	PaymentAmount float64     `json:"payment_amount"` // Regular payment; worked out from the terms when left out
+	CategoryID    *int64      `json:"category_id,omitempty"` // Expense category for recorded payments
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+	Status        *LoanStatus `json:"status,omitempty"`
+
+	// Joined fields
+	CategoryName string `json:"category_name,omitempty"`
+}
+
+// LoanStatus is where a loan stands after the payments recorded so far
+type LoanStatus struct {
+	Balance           float64 `json:"balance"`
+	PrincipalPaid     float64 `json:"principal_paid"`
+	InterestPaid      float64 `json:"interest_paid"`
+	PaymentsMade      int     `json:"payments_made"`
+	NextPaymentDate   string  `json:"next_payment_date,omitempty"`
+	RemainingPayments int     `json:"remaining_payments"`
+	RemainingInterest float64 `json:"remaining_interest"`
+	PayoffDate        string  `json:"payoff_date,omitempty"`
+}
+
+// LoanPayment is a recorded payment split into principal and interest
+type LoanPayment struct {
+	ID          int64     `json:"id"`
+	LoanID      int64     `json:"loan_id"`
+	PaymentDate string    `json:"payment_date"` // Date in YYYY-MM-DD format
+	Amount      float64   `json:"amount"`
+	Principal   float64   `json:"principal"`
+	Interest    float64   `json:"interest"`
+	Balance     float64   `json:"balance"` // Balance left after the payment
+	ExpenseID   *int64    `json:"expense_id,omitempty"` // The expense recording the payment, if any
+	Note        string    `json:"note"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Bill statuses
+const (
+	BillUpcoming = "upcoming" // Next due later than the reminder window
+	BillDueSoon  = "due_soon" // Next due within the reminder window
+	BillOverdue  = "overdue"  // Next due date has passed
+	BillPaid     = "paid"     // A one-off bill that has been paid
+)
+
+// Bill is a payment due on a date, once or on a recurring schedule
+type Bill struct {
+	ID           int64     `json:"id"`
+	UserID       int64     `json:"user_id"`
+	Name         string    `json:"name"`
+	Amount       float64   `json:"amount"`
+	CategoryID   int64     `json:"category_id"` // Expense category of the payments
+	Account      string    `json:"account"`
+	FirstDueDate string    `json:"first_due_date"`          // Date in YYYY-MM-DD format; anchors the recurrence
+	NextDueDate  *string   `json:"next_due_date,omitempty"` // Oldest unpaid due date; unset once a one-off bill is paid
+	Recurrence   string    `json:"recurrence"`              // "none", "weekly", "biweekly", "monthly", "quarterly" or "yearly"
+	RemindDays   int       `json:"remind_days"`             // Days before the due date that the bill is due soon
+	Notes        string    `json:"notes"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	// Calculated fields
+	Status       string `json:"status,omitempty"`
+	DaysUntilDue *int   `json:"days_until_due,omitempty"` // Negative when overdue
+
+	// Joined fields
+	CategoryName string `json:"category_name,omitempty"`
+}
+
+// BillPayment records paying one occurrence of a bill
+type BillPayment struct {
+	ID        int64     `json:"id"`
+	BillID    int64     `json:"bill_id"`
+	DueDate   string    `json:"due_date"` // The occurrence that was paid
+	PaidOn    string    `json:"paid_on"`
+	Amount    float64   `json:"amount"`
+	ExpenseID *int64    `json:"expense_id,omitempty"` // The expense recording the payment
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BillOccurrence is one upcoming due date of a bill
+type BillOccurrence struct {
+	BillID       int64   `json:"bill_id"`
+	Name         string  `json:"name"`
+	Amount       float64 `json:"amount"`
+	DueDate      string  `json:"due_date"`
+	CategoryName string  `json:"category_name"`
+	Status       string  `json:"status"`
+}
+
+// Notification event types
+const (
+	NotifyBillDue     = "bill_due"     // A bill enters its reminder window
+	NotifyBillOverdue = "bill_overdue" // A bill's due date passed unpaid
+	NotifyAnomaly     = "anomaly"      // The insights engine found unusual spending
+	NotifyTest        = "test"         // Sent on request to try out the channels
+)
+
+// Notification delivery channels
+const (
+	ChannelInApp   = "in_app"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// Notification delivery statuses
+const (
+	DeliveryPending = "pending"
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed" // Gave up after the last retry
+)
+
+// Notification is a message to a user about an event
+type Notification struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	Event     string     `json:"event"`
+	DedupeKey string     `json:"-"`
+	Title     string     `json:"title"`
+	Message   string     `json:"message"`
+	Link      string     `json:"link,omitempty"` // API path of the record the notification is about
+	InInbox   bool       `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+}
+
+// NotificationPreference chooses the channels a user is notified on for an event type
+type NotificationPreference struct {
+	Event   string `json:"event"`
+	InApp   bool   `json:"in_app"`
+	Email   bool   `json:"email"`
+	Webhook bool   `json:"webhook"`
+	Digest  bool   `json:"digest"` // Batch email and webhook deliveries into a daily digest
+}
+
+// NotificationSettings holds where a user's notifications are delivered
+type NotificationSettings struct {
+	Email      string `json:"email"`       // Defaults to the account's email address
+	WebhookURL string `json:"webhook_url"` // Receives a JSON POST per delivery
+	DigestHour int    `json:"digest_hour"` // Hour of the day (0-23, server time) digests are sent
+}
+
+// NotificationDelivery tracks sending a notification on an email or webhook channel
+type NotificationDelivery struct {
+	ID             int64      `json:"id"`
+	NotificationID int64      `json:"notification_id"`
+	UserID         int64      `json:"-"`
+	Channel        string     `json:"channel"`
+	Digest         bool       `json:"digest"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	SentAt         *time.Time `json:"sent_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+
+	// Joined fields
+	Title string `json:"title,omitempty"`
+}
+
+// Webhook event types
+const (
+	WebhookExpenseCreated  = "expense.created"
+	WebhookExpenseUpdated  = "expense.updated"
+	WebhookExpenseDeleted  = "expense.deleted" // Moved to the trash
+	WebhookExpenseRestored = "expense.restored"
+	WebhookIncomeCreated   = "income.created"
+	WebhookIncomeUpdated   = "income.updated"
+	WebhookIncomeDeleted   = "income.deleted" // Moved to the trash
+	WebhookIncomeRestored  = "income.restored"
+	WebhookPing            = "ping" // Sent on request to test an endpoint
+)
+
+// Webhook is an endpoint that receives signed POSTs for the events it subscribes to
+type Webhook struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"user_id"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	Events      []string  `json:"events"`
+	Active      bool      `json:"active"`
+	Secret      string    `json:"secret,omitempty"` // Signing key; only returned when created or rotated
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is one event sent, or to be sent, to a webhook
+type WebhookDelivery struct {
+	ID            int64            `json:"id"`
+	WebhookID     int64            `json:"webhook_id"`
+	EventID       string           `json:"event_id"` // Same for every delivery of the event, so receivers can drop repeats
+	Event         string           `json:"event"`
+	Payload       string           `json:"payload"`
+	Status        string           `json:"status"` // "pending", "sent" or "failed"
+	Attempts      int              `json:"attempts"`
+	NextAttemptAt *time.Time       `json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	CompletedAt   *time.Time       `json:"completed_at,omitempty"`
+	AttemptLog    []WebhookAttempt `json:"attempt_log,omitempty"`
+}
+
+// WebhookAttempt records one try at sending a webhook delivery
+type WebhookAttempt struct {
+	ID           int64     `json:"id"`
+	DeliveryID   int64     `json:"delivery_id"`
+	StatusCode   int       `json:"status_code,omitempty"` // Unset when no response was received
+	Error        string    `json:"error,omitempty"`
+	ResponseBody string    `json:"response_body,omitempty"` // First KB of the response
+	DurationMs   int64     `json:"duration_ms"`
+	AttemptedAt  time.Time `json:"attempted_at"`
+}
+
+// Sync entities
+const (
+	SyncIncome   = "income"
+	SyncExpense  = "expense"
+	SyncCategory = "category"
+	SyncSettings = "settings"
+)
+
+// Sync push operations
+const (
+	SyncUpsert = "upsert"
+	SyncDelete = "delete"
+)
+
+// Sync conflict resolutions
+const (
+	SyncServerWins = "server_wins"
+	SyncClientWins = "client_wins"
+)
+
+// Sync push result statuses
+const (
+	SyncApplied   = "applied"
+	SyncUnchanged = "unchanged"
+	SyncConflict  = "conflict"
+	SyncFailed    = "failed"
+)
+
+// Sync conflict reasons
+const (
+	SyncModified = "modified" // The record changed on the server since base_version
+	SyncDeleted  = "deleted"  // The record was deleted on the server
+)
+
+// UserSettings are the settings a user can change, as carried by the change feed
+type UserSettings struct {
+	Currency string `json:"currency"`
+	Theme    string `json:"theme"`
+}
+
+// SyncChange is one record in the change feed: its latest state, or a
+// tombstone when it was deleted
+type SyncChange struct {
+	Entity  string      `json:"entity"` // "income", "expense", "category" or "settings"
+	ID      int64       `json:"id"`
+	UUID    string      `json:"uuid,omitempty"` // Set for income and expense records
+	Version int64       `json:"version"`
+	Deleted bool        `json:"deleted,omitempty"`
+	Data    interface{} `json:"data,omitempty"` // The record; omitted from tombstones
+}
+
+// SyncFeed is a page of the change feed
+type SyncFeed struct {
+	Changes []SyncChange `json:"changes"`
+	Token   string       `json:"token"` // Pass as since to get the changes after this page
+	HasMore bool         `json:"has_more"`
+}
+
+// SyncPushRequest is a batch of changes made on a client
+type SyncPushRequest struct {
+	Changes    []SyncPushChange `json:"changes"`
+	OnConflict string           `json:"on_conflict"` // "server_wins" (default) or "client_wins"
+}
+
+// SyncPushChange is one change made on a client. Records are identified by
+// uuid, or by id for records the client got from the feed.
+type SyncPushChange struct {
+	Entity      string          `json:"entity"` // "income", "expense" or "settings"
+	Op          string          `json:"op"`     // "upsert" or "delete"
+	UUID        string          `json:"uuid"`
+	ID          int64           `json:"id"`
+	BaseVersion int64           `json:"base_version"` // Version the client last saw; 0 for a new record
+	OnConflict  string          `json:"on_conflict"`  // Overrides the batch's resolution
+	Data        json.RawMessage `json:"data"`
+}
+
+// SyncPushResult is the outcome of one pushed change
+type SyncPushResult struct {
+	Entity   string      `json:"entity"`
+	UUID     string      `json:"uuid,omitempty"`
+	ID       int64       `json:"id,omitempty"`
+	Status   string      `json:"status"` // "applied", "unchanged", "conflict" or "failed"
+	Version  int64       `json:"version,omitempty"`
+	Conflict string      `json:"conflict,omitempty"` // "modified" or "deleted"
+	Server   interface{} `json:"server,omitempty"`   // The server's copy, sent with conflicts
+	Error    string      `json:"error,omitempty"`
+}
+
+// SyncPushResponse reports the outcome of a push
+type SyncPushResponse struct {
+	Results   []SyncPushResult `json:"results"`
+	Applied   int              `json:"applied"`
+	Unchanged int              `json:"unchanged"`
+	Conflicts int              `json:"conflicts"`
+	Failed    int              `json:"failed"`
+}
+
+// Import formats
+const (
+	ImportOFX = "ofx"
+	ImportQIF = "qif"
+)
+
+// Import statuses
+const (
+	ImportPreview    = "preview"
+	ImportCommitting = "committing"
+	ImportCommitted  = "committed"
+)
+
+// Import row statuses. Rows start as new, duplicate or possible_duplicate and
+// end as imported, skipped or failed once the import is committed.
+const (
+	ImportRowNew               = "new"
+	ImportRowDuplicate         = "duplicate"          // Already imported from an earlier file
+	ImportRowPossibleDuplicate = "possible_duplicate" // Looks like an existing expense
+	ImportRowImported          = "imported"
+	ImportRowSkipped           = "skipped"
+	ImportRowFailed            = "failed"
+)
+
+// Sources of an import row's category
+const (
+	ImportCategoryFile       = "file"
+	ImportCategoryRule       = "rule"
+	ImportCategorySuggestion = "suggestion"
+	ImportCategoryChosen     = "chosen"
+	ImportCategoryDefault    = "default"
+)
+
+// Import is an uploaded statement file. It is previewed first and its rows
+// are only turned into income and expense records when it is committed.
+type Import struct {
+	ID                int64            `json:"id"`
+	UserID            int64            `json:"user_id"`
+	HouseholdID       *int64           `json:"household_id,omitempty"` // Set for imports into a household ledger
+	Format            string           `json:"format"`
+	Filename          string           `json:"filename"`
+	Status            string           `json:"status"`
+	Counts            map[string]int   `json:"counts"`                       // Rows per status
+	MissingCategories []ImportCategory `json:"missing_categories,omitempty"` // File categories with no match, while previewed
+	Rows              []ImportRow      `json:"rows,omitempty"`
+	CreatedAt         time.Time        `json:"created_at"`
+	CommittedAt       *time.Time       `json:"committed_at,omitempty"`
+}
+
+// ImportCategory is a category named in an imported file
+type ImportCategory struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // "income" or "expense"
+}
+
+// ImportRow is one transaction of an import and the record it maps to
+type ImportRow struct {
+	Index          int           `json:"index"`
+	Type           string        `json:"type"` // "income" or "expense"
+	Date           string        `json:"date"`
+	Amount         float64       `json:"amount"`
+	Description    string        `json:"description"`
+	Memo           string        `json:"memo,omitempty"`
+	Account        string        `json:"account"`
+	Tags           string        `json:"tags"`
+	Category       string        `json:"category,omitempty"` // The file's category name
+	CategoryID     int64         `json:"category_id"`        // 0 until the file, a rule, a suggestion or the user picks one
+	CategorySource string        `json:"category_source,omitempty"`
+	Splits         []ImportSplit `json:"splits,omitempty"`
+	ExternalID     string        `json:"external_id,omitempty"` // The bank's transaction id, e.g. the OFX FITID
+	Source         string        `json:"-"`                     // The account the external id is unique in
+	Status         string        `json:"status"`
+	DuplicateOf    int64         `json:"duplicate_of,omitempty"` // The existing record the row duplicates
+	RecordID       int64         `json:"record_id,omitempty"`    // The record created on commit
+	Error          string        `json:"error,omitempty"`
+}
+
+// ImportSplit is a line item of a split expense in an imported file
+type ImportSplit struct {
+	Category   string  `json:"category,omitempty"` // The file's category name
+	CategoryID int64   `json:"category_id"`
+	Amount     float64 `json:"amount"`
+	Memo       string  `json:"memo,omitempty"`
+}
+
+// ImportCommitRequest chooses categories and which rows to import. Rows
+// without a category get the default for their type.
+type ImportCommitRequest struct {
+	Rows                      []ImportRowChoice `json:"rows"`
+	DefaultIncomeCategoryID   int64             `json:"default_income_category_id"`
+	DefaultExpenseCategoryID  int64             `json:"default_expense_category_id"`
+	IncludePossibleDuplicates bool              `json:"include_possible_duplicates"`
+	CreateCategories          bool              `json:"create_categories"` // Create the missing categories in the household
+}
+
+// ImportRowChoice overrides the preview for one row
+type ImportRowChoice struct {
+	Index      int    `json:"index"`
+	CategoryID *int64 `json:"category_id"`
+	Skip       *bool  `json:"skip"` // false imports a possible duplicate
+}
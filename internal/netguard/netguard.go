@@ -0,0 +1,99 @@
+package netguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrInvalidURL is returned for URLs that are not http or https
+	ErrInvalidURL = errors.New("not an http or https URL")
+	// ErrUnresolved is returned when a URL's host has no addresses
+	ErrUnresolved = errors.New("host could not be resolved")
+	// ErrBlockedAddress is returned for hosts on loopback, private, link-local
+	// or unspecified addresses, which user-supplied URLs may not reach
+	ErrBlockedAddress = errors.New("address is not publicly routable")
+)
+
+// sharedAddressSpace is the carrier-grade NAT range, 100.64.0.0/10
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// Blocked reports whether ip is an address user-supplied URLs may not reach
+func Blocked(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || sharedAddressSpace.Contains(ip)
+}
+
+// control refuses connections to blocked addresses. It runs after DNS
+// resolution, on the address actually dialed, so a host that resolves to a
+// public address when checked and a private one when used is still refused.
+func control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || Blocked(ip) {
+		return fmt.Errorf("%s: %w", host, ErrBlockedAddress)
+	}
+	return nil
+}
+
+// NewClient returns an HTTP client for user-supplied URLs. It connects only
+// to public addresses, including on redirects, and ignores proxy settings so
+// the check applies to the real destination.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   control,
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+}
+
+// CheckURL checks that a user-supplied URL is http or https and that its host
+// resolves only to public addresses. Connections are checked again when they
+// are made, since DNS answers can change.
+func CheckURL(ctx context.Context, raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return ErrInvalidURL
+	}
+
+	host := parsed.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if Blocked(ip) {
+			return ErrBlockedAddress
+		}
+		return nil
+	}
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addresses) == 0 {
+		return ErrUnresolved
+	}
+	for _, address := range addresses {
+		if Blocked(address.IP) {
+			return ErrBlockedAddress
+		}
+	}
+	return nil
+}
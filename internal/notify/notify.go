@@ -0,0 +1,305 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/repository"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// pollInterval is how often the worker looks for deliveries that are due
+	pollInterval = 30 * time.Second
+	// batchSize caps the deliveries handled per pass of the worker
+	batchSize = 100
+	// sendTimeout bounds a single attempt on a channel
+	sendTimeout = 15 * time.Second
+	// maxAttempts is how many times a delivery is tried before giving up
+	maxAttempts = 5
+)
+
+// retryDelays is the wait after each failed attempt, indexed by attempts made - 1
+var retryDelays = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour}
+
+// Events lists the event types users can set preferences for
+var Events = []string{models.NotifyBillDue, models.NotifyBillOverdue, models.NotifyAnomaly}
+
+// Message is what a channel sends: one notification, or several as a digest
+type Message struct {
+	To            string // Email address or webhook URL
+	Subject       string
+	Body          string // Plain text
+	Digest        bool
+	Notifications []models.Notification
+}
+
+// Channel delivers messages to users outside the app
+type Channel interface {
+	// Name is the channel's preference name, such as "email"
+	Name() string
+	// Send delivers a message, returning an error to have it retried
+	Send(ctx context.Context, message Message) error
+}
+
+// Service stores notifications in users' inboxes and delivers them on the
+// channels each user chose for the event, retrying failed deliveries and
+// batching digest deliveries
+type Service struct {
+	notificationRepo *repository.NotificationRepository
+	billRepo         *repository.BillRepository
+
+	mu       sync.RWMutex
+	channels map[string]Channel
+	wake     chan struct{}
+}
+
+// NewService creates a new notification service
+func NewService(notificationRepo *repository.NotificationRepository, billRepo *repository.BillRepository) *Service {
+	return &Service{
+		notificationRepo: notificationRepo,
+		billRepo:         billRepo,
+		channels:         make(map[string]Channel),
+		wake:             make(chan struct{}, 1),
+	}
+}
+
+// Register adds a delivery channel, replacing any channel with the same name
+func (s *Service) Register(channel Channel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.channels[channel.Name()] = channel
+}
+
+// Available reports whether a channel can be used. The in-app inbox always can.
+func (s *Service) Available(name string) bool {
+	if name == models.ChannelInApp {
+		return true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.channels[name] != nil
+}
+
+// Start launches the delivery worker and the hourly bill reminder check
+func (s *Service) Start() {
+	go s.worker()
+	go s.reminders()
+}
+
+// DefaultPreference is the preference for an event type a user has not set:
+// in-app only
+func DefaultPreference(event string) models.NotificationPreference {
+	return models.NotificationPreference{Event: event, InApp: true}
+}
+
+// Preferences returns a user's preference for every event type, with defaults
+// for those not set
+func (s *Service) Preferences(userID int64) ([]models.NotificationPreference, error) {
+	stored, err := s.notificationRepo.GetPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	preferences := make([]models.NotificationPreference, 0, len(Events))
+	for _, event := range Events {
+		preference, ok := stored[event]
+		if !ok {
+			preference = DefaultPreference(event)
+		}
+		preferences = append(preferences, preference)
+	}
+	return preferences, nil
+}
+
+// Notify notifies a user of an event on the channels they chose for it.
+// Notifications with a dedupe key the user was already notified with are dropped.
+func (s *Service) Notify(notification models.Notification) error {
+	stored, err := s.notificationRepo.GetPreferences(notification.UserID)
+	if err != nil {
+		return err
+	}
+	preference, ok := stored[notification.Event]
+	if !ok {
+		preference = DefaultPreference(notification.Event)
+	}
+
+	_, err = s.Send(&notification, preference)
+	return err
+}
+
+// Send stores a notification and queues it on the channels of a preference.
+// It reports whether the notification was new.
+func (s *Service) Send(notification *models.Notification, preference models.NotificationPreference) (bool, error) {
+	notification.InInbox = preference.InApp
+	created, err := s.notificationRepo.Create(notification)
+	if err != nil || !created {
+		return false, err
+	}
+
+	now := time.Now()
+	next := now
+	if preference.Digest {
+		settings, err := s.notificationRepo.GetSettings(notification.UserID)
+		if err != nil {
+			return true, err
+		}
+		next = nextDigest(now, settings.DigestHour)
+	}
+
+	for channel, enabled := range map[string]bool{models.ChannelEmail: preference.Email, models.ChannelWebhook: preference.Webhook} {
+		if !enabled || !s.Available(channel) {
+			continue
+		}
+		delivery := models.NotificationDelivery{
+			NotificationID: notification.ID,
+			UserID:         notification.UserID,
+			Channel:        channel,
+			Digest:         preference.Digest,
+			NextAttemptAt:  &next,
+		}
+		if err := s.notificationRepo.AddDelivery(&delivery); err != nil {
+			return true, err
+		}
+	}
+
+	if !preference.Digest {
+		s.poke()
+	}
+	return true, nil
+}
+
+// nextDigest is the next time after now at the digest hour
+func nextDigest(now time.Time, hour int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// poke wakes the worker without waiting for its next poll
+func (s *Service) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// worker sends due deliveries whenever poked, and at least every poll interval
+func (s *Service) worker() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.wake:
+		}
+		if err := s.DeliverDue(time.Now()); err != nil {
+			log.Printf("notify: delivery run failed: %v", err)
+		}
+	}
+}
+
+// DeliverDue sends the deliveries that are due. Digest deliveries for the same
+// user and channel are sent together as one message.
+func (s *Service) DeliverDue(now time.Time) error {
+	due, err := s.notificationRepo.DueDeliveries(now, batchSize)
+	if err != nil {
+		return err
+	}
+
+	var groups [][]repository.DueDelivery
+	digests := make(map[string]int)
+	for _, item := range due {
+		if !item.Delivery.Digest {
+			groups = append(groups, []repository.DueDelivery{item})
+			continue
+		}
+		key := fmt.Sprintf("%d:%s", item.Delivery.UserID, item.Delivery.Channel)
+		if i, ok := digests[key]; ok {
+			groups[i] = append(groups[i], item)
+			continue
+		}
+		digests[key] = len(groups)
+		groups = append(groups, []repository.DueDelivery{item})
+	}
+
+	for _, group := range groups {
+		s.deliver(group, now)
+	}
+	return nil
+}
+
+// deliver sends a group of deliveries for one user and channel as a single
+// message, recording the outcome on each
+func (s *Service) deliver(group []repository.DueDelivery, now time.Time) {
+	first := group[0]
+	s.mu.RLock()
+	channel := s.channels[first.Delivery.Channel]
+	s.mu.RUnlock()
+
+	var err error
+	switch {
+	case channel == nil:
+		err = fmt.Errorf("%s channel is not configured", first.Delivery.Channel)
+	case first.Address == "":
+		err = fmt.Errorf("no %s address set", first.Delivery.Channel)
+	default:
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err = channel.Send(ctx, buildMessage(group))
+		cancel()
+	}
+
+	if err == nil {
+		ids := make([]int64, len(group))
+		for i, item := range group {
+			ids[i] = item.Delivery.ID
+		}
+		if err := s.notificationRepo.MarkSent(ids, now); err != nil {
+			log.Printf("notify: %v", err)
+		}
+		return
+	}
+
+	for _, item := range group {
+		var retryAt *time.Time
+		if attempts := item.Delivery.Attempts + 1; attempts < maxAttempts {
+			next := now.Add(retryDelays[attempts-1])
+			retryAt = &next
+		}
+		if err := s.notificationRepo.MarkAttemptFailed(item.Delivery.ID, err.Error(), retryAt); err != nil {
+			log.Printf("notify: %v", err)
+		}
+	}
+	log.Printf("notify: %s delivery to user %d failed: %v", first.Delivery.Channel, first.Delivery.UserID, err)
+}
+
+// buildMessage renders a group of deliveries as one message
+func buildMessage(group []repository.DueDelivery) Message {
+	message := Message{
+		To:     group[0].Address,
+		Digest: group[0].Delivery.Digest,
+	}
+	for _, item := range group {
+		message.Notifications = append(message.Notifications, item.Notification)
+	}
+
+	if !message.Digest {
+		notification := message.Notifications[0]
+		message.Subject = notification.Title
+		message.Body = notification.Message + "\n"
+		return message
+	}
+
+	message.Subject = fmt.Sprintf("Your notification digest: %d new", len(message.Notifications))
+	var body strings.Builder
+	for _, notification := range message.Notifications {
+		fmt.Fprintf(&body, "- %s\n  %s\n", notification.Title, notification.Message)
+	}
+	message.Body = body.String()
+	return message
+}
@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"myexpress-tracker/internal/models"
+	"time"
+)
+
+// reminderInterval is how often bills are checked for reminders
+const reminderInterval = time.Hour
+
+// reminders checks bills at startup and then every reminder interval
+func (s *Service) reminders() {
+	for {
+		if err := s.RemindBills(time.Now()); err != nil {
+			log.Printf("notify: bill reminders failed: %v", err)
+		}
+		time.Sleep(reminderInterval)
+	}
+}
+
+// RemindBills notifies users of bills entering their reminder window and of
+// bills that became overdue. Each due date is notified about once per event.
+func (s *Service) RemindBills(now time.Time) error {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	billList, err := s.billRepo.GetDueForReminders(today.Format("2006-01-02"))
+	if err != nil {
+		return err
+	}
+
+	for _, bill := range billList {
+		due, err := time.Parse("2006-01-02", *bill.NextDueDate)
+		if err != nil {
+			continue
+		}
+
+		notification := models.Notification{
+			UserID: bill.UserID,
+			Link:   fmt.Sprintf("/api/bills/%d", bill.ID),
+		}
+		days := int(due.Sub(today).Hours() / 24)
+		switch {
+		case days < 0:
+			notification.Event = models.NotifyBillOverdue
+			notification.DedupeKey = fmt.Sprintf("bill_overdue:%d:%s", bill.ID, *bill.NextDueDate)
+			notification.Title = fmt.Sprintf("%s is overdue", bill.Name)
+			notification.Message = fmt.Sprintf("%s (%.2f) was due on %s.", bill.Name, bill.Amount, *bill.NextDueDate)
+		case days == 0:
+			notification.Event = models.NotifyBillDue
+			notification.DedupeKey = fmt.Sprintf("bill_due:%d:%s", bill.ID, *bill.NextDueDate)
+			notification.Title = fmt.Sprintf("%s is due today", bill.Name)
+		default:
+			notification.Event = models.NotifyBillDue
+			notification.DedupeKey = fmt.Sprintf("bill_due:%d:%s", bill.ID, *bill.NextDueDate)
+			notification.Title = fmt.Sprintf("%s is due in %d day%s", bill.Name, days, plural(days))
+		}
+		if notification.Message == "" {
+			notification.Message = fmt.Sprintf("%s (%.2f) is due on %s.", bill.Name, bill.Amount, *bill.NextDueDate)
+		}
+
+		if err := s.Notify(notification); err != nil {
+			log.Printf("notify: failed to notify user %d of bill %d: %v", bill.UserID, bill.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// NotifyInsight notifies a user of unusual spending found by the insights engine
+func (s *Service) NotifyInsight(insight *models.Insight) error {
+	return s.Notify(models.Notification{
+		UserID:    insight.UserID,
+		Event:     models.NotifyAnomaly,
+		DedupeKey: fmt.Sprintf("insight:%d", insight.ID),
+		Title:     insight.Title,
+		Message:   insight.Message,
+		Link:      "/api/insights",
+	})
+}
+
+// plural is "s" unless n is 1
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
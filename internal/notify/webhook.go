@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/netguard"
+	"net/http"
+)
+
+// WebhookChannel posts notifications as JSON to the URL each user sets.
+// Its client only connects to public addresses.
+type WebhookChannel struct {
+	Client *http.Client
+}
+
+// NewWebhookChannel creates a new webhook channel
+func NewWebhookChannel() *WebhookChannel {
+	return &WebhookChannel{Client: netguard.NewClient(sendTimeout)}
+}
+
+// webhookPayload is the JSON body posted to a webhook
+type webhookPayload struct {
+	Type          string                `json:"type"` // "notification" or "digest"
+	Subject       string                `json:"subject"`
+	Notifications []models.Notification `json:"notifications"`
+}
+
+// Name is the channel's preference name
+func (c *WebhookChannel) Name() string {
+	return models.ChannelWebhook
+}
+
+// Send posts a message. Any response other than 2xx is a failure.
+func (c *WebhookChannel) Send(ctx context.Context, message Message) error {
+	payload := webhookPayload{
+		Type:          "notification",
+		Subject:       message.Subject,
+		Notifications: message.Notifications,
+	}
+	if message.Digest {
+		payload.Type = "digest"
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, message.To, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "MyExpress-Tracker-Notifications/1.0")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
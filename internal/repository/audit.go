@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"myexpress-tracker/internal/audit"
+	"myexpress-tracker/internal/models"
+	"strings"
+)
+
+// Audit actions that have no change notification of their own
+const (
+	ActionPurged = "purged"
+)
+
+// maxAuditPage caps the number of audit entries returned at once
+const maxAuditPage = 500
+
+// recordAudit appends an audit entry describing a write. It must run in the
+// same transaction as the write so the trail can never disagree with the data.
+// householdID is the household ledger of the record, or nil for personal ones.
+func recordAudit(ctx context.Context, tx execer, userID int64, householdID *int64, entity string, entityID int64, action string, before, after interface{}) error {
+	beforeFields, err := audit.Snapshot(before)
+	if err != nil {
+		return fmt.Errorf("failed to snapshot %s: %w", entity, err)
+	}
+	afterFields, err := audit.Snapshot(after)
+	if err != nil {
+		return fmt.Errorf("failed to snapshot %s: %w", entity, err)
+	}
+
+	beforeJSON, err := nullableJSON(beforeFields)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	afterJSON, err := nullableJSON(afterFields)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	changes, err := json.Marshal(audit.Diff(beforeFields, afterFields))
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+
+	meta := audit.FromContext(ctx)
+	var actorID interface{}
+	if meta.ActorID != 0 {
+		actorID = meta.ActorID
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO audit_log (user_id, household_id, actor_id, entity, entity_id, action, before_json, after_json, changes, request_id, ip)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, userID, householdID, actorID, entity, entityID, action, beforeJSON, afterJSON, string(changes), meta.RequestID, meta.IP)
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// nullableJSON encodes a snapshot, mapping a nil snapshot to SQL NULL
+func nullableJSON(fields map[string]interface{}) (interface{}, error) {
+	if fields == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// AuditRepository reads the audit trail
+type AuditRepository struct {
+	db *sql.DB
+}
+
+// NewAuditRepository creates a new audit repository
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// GetByEntity retrieves the history of a single record in the ledger, oldest first
+func (r *AuditRepository) GetByEntity(ledger Ledger, entity string, entityID int64) ([]models.AuditEntry, error) {
+	condition, args := ledger.readCondition("a.")
+	query := auditSelect + `
+		WHERE ` + condition + ` AND a.entity = ? AND a.entity_id = ?
+		ORDER BY a.id
+	`
+	return r.query(query, append(args, entity, entityID)...)
+}
+
+// GetByLedger retrieves a ledger's audit log, newest first, with optional
+// filters. A household's log holds every member's changes to its records.
+func (r *AuditRepository) GetByLedger(ledger Ledger, filters map[string]interface{}) ([]models.AuditEntry, error) {
+	condition, args := ledger.readCondition("a.")
+	conditions := []string{condition}
+
+	if entity, ok := filters["entity"].(string); ok && entity != "" {
+		conditions = append(conditions, "a.entity = ?")
+		args = append(args, entity)
+	}
+
+	if entityID, ok := filters["entity_id"].(int64); ok && entityID > 0 {
+		conditions = append(conditions, "a.entity_id = ?")
+		args = append(args, entityID)
+	}
+
+	if action, ok := filters["action"].(string); ok && action != "" {
+		conditions = append(conditions, "a.action = ?")
+		args = append(args, action)
+	}
+
+	if actorID, ok := filters["actor_id"].(int64); ok && actorID > 0 {
+		conditions = append(conditions, "a.actor_id = ?")
+		args = append(args, actorID)
+	}
+
+	if requestID, ok := filters["request_id"].(string); ok && requestID != "" {
+		conditions = append(conditions, "a.request_id = ?")
+		args = append(args, requestID)
+	}
+
+	if startDate, ok := filters["start_date"].(string); ok && startDate != "" {
+		conditions = append(conditions, "date(a.created_at) >= ?")
+		args = append(args, startDate)
+	}
+
+	if endDate, ok := filters["end_date"].(string); ok && endDate != "" {
+		conditions = append(conditions, "date(a.created_at) <= ?")
+		args = append(args, endDate)
+	}
+
+	limit, _ := filters["limit"].(int)
+	if limit <= 0 || limit > maxAuditPage {
+		limit = maxAuditPage
+	}
+	offset, _ := filters["offset"].(int)
+	if offset < 0 {
+		offset = 0
+	}
+
+	query := auditSelect + " WHERE " + strings.Join(conditions, " AND ") + " ORDER BY a.id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	return r.query(query, args...)
+}
+
+// auditSelect is the column list shared by the audit queries
+const auditSelect = `
+	SELECT a.id, a.user_id, a.actor_id, COALESCE(u.username, ''), a.entity, a.entity_id, a.action,
+		a.before_json, a.after_json, a.changes, a.request_id, a.ip, a.created_at
+	FROM audit_log a
+	LEFT JOIN users u ON a.actor_id = u.id
+`
+
+// query runs an audit query and scans the entries
+func (r *AuditRepository) query(query string, args ...interface{}) ([]models.AuditEntry, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		var before, after sql.NullString
+		var changes string
+		if err := rows.Scan(
+			&entry.ID, &entry.UserID, &entry.ActorID, &entry.ActorName, &entry.Entity, &entry.EntityID, &entry.Action,
+			&before, &after, &changes, &entry.RequestID, &entry.IP, &entry.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entry.Before = rawJSON(before)
+		entry.After = rawJSON(after)
+		entry.Changes = json.RawMessage(changes)
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// rawJSON converts a nullable JSON column into a raw message, using null for NULL
+func rawJSON(value sql.NullString) json.RawMessage {
+	if !value.Valid {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(value.String)
+}
@@ -0,0 +1,446 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"myexpress-tracker/internal/bills"
+	"myexpress-tracker/internal/models"
+	"time"
+)
+
+// ErrBillNotDue is returned when paying a due date that is not the bill's next one,
+// such as a bill that was paid in the meantime
+var ErrBillNotDue = errors.New("bill has no unpaid occurrence on that due date")
+
+// BillRepository handles database operations for bills, bill payments and calendar feeds
+type BillRepository struct {
+	db *sql.DB
+}
+
+// NewBillRepository creates a new bill repository
+func NewBillRepository(db *sql.DB) *BillRepository {
+	return &BillRepository{db: db}
+}
+
+// Create creates a new bill, first due on its first due date
+func (r *BillRepository) Create(bill *models.Bill) error {
+	result, err := r.db.Exec(`
+		INSERT INTO bills (user_id, name, amount, category_id, account, first_due_date, next_due_date, recurrence, remind_days, notes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		bill.UserID, bill.Name, bill.Amount, bill.CategoryID, bill.Account, bill.FirstDueDate, bill.FirstDueDate,
+		bill.Recurrence, bill.RemindDays, bill.Notes,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create bill: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	bill.ID = id
+	return nil
+}
+
+// Update updates a bill. The next due date is worked out again from the new
+// schedule and the latest paid occurrence.
+func (r *BillRepository) Update(bill *models.Bill) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE bills
+		SET name = ?, amount = ?, category_id = ?, account = ?, first_due_date = ?, recurrence = ?, remind_days = ?,
+			notes = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND user_id = ?`,
+		bill.Name, bill.Amount, bill.CategoryID, bill.Account, bill.FirstDueDate, bill.Recurrence, bill.RemindDays,
+		bill.Notes, bill.ID, bill.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update bill: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update bill: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("bill not found or unauthorized")
+	}
+
+	if err := resetNextDue(tx, bill.ID, bill.FirstDueDate, bill.Recurrence); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit bill: %w", err)
+	}
+
+	return nil
+}
+
+// resetNextDue sets a bill's next due date to the first occurrence after its
+// latest paid one, or clears it when a one-off bill has been paid
+func resetNextDue(tx *sql.Tx, billID int64, firstDueDate, recurrence string) error {
+	var lastPaid sql.NullString
+	if err := tx.QueryRow(`SELECT MAX(due_date) FROM bill_payments WHERE bill_id = ?`, billID).Scan(&lastPaid); err != nil {
+		return fmt.Errorf("failed to read bill payments: %w", err)
+	}
+
+	var next interface{} = firstDueDate
+	if lastPaid.Valid {
+		first, err := time.Parse("2006-01-02", firstDueDate)
+		if err != nil {
+			return fmt.Errorf("invalid first due date: %w", err)
+		}
+		paid, err := time.Parse("2006-01-02", DateOnly(lastPaid.String))
+		if err != nil {
+			return fmt.Errorf("invalid bill payment due date: %w", err)
+		}
+		next = nil
+		if date, ok := bills.After(first, recurrence, paid); ok {
+			next = date.Format("2006-01-02")
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE bills SET next_due_date = ? WHERE id = ?`, next, billID); err != nil {
+		return fmt.Errorf("failed to update bill due date: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes a bill and its payments. Expenses recorded for the payments are kept.
+func (r *BillRepository) Delete(id, userID int64) error {
+	result, err := r.db.Exec(`DELETE FROM bills WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete bill: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete bill: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("bill not found or unauthorized")
+	}
+
+	return nil
+}
+
+// billSelect selects bills (aliased b) with their category name
+const billSelect = `
+	SELECT b.id, b.user_id, b.name, b.amount, b.category_id, b.account, b.first_due_date, b.next_due_date, b.recurrence,
+		b.remind_days, b.notes, b.created_at, b.updated_at, COALESCE(c.name, '')
+	FROM bills b
+	LEFT JOIN categories c ON b.category_id = c.id`
+
+// scanBill scans a row selected with billSelect
+func scanBill(row rowScanner) (*models.Bill, error) {
+	bill := &models.Bill{}
+	var nextDueDate sql.NullString
+	if err := row.Scan(
+		&bill.ID, &bill.UserID, &bill.Name, &bill.Amount, &bill.CategoryID, &bill.Account, &bill.FirstDueDate, &nextDueDate,
+		&bill.Recurrence, &bill.RemindDays, &bill.Notes, &bill.CreatedAt, &bill.UpdatedAt, &bill.CategoryName,
+	); err != nil {
+		return nil, err
+	}
+	if nextDueDate.Valid {
+		date := DateOnly(nextDueDate.String)
+		bill.NextDueDate = &date
+	}
+	bill.FirstDueDate = DateOnly(bill.FirstDueDate)
+	return bill, nil
+}
+
+// GetByID retrieves one of a user's bills
+func (r *BillRepository) GetByID(id, userID int64) (*models.Bill, error) {
+	bill, err := scanBill(r.db.QueryRow(billSelect+` WHERE b.id = ? AND b.user_id = ?`, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bill by id: %w", err)
+	}
+
+	return bill, nil
+}
+
+// GetByUser retrieves a user's bills, soonest due first and paid one-off bills last
+func (r *BillRepository) GetByUser(userID int64) ([]models.Bill, error) {
+	rows, err := r.db.Query(billSelect+`
+		WHERE b.user_id = ?
+		ORDER BY b.next_due_date IS NULL, b.next_due_date, b.name COLLATE NOCASE, b.id`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bills: %w", err)
+	}
+	defer rows.Close()
+
+	var billList []models.Bill
+	for rows.Next() {
+		bill, err := scanBill(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan bill: %w", err)
+		}
+		billList = append(billList, *bill)
+	}
+
+	return billList, nil
+}
+
+// CreateBillPayment records paying a bill's next due date with a new expense.
+// The expense and the payment are written in one transaction, so neither is
+// kept without the other. It fails with ErrBillNotDue if the due date is no
+// longer the bill's next one.
+func (r *ExpenseRepository) CreateBillPayment(ctx context.Context, bill *models.Bill, payment *models.BillPayment, expense *models.Expense) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	expenseID, err := r.createTx(ctx, tx, expense)
+	if err != nil {
+		return err
+	}
+
+	payment.ExpenseID = &expenseID
+	paymentID, err := recordBillPayment(tx, bill, payment)
+	if err != nil {
+		payment.ExpenseID = nil
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		payment.ExpenseID = nil
+		return fmt.Errorf("failed to commit bill payment: %w", err)
+	}
+
+	expense.ID = expenseID
+	payment.ID = paymentID
+	r.notify(ChangeEvent{UserID: expense.UserID, Entity: "expense", Action: ActionCreated, ID: expenseID})
+	return nil
+}
+
+// recordBillPayment writes a payment of a bill's next due date and moves the
+// bill on to the following occurrence, returning the payment's id
+func recordBillPayment(tx execer, bill *models.Bill, payment *models.BillPayment) (int64, error) {
+	if bill.NextDueDate == nil {
+		return 0, ErrBillNotDue
+	}
+	first, err := time.Parse("2006-01-02", bill.FirstDueDate)
+	if err != nil {
+		return 0, fmt.Errorf("invalid first due date: %w", err)
+	}
+	due, err := time.Parse("2006-01-02", *bill.NextDueDate)
+	if err != nil {
+		return 0, fmt.Errorf("invalid next due date: %w", err)
+	}
+
+	var next interface{}
+	if date, ok := bills.After(first, bill.Recurrence, due); ok {
+		next = date.Format("2006-01-02")
+	}
+
+	// Guarded on the due date so that paying twice at once only records one payment
+	result, err := tx.Exec(`
+		UPDATE bills SET next_due_date = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND user_id = ? AND next_due_date = ?`,
+		next, bill.ID, bill.UserID, *bill.NextDueDate,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update bill due date: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to update bill due date: %w", err)
+	}
+	if updated == 0 {
+		return 0, ErrBillNotDue
+	}
+
+	payment.BillID = bill.ID
+	payment.DueDate = *bill.NextDueDate
+
+	result, err = tx.Exec(`
+		INSERT INTO bill_payments (bill_id, due_date, paid_on, amount, expense_id)
+		VALUES (?, ?, ?, ?, ?)`,
+		payment.BillID, payment.DueDate, payment.PaidOn, payment.Amount, payment.ExpenseID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create bill payment: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	if err := tx.QueryRow(`SELECT created_at FROM bill_payments WHERE id = ?`, id).Scan(&payment.CreatedAt); err != nil {
+		return 0, fmt.Errorf("failed to read bill payment: %w", err)
+	}
+
+	return id, nil
+}
+
+// DeletePayment deletes a payment from one of a user's bills, making its due
+// date unpaid again when it was the latest. The expense recorded for it, if
+// any, is kept.
+func (r *BillRepository) DeletePayment(id int64, bill *models.Bill) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		DELETE FROM bill_payments
+		WHERE id = ? AND bill_id = ? AND bill_id IN (SELECT id FROM bills WHERE user_id = ?)`,
+		id, bill.ID, bill.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete bill payment: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete bill payment: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("bill payment not found or unauthorized")
+	}
+
+	if err := resetNextDue(tx, bill.ID, bill.FirstDueDate, bill.Recurrence); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit bill payment: %w", err)
+	}
+
+	return nil
+}
+
+// GetPayments retrieves the payments of a bill, latest due date first
+func (r *BillRepository) GetPayments(billID, userID int64) ([]models.BillPayment, error) {
+	rows, err := r.db.Query(`
+		SELECT p.id, p.bill_id, p.due_date, p.paid_on, p.amount, p.expense_id, p.created_at
+		FROM bill_payments p
+		JOIN bills b ON p.bill_id = b.id
+		WHERE p.bill_id = ? AND b.user_id = ?
+		ORDER BY p.due_date DESC, p.id DESC`,
+		billID, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bill payments: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []models.BillPayment
+	for rows.Next() {
+		var payment models.BillPayment
+		if err := rows.Scan(
+			&payment.ID, &payment.BillID, &payment.DueDate, &payment.PaidOn, &payment.Amount, &payment.ExpenseID, &payment.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan bill payment: %w", err)
+		}
+		payment.DueDate = DateOnly(payment.DueDate)
+		payment.PaidOn = DateOnly(payment.PaidOn)
+		payments = append(payments, payment)
+	}
+
+	return payments, nil
+}
+
+// SetFeedToken stores the hash of a user's calendar feed token, replacing any
+// previous token
+func (r *BillRepository) SetFeedToken(userID int64, tokenHash string) error {
+	_, err := r.db.Exec(`
+		INSERT INTO calendar_feeds (user_id, token_hash) VALUES (?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET token_hash = excluded.token_hash, created_at = CURRENT_TIMESTAMP`,
+		userID, tokenHash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save calendar feed token: %w", err)
+	}
+	return nil
+}
+
+// DeleteFeedToken revokes a user's calendar feed token
+func (r *BillRepository) DeleteFeedToken(userID int64) error {
+	result, err := r.db.Exec(`DELETE FROM calendar_feeds WHERE user_id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete calendar feed token: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete calendar feed token: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("calendar feed not found")
+	}
+
+	return nil
+}
+
+// GetFeed returns when a user's calendar feed token was created, or nil if
+// the user has no feed
+func (r *BillRepository) GetFeed(userID int64) (*time.Time, error) {
+	var createdAt time.Time
+	err := r.db.QueryRow(`SELECT created_at FROM calendar_feeds WHERE user_id = ?`, userID).Scan(&createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get calendar feed: %w", err)
+	}
+
+	return &createdAt, nil
+}
+
+// UserByFeedToken returns the user a calendar feed token hash belongs to, or 0
+// if no feed has it
+func (r *BillRepository) UserByFeedToken(tokenHash string) (int64, error) {
+	var userID int64
+	err := r.db.QueryRow(`SELECT user_id FROM calendar_feeds WHERE token_hash = ?`, tokenHash).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up calendar feed: %w", err)
+	}
+
+	return userID, nil
+}
+
+// GetDueForReminders retrieves every user's unpaid bills whose next due date is
+// within their reminder window of a day, or already past
+func (r *BillRepository) GetDueForReminders(today string) ([]models.Bill, error) {
+	rows, err := r.db.Query(billSelect+`
+		WHERE b.next_due_date IS NOT NULL AND date(b.next_due_date, '-' || b.remind_days || ' days') <= ?
+		ORDER BY b.user_id, b.next_due_date, b.id`,
+		today,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due bills: %w", err)
+	}
+	defer rows.Close()
+
+	var billList []models.Bill
+	for rows.Next() {
+		bill, err := scanBill(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan bill: %w", err)
+		}
+		billList = append(billList, *bill)
+	}
+
+	return billList, nil
+}
@@ -0,0 +1,9 @@
+package repository
+
+import "errors"
+
+// MaxBulkItems caps the number of records a single bulk operation may touch
+const MaxBulkItems = 5000
+
+// ErrTooManyBulkItems is returned when a bulk operation selects more than MaxBulkItems records
+var ErrTooManyBulkItems = errors.New("bulk operation selects too many records")
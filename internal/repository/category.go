@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"myexpress-tracker/internal/models"
+	"strings"
 )
 
 // CategoryRepository handles database operations for categories
@@ -16,15 +17,26 @@ func NewCategoryRepository(db *sql.DB) *CategoryRepository {
 	return &CategoryRepository{db: db}
 }
 
-// GetAll retrieves all categories
-func (r *CategoryRepository) GetAll() ([]models.Category, error) {
+// visibleIn restricts categories to the ones usable in a ledger: the shared
+// defaults plus the ledger's own, a user's personal categories or a household's
+func visibleIn(ledger Ledger) (string, []interface{}) {
+	if ledger.HouseholdID == 0 {
+		return "household_id IS NULL AND (user_id IS NULL OR user_id = ?)", []interface{}{ledger.UserID}
+	}
+	return "((household_id IS NULL AND user_id IS NULL) OR household_id = ?)", []interface{}{ledger.HouseholdID}
+}
+
+// GetAll retrieves all categories visible in a ledger
+func (r *CategoryRepository) GetAll(ledger Ledger) ([]models.Category, error) {
+	condition, args := visibleIn(ledger)
 	query := `
-		SELECT id, name, type, created_at
+		SELECT id, name, type, household_id, user_id, created_at
 		FROM categories
+		WHERE ` + condition + `
 		ORDER BY type, name
 	`
-	
-	rows, err := r.db.Query(query)
+
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query categories: %w", err)
 	}
@@ -33,7 +45,7 @@ func (r *CategoryRepository) GetAll() ([]models.Category, error) {
 	var categories []models.Category
 	for rows.Next() {
 		var cat models.Category
-		if err := rows.Scan(&cat.ID, &cat.Name, &cat.Type, &cat.CreatedAt); err != nil {
+		if err := rows.Scan(&cat.ID, &cat.Name, &cat.Type, &cat.HouseholdID, &cat.UserID, &cat.CreatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan category: %w", err)
 		}
 		categories = append(categories, cat)
@@ -42,16 +54,17 @@ func (r *CategoryRepository) GetAll() ([]models.Category, error) {
 	return categories, nil
 }
 
-// GetByType retrieves categories by type (income or expense)
-func (r *CategoryRepository) GetByType(categoryType string) ([]models.Category, error) {
+// GetByType retrieves categories by type (income or expense) visible in a ledger
+func (r *CategoryRepository) GetByType(categoryType string, ledger Ledger) ([]models.Category, error) {
+	condition, args := visibleIn(ledger)
 	query := `
-		SELECT id, name, type, created_at
+		SELECT id, name, type, household_id, user_id, created_at
 		FROM categories
-		WHERE type = ?
+		WHERE type = ? AND ` + condition + `
 		ORDER BY name
 	`
-	
-	rows, err := r.db.Query(query, categoryType)
+
+	rows, err := r.db.Query(query, append([]interface{}{categoryType}, args...)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query categories by type: %w", err)
 	}
@@ -60,7 +73,7 @@ func (r *CategoryRepository) GetByType(categoryType string) ([]models.Category,
 	var categories []models.Category
 	for rows.Next() {
 		var cat models.Category
-		if err := rows.Scan(&cat.ID, &cat.Name, &cat.Type, &cat.CreatedAt); err != nil {
+		if err := rows.Scan(&cat.ID, &cat.Name, &cat.Type, &cat.HouseholdID, &cat.UserID, &cat.CreatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan category: %w", err)
 		}
 		categories = append(categories, cat)
@@ -69,17 +82,17 @@ func (r *CategoryRepository) GetByType(categoryType string) ([]models.Category,
 	return categories, nil
 }
 
-// GetByID retrieves a category by ID
-func (r *CategoryRepository) GetByID(id int64) (*models.Category, error) {
+// GetByID retrieves a category by ID if it is visible in a ledger
+func (r *CategoryRepository) GetByID(id int64, ledger Ledger) (*models.Category, error) {
+	condition, args := visibleIn(ledger)
 	query := `
-		SELECT id, name, type, created_at
+		SELECT id, name, type, household_id, user_id, created_at
 		FROM categories
-		WHERE id = ?
-	`
-	
+		WHERE id = ? AND ` + condition
+
 	cat := &models.Category{}
-	err := r.db.QueryRow(query, id).Scan(&cat.ID, &cat.Name, &cat.Type, &cat.CreatedAt)
-	
+	err := r.db.QueryRow(query, append([]interface{}{id}, args...)...).Scan(&cat.ID, &cat.Name, &cat.Type, &cat.HouseholdID, &cat.UserID, &cat.CreatedAt)
+
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -89,3 +102,56 @@ func (r *CategoryRepository) GetByID(id int64) (*models.Category, error) {
 
 	return cat, nil
 }
+
+// Create adds a category to the personal ledger of userID, or to a household
+// when category.HouseholdID is set, where userID must be an owner or editor.
+// Names are unique among the categories visible in the ledger, so
+// ErrDuplicateName is returned on a clash.
+func (r *CategoryRepository) Create(category *models.Category, userID int64) error {
+	ledger := ledgerOf(userID, category.HouseholdID)
+	allowed, err := ledger.canWrite(r.db)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrForbidden
+	}
+
+	category.UserID = nil
+	if ledger.HouseholdID == 0 {
+		category.UserID = &userID
+	}
+
+	condition, args := visibleIn(ledger)
+	result, err := r.db.Exec(`
+		INSERT INTO categories (name, type, household_id, user_id)
+		SELECT ?, ?, ?, ?
+		WHERE NOT EXISTS (
+			SELECT 1 FROM categories
+			WHERE name = ? COLLATE NOCASE AND `+condition+`
+		)`,
+		append([]interface{}{category.Name, category.Type, category.HouseholdID, category.UserID, category.Name}, args...)...,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrDuplicateName
+		}
+		return fmt.Errorf("failed to create category: %w", err)
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to create category: %w", err)
+	}
+	if inserted == 0 {
+		return ErrDuplicateName
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	category.ID = id
+	return nil
+}
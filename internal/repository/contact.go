@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"myexpress-tracker/internal/models"
+	"strings"
+)
+
+// ErrContactInUse is returned when deleting a contact that shared bills or settlements refer to
+var ErrContactInUse = errors.New("contact is used by shared bills or settlements")
+
+// ContactRepository handles database operations for a user's contacts
+type ContactRepository struct {
+	db *sql.DB
+}
+
+// NewContactRepository creates a new contact repository
+func NewContactRepository(db *sql.DB) *ContactRepository {
+	return &ContactRepository{db: db}
+}
+
+// Create creates a new contact. Names are unique per user, so ErrDuplicateName
+// is returned on a clash.
+func (r *ContactRepository) Create(contact *models.Contact) error {
+	result, err := r.db.Exec(
+		`INSERT INTO contacts (user_id, name, email) VALUES (?, ?, ?)`,
+		contact.UserID, contact.Name, contact.Email,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrDuplicateName
+		}
+		return fmt.Errorf("failed to create contact: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	contact.ID = id
+	return nil
+}
+
+// Update renames a contact or changes its email
+func (r *ContactRepository) Update(contact *models.Contact) error {
+	result, err := r.db.Exec(
+		`UPDATE contacts SET name = ?, email = ? WHERE id = ? AND user_id = ?`,
+		contact.Name, contact.Email, contact.ID, contact.UserID,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrDuplicateName
+		}
+		return fmt.Errorf("failed to update contact: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update contact: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("contact not found or unauthorized")
+	}
+
+	return nil
+}
+
+// Delete deletes a contact that no shared bill or settlement refers to
+func (r *ContactRepository) Delete(id, userID int64) error {
+	result, err := r.db.Exec(`DELETE FROM contacts WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "FOREIGN KEY constraint failed") {
+			return ErrContactInUse
+		}
+		return fmt.Errorf("failed to delete contact: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete contact: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("contact not found or unauthorized")
+	}
+
+	return nil
+}
+
+// GetByID retrieves one of a user's contacts
+func (r *ContactRepository) GetByID(id, userID int64) (*models.Contact, error) {
+	contact := &models.Contact{}
+	err := r.db.QueryRow(
+		`SELECT id, user_id, name, email, created_at FROM contacts WHERE id = ? AND user_id = ?`,
+		id, userID,
+	).Scan(&contact.ID, &contact.UserID, &contact.Name, &contact.Email, &contact.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contact by id: %w", err)
+	}
+
+	return contact, nil
+}
+
+// GetByUser retrieves a user's contacts by name
+func (r *ContactRepository) GetByUser(userID int64) ([]models.Contact, error) {
+	rows, err := r.db.Query(
+		`SELECT id, user_id, name, email, created_at FROM contacts WHERE user_id = ? ORDER BY name COLLATE NOCASE`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contacts: %w", err)
+	}
+	defer rows.Close()
+
+	var contacts []models.Contact
+	for rows.Next() {
+		var contact models.Contact
+		if err := rows.Scan(&contact.ID, &contact.UserID, &contact.Name, &contact.Email, &contact.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan contact: %w", err)
+		}
+		contacts = append(contacts, contact)
+	}
+
+	return contacts, nil
+}
@@ -0,0 +1,10 @@
+package repository
+
+// DateOnly trims a scanned DATE value (which the driver returns as a
+// timestamp) back to YYYY-MM-DD
+func DateOnly(date string) string {
+	if len(date) > 10 {
+		return date[:10]
+	}
+	return date
+}
@@ -0,0 +1,18 @@
+package repository
+
+import "errors"
+
+// ErrModified is returned when a conditional update finds that the record
+// changed since the caller read it
+var ErrModified = errors.New("record was modified by another request")
+
+// ErrForbidden is returned when a user's household role does not allow a change
+var ErrForbidden = errors.New("not allowed for this household role")
+
+// nextUpdatedAt is the SQL expression for a record's new updated_at. It has
+// millisecond precision and always moves forward, so every write yields a
+// distinct value usable as an ETag.
+const nextUpdatedAt = `MAX(strftime('%Y-%m-%d %H:%M:%f', 'now'), strftime('%Y-%m-%d %H:%M:%f', updated_at, '+0.001 seconds'))`
+
+// ErrDuplicateName is returned when a name is already taken in the same scope
+var ErrDuplicateName = errors.New("name already exists")
@@ -0,0 +1,45 @@
+package repository
+
+import "sync"
+
+// Change actions reported to listeners
+const (
+	ActionCreated  = "created"
+	ActionUpdated  = "updated"
+	ActionDeleted  = "deleted"
+	ActionRestored = "restored"
+)
+
+// ChangeEvent describes a successful write to a user's records
+type ChangeEvent struct {
+	UserID      int64
+	HouseholdID int64  // The household ledger of the record, 0 for personal records
+	Entity      string // "income", "expense", "goal" or "insight"
+	Action      string // "created", "updated", "deleted" or "restored"
+	ID          int64
+}
+
+// ChangeListener is called after a repository write succeeds
+type ChangeListener func(event ChangeEvent)
+
+// notifier fans change events out to registered listeners
+type notifier struct {
+	mu        sync.RWMutex
+	listeners []ChangeListener
+}
+
+// OnChange registers a listener that is notified after every write
+func (n *notifier) OnChange(listener ChangeListener) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.listeners = append(n.listeners, listener)
+}
+
+// notify calls every registered listener with the event
+func (n *notifier) notify(event ChangeEvent) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	for _, listener := range n.listeners {
+		listener(event)
+	}
+}
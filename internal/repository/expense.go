@@ -1,14 +1,17 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"myexpress-tracker/internal/models"
 	"strings"
+	"time"
 )
 
 // ExpenseRepository handles database operations for expenses
 type ExpenseRepository struct {
+	notifier
 	db *sql.DB
 }
 
@@ -17,83 +20,266 @@ func NewExpenseRepository(db *sql.DB) *ExpenseRepository {
 	return &ExpenseRepository{db: db}
 }
 
-// Create creates a new expense record
-func (r *ExpenseRepository) Create(expense *models.Expense) error {
+// Create creates a new expense record along with its splits and shared bill, if any
+func (r *ExpenseRepository) Create(ctx context.Context, expense *models.Expense) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	id, err := r.createTx(ctx, tx, expense)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit expense: %w", err)
+	}
+
+	expense.ID = id
+	r.notify(ChangeEvent{UserID: expense.UserID, HouseholdID: householdIDOf(expense.HouseholdID), Entity: "expense", Action: ActionCreated, ID: id})
+	return nil
+}
+
+// createTx writes a new expense record along with its splits, shared bill,
+// rollup and audit changes, returning its id
+func (r *ExpenseRepository) createTx(ctx context.Context, tx execer, expense *models.Expense) (int64, error) {
+	allowed, err := ledgerOf(expense.UserID, expense.HouseholdID).canWrite(tx)
+	if err != nil {
+		return 0, err
+	}
+	if !allowed {
+		return 0, ErrForbidden
+	}
+
+	if err := prepareShares(tx, expense); err != nil {
+		return 0, err
+	}
+	if err := prepareSplits(expense); err != nil {
+		return 0, err
+	}
+
 	query := `
-		INSERT INTO expense (user_id, category_id, amount, description, expense_date)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO expense (user_id, household_id, category_id, amount, description, expense_date, tags, account)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	result, err := r.db.Exec(query, expense.UserID, expense.CategoryID, expense.Amount, expense.Description, expense.ExpenseDate)
+	result, err := tx.Exec(query, expense.UserID, expense.HouseholdID, expense.CategoryID, expense.Amount, expense.Description, expense.ExpenseDate, expense.Tags, expense.Account)
 	if err != nil {
-		return fmt.Errorf("failed to create expense: %w", err)
+		return 0, fmt.Errorf("failed to create expense: %w", err)
 	}
 
 	id, err := result.LastInsertId()
 	if err != nil {
-		return fmt.Errorf("failed to get last insert id: %w", err)
+		return 0, fmt.Errorf("failed to get last insert id: %w", err)
 	}
 
-	expense.ID = id
-	return nil
+	if err := writeSplits(tx, id, expense.Splits); err != nil {
+		return 0, err
+	}
+	if err := writeShares(tx, id, expense.Shared); err != nil {
+		return 0, err
+	}
+
+	if err := adjustExpenseRollups(tx, expense, 1); err != nil {
+		return 0, err
+	}
+
+	if expense.HouseholdID == nil {
+		if err := claimClientUUID(ctx, tx, expense.UserID, "expense", id); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := recordExternalID(ctx, tx, expense.UserID, "expense", id); err != nil {
+		return 0, err
+	}
+
+	if err := recordAudit(ctx, tx, expense.UserID, expense.HouseholdID, "expense", id, ActionCreated, nil, expense); err != nil {
+		return 0, err
+	}
+
+	return id, nil
 }
 
 // Update updates an existing expense record
-func (r *ExpenseRepository) Update(expense *models.Expense) error {
+func (r *ExpenseRepository) Update(ctx context.Context, expense *models.Expense) error {
+	return r.update(ctx, expense, nil)
+}
+
+// UpdateIfUnmodified updates an existing expense record only if its updated_at
+// still equals updatedAt, returning ErrModified otherwise
+func (r *ExpenseRepository) UpdateIfUnmodified(ctx context.Context, expense *models.Expense, updatedAt time.Time) error {
+	return r.update(ctx, expense, &updatedAt)
+}
+
+// update writes an expense record, optionally checking it is unmodified first
+func (r *ExpenseRepository) update(ctx context.Context, expense *models.Expense, updatedAt *time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	old, err := r.loadForWrite(tx, expense.ID, ledgerOf(expense.UserID, expense.HouseholdID))
+	if err != nil {
+		return err
+	}
+	if updatedAt != nil && !old.UpdatedAt.Equal(*updatedAt) {
+		return ErrModified
+	}
+
+	if err := r.updateTx(ctx, tx, old, expense); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit expense: %w", err)
+	}
+
+	r.notify(ChangeEvent{UserID: expense.UserID, HouseholdID: householdIDOf(expense.HouseholdID), Entity: "expense", Action: ActionUpdated, ID: expense.ID})
+	return nil
+}
+
+// updateTx writes an updated expense record along with its splits, shared bill,
+// rollup and audit changes. Nil splits keep the existing ones; an empty slice
+// removes them. A nil shared bill keeps the existing one, whose owner share must
+// then stay the amount; a shared bill without participants stops sharing.
+// A record keeps its author and ledger whoever edits it.
+func (r *ExpenseRepository) updateTx(ctx context.Context, tx execer, old, expense *models.Expense) error {
+	expense.UserID = old.UserID
+	expense.HouseholdID = old.HouseholdID
+	if expense.Splits == nil {
+		expense.Splits = old.Splits
+	}
+	if expense.Shared == nil && old.Shared != nil {
+		if toCents(expense.Amount) != toCents(old.Amount) {
+			return ErrSharedAmount
+		}
+		expense.Shared = old.Shared
+	}
+	if err := prepareShares(tx, expense); err != nil {
+		return err
+	}
+	if err := prepareSplits(expense); err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE expense
-		SET category_id = ?, amount = ?, description = ?, expense_date = ?, updated_at = CURRENT_TIMESTAMP
+		SET category_id = ?, amount = ?, description = ?, expense_date = ?, tags = ?, account = ?, updated_at = `+nextUpdatedAt+`
 		WHERE id = ? AND user_id = ?
 	`
-	result, err := r.db.Exec(query, expense.CategoryID, expense.Amount, expense.Description, expense.ExpenseDate, expense.ID, expense.UserID)
+	_, err := tx.Exec(query, expense.CategoryID, expense.Amount, expense.Description, expense.ExpenseDate, expense.Tags, expense.Account, expense.ID, expense.UserID)
 	if err != nil {
 		return fmt.Errorf("failed to update expense: %w", err)
 	}
 
-	rows, err := result.RowsAffected()
+	if err := writeSplits(tx, expense.ID, expense.Splits); err != nil {
+		return err
+	}
+	if err := writeShares(tx, expense.ID, expense.Shared); err != nil {
+		return err
+	}
+
+	if err := adjustExpenseRollups(tx, old, -1); err != nil {
+		return err
+	}
+	if err := adjustExpenseRollups(tx, expense, 1); err != nil {
+		return err
+	}
+
+	return recordAudit(ctx, tx, expense.UserID, expense.HouseholdID, "expense", expense.ID, ActionUpdated, old, expense)
+}
+
+// Delete moves an expense record in the ledger to the trash
+func (r *ExpenseRepository) Delete(ctx context.Context, id int64, ledger Ledger) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	old, err := r.loadForWrite(tx, id, ledger)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return err
 	}
 
-	if rows == 0 {
-		return fmt.Errorf("expense not found or unauthorized")
+	if err := r.deleteTx(ctx, tx, old); err != nil {
+		return err
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit expense: %w", err)
+	}
+
+	r.notify(ChangeEvent{UserID: old.UserID, HouseholdID: householdIDOf(old.HouseholdID), Entity: "expense", Action: ActionDeleted, ID: id})
 	return nil
 }
 
-// Delete deletes an expense record
-func (r *ExpenseRepository) Delete(id, userID int64) error {
-	query := `DELETE FROM expense WHERE id = ? AND user_id = ?`
-	result, err := r.db.Exec(query, id, userID)
-	if err != nil {
+// deleteTx moves a loaded expense record to the trash along with its rollup and audit changes
+func (r *ExpenseRepository) deleteTx(ctx context.Context, tx execer, old *models.Expense) error {
+	if _, err := tx.Exec(`UPDATE expense SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ?`, old.ID, old.UserID); err != nil {
 		return fmt.Errorf("failed to delete expense: %w", err)
 	}
 
-	rows, err := result.RowsAffected()
+	if err := adjustExpenseRollups(tx, old, -1); err != nil {
+		return err
+	}
+
+	return recordAudit(ctx, tx, old.UserID, old.HouseholdID, "expense", old.ID, ActionDeleted, old, nil)
+}
+
+// loadForWrite loads a live expense record, its splits and shared bill inside a write transaction
+func (r *ExpenseRepository) loadForWrite(tx execer, id int64, ledger Ledger) (*models.Expense, error) {
+	condition, args := ledger.writeCondition("")
+	old, err := scanExpenseForWrite(tx.QueryRow(
+		`SELECT id, user_id, household_id, category_id, amount, COALESCE(description, ''), expense_date, tags, account, updated_at
+		FROM expense WHERE id = ? AND deleted_at IS NULL AND `+condition,
+		append([]interface{}{id}, args...)...,
+	))
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("expense not found or unauthorized")
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return nil, fmt.Errorf("failed to load expense: %w", err)
 	}
 
-	if rows == 0 {
-		return fmt.Errorf("expense not found or unauthorized")
+	if old.Splits, err = loadSplits(tx, old.ID); err != nil {
+		return nil, err
+	}
+	if err := loadShares(tx, old); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return old, nil
 }
 
-// GetByID retrieves an expense record by ID
-func (r *ExpenseRepository) GetByID(id, userID int64) (*models.Expense, error) {
+// scanExpenseForWrite scans the columns selected by loadForWrite
+func scanExpenseForWrite(row interface{ Scan(dest ...interface{}) error }) (*models.Expense, error) {
+	expense := &models.Expense{}
+	if err := row.Scan(&expense.ID, &expense.UserID, &expense.HouseholdID, &expense.CategoryID, &expense.Amount, &expense.Description, &expense.ExpenseDate, &expense.Tags, &expense.Account, &expense.UpdatedAt); err != nil {
+		return nil, err
+	}
+	expense.ExpenseDate = DateOnly(expense.ExpenseDate)
+	return expense, nil
+}
+
+// GetByID retrieves an expense record by ID from the ledger
+func (r *ExpenseRepository) GetByID(id int64, ledger Ledger) (*models.Expense, error) {
+	condition, args := ledger.readCondition("e.")
 	query := `
-		SELECT e.id, e.user_id, e.category_id, e.amount, e.description, e.expense_date, e.created_at, e.updated_at, c.name
+		SELECT e.id, e.user_id, e.household_id, e.category_id, e.amount, e.description, e.expense_date, e.tags, e.account, e.created_at, e.updated_at, c.name
 		FROM expense e
 		JOIN categories c ON e.category_id = c.id
-		WHERE e.id = ? AND e.user_id = ?
+		WHERE e.id = ? AND e.deleted_at IS NULL AND `+condition+`
 	`
 	
 	expense := &models.Expense{}
-	err := r.db.QueryRow(query, id, userID).Scan(
-		&expense.ID, &expense.UserID, &expense.CategoryID, &expense.Amount, &expense.Description,
-		&expense.ExpenseDate, &expense.CreatedAt, &expense.UpdatedAt, &expense.CategoryName,
+	err := r.db.QueryRow(query, append([]interface{}{id}, args...)...).Scan(
+		&expense.ID, &expense.UserID, &expense.HouseholdID, &expense.CategoryID, &expense.Amount, &expense.Description,
+		&expense.ExpenseDate, &expense.Tags, &expense.Account, &expense.CreatedAt, &expense.UpdatedAt, &expense.CategoryName,
 	)
 	
 	if err == sql.ErrNoRows {
@@ -103,40 +289,31 @@ func (r *ExpenseRepository) GetByID(id, userID int64) (*models.Expense, error) {
 		return nil, fmt.Errorf("failed to get expense by id: %w", err)
 	}
 
-	return expense, nil
+	expense.ExpenseDate = DateOnly(expense.ExpenseDate)
+	expenses := []models.Expense{*expense}
+	if err := attachSplits(r.db, expenses, "e.id = ?", id); err != nil {
+		return nil, err
+	}
+	if err := attachShares(r.db, expenses, "e.id = ?", id); err != nil {
+		return nil, err
+	}
+	return &expenses[0], nil
 }
 
-// GetByUser retrieves all expense records for a user with optional filters
-func (r *ExpenseRepository) GetByUser(userID int64, filters map[string]interface{}) ([]models.Expense, error) {
+// GetByUser retrieves all expense records in a user's ledger with optional filters
+func (r *ExpenseRepository) GetByUser(ledger Ledger, filters map[string]interface{}) ([]models.Expense, error) {
+	condition, args := ledger.readCondition("e.")
 	query := `
-		SELECT e.id, e.user_id, e.category_id, e.amount, e.description, e.expense_date, e.created_at, e.updated_at, c.name
+		SELECT e.id, e.user_id, e.household_id, e.category_id, e.amount, e.description, e.expense_date, e.tags, e.account, e.created_at, e.updated_at, c.name
 		FROM expense e
 		JOIN categories c ON e.category_id = c.id
-		WHERE e.user_id = ?
+		WHERE e.deleted_at IS NULL AND `+condition+`
 	`
 	
-	args := []interface{}{userID}
-	
 	// Add filters
-	if categoryID, ok := filters["category_id"].(int64); ok && categoryID > 0 {
-		query += " AND e.category_id = ?"
-		args = append(args, categoryID)
-	}
-	
-	if date, ok := filters["date"].(string); ok && date != "" {
-		query += " AND e.expense_date = ?"
-		args = append(args, date)
-	}
-	
-	if startDate, ok := filters["start_date"].(string); ok && startDate != "" {
-		query += " AND e.expense_date >= ?"
-		args = append(args, startDate)
-	}
-	
-	if endDate, ok := filters["end_date"].(string); ok && endDate != "" {
-		query += " AND e.expense_date <= ?"
-		args = append(args, endDate)
-	}
+	conditions, filterArgs := expenseFilterConditions("e.", filters)
+	query += conditions
+	args = append(args, filterArgs...)
 	
 	query += " ORDER BY e.expense_date DESC, e.created_at DESC"
 	
@@ -150,47 +327,79 @@ func (r *ExpenseRepository) GetByUser(userID int64, filters map[string]interface
 	for rows.Next() {
 		var expense models.Expense
 		if err := rows.Scan(
-			&expense.ID, &expense.UserID, &expense.CategoryID, &expense.Amount, &expense.Description,
-			&expense.ExpenseDate, &expense.CreatedAt, &expense.UpdatedAt, &expense.CategoryName,
+			&expense.ID, &expense.UserID, &expense.HouseholdID, &expense.CategoryID, &expense.Amount, &expense.Description,
+			&expense.ExpenseDate, &expense.Tags, &expense.Account, &expense.CreatedAt, &expense.UpdatedAt, &expense.CategoryName,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan expense: %w", err)
 		}
+		expense.ExpenseDate = DateOnly(expense.ExpenseDate)
 		expenses = append(expenses, expense)
 	}
 
+	if err := attachSplits(r.db, expenses, "e.deleted_at IS NULL AND "+condition+conditions, args...); err != nil {
+		return nil, err
+	}
+	if err := attachShares(r.db, expenses, "e.deleted_at IS NULL AND "+condition+conditions, args...); err != nil {
+		return nil, err
+	}
+
 	return expenses, nil
 }
 
-// GetTotalByUser calculates total expense for a user with optional filters
-func (r *ExpenseRepository) GetTotalByUser(userID int64, filters map[string]interface{}) (float64, error) {
-	query := `SELECT COALESCE(SUM(amount), 0) FROM expense WHERE user_id = ?`
-	args := []interface{}{userID}
-	
-	// Add filters
+// expenseFilterConditions builds the SQL conditions (each prefixed with AND) for
+// the list filters, qualifying columns with prefix. A category filter matches
+// split expenses with a split in that category.
+func expenseFilterConditions(prefix string, filters map[string]interface{}) (string, []interface{}) {
 	conditions := []string{}
+	args := []interface{}{}
+
 	if categoryID, ok := filters["category_id"].(int64); ok && categoryID > 0 {
-		conditions = append(conditions, "category_id = ?")
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM expense_lines l WHERE l.expense_id = "+prefix+"id AND l.category_id = ?)")
 		args = append(args, categoryID)
 	}
-	
+
 	if date, ok := filters["date"].(string); ok && date != "" {
-		conditions = append(conditions, "expense_date = ?")
+		conditions = append(conditions, prefix+"expense_date = ?")
 		args = append(args, date)
 	}
-	
+
 	if startDate, ok := filters["start_date"].(string); ok && startDate != "" {
-		conditions = append(conditions, "expense_date >= ?")
+		conditions = append(conditions, prefix+"expense_date >= ?")
 		args = append(args, startDate)
 	}
-	
+
 	if endDate, ok := filters["end_date"].(string); ok && endDate != "" {
-		conditions = append(conditions, "expense_date <= ?")
+		conditions = append(conditions, prefix+"expense_date <= ?")
 		args = append(args, endDate)
 	}
-	
-	if len(conditions) > 0 {
-		query += " AND " + strings.Join(conditions, " AND ")
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " AND " + strings.Join(conditions, " AND "), args
+}
+
+// GetTotalByUser calculates total expense in a user's ledger with optional filters.
+// With a category filter only the splits in that category are counted.
+func (r *ExpenseRepository) GetTotalByUser(ledger Ledger, filters map[string]interface{}) (float64, error) {
+	condition, args := ledger.readCondition("")
+	query := `SELECT COALESCE(SUM(amount), 0) FROM expense_lines WHERE deleted_at IS NULL AND ` + condition
+
+	// Filter lines by category directly so other splits of the same expense are left out
+	dateFilters := make(map[string]interface{}, len(filters))
+	for name, value := range filters {
+		dateFilters[name] = value
 	}
+	if categoryID, ok := filters["category_id"].(int64); ok && categoryID > 0 {
+		query += " AND category_id = ?"
+		args = append(args, categoryID)
+		delete(dateFilters, "category_id")
+	}
+
+	// Add filters
+	conditions, filterArgs := expenseFilterConditions("", dateFilters)
+	query += conditions
+	args = append(args, filterArgs...)
 	
 	var total float64
 	err := r.db.QueryRow(query, args...).Scan(&total)
@@ -200,3 +409,357 @@ func (r *ExpenseRepository) GetTotalByUser(userID int64, filters map[string]inte
 
 	return total, nil
 }
+
+// GetByAmountNearDate retrieves a user's personal expenses with the same amount dated within days of date
+func (r *ExpenseRepository) GetByAmountNearDate(userID int64, amount float64, date string, days int, excludeID int64) ([]models.Expense, error) {
+	query := `
+		SELECT e.id, e.user_id, e.household_id, e.category_id, e.amount, e.description, e.expense_date, e.tags, e.account, e.created_at, e.updated_at, c.name
+		FROM expense e
+		JOIN categories c ON e.category_id = c.id
+		WHERE e.user_id = ? AND e.household_id IS NULL AND e.id != ? AND e.deleted_at IS NULL AND ABS(e.amount - ?) < 0.005
+			AND e.expense_date >= date(?, ?) AND e.expense_date <= date(?, ?)
+		ORDER BY e.expense_date, e.id
+	`
+
+	window := fmt.Sprintf("%d days", days)
+	rows, err := r.db.Query(query, userID, excludeID, amount, date, "-"+window, date, "+"+window)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expense: %w", err)
+	}
+	defer rows.Close()
+
+	var expenses []models.Expense
+	for rows.Next() {
+		var expense models.Expense
+		if err := rows.Scan(
+			&expense.ID, &expense.UserID, &expense.HouseholdID, &expense.CategoryID, &expense.Amount, &expense.Description,
+			&expense.ExpenseDate, &expense.Tags, &expense.Account, &expense.CreatedAt, &expense.UpdatedAt, &expense.CategoryName,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan expense: %w", err)
+		}
+		expense.ExpenseDate = DateOnly(expense.ExpenseDate)
+		expenses = append(expenses, expense)
+	}
+
+	return expenses, nil
+}
+
+// Merge folds duplicate expenses into the kept one in a single transaction:
+// the kept record is updated and the duplicates are moved to the trash
+func (r *ExpenseRepository) Merge(ctx context.Context, keep *models.Expense, duplicateIDs []int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	old, err := r.loadForWrite(tx, keep.ID, Personal(keep.UserID))
+	if err != nil {
+		return err
+	}
+	if err := r.updateTx(ctx, tx, old, keep); err != nil {
+		return err
+	}
+
+	for _, id := range duplicateIDs {
+		duplicate, err := r.loadForWrite(tx, id, Personal(keep.UserID))
+		if err != nil {
+			return err
+		}
+		if err := r.deleteTx(ctx, tx, duplicate); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit merge: %w", err)
+	}
+
+	r.notify(ChangeEvent{UserID: keep.UserID, HouseholdID: householdIDOf(keep.HouseholdID), Entity: "expense", Action: ActionUpdated, ID: keep.ID})
+	for _, id := range duplicateIDs {
+		r.notify(ChangeEvent{UserID: keep.UserID, HouseholdID: householdIDOf(keep.HouseholdID), Entity: "expense", Action: ActionDeleted, ID: id})
+	}
+	return nil
+}
+
+// GetTrash retrieves the ledger's trashed expense records, most recently deleted first
+func (r *ExpenseRepository) GetTrash(ledger Ledger) ([]models.Expense, error) {
+	condition, args := ledger.readCondition("e.")
+	query := `
+		SELECT e.id, e.user_id, e.household_id, e.category_id, e.amount, e.description, e.expense_date, e.tags, e.account, e.created_at, e.updated_at, e.deleted_at, c.name
+		FROM expense e
+		JOIN categories c ON e.category_id = c.id
+		WHERE e.deleted_at IS NOT NULL AND `+condition+`
+		ORDER BY e.deleted_at DESC, e.id DESC
+	`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trashed expense: %w", err)
+	}
+	defer rows.Close()
+
+	var expenses []models.Expense
+	for rows.Next() {
+		var expense models.Expense
+		var deletedAt sql.NullTime
+		if err := rows.Scan(
+			&expense.ID, &expense.UserID, &expense.HouseholdID, &expense.CategoryID, &expense.Amount, &expense.Description,
+			&expense.ExpenseDate, &expense.Tags, &expense.Account, &expense.CreatedAt, &expense.UpdatedAt, &deletedAt, &expense.CategoryName,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan expense: %w", err)
+		}
+		expense.ExpenseDate = DateOnly(expense.ExpenseDate)
+		if deletedAt.Valid {
+			expense.DeletedAt = &deletedAt.Time
+		}
+		expenses = append(expenses, expense)
+	}
+
+	if err := attachSplits(r.db, expenses, "e.deleted_at IS NOT NULL AND "+condition, args...); err != nil {
+		return nil, err
+	}
+	if err := attachShares(r.db, expenses, "e.deleted_at IS NOT NULL AND "+condition, args...); err != nil {
+		return nil, err
+	}
+
+	return expenses, nil
+}
+
+// Restore moves a trashed expense record in the ledger back out of the trash
+func (r *ExpenseRepository) Restore(ctx context.Context, id int64, ledger Ledger) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	condition, args := ledger.writeCondition("")
+	old, err := scanExpenseForWrite(tx.QueryRow(
+		`SELECT id, user_id, household_id, category_id, amount, COALESCE(description, ''), expense_date, tags, account, updated_at
+		FROM expense WHERE id = ? AND deleted_at IS NOT NULL AND `+condition,
+		append([]interface{}{id}, args...)...,
+	))
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("expense not found in trash")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load expense: %w", err)
+	}
+	if old.Splits, err = loadSplits(tx, id); err != nil {
+		return err
+	}
+	if err := loadShares(tx, old); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE expense SET deleted_at = NULL WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to restore expense: %w", err)
+	}
+
+	if err := adjustExpenseRollups(tx, old, 1); err != nil {
+		return err
+	}
+
+	if err := recordAudit(ctx, tx, old.UserID, old.HouseholdID, "expense", id, ActionRestored, nil, old); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit expense: %w", err)
+	}
+
+	r.notify(ChangeEvent{UserID: old.UserID, HouseholdID: householdIDOf(old.HouseholdID), Entity: "expense", Action: ActionRestored, ID: id})
+	return nil
+}
+
+// Purge permanently deletes a trashed expense record in the ledger
+func (r *ExpenseRepository) Purge(ctx context.Context, id int64, ledger Ledger) error {
+	condition, args := ledger.writeCondition("")
+	purged, err := r.purgeTrashed(ctx, "id = ? AND "+condition, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return err
+	}
+	if purged == 0 {
+		return fmt.Errorf("expense not found in trash")
+	}
+
+	return nil
+}
+
+// EmptyTrash permanently deletes all of a user's trashed expense records
+func (r *ExpenseRepository) EmptyTrash(ctx context.Context, ledger Ledger) (int64, error) {
+	condition, args := ledger.writeCondition("")
+	return r.purgeTrashed(ctx, condition, args...)
+}
+
+// PurgeDeletedBefore permanently deletes expense records trashed before the given time
+func (r *ExpenseRepository) PurgeDeletedBefore(ctx context.Context, before time.Time) (int64, error) {
+	return r.purgeTrashed(ctx, "deleted_at < ?", before.UTC().Format(sqliteTimeFormat))
+}
+
+// purgeTrashed permanently deletes the trashed expense records matching a condition,
+// auditing each one
+func (r *ExpenseRepository) purgeTrashed(ctx context.Context, condition string, args ...interface{}) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(
+		`SELECT id, user_id, household_id, category_id, amount, COALESCE(description, ''), expense_date, tags, account, updated_at
+		FROM expense WHERE deleted_at IS NOT NULL AND `+condition,
+		args...,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query trashed expense: %w", err)
+	}
+
+	var purged []*models.Expense
+	for rows.Next() {
+		expense, err := scanExpenseForWrite(rows)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan expense: %w", err)
+		}
+		purged = append(purged, expense)
+	}
+	rows.Close()
+
+	for _, expense := range purged {
+		if expense.Splits, err = loadSplits(tx, expense.ID); err != nil {
+			return 0, err
+		}
+		if err := loadShares(tx, expense); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`DELETE FROM expense WHERE id = ?`, expense.ID); err != nil {
+			return 0, fmt.Errorf("failed to purge expense: %w", err)
+		}
+		if err := recordAudit(ctx, tx, expense.UserID, expense.HouseholdID, "expense", expense.ID, ActionPurged, expense, nil); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit purge: %w", err)
+	}
+
+	return int64(len(purged)), nil
+}
+
+// Bulk updates or trashes many expense records, selected by ID or by filter, in a
+// single transaction. If any record fails nothing is written. A dry run
+// performs the same writes and rolls them back, so its report matches a real run.
+func (r *ExpenseRepository) Bulk(ctx context.Context, userID int64, req *models.BulkRequest) (*models.BulkResponse, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ids := req.IDs
+	if req.Filter != nil {
+		ids, err = r.matchingIDs(tx, userID, req.Filter.Map())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(ids) > MaxBulkItems {
+		return nil, ErrTooManyBulkItems
+	}
+
+	results := make([]models.BulkResult, 0, len(ids))
+	for _, id := range ids {
+		old, err := r.loadForWrite(tx, id, Personal(userID))
+		if err != nil {
+			results = append(results, models.BulkResult{ID: id, Status: models.BulkFailed, Error: err.Error()})
+			continue
+		}
+
+		if req.Action == models.BulkActionDelete {
+			if err := r.deleteTx(ctx, tx, old); err != nil {
+				return nil, err
+			}
+			results = append(results, models.BulkResult{ID: id, Status: models.BulkDeleted})
+			continue
+		}
+
+		if req.Set.CategoryID != nil && len(old.Splits) > 0 {
+			results = append(results, models.BulkResult{ID: id, Status: models.BulkFailed, Error: "expense is split; change the category of its splits instead"})
+			continue
+		}
+
+		// Nil splits leave the existing ones in place
+		updated := *old
+		updated.Splits = nil
+		if req.Set.CategoryID != nil {
+			updated.CategoryID = *req.Set.CategoryID
+		}
+		if req.Set.Tags != nil {
+			updated.Tags = *req.Set.Tags
+		}
+		if req.Set.Date != nil {
+			updated.ExpenseDate = *req.Set.Date
+		}
+		if req.Set.Account != nil {
+			updated.Account = *req.Set.Account
+		}
+
+		if updated.CategoryID == old.CategoryID && updated.Tags == old.Tags &&
+			updated.ExpenseDate == old.ExpenseDate && updated.Account == old.Account {
+			results = append(results, models.BulkResult{ID: id, Status: models.BulkUnchanged})
+			continue
+		}
+
+		if err := r.updateTx(ctx, tx, old, &updated); err != nil {
+			return nil, err
+		}
+		results = append(results, models.BulkResult{ID: id, Status: models.BulkUpdated})
+	}
+
+	response := models.NewBulkResponse(req, results)
+	if req.DryRun || response.Failed > 0 {
+		return response, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit bulk expense changes: %w", err)
+	}
+	response.Committed = true
+
+	for _, result := range results {
+		switch result.Status {
+		case models.BulkUpdated:
+			r.notify(ChangeEvent{UserID: userID, Entity: "expense", Action: ActionUpdated, ID: result.ID})
+		case models.BulkDeleted:
+			r.notify(ChangeEvent{UserID: userID, Entity: "expense", Action: ActionDeleted, ID: result.ID})
+		}
+	}
+
+	return response, nil
+}
+
+// matchingIDs returns the IDs of a user's live expense records that match the list filters
+func (r *ExpenseRepository) matchingIDs(tx execer, userID int64, filters map[string]interface{}) ([]int64, error) {
+	conditions, args := expenseFilterConditions("expense.", filters)
+	query := `SELECT id FROM expense WHERE user_id = ? AND household_id IS NULL AND deleted_at IS NULL` + conditions + ` ORDER BY expense_date, id`
+
+	rows, err := tx.Query(query, append([]interface{}{userID}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expense: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan expense: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,271 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"myexpress-tracker/internal/models"
+)
+
+// GoalRepository handles database operations for savings goals and their contributions
+type GoalRepository struct {
+	db *sql.DB
+	notifier
+}
+
+// NewGoalRepository creates a new savings goal repository
+func NewGoalRepository(db *sql.DB) *GoalRepository {
+	return &GoalRepository{db: db}
+}
+
+// Create creates a new savings goal
+func (r *GoalRepository) Create(goal *models.SavingsGoal) error {
+	result, err := r.db.Exec(`
+		INSERT INTO savings_goals (user_id, name, target_amount, target_date, start_date, account, category_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		goal.UserID, goal.Name, goal.TargetAmount, goal.TargetDate, goal.StartDate, goal.Account, goal.CategoryID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create savings goal: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	goal.ID = id
+	r.notify(ChangeEvent{UserID: goal.UserID, Entity: "goal", Action: ActionCreated, ID: id})
+	return nil
+}
+
+// Update updates a savings goal
+func (r *GoalRepository) Update(goal *models.SavingsGoal) error {
+	result, err := r.db.Exec(`
+		UPDATE savings_goals
+		SET name = ?, target_amount = ?, target_date = ?, start_date = ?, account = ?, category_id = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND user_id = ?`,
+		goal.Name, goal.TargetAmount, goal.TargetDate, goal.StartDate, goal.Account, goal.CategoryID, goal.ID, goal.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update savings goal: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update savings goal: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("savings goal not found or unauthorized")
+	}
+
+	r.notify(ChangeEvent{UserID: goal.UserID, Entity: "goal", Action: ActionUpdated, ID: goal.ID})
+	return nil
+}
+
+// Delete deletes a savings goal and its contributions
+func (r *GoalRepository) Delete(id, userID int64) error {
+	result, err := r.db.Exec(`DELETE FROM savings_goals WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete savings goal: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete savings goal: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("savings goal not found or unauthorized")
+	}
+
+	r.notify(ChangeEvent{UserID: userID, Entity: "goal", Action: ActionDeleted, ID: id})
+	return nil
+}
+
+// goalSelect selects savings goals (aliased g) with their linked category name
+const goalSelect = `
+	SELECT g.id, g.user_id, g.name, g.target_amount, g.target_date, g.start_date, g.account, g.category_id,
+		g.created_at, g.updated_at, COALESCE(c.name, '')
+	FROM savings_goals g
+	LEFT JOIN categories c ON g.category_id = c.id`
+
+// scanGoal scans a row selected with goalSelect
+func scanGoal(row rowScanner) (*models.SavingsGoal, error) {
+	goal := &models.SavingsGoal{}
+	var targetDate sql.NullString
+	if err := row.Scan(
+		&goal.ID, &goal.UserID, &goal.Name, &goal.TargetAmount, &targetDate, &goal.StartDate, &goal.Account, &goal.CategoryID,
+		&goal.CreatedAt, &goal.UpdatedAt, &goal.CategoryName,
+	); err != nil {
+		return nil, err
+	}
+	if targetDate.Valid {
+		date := DateOnly(targetDate.String)
+		goal.TargetDate = &date
+	}
+	goal.StartDate = DateOnly(goal.StartDate)
+	return goal, nil
+}
+
+// GetByID retrieves one of a user's savings goals
+func (r *GoalRepository) GetByID(id, userID int64) (*models.SavingsGoal, error) {
+	goal, err := scanGoal(r.db.QueryRow(goalSelect+` WHERE g.id = ? AND g.user_id = ?`, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get savings goal by id: %w", err)
+	}
+
+	return goal, nil
+}
+
+// GetByUser retrieves a user's savings goals, soonest target date first
+func (r *GoalRepository) GetByUser(userID int64) ([]models.SavingsGoal, error) {
+	rows, err := r.db.Query(goalSelect+`
+		WHERE g.user_id = ?
+		ORDER BY g.target_date IS NULL, g.target_date, g.id`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query savings goals: %w", err)
+	}
+	defer rows.Close()
+
+	var goals []models.SavingsGoal
+	for rows.Next() {
+		goal, err := scanGoal(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan savings goal: %w", err)
+		}
+		goals = append(goals, *goal)
+	}
+
+	return goals, nil
+}
+
+// Totals returns what has been saved towards a goal up to a day: the sum of its
+// contributions and the amount saved through its linked account or category.
+// Only live personal records from the goal's start date on are counted.
+func (r *GoalRepository) Totals(goal *models.SavingsGoal, today string) (contributed, linked float64, err error) {
+	err = r.db.QueryRow(
+		`SELECT COALESCE(SUM(amount), 0) FROM goal_contributions WHERE goal_id = ? AND contributed_on <= ?`,
+		goal.ID, today,
+	).Scan(&contributed)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to sum goal contributions: %w", err)
+	}
+
+	switch {
+	case goal.Account != "":
+		err = r.db.QueryRow(`
+			SELECT
+				(SELECT COALESCE(SUM(amount), 0) FROM income
+				 WHERE user_id = ? AND household_id IS NULL AND deleted_at IS NULL AND account = ? COLLATE NOCASE
+				   AND income_date >= ? AND income_date <= ?)
+				- (SELECT COALESCE(SUM(amount), 0) FROM expense
+				 WHERE user_id = ? AND household_id IS NULL AND deleted_at IS NULL AND account = ? COLLATE NOCASE
+				   AND expense_date >= ? AND expense_date <= ?)`,
+			goal.UserID, goal.Account, goal.StartDate, today,
+			goal.UserID, goal.Account, goal.StartDate, today,
+		).Scan(&linked)
+	case goal.CategoryID != nil:
+		err = r.db.QueryRow(`
+			SELECT COALESCE((
+				SELECT SUM(amount) FROM income
+				WHERE user_id = ? AND household_id IS NULL AND deleted_at IS NULL AND category_id = ?
+				  AND income_date >= ? AND income_date <= ?
+			), 0) + COALESCE((
+				SELECT SUM(amount) FROM expense_lines
+				WHERE user_id = ? AND household_id IS NULL AND deleted_at IS NULL AND category_id = ?
+				  AND expense_date >= ? AND expense_date <= ?
+			), 0)`,
+			goal.UserID, *goal.CategoryID, goal.StartDate, today,
+			goal.UserID, *goal.CategoryID, goal.StartDate, today,
+		).Scan(&linked)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to sum linked savings: %w", err)
+	}
+
+	return contributed, linked, nil
+}
+
+// AddContribution records a contribution to one of a user's goals
+func (r *GoalRepository) AddContribution(contribution *models.GoalContribution, userID int64) error {
+	result, err := r.db.Exec(`
+		INSERT INTO goal_contributions (goal_id, amount, contributed_on, note)
+		SELECT id, ?, ?, ? FROM savings_goals WHERE id = ? AND user_id = ?`,
+		contribution.Amount, contribution.ContributedOn, contribution.Note, contribution.GoalID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create goal contribution: %w", err)
+	}
+
+	created, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to create goal contribution: %w", err)
+	}
+	if created == 0 {
+		return fmt.Errorf("savings goal not found or unauthorized")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	contribution.ID = id
+	r.notify(ChangeEvent{UserID: userID, Entity: "goal", Action: ActionUpdated, ID: contribution.GoalID})
+	return nil
+}
+
+// DeleteContribution deletes a contribution from one of a user's goals
+func (r *GoalRepository) DeleteContribution(id, goalID, userID int64) error {
+	result, err := r.db.Exec(`
+		DELETE FROM goal_contributions
+		WHERE id = ? AND goal_id = ? AND goal_id IN (SELECT id FROM savings_goals WHERE user_id = ?)`,
+		id, goalID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete goal contribution: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete goal contribution: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("goal contribution not found or unauthorized")
+	}
+
+	r.notify(ChangeEvent{UserID: userID, Entity: "goal", Action: ActionUpdated, ID: goalID})
+	return nil
+}
+
+// GetContributions retrieves the contributions to a goal, newest first
+func (r *GoalRepository) GetContributions(goalID, userID int64) ([]models.GoalContribution, error) {
+	rows, err := r.db.Query(`
+		SELECT gc.id, gc.goal_id, gc.amount, gc.contributed_on, gc.note, gc.created_at
+		FROM goal_contributions gc
+		JOIN savings_goals g ON gc.goal_id = g.id
+		WHERE gc.goal_id = ? AND g.user_id = ?
+		ORDER BY gc.contributed_on DESC, gc.id DESC`,
+		goalID, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query goal contributions: %w", err)
+	}
+	defer rows.Close()
+
+	var contributions []models.GoalContribution
+	for rows.Next() {
+		var contribution models.GoalContribution
+		if err := rows.Scan(&contribution.ID, &contribution.GoalID, &contribution.Amount, &contribution.ContributedOn, &contribution.Note, &contribution.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan goal contribution: %w", err)
+		}
+		contribution.ContributedOn = DateOnly(contribution.ContributedOn)
+		contributions = append(contributions, contribution)
+	}
+
+	return contributions, nil
+}
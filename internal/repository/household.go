@@ -0,0 +1,617 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"myexpress-tracker/internal/models"
+	"strings"
+)
+
+var (
+	// ErrNotMember is returned when a user does not belong to a household
+	ErrNotMember = errors.New("household not found")
+	// ErrLastOwner is returned when a change would leave a household without an owner
+	ErrLastOwner = errors.New("a household needs at least one owner")
+	// ErrAlreadyMember is returned when inviting someone who already belongs to the household
+	ErrAlreadyMember = errors.New("user is already a member")
+	// ErrInviteeNotFound is returned when no user matches an invitation's email or username
+	ErrInviteeNotFound = errors.New("no user with that email or username")
+	// ErrAlreadyInvited is returned when the user already has a pending invitation
+	ErrAlreadyInvited = errors.New("user already has a pending invitation")
+	// ErrInvitationNotFound is returned when a pending invitation does not exist
+	ErrInvitationNotFound = errors.New("invitation not found")
+)
+
+// HouseholdRepository handles database operations for households, their
+// members and invitations. Every change checks the acting user's role.
+type HouseholdRepository struct {
+	db *sql.DB
+}
+
+// NewHouseholdRepository creates a new household repository
+func NewHouseholdRepository(db *sql.DB) *HouseholdRepository {
+	return &HouseholdRepository{db: db}
+}
+
+// Create creates a household with its creator as the owner
+func (r *HouseholdRepository) Create(household *models.Household, ownerID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO households (name) VALUES (?)`, household.Name)
+	if err != nil {
+		return fmt.Errorf("failed to create household: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO household_members (household_id, user_id, role) VALUES (?, ?, ?)`,
+		id, ownerID, models.RoleOwner,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add household owner: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit household: %w", err)
+	}
+
+	household.ID = id
+	household.Role = models.RoleOwner
+	return nil
+}
+
+// Role returns a user's role in a household, or "" when they are not a member
+func (r *HouseholdRepository) Role(householdID, userID int64) (string, error) {
+	return memberRole(r.db, householdID, userID)
+}
+
+// memberRole looks up a user's role in a household
+func memberRole(q execer, householdID, userID int64) (string, error) {
+	var role string
+	err := q.QueryRow(
+		`SELECT role FROM household_members WHERE household_id = ? AND user_id = ?`,
+		householdID, userID,
+	).Scan(&role)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get household role: %w", err)
+	}
+
+	return role, nil
+}
+
+// requireRole checks that the acting user has one of roles in a household
+func requireRole(q execer, householdID, actorID int64, roles ...string) error {
+	role, err := memberRole(q, householdID, actorID)
+	if err != nil {
+		return err
+	}
+	if role == "" {
+		return ErrNotMember
+	}
+
+	for _, allowed := range roles {
+		if role == allowed {
+			return nil
+		}
+	}
+	return ErrForbidden
+}
+
+// GetByUser retrieves the households a user belongs to, with their role in each
+func (r *HouseholdRepository) GetByUser(userID int64) ([]models.Household, error) {
+	query := `
+		SELECT h.id, h.name, m.role, h.created_at
+		FROM households h
+		JOIN household_members m ON m.household_id = h.id
+		WHERE m.user_id = ?
+		ORDER BY h.name, h.id
+	`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query households: %w", err)
+	}
+	defer rows.Close()
+
+	var households []models.Household
+	for rows.Next() {
+		var household models.Household
+		if err := rows.Scan(&household.ID, &household.Name, &household.Role, &household.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan household: %w", err)
+		}
+		households = append(households, household)
+	}
+
+	return households, nil
+}
+
+// GetByID retrieves a household and its members if the user belongs to it
+func (r *HouseholdRepository) GetByID(id, userID int64) (*models.Household, error) {
+	query := `
+		SELECT h.id, h.name, m.role, h.created_at
+		FROM households h
+		JOIN household_members m ON m.household_id = h.id
+		WHERE h.id = ? AND m.user_id = ?
+	`
+
+	household := &models.Household{}
+	err := r.db.QueryRow(query, id, userID).Scan(&household.ID, &household.Name, &household.Role, &household.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get household by id: %w", err)
+	}
+
+	household.Members, err = r.members(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return household, nil
+}
+
+// members retrieves the members of a household, owners first
+func (r *HouseholdRepository) members(householdID int64) ([]models.HouseholdMember, error) {
+	query := `
+		SELECT m.household_id, m.user_id, u.username, u.email, m.role, m.joined_at
+		FROM household_members m
+		JOIN users u ON m.user_id = u.id
+		WHERE m.household_id = ?
+		ORDER BY CASE m.role WHEN 'owner' THEN 0 WHEN 'editor' THEN 1 ELSE 2 END, u.username
+	`
+
+	rows, err := r.db.Query(query, householdID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query household members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []models.HouseholdMember
+	for rows.Next() {
+		var member models.HouseholdMember
+		if err := rows.Scan(&member.HouseholdID, &member.UserID, &member.Username, &member.Email, &member.Role, &member.JoinedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan household member: %w", err)
+		}
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+// Rename changes a household's name. Only owners can rename a household.
+func (r *HouseholdRepository) Rename(householdID, actorID int64, name string) error {
+	if err := requireRole(r.db, householdID, actorID, models.RoleOwner); err != nil {
+		return err
+	}
+
+	if _, err := r.db.Exec(`UPDATE households SET name = ? WHERE id = ?`, name, householdID); err != nil {
+		return fmt.Errorf("failed to rename household: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes a household along with its shared records and categories.
+// Only owners can delete a household. Each record is audited as purged.
+func (r *HouseholdRepository) Delete(ctx context.Context, householdID, actorID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := requireRole(tx, householdID, actorID, models.RoleOwner); err != nil {
+		return err
+	}
+
+	// Records go first: their categories cannot be deleted while in use
+	if err := purgeHouseholdIncome(ctx, tx, householdID); err != nil {
+		return err
+	}
+	if err := purgeHouseholdExpenses(ctx, tx, householdID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM households WHERE id = ?`, householdID); err != nil {
+		return fmt.Errorf("failed to delete household: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit household deletion: %w", err)
+	}
+	return nil
+}
+
+// purgeHouseholdIncome permanently deletes a household's income records,
+// trashed or not, auditing each one. The household's log is no longer readable
+// once it is gone, so the entries go to the personal log of each record's author.
+func purgeHouseholdIncome(ctx context.Context, tx *sql.Tx, householdID int64) error {
+	rows, err := tx.Query(
+		`SELECT id, user_id, household_id, category_id, amount, COALESCE(description, ''), income_date, tags, account, updated_at
+		FROM income WHERE household_id = ?`,
+		householdID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query household income: %w", err)
+	}
+
+	var purged []*models.Income
+	for rows.Next() {
+		income, err := scanIncomeForWrite(rows)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan income: %w", err)
+		}
+		purged = append(purged, income)
+	}
+	rows.Close()
+
+	for _, income := range purged {
+		if _, err := tx.Exec(`DELETE FROM income WHERE id = ?`, income.ID); err != nil {
+			return fmt.Errorf("failed to purge income: %w", err)
+		}
+		if err := recordAudit(ctx, tx, income.UserID, nil, "income", income.ID, ActionPurged, income, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// purgeHouseholdExpenses permanently deletes a household's expense records,
+// trashed or not, auditing each one in its author's personal log
+func purgeHouseholdExpenses(ctx context.Context, tx *sql.Tx, householdID int64) error {
+	rows, err := tx.Query(
+		`SELECT id, user_id, household_id, category_id, amount, COALESCE(description, ''), expense_date, tags, account, updated_at
+		FROM expense WHERE household_id = ?`,
+		householdID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query household expense: %w", err)
+	}
+
+	var purged []*models.Expense
+	for rows.Next() {
+		expense, err := scanExpenseForWrite(rows)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan expense: %w", err)
+		}
+		purged = append(purged, expense)
+	}
+	rows.Close()
+
+	for _, expense := range purged {
+		if expense.Splits, err = loadSplits(tx, expense.ID); err != nil {
+			return err
+		}
+		if err := loadShares(tx, expense); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM expense WHERE id = ?`, expense.ID); err != nil {
+			return fmt.Errorf("failed to purge expense: %w", err)
+		}
+		if err := recordAudit(ctx, tx, expense.UserID, nil, "expense", expense.ID, ActionPurged, expense, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetRole changes a member's role. Only owners can change roles, and the
+// last owner cannot be demoted.
+func (r *HouseholdRepository) SetRole(householdID, memberID, actorID int64, role string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := requireRole(tx, householdID, actorID, models.RoleOwner); err != nil {
+		return err
+	}
+
+	current, err := memberRole(tx, householdID, memberID)
+	if err != nil {
+		return err
+	}
+	if current == "" {
+		return ErrNotMember
+	}
+
+	if current == models.RoleOwner && role != models.RoleOwner {
+		if err := ensureAnotherOwner(tx, householdID, memberID); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(
+		`UPDATE household_members SET role = ? WHERE household_id = ? AND user_id = ?`,
+		role, householdID, memberID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update household role: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// RemoveMember removes a member from a household. Owners can remove anyone and
+// every member can remove themselves, but the last owner cannot leave.
+func (r *HouseholdRepository) RemoveMember(householdID, memberID, actorID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if memberID != actorID {
+		if err := requireRole(tx, householdID, actorID, models.RoleOwner); err != nil {
+			return err
+		}
+	}
+
+	current, err := memberRole(tx, householdID, memberID)
+	if err != nil {
+		return err
+	}
+	if current == "" {
+		return ErrNotMember
+	}
+
+	if current == models.RoleOwner {
+		if err := ensureAnotherOwner(tx, householdID, memberID); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`DELETE FROM household_members WHERE household_id = ? AND user_id = ?`, householdID, memberID)
+	if err != nil {
+		return fmt.Errorf("failed to remove household member: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// ensureAnotherOwner returns ErrLastOwner unless a household has an owner besides userID
+func ensureAnotherOwner(tx execer, householdID, userID int64) error {
+	var owners int
+	err := tx.QueryRow(
+		`SELECT COUNT(*) FROM household_members WHERE household_id = ? AND role = ? AND user_id != ?`,
+		householdID, models.RoleOwner, userID,
+	).Scan(&owners)
+	if err != nil {
+		return fmt.Errorf("failed to count household owners: %w", err)
+	}
+	if owners == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// Invite invites an existing user, found by email or username, to join a
+// household with the given role. Only owners can invite.
+func (r *HouseholdRepository) Invite(householdID, actorID int64, invitee, role string) (*models.HouseholdInvitation, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := requireRole(tx, householdID, actorID, models.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	var inviteeID int64
+	var username string
+	err = tx.QueryRow(
+		`SELECT id, username FROM users WHERE email = ? OR username = ?`,
+		invitee, invitee,
+	).Scan(&inviteeID, &username)
+	if err == sql.ErrNoRows {
+		return nil, ErrInviteeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find invitee: %w", err)
+	}
+
+	current, err := memberRole(tx, householdID, inviteeID)
+	if err != nil {
+		return nil, err
+	}
+	if current != "" {
+		return nil, ErrAlreadyMember
+	}
+
+	result, err := tx.Exec(
+		`INSERT INTO household_invitations (household_id, invited_by, invitee_id, role) VALUES (?, ?, ?, ?)`,
+		householdID, actorID, inviteeID, role,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, ErrAlreadyInvited
+		}
+		return nil, fmt.Errorf("failed to create invitation: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	invitation, err := scanInvitation(tx.QueryRow(invitationSelect+` WHERE i.id = ?`, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load invitation: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit invitation: %w", err)
+	}
+
+	return invitation, nil
+}
+
+// invitationSelect selects invitations (aliased i) with their household and invitee names
+const invitationSelect = `
+	SELECT i.id, i.household_id, h.name, i.invited_by, i.invitee_id, u.username, i.role, i.status, i.created_at, i.responded_at
+	FROM household_invitations i
+	JOIN households h ON i.household_id = h.id
+	JOIN users u ON i.invitee_id = u.id`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanInvitation scans the columns selected by invitationSelect
+func scanInvitation(row rowScanner) (*models.HouseholdInvitation, error) {
+	invitation := &models.HouseholdInvitation{}
+	var respondedAt sql.NullTime
+	err := row.Scan(
+		&invitation.ID, &invitation.HouseholdID, &invitation.HouseholdName, &invitation.InvitedBy, &invitation.InviteeID,
+		&invitation.Invitee, &invitation.Role, &invitation.Status, &invitation.CreatedAt, &respondedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if respondedAt.Valid {
+		invitation.RespondedAt = &respondedAt.Time
+	}
+	return invitation, nil
+}
+
+// queryInvitations runs an invitation query and scans every row
+func (r *HouseholdRepository) queryInvitations(query string, args ...interface{}) ([]models.HouseholdInvitation, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invitations: %w", err)
+	}
+	defer rows.Close()
+
+	var invitations []models.HouseholdInvitation
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invitation: %w", err)
+		}
+		invitations = append(invitations, *invitation)
+	}
+
+	return invitations, nil
+}
+
+// GetInvitations retrieves all invitations of a household, newest first. Only
+// owners can see them.
+func (r *HouseholdRepository) GetInvitations(householdID, actorID int64) ([]models.HouseholdInvitation, error) {
+	if err := requireRole(r.db, householdID, actorID, models.RoleOwner); err != nil {
+		return nil, err
+	}
+	return r.queryInvitations(invitationSelect+` WHERE i.household_id = ? ORDER BY i.created_at DESC, i.id DESC`, householdID)
+}
+
+// GetPendingForUser retrieves the invitations a user has not answered yet
+func (r *HouseholdRepository) GetPendingForUser(userID int64) ([]models.HouseholdInvitation, error) {
+	return r.queryInvitations(
+		invitationSelect+` WHERE i.invitee_id = ? AND i.status = ? ORDER BY i.created_at DESC, i.id DESC`,
+		userID, models.InvitationPending,
+	)
+}
+
+// Respond accepts or declines a pending invitation sent to the user. Accepting
+// adds them to the household with the invited role.
+func (r *HouseholdRepository) Respond(invitationID, userID int64, accept bool) (*models.HouseholdInvitation, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	invitation, err := scanInvitation(tx.QueryRow(
+		invitationSelect+` WHERE i.id = ? AND i.invitee_id = ? AND i.status = ?`,
+		invitationID, userID, models.InvitationPending,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load invitation: %w", err)
+	}
+
+	status := models.InvitationDeclined
+	if accept {
+		status = models.InvitationAccepted
+		_, err := tx.Exec(
+			`INSERT OR IGNORE INTO household_members (household_id, user_id, role) VALUES (?, ?, ?)`,
+			invitation.HouseholdID, userID, invitation.Role,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add household member: %w", err)
+		}
+	}
+
+	if err := r.closeInvitation(tx, invitation, status); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit invitation: %w", err)
+	}
+
+	return invitation, nil
+}
+
+// Revoke withdraws a pending invitation. Only owners can revoke invitations.
+func (r *HouseholdRepository) Revoke(householdID, invitationID, actorID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := requireRole(tx, householdID, actorID, models.RoleOwner); err != nil {
+		return err
+	}
+
+	invitation, err := scanInvitation(tx.QueryRow(
+		invitationSelect+` WHERE i.id = ? AND i.household_id = ? AND i.status = ?`,
+		invitationID, householdID, models.InvitationPending,
+	))
+	if err == sql.ErrNoRows {
+		return ErrInvitationNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load invitation: %w", err)
+	}
+
+	if err := r.closeInvitation(tx, invitation, models.InvitationRevoked); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// closeInvitation records the final status of a pending invitation
+func (r *HouseholdRepository) closeInvitation(tx execer, invitation *models.HouseholdInvitation, status string) error {
+	_, err := tx.Exec(
+		`UPDATE household_invitations SET status = ?, responded_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		status, invitation.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update invitation: %w", err)
+	}
+
+	invitation.Status = status
+	return nil
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"myexpress-tracker/internal/models"
+	"time"
+)
+
+// sqliteTimeFormat matches the format of CURRENT_TIMESTAMP
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+// IdempotencyRepository stores Idempotency-Key fingerprints and responses
+type IdempotencyRepository struct {
+	db *sql.DB
+}
+
+// NewIdempotencyRepository creates a new idempotency repository
+func NewIdempotencyRepository(db *sql.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// Reserve claims a key for a new request. If the key is already in use
+// within the window, the existing record is returned instead.
+func (r *IdempotencyRepository) Reserve(userID int64, key, fingerprint string, since time.Time) (*models.IdempotencyRecord, bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// An expired key may be reused
+	_, err = tx.Exec(`DELETE FROM idempotency_keys WHERE user_id = ? AND idempotency_key = ? AND created_at < ?`,
+		userID, key, since.UTC().Format(sqliteTimeFormat))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to expire idempotency key: %w", err)
+	}
+
+	result, err := tx.Exec(`INSERT OR IGNORE INTO idempotency_keys (user_id, idempotency_key, fingerprint) VALUES (?, ?, ?)`,
+		userID, key, fingerprint)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 1 {
+		if err := tx.Commit(); err != nil {
+			return nil, false, fmt.Errorf("failed to commit idempotency key: %w", err)
+		}
+		return nil, true, nil
+	}
+
+	record := &models.IdempotencyRecord{}
+	err = tx.QueryRow(`
+		SELECT fingerprint, completed, status, content_type, COALESCE(response, '')
+		FROM idempotency_keys
+		WHERE user_id = ? AND idempotency_key = ?
+	`, userID, key).Scan(&record.Fingerprint, &record.Completed, &record.Status, &record.ContentType, &record.Body)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to load idempotency key: %w", err)
+	}
+
+	return record, false, tx.Commit()
+}
+
+// Complete stores the response of a reserved request
+func (r *IdempotencyRepository) Complete(userID int64, key string, status int, contentType string, body []byte) error {
+	_, err := r.db.Exec(`
+		UPDATE idempotency_keys
+		SET completed = 1, status = ?, content_type = ?, response = ?
+		WHERE user_id = ? AND idempotency_key = ?
+	`, status, contentType, body, userID, key)
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Release frees a reserved key so the request can be retried
+func (r *IdempotencyRepository) Release(userID int64, key string) error {
+	_, err := r.db.Exec(`DELETE FROM idempotency_keys WHERE user_id = ? AND idempotency_key = ?`, userID, key)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// PurgeBefore deletes keys created before the given time
+func (r *IdempotencyRepository) PurgeBefore(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM idempotency_keys WHERE created_at < ?`, before.UTC().Format(sqliteTimeFormat))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge idempotency keys: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,330 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"myexpress-tracker/internal/models"
+	"strings"
+	"time"
+)
+
+// ErrAlreadyImported is returned when a bank transaction id was already imported
+var ErrAlreadyImported = errors.New("transaction was already imported")
+
+// externalIDKey is the context key for the bank transaction id of a new record
+const externalIDKey contextKey = "external_id"
+
+// externalID is a bank's id for a transaction and the account it is unique in
+type externalID struct {
+	source string
+	id     string
+}
+
+// WithExternalID returns a context that makes Create remember that the record
+// was imported from the bank transaction id in source
+func WithExternalID(ctx context.Context, source, id string) context.Context {
+	return context.WithValue(ctx, externalIDKey, externalID{source: source, id: id})
+}
+
+// recordExternalID remembers the bank transaction id a new record was
+// imported from, if ctx carries one. It must run in the creating transaction,
+// so two commits of the same transaction cannot both create a record.
+func recordExternalID(ctx context.Context, tx execer, userID int64, entity string, id int64) error {
+	external, _ := ctx.Value(externalIDKey).(externalID)
+	if external.id == "" {
+		return nil
+	}
+
+	// A record purged earlier may be imported again
+	_, err := tx.Exec(`
+		DELETE FROM import_ids
+		WHERE user_id = ? AND source = ? AND external_id = ? AND NOT EXISTS (
+			SELECT 1 FROM income WHERE import_ids.entity = 'income' AND income.id = import_ids.entity_id
+			UNION ALL
+			SELECT 1 FROM expense WHERE import_ids.entity = 'expense' AND expense.id = import_ids.entity_id
+		)`,
+		userID, external.source, external.id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to release transaction id: %w", err)
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO import_ids (user_id, source, external_id, entity, entity_id) VALUES (?, ?, ?, ?, ?)`,
+		userID, external.source, external.id, entity, id,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrAlreadyImported
+		}
+		return fmt.Errorf("failed to store transaction id: %w", err)
+	}
+
+	return nil
+}
+
+// storedImportRow is an import row as it is stored, with the source its
+// external id belongs to
+type storedImportRow struct {
+	models.ImportRow
+	Source string `json:"source,omitempty"`
+}
+
+// ImportRepository handles database operations for statement imports
+type ImportRepository struct {
+	db *sql.DB
+}
+
+// NewImportRepository creates a new import repository
+func NewImportRepository(db *sql.DB) *ImportRepository {
+	return &ImportRepository{db: db}
+}
+
+// Create stores a new import preview with its rows
+func (r *ImportRepository) Create(imp *models.Import) error {
+	rows, err := encodeImportRows(imp.Rows)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.Exec(
+		`INSERT INTO imports (user_id, household_id, format, filename, status, rows) VALUES (?, ?, ?, ?, ?, ?)`,
+		imp.UserID, imp.HouseholdID, imp.Format, imp.Filename, imp.Status, rows,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create import: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	imp.ID = id
+	imp.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	imp.Counts = countImportRows(imp.Rows)
+	imp.MissingCategories = MissingImportCategories(imp.Rows)
+	return nil
+}
+
+// importSelect lists the columns read by scanImport
+const importSelect = `SELECT id, user_id, household_id, format, filename, status, rows, created_at, committed_at FROM imports`
+
+// scanImport reads an import row produced by importSelect
+func scanImport(row rowScanner) (*models.Import, error) {
+	imp := &models.Import{}
+	var rows string
+	if err := row.Scan(&imp.ID, &imp.UserID, &imp.HouseholdID, &imp.Format, &imp.Filename, &imp.Status, &rows, &imp.CreatedAt, &imp.CommittedAt); err != nil {
+		return nil, err
+	}
+
+	var stored []storedImportRow
+	if err := json.Unmarshal([]byte(rows), &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode import rows: %w", err)
+	}
+	imp.Rows = make([]models.ImportRow, len(stored))
+	for i, row := range stored {
+		imp.Rows[i] = row.ImportRow
+		imp.Rows[i].Source = row.Source
+	}
+	imp.Counts = countImportRows(imp.Rows)
+	if imp.Status == models.ImportPreview {
+		imp.MissingCategories = MissingImportCategories(imp.Rows)
+	}
+	return imp, nil
+}
+
+// GetByID retrieves one of a user's imports with its rows
+func (r *ImportRepository) GetByID(id, userID int64) (*models.Import, error) {
+	imp, err := scanImport(r.db.QueryRow(importSelect+` WHERE id = ? AND user_id = ?`, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import by id: %w", err)
+	}
+
+	return imp, nil
+}
+
+// GetByUser retrieves a user's imports, newest first, without their rows
+func (r *ImportRepository) GetByUser(userID int64) ([]models.Import, error) {
+	rows, err := r.db.Query(importSelect+` WHERE user_id = ? ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get imports: %w", err)
+	}
+	defer rows.Close()
+
+	var imports []models.Import
+	for rows.Next() {
+		imp, err := scanImport(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan import: %w", err)
+		}
+		imp.Rows = nil
+		imports = append(imports, *imp)
+	}
+
+	return imports, rows.Err()
+}
+
+// Claim marks a previewed import as being committed. It reports false when
+// the import is not a preview, so only one request can commit it.
+func (r *ImportRepository) Claim(id, userID int64) (bool, error) {
+	result, err := r.db.Exec(
+		`UPDATE imports SET status = ? WHERE id = ? AND user_id = ? AND status = ?`,
+		models.ImportCommitting, id, userID, models.ImportPreview,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim import: %w", err)
+	}
+
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim import: %w", err)
+	}
+
+	return claimed > 0, nil
+}
+
+// Complete stores the outcome of a commit and marks the import committed
+func (r *ImportRepository) Complete(imp *models.Import) error {
+	rows, err := encodeImportRows(imp.Rows)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	_, err = r.db.Exec(
+		`UPDATE imports SET status = ?, rows = ?, committed_at = ? WHERE id = ? AND user_id = ?`,
+		models.ImportCommitted, rows, now, imp.ID, imp.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to complete import: %w", err)
+	}
+
+	imp.Status = models.ImportCommitted
+	imp.CommittedAt = &now
+	imp.Counts = countImportRows(imp.Rows)
+	imp.MissingCategories = nil
+	return nil
+}
+
+// Delete removes an import that is not being committed. Records created by
+// a committed import are kept.
+func (r *ImportRepository) Delete(id, userID int64) error {
+	result, err := r.db.Exec(
+		`DELETE FROM imports WHERE id = ? AND user_id = ? AND status != ?`,
+		id, userID, models.ImportCommitting,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete import: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete import: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("import not found or unauthorized")
+	}
+
+	return nil
+}
+
+// PurgePreviewsBefore deletes import previews created before a time that
+// were never committed
+func (r *ImportRepository) PurgePreviewsBefore(before time.Time) (int64, error) {
+	result, err := r.db.Exec(
+		`DELETE FROM imports WHERE status = ? AND created_at < ?`,
+		models.ImportPreview, before.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge import previews: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// Imported returns the record a bank transaction id was imported into, or 0
+// when it was never imported or the record has since been purged. Records in
+// the trash still count, so deleting an imported record does not bring it back.
+func (r *ImportRepository) Imported(userID int64, source, externalID string) (int64, error) {
+	var id int64
+	err := r.db.QueryRow(`
+		SELECT i.entity_id FROM import_ids i
+		WHERE i.user_id = ? AND i.source = ? AND i.external_id = ? AND EXISTS (
+			SELECT 1 FROM income WHERE i.entity = 'income' AND income.id = i.entity_id
+			UNION ALL
+			SELECT 1 FROM expense WHERE i.entity = 'expense' AND expense.id = i.entity_id
+		)`,
+		userID, source, externalID,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up transaction id: %w", err)
+	}
+
+	return id, nil
+}
+
+// encodeImportRows encodes rows for the rows column
+func encodeImportRows(rows []models.ImportRow) (string, error) {
+	stored := make([]storedImportRow, len(rows))
+	for i, row := range rows {
+		stored[i] = storedImportRow{ImportRow: row, Source: row.Source}
+	}
+
+	encoded, err := json.Marshal(stored)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode import rows: %w", err)
+	}
+	return string(encoded), nil
+}
+
+// countImportRows counts rows per status
+func countImportRows(rows []models.ImportRow) map[string]int {
+	counts := make(map[string]int)
+	for _, row := range rows {
+		counts[row.Status]++
+	}
+	return counts
+}
+
+// MissingImportCategories lists the file categories of the rows to import
+// that match no category
+func MissingImportCategories(rows []models.ImportRow) []models.ImportCategory {
+	seen := make(map[string]bool)
+	var missing []models.ImportCategory
+	add := func(name, recordType string) {
+		key := recordType + ":" + strings.ToLower(name)
+		if name == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		missing = append(missing, models.ImportCategory{Name: name, Type: recordType})
+	}
+
+	for _, row := range rows {
+		if row.Status != models.ImportRowNew && row.Status != models.ImportRowPossibleDuplicate {
+			continue
+		}
+		if len(row.Splits) == 0 {
+			if row.CategoryID == 0 {
+				add(row.Category, row.Type)
+			}
+			continue
+		}
+		for _, split := range row.Splits {
+			if split.CategoryID == 0 {
+				add(split.Category, row.Type)
+			}
+		}
+	}
+	return missing
+}
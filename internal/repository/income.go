@@ -1,14 +1,17 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"myexpress-tracker/internal/models"
 	"strings"
+	"time"
 )
 
 // IncomeRepository handles database operations for income
 type IncomeRepository struct {
+	notifier
 	db *sql.DB
 }
 
@@ -18,12 +21,26 @@ func NewIncomeRepository(db *sql.DB) *IncomeRepository {
 }
 
 // Create creates a new income record
-func (r *IncomeRepository) Create(income *models.Income) error {
+func (r *IncomeRepository) Create(ctx context.Context, income *models.Income) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	allowed, err := ledgerOf(income.UserID, income.HouseholdID).canWrite(tx)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrForbidden
+	}
+
 	query := `
-		INSERT INTO income (user_id, category_id, amount, description, income_date)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO income (user_id, household_id, category_id, amount, description, income_date, tags, account)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	result, err := r.db.Exec(query, income.UserID, income.CategoryID, income.Amount, income.Description, income.IncomeDate)
+	result, err := tx.Exec(query, income.UserID, income.HouseholdID, income.CategoryID, income.Amount, income.Description, income.IncomeDate, income.Tags, income.Account)
 	if err != nil {
 		return fmt.Errorf("failed to create income: %w", err)
 	}
@@ -33,67 +50,188 @@ func (r *IncomeRepository) Create(income *models.Income) error {
 		return fmt.Errorf("failed to get last insert id: %w", err)
 	}
 
+	if err := adjustIncomeRollup(tx, income, 1); err != nil {
+		return err
+	}
+
+	if income.HouseholdID == nil {
+		if err := claimClientUUID(ctx, tx, income.UserID, "income", id); err != nil {
+			return err
+		}
+	}
+
+	if err := recordExternalID(ctx, tx, income.UserID, "income", id); err != nil {
+		return err
+	}
+
+	if err := recordAudit(ctx, tx, income.UserID, income.HouseholdID, "income", id, ActionCreated, nil, income); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit income: %w", err)
+	}
+
 	income.ID = id
+	r.notify(ChangeEvent{UserID: income.UserID, HouseholdID: householdIDOf(income.HouseholdID), Entity: "income", Action: ActionCreated, ID: id})
 	return nil
 }
 
 // Update updates an existing income record
-func (r *IncomeRepository) Update(income *models.Income) error {
+func (r *IncomeRepository) Update(ctx context.Context, income *models.Income) error {
+	return r.update(ctx, income, nil)
+}
+
+// UpdateIfUnmodified updates an existing income record only if its updated_at
+// still equals updatedAt, returning ErrModified otherwise
+func (r *IncomeRepository) UpdateIfUnmodified(ctx context.Context, income *models.Income, updatedAt time.Time) error {
+	return r.update(ctx, income, &updatedAt)
+}
+
+// update writes an income record, optionally checking it is unmodified first
+func (r *IncomeRepository) update(ctx context.Context, income *models.Income, updatedAt *time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	old, err := r.loadForWrite(tx, income.ID, ledgerOf(income.UserID, income.HouseholdID))
+	if err != nil {
+		return err
+	}
+	if updatedAt != nil && !old.UpdatedAt.Equal(*updatedAt) {
+		return ErrModified
+	}
+
+	if err := r.updateTx(ctx, tx, old, income); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit income: %w", err)
+	}
+
+	r.notify(ChangeEvent{UserID: income.UserID, HouseholdID: householdIDOf(income.HouseholdID), Entity: "income", Action: ActionUpdated, ID: income.ID})
+	return nil
+}
+
+// updateTx writes an updated income record along with its rollup and audit changes.
+// A record keeps its author and ledger whoever edits it.
+func (r *IncomeRepository) updateTx(ctx context.Context, tx execer, old, income *models.Income) error {
+	income.UserID = old.UserID
+	income.HouseholdID = old.HouseholdID
+
 	query := `
 		UPDATE income
-		SET category_id = ?, amount = ?, description = ?, income_date = ?, updated_at = CURRENT_TIMESTAMP
+		SET category_id = ?, amount = ?, description = ?, income_date = ?, tags = ?, account = ?, updated_at = `+nextUpdatedAt+`
 		WHERE id = ? AND user_id = ?
 	`
-	result, err := r.db.Exec(query, income.CategoryID, income.Amount, income.Description, income.IncomeDate, income.ID, income.UserID)
+	_, err := tx.Exec(query, income.CategoryID, income.Amount, income.Description, income.IncomeDate, income.Tags, income.Account, income.ID, income.UserID)
 	if err != nil {
 		return fmt.Errorf("failed to update income: %w", err)
 	}
 
-	rows, err := result.RowsAffected()
+	if err := adjustIncomeRollup(tx, old, -1); err != nil {
+		return err
+	}
+	if err := adjustIncomeRollup(tx, income, 1); err != nil {
+		return err
+	}
+
+	return recordAudit(ctx, tx, income.UserID, income.HouseholdID, "income", income.ID, ActionUpdated, old, income)
+}
+
+// Delete moves an income record in the ledger to the trash
+func (r *IncomeRepository) Delete(ctx context.Context, id int64, ledger Ledger) error {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	old, err := r.loadForWrite(tx, id, ledger)
+	if err != nil {
+		return err
+	}
+
+	if err := r.deleteTx(ctx, tx, old); err != nil {
+		return err
 	}
 
-	if rows == 0 {
-		return fmt.Errorf("income not found or unauthorized")
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit income: %w", err)
 	}
 
+	r.notify(ChangeEvent{UserID: old.UserID, HouseholdID: householdIDOf(old.HouseholdID), Entity: "income", Action: ActionDeleted, ID: id})
 	return nil
 }
 
-// Delete deletes an income record
-func (r *IncomeRepository) Delete(id, userID int64) error {
-	query := `DELETE FROM income WHERE id = ? AND user_id = ?`
-	result, err := r.db.Exec(query, id, userID)
-	if err != nil {
+// deleteTx moves a loaded income record to the trash along with its rollup and audit changes
+func (r *IncomeRepository) deleteTx(ctx context.Context, tx execer, old *models.Income) error {
+	if _, err := tx.Exec(`UPDATE income SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ?`, old.ID, old.UserID); err != nil {
 		return fmt.Errorf("failed to delete income: %w", err)
 	}
 
-	rows, err := result.RowsAffected()
+	if err := adjustIncomeRollup(tx, old, -1); err != nil {
+		return err
+	}
+
+	return recordAudit(ctx, tx, old.UserID, old.HouseholdID, "income", old.ID, ActionDeleted, old, nil)
+}
+
+// loadForWrite loads a live income record inside a write transaction
+func (r *IncomeRepository) loadForWrite(tx execer, id int64, ledger Ledger) (*models.Income, error) {
+	condition, args := ledger.writeCondition("")
+	old, err := scanIncomeForWrite(tx.QueryRow(
+		`SELECT id, user_id, household_id, category_id, amount, COALESCE(description, ''), income_date, tags, account, updated_at
+		FROM income WHERE id = ? AND deleted_at IS NULL AND `+condition,
+		append([]interface{}{id}, args...)...,
+	))
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("income not found or unauthorized")
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return nil, fmt.Errorf("failed to load income: %w", err)
 	}
 
-	if rows == 0 {
-		return fmt.Errorf("income not found or unauthorized")
+	return old, nil
+}
+
+// adjustIncomeRollup adds (sign 1) or removes (sign -1) a personal income record
+// from the monthly rollups. Household records are not rolled up.
+func adjustIncomeRollup(tx execer, income *models.Income, sign float64) error {
+	if income.HouseholdID != nil {
+		return nil
 	}
+	return adjustRollup(tx, income.UserID, "income", income.CategoryID, income.IncomeDate, sign*income.Amount, int(sign))
+}
 
-	return nil
+// scanIncomeForWrite scans the columns selected by loadForWrite
+func scanIncomeForWrite(row interface{ Scan(dest ...interface{}) error }) (*models.Income, error) {
+	income := &models.Income{}
+	if err := row.Scan(&income.ID, &income.UserID, &income.HouseholdID, &income.CategoryID, &income.Amount, &income.Description, &income.IncomeDate, &income.Tags, &income.Account, &income.UpdatedAt); err != nil {
+		return nil, err
+	}
+	income.IncomeDate = DateOnly(income.IncomeDate)
+	return income, nil
 }
 
-// GetByID retrieves an income record by ID
-func (r *IncomeRepository) GetByID(id, userID int64) (*models.Income, error) {
+// GetByID retrieves an income record by ID from the ledger
+func (r *IncomeRepository) GetByID(id int64, ledger Ledger) (*models.Income, error) {
+	condition, args := ledger.readCondition("i.")
 	query := `
-		SELECT i.id, i.user_id, i.category_id, i.amount, i.description, i.income_date, i.created_at, i.updated_at, c.name
+		SELECT i.id, i.user_id, i.household_id, i.category_id, i.amount, i.description, i.income_date, i.tags, i.account, i.created_at, i.updated_at, c.name
 		FROM income i
 		JOIN categories c ON i.category_id = c.id
-		WHERE i.id = ? AND i.user_id = ?
+		WHERE i.id = ? AND i.deleted_at IS NULL AND `+condition+`
 	`
 	
 	income := &models.Income{}
-	err := r.db.QueryRow(query, id, userID).Scan(
-		&income.ID, &income.UserID, &income.CategoryID, &income.Amount, &income.Description,
-		&income.IncomeDate, &income.CreatedAt, &income.UpdatedAt, &income.CategoryName,
+	err := r.db.QueryRow(query, append([]interface{}{id}, args...)...).Scan(
+		&income.ID, &income.UserID, &income.HouseholdID, &income.CategoryID, &income.Amount, &income.Description,
+		&income.IncomeDate, &income.Tags, &income.Account, &income.CreatedAt, &income.UpdatedAt, &income.CategoryName,
 	)
 	
 	if err == sql.ErrNoRows {
@@ -103,40 +241,24 @@ func (r *IncomeRepository) GetByID(id, userID int64) (*models.Income, error) {
 		return nil, fmt.Errorf("failed to get income by id: %w", err)
 	}
 
+	income.IncomeDate = DateOnly(income.IncomeDate)
 	return income, nil
 }
 
-// GetByUser retrieves all income records for a user with optional filters
-func (r *IncomeRepository) GetByUser(userID int64, filters map[string]interface{}) ([]models.Income, error) {
+// GetByUser retrieves all income records in a user's ledger with optional filters
+func (r *IncomeRepository) GetByUser(ledger Ledger, filters map[string]interface{}) ([]models.Income, error) {
+	condition, args := ledger.readCondition("i.")
 	query := `
-		SELECT i.id, i.user_id, i.category_id, i.amount, i.description, i.income_date, i.created_at, i.updated_at, c.name
+		SELECT i.id, i.user_id, i.household_id, i.category_id, i.amount, i.description, i.income_date, i.tags, i.account, i.created_at, i.updated_at, c.name
 		FROM income i
 		JOIN categories c ON i.category_id = c.id
-		WHERE i.user_id = ?
+		WHERE i.deleted_at IS NULL AND `+condition+`
 	`
 	
-	args := []interface{}{userID}
-	
 	// Add filters
-	if categoryID, ok := filters["category_id"].(int64); ok && categoryID > 0 {
-		query += " AND i.category_id = ?"
-		args = append(args, categoryID)
-	}
-	
-	if date, ok := filters["date"].(string); ok && date != "" {
-		query += " AND i.income_date = ?"
-		args = append(args, date)
-	}
-	
-	if startDate, ok := filters["start_date"].(string); ok && startDate != "" {
-		query += " AND i.income_date >= ?"
-		args = append(args, startDate)
-	}
-	
-	if endDate, ok := filters["end_date"].(string); ok && endDate != "" {
-		query += " AND i.income_date <= ?"
-		args = append(args, endDate)
-	}
+	conditions, filterArgs := incomeFilterConditions("i.", filters)
+	query += conditions
+	args = append(args, filterArgs...)
 	
 	query += " ORDER BY i.income_date DESC, i.created_at DESC"
 	
@@ -150,47 +272,59 @@ func (r *IncomeRepository) GetByUser(userID int64, filters map[string]interface{
 	for rows.Next() {
 		var income models.Income
 		if err := rows.Scan(
-			&income.ID, &income.UserID, &income.CategoryID, &income.Amount, &income.Description,
-			&income.IncomeDate, &income.CreatedAt, &income.UpdatedAt, &income.CategoryName,
+			&income.ID, &income.UserID, &income.HouseholdID, &income.CategoryID, &income.Amount, &income.Description,
+			&income.IncomeDate, &income.Tags, &income.Account, &income.CreatedAt, &income.UpdatedAt, &income.CategoryName,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan income: %w", err)
 		}
+		income.IncomeDate = DateOnly(income.IncomeDate)
 		incomes = append(incomes, income)
 	}
 
 	return incomes, nil
 }
 
-// GetTotalByUser calculates total income for a user with optional filters
-func (r *IncomeRepository) GetTotalByUser(userID int64, filters map[string]interface{}) (float64, error) {
-	query := `SELECT COALESCE(SUM(amount), 0) FROM income WHERE user_id = ?`
-	args := []interface{}{userID}
-	
-	// Add filters
+// incomeFilterConditions builds the SQL conditions (each prefixed with AND) for
+// the list filters, qualifying columns with prefix
+func incomeFilterConditions(prefix string, filters map[string]interface{}) (string, []interface{}) {
 	conditions := []string{}
+	args := []interface{}{}
+
 	if categoryID, ok := filters["category_id"].(int64); ok && categoryID > 0 {
-		conditions = append(conditions, "category_id = ?")
+		conditions = append(conditions, prefix+"category_id = ?")
 		args = append(args, categoryID)
 	}
-	
+
 	if date, ok := filters["date"].(string); ok && date != "" {
-		conditions = append(conditions, "income_date = ?")
+		conditions = append(conditions, prefix+"income_date = ?")
 		args = append(args, date)
 	}
-	
+
 	if startDate, ok := filters["start_date"].(string); ok && startDate != "" {
-		conditions = append(conditions, "income_date >= ?")
+		conditions = append(conditions, prefix+"income_date >= ?")
 		args = append(args, startDate)
 	}
-	
+
 	if endDate, ok := filters["end_date"].(string); ok && endDate != "" {
-		conditions = append(conditions, "income_date <= ?")
+		conditions = append(conditions, prefix+"income_date <= ?")
 		args = append(args, endDate)
 	}
-	
-	if len(conditions) > 0 {
-		query += " AND " + strings.Join(conditions, " AND ")
+
+	if len(conditions) == 0 {
+		return "", args
 	}
+	return " AND " + strings.Join(conditions, " AND "), args
+}
+
+// GetTotalByUser calculates total income in a user's ledger with optional filters
+func (r *IncomeRepository) GetTotalByUser(ledger Ledger, filters map[string]interface{}) (float64, error) {
+	condition, args := ledger.readCondition("")
+	query := `SELECT COALESCE(SUM(amount), 0) FROM income WHERE deleted_at IS NULL AND ` + condition
+	
+	// Add filters
+	conditions, filterArgs := incomeFilterConditions("", filters)
+	query += conditions
+	args = append(args, filterArgs...)
 	
 	var total float64
 	err := r.db.QueryRow(query, args...).Scan(&total)
@@ -200,3 +334,258 @@ func (r *IncomeRepository) GetTotalByUser(userID int64, filters map[string]inter
 
 	return total, nil
 }
+
+// GetTrash retrieves the ledger's trashed income records, most recently deleted first
+func (r *IncomeRepository) GetTrash(ledger Ledger) ([]models.Income, error) {
+	condition, args := ledger.readCondition("i.")
+	query := `
+		SELECT i.id, i.user_id, i.household_id, i.category_id, i.amount, i.description, i.income_date, i.tags, i.account, i.created_at, i.updated_at, i.deleted_at, c.name
+		FROM income i
+		JOIN categories c ON i.category_id = c.id
+		WHERE i.deleted_at IS NOT NULL AND `+condition+`
+		ORDER BY i.deleted_at DESC, i.id DESC
+	`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trashed income: %w", err)
+	}
+	defer rows.Close()
+
+	var incomes []models.Income
+	for rows.Next() {
+		var income models.Income
+		var deletedAt sql.NullTime
+		if err := rows.Scan(
+			&income.ID, &income.UserID, &income.HouseholdID, &income.CategoryID, &income.Amount, &income.Description,
+			&income.IncomeDate, &income.Tags, &income.Account, &income.CreatedAt, &income.UpdatedAt, &deletedAt, &income.CategoryName,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan income: %w", err)
+		}
+		income.IncomeDate = DateOnly(income.IncomeDate)
+		if deletedAt.Valid {
+			income.DeletedAt = &deletedAt.Time
+		}
+		incomes = append(incomes, income)
+	}
+
+	return incomes, nil
+}
+
+// Restore moves a trashed income record in the ledger back out of the trash
+func (r *IncomeRepository) Restore(ctx context.Context, id int64, ledger Ledger) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	condition, args := ledger.writeCondition("")
+	old, err := scanIncomeForWrite(tx.QueryRow(
+		`SELECT id, user_id, household_id, category_id, amount, COALESCE(description, ''), income_date, tags, account, updated_at
+		FROM income WHERE id = ? AND deleted_at IS NOT NULL AND `+condition,
+		append([]interface{}{id}, args...)...,
+	))
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("income not found in trash")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load income: %w", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE income SET deleted_at = NULL WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to restore income: %w", err)
+	}
+
+	if err := adjustIncomeRollup(tx, old, 1); err != nil {
+		return err
+	}
+
+	if err := recordAudit(ctx, tx, old.UserID, old.HouseholdID, "income", id, ActionRestored, nil, old); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit income: %w", err)
+	}
+
+	r.notify(ChangeEvent{UserID: old.UserID, HouseholdID: householdIDOf(old.HouseholdID), Entity: "income", Action: ActionRestored, ID: id})
+	return nil
+}
+
+// Purge permanently deletes a trashed income record in the ledger
+func (r *IncomeRepository) Purge(ctx context.Context, id int64, ledger Ledger) error {
+	condition, args := ledger.writeCondition("")
+	purged, err := r.purgeTrashed(ctx, "id = ? AND "+condition, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return err
+	}
+	if purged == 0 {
+		return fmt.Errorf("income not found in trash")
+	}
+
+	return nil
+}
+
+// EmptyTrash permanently deletes all of a user's trashed income records
+func (r *IncomeRepository) EmptyTrash(ctx context.Context, ledger Ledger) (int64, error) {
+	condition, args := ledger.writeCondition("")
+	return r.purgeTrashed(ctx, condition, args...)
+}
+
+// PurgeDeletedBefore permanently deletes income records trashed before the given time
+func (r *IncomeRepository) PurgeDeletedBefore(ctx context.Context, before time.Time) (int64, error) {
+	return r.purgeTrashed(ctx, "deleted_at < ?", before.UTC().Format(sqliteTimeFormat))
+}
+
+// purgeTrashed permanently deletes the trashed income records matching a condition,
+// auditing each one
+func (r *IncomeRepository) purgeTrashed(ctx context.Context, condition string, args ...interface{}) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(
+		`SELECT id, user_id, household_id, category_id, amount, COALESCE(description, ''), income_date, tags, account, updated_at
+		FROM income WHERE deleted_at IS NOT NULL AND `+condition,
+		args...,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query trashed income: %w", err)
+	}
+
+	var purged []*models.Income
+	for rows.Next() {
+		income, err := scanIncomeForWrite(rows)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan income: %w", err)
+		}
+		purged = append(purged, income)
+	}
+	rows.Close()
+
+	for _, income := range purged {
+		if _, err := tx.Exec(`DELETE FROM income WHERE id = ?`, income.ID); err != nil {
+			return 0, fmt.Errorf("failed to purge income: %w", err)
+		}
+		if err := recordAudit(ctx, tx, income.UserID, income.HouseholdID, "income", income.ID, ActionPurged, income, nil); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit purge: %w", err)
+	}
+
+	return int64(len(purged)), nil
+}
+
+// Bulk updates or trashes many income records, selected by ID or by filter, in a
+// single transaction. If any record fails nothing is written. A dry run
+// performs the same writes and rolls them back, so its report matches a real run.
+func (r *IncomeRepository) Bulk(ctx context.Context, userID int64, req *models.BulkRequest) (*models.BulkResponse, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ids := req.IDs
+	if req.Filter != nil {
+		ids, err = r.matchingIDs(tx, userID, req.Filter.Map())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(ids) > MaxBulkItems {
+		return nil, ErrTooManyBulkItems
+	}
+
+	results := make([]models.BulkResult, 0, len(ids))
+	for _, id := range ids {
+		old, err := r.loadForWrite(tx, id, Personal(userID))
+		if err != nil {
+			results = append(results, models.BulkResult{ID: id, Status: models.BulkFailed, Error: err.Error()})
+			continue
+		}
+
+		if req.Action == models.BulkActionDelete {
+			if err := r.deleteTx(ctx, tx, old); err != nil {
+				return nil, err
+			}
+			results = append(results, models.BulkResult{ID: id, Status: models.BulkDeleted})
+			continue
+		}
+
+		updated := *old
+		if req.Set.CategoryID != nil {
+			updated.CategoryID = *req.Set.CategoryID
+		}
+		if req.Set.Tags != nil {
+			updated.Tags = *req.Set.Tags
+		}
+		if req.Set.Date != nil {
+			updated.IncomeDate = *req.Set.Date
+		}
+		if req.Set.Account != nil {
+			updated.Account = *req.Set.Account
+		}
+
+		if updated == *old {
+			results = append(results, models.BulkResult{ID: id, Status: models.BulkUnchanged})
+			continue
+		}
+
+		if err := r.updateTx(ctx, tx, old, &updated); err != nil {
+			return nil, err
+		}
+		results = append(results, models.BulkResult{ID: id, Status: models.BulkUpdated})
+	}
+
+	response := models.NewBulkResponse(req, results)
+	if req.DryRun || response.Failed > 0 {
+		return response, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit bulk income changes: %w", err)
+	}
+	response.Committed = true
+
+	for _, result := range results {
+		switch result.Status {
+		case models.BulkUpdated:
+			r.notify(ChangeEvent{UserID: userID, Entity: "income", Action: ActionUpdated, ID: result.ID})
+		case models.BulkDeleted:
+			r.notify(ChangeEvent{UserID: userID, Entity: "income", Action: ActionDeleted, ID: result.ID})
+		}
+	}
+
+	return response, nil
+}
+
+// matchingIDs returns the IDs of a user's live income records that match the list filters
+func (r *IncomeRepository) matchingIDs(tx execer, userID int64, filters map[string]interface{}) ([]int64, error) {
+	conditions, args := incomeFilterConditions("", filters)
+	query := `SELECT id FROM income WHERE user_id = ? AND household_id IS NULL AND deleted_at IS NULL` + conditions + ` ORDER BY income_date, id`
+
+	rows, err := tx.Query(query, append([]interface{}{userID}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query income: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan income: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
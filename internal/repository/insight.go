@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"myexpress-tracker/internal/models"
+)
+
+// InsightRepository handles database operations for insights
+type InsightRepository struct {
+	db *sql.DB
+	notifier
+}
+
+// NewInsightRepository creates a new insight repository
+func NewInsightRepository(db *sql.DB) *InsightRepository {
+	return &InsightRepository{db: db}
+}
+
+// Create stores an insight unless one with the same dedupe key already exists.
+// It reports whether a new insight was stored.
+func (r *InsightRepository) Create(insight *models.Insight) (bool, error) {
+	query := `
+		INSERT OR IGNORE INTO insights (user_id, kind, dedupe_key, title, message, expense_id, category_id, amount)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := r.db.Exec(query, insight.UserID, insight.Kind, insight.DedupeKey, insight.Title, insight.Message,
+		insight.ExpenseID, insight.CategoryID, insight.Amount)
+	if err != nil {
+		return false, fmt.Errorf("failed to create insight: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return false, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	insight.ID = id
+	r.notify(ChangeEvent{UserID: insight.UserID, Entity: "insight", Action: ActionCreated, ID: id})
+	return true, nil
+}
+
+// GetByID retrieves one of a user's insights
+func (r *InsightRepository) GetByID(id, userID int64) (*models.Insight, error) {
+	query := `
+		SELECT id, user_id, kind, dedupe_key, title, message, expense_id, category_id, COALESCE(amount, 0), created_at, dismissed_at
+		FROM insights
+		WHERE id = ? AND user_id = ?
+	`
+
+	insight := &models.Insight{}
+	err := r.db.QueryRow(query, id, userID).Scan(
+		&insight.ID, &insight.UserID, &insight.Kind, &insight.DedupeKey, &insight.Title, &insight.Message,
+		&insight.ExpenseID, &insight.CategoryID, &insight.Amount, &insight.CreatedAt, &insight.DismissedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get insight by id: %w", err)
+	}
+
+	return insight, nil
+}
+
+// GetByUser retrieves a user's insights, newest first, hiding those about trashed expenses
+func (r *InsightRepository) GetByUser(userID int64, includeDismissed bool) ([]models.Insight, error) {
+	query := `
+		SELECT id, user_id, kind, dedupe_key, title, message, expense_id, category_id, COALESCE(amount, 0), created_at, dismissed_at
+		FROM insights
+		WHERE user_id = ?
+			AND (expense_id IS NULL OR expense_id NOT IN (SELECT id FROM expense WHERE deleted_at IS NOT NULL))
+	`
+	if !includeDismissed {
+		query += " AND dismissed_at IS NULL"
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query insights: %w", err)
+	}
+	defer rows.Close()
+
+	insights := []models.Insight{}
+	for rows.Next() {
+		var insight models.Insight
+		if err := rows.Scan(
+			&insight.ID, &insight.UserID, &insight.Kind, &insight.DedupeKey, &insight.Title, &insight.Message,
+			&insight.ExpenseID, &insight.CategoryID, &insight.Amount, &insight.CreatedAt, &insight.DismissedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan insight: %w", err)
+		}
+		insights = append(insights, insight)
+	}
+
+	return insights, nil
+}
+
+// Dismiss hides an insight from the feed
+func (r *InsightRepository) Dismiss(id, userID int64) error {
+	query := `UPDATE insights SET dismissed_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ? AND dismissed_at IS NULL`
+	result, err := r.db.Exec(query, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to dismiss insight: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("insight not found or unauthorized")
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"myexpress-tracker/internal/models"
+)
+
+// Ledger selects whose records a repository call works with: a user's personal
+// records, or the shared records of a household the user belongs to
+type Ledger struct {
+	UserID      int64
+	HouseholdID int64 // 0 for the personal ledger
+}
+
+// Personal returns the personal ledger of a user
+func Personal(userID int64) Ledger {
+	return Ledger{UserID: userID}
+}
+
+// HouseholdRef returns the household_id value stored on the ledger's records
+func (l Ledger) HouseholdRef() *int64 {
+	if l.HouseholdID == 0 {
+		return nil
+	}
+	id := l.HouseholdID
+	return &id
+}
+
+// householdIDOf returns the household a record's household_id points to, or 0
+// for a personal record
+func householdIDOf(householdID *int64) int64 {
+	if householdID == nil {
+		return 0
+	}
+	return *householdID
+}
+
+// readCondition restricts a table (columns qualified with prefix) to the records
+// of the ledger the user may read
+func (l Ledger) readCondition(prefix string) (string, []interface{}) {
+	return l.condition(prefix, models.RoleOwner, models.RoleEditor, models.RoleViewer)
+}
+
+// writeCondition restricts a table (columns qualified with prefix) to the records
+// of the ledger the user may change
+func (l Ledger) writeCondition(prefix string) (string, []interface{}) {
+	return l.condition(prefix, models.RoleOwner, models.RoleEditor)
+}
+
+// condition builds the ledger restriction. Household records also require the
+// user to be a member with one of roles, so access never relies on callers alone.
+func (l Ledger) condition(prefix string, roles ...string) (string, []interface{}) {
+	if l.HouseholdID == 0 {
+		return prefix + "user_id = ? AND " + prefix + "household_id IS NULL", []interface{}{l.UserID}
+	}
+
+	args := []interface{}{l.HouseholdID, l.HouseholdID, l.UserID}
+	placeholders := ""
+	for i, role := range roles {
+		if i > 0 {
+			placeholders += ", "
+		}
+		placeholders += "?"
+		args = append(args, role)
+	}
+
+	return prefix + `household_id = ? AND EXISTS (
+			SELECT 1 FROM household_members m
+			WHERE m.household_id = ? AND m.user_id = ? AND m.role IN (` + placeholders + `))`, args
+}
+
+// ledgerOf returns the ledger a record belongs to, as seen by userID
+func ledgerOf(userID int64, householdID *int64) Ledger {
+	if householdID == nil {
+		return Personal(userID)
+	}
+	return Ledger{UserID: userID, HouseholdID: *householdID}
+}
+
+// canWrite reports whether the user may add records to the ledger
+func (l Ledger) canWrite(tx execer) (bool, error) {
+	if l.HouseholdID == 0 {
+		return true, nil
+	}
+
+	var role string
+	err := tx.QueryRow(
+		`SELECT role FROM household_members WHERE household_id = ? AND user_id = ?`,
+		l.HouseholdID, l.UserID,
+	).Scan(&role)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check household membership: %w", err)
+	}
+	return role == models.RoleOwner || role == models.RoleEditor, nil
+}
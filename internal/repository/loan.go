@@ -0,0 +1,305 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"myexpress-tracker/internal/amortization"
+	"myexpress-tracker/internal/models"
+)
+
+// ErrOverpayment is returned when a payment is more than the loan's balance plus a period's interest
+var ErrOverpayment = errors.New("payment is more than the remaining balance plus interest")
+
+// LoanRepository handles database operations for loans and their payments
+type LoanRepository struct {
+	db *sql.DB
+}
+
+// NewLoanRepository creates a new loan repository
+func NewLoanRepository(db *sql.DB) *LoanRepository {
+	return &LoanRepository{db: db}
+}
+
+// Create creates a new loan
+func (r *LoanRepository) Create(loan *models.Loan) error {
+	result, err := r.db.Exec(`
+		INSERT INTO loans (user_id, name, lender, principal, annual_rate, term_months, frequency, start_date, payment_amount, category_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		loan.UserID, loan.Name, loan.Lender, loan.Principal, loan.AnnualRate, loan.TermMonths, loan.Frequency,
+		loan.StartDate, loan.PaymentAmount, loan.CategoryID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create loan: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	loan.ID = id
+	return nil
+}
+
+// Update updates a loan's terms. Recorded payments keep their split.
+func (r *LoanRepository) Update(loan *models.Loan) error {
+	result, err := r.db.Exec(`
+		UPDATE loans
+		SET name = ?, lender = ?, principal = ?, annual_rate = ?, term_months = ?, frequency = ?, start_date = ?,
+			payment_amount = ?, category_id = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND user_id = ?`,
+		loan.Name, loan.Lender, loan.Principal, loan.AnnualRate, loan.TermMonths, loan.Frequency, loan.StartDate,
+		loan.PaymentAmount, loan.CategoryID, loan.ID, loan.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update loan: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update loan: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("loan not found or unauthorized")
+	}
+
+	return nil
+}
+
+// Delete deletes a loan and its payments. Expenses recorded for the payments are kept.
+func (r *LoanRepository) Delete(id, userID int64) error {
+	result, err := r.db.Exec(`DELETE FROM loans WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete loan: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete loan: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("loan not found or unauthorized")
+	}
+
+	return nil
+}
+
+// loanSelect selects loans (aliased l) with their repayment category name
+const loanSelect = `
+	SELECT l.id, l.user_id, l.name, l.lender, l.principal, l.annual_rate, l.term_months, l.frequency, l.start_date,
+		l.payment_amount, l.category_id, l.created_at, l.updated_at, COALESCE(c.name, '')
+	FROM loans l
+	LEFT JOIN categories c ON l.category_id = c.id`
+
+// scanLoan scans a row selected with loanSelect
+func scanLoan(row rowScanner) (*models.Loan, error) {
+	loan := &models.Loan{}
+	if err := row.Scan(
+		&loan.ID, &loan.UserID, &loan.Name, &loan.Lender, &loan.Principal, &loan.AnnualRate, &loan.TermMonths, &loan.Frequency,
+		&loan.StartDate, &loan.PaymentAmount, &loan.CategoryID, &loan.CreatedAt, &loan.UpdatedAt, &loan.CategoryName,
+	); err != nil {
+		return nil, err
+	}
+	loan.StartDate = DateOnly(loan.StartDate)
+	return loan, nil
+}
+
+// GetByID retrieves one of a user's loans
+func (r *LoanRepository) GetByID(id, userID int64) (*models.Loan, error) {
+	loan, err := scanLoan(r.db.QueryRow(loanSelect+` WHERE l.id = ? AND l.user_id = ?`, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loan by id: %w", err)
+	}
+
+	return loan, nil
+}
+
+// GetByUser retrieves a user's loans
+func (r *LoanRepository) GetByUser(userID int64) ([]models.Loan, error) {
+	rows, err := r.db.Query(loanSelect+` WHERE l.user_id = ? ORDER BY l.name COLLATE NOCASE, l.id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query loans: %w", err)
+	}
+	defer rows.Close()
+
+	var loans []models.Loan
+	for rows.Next() {
+		loan, err := scanLoan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan loan: %w", err)
+		}
+		loans = append(loans, *loan)
+	}
+
+	return loans, nil
+}
+
+// Paid returns the principal and interest repaid on a loan so far, the number
+// of payments and the date of the latest one
+func (r *LoanRepository) Paid(loanID int64) (principal, interest float64, payments int, lastDate string, err error) {
+	var last sql.NullString
+	err = r.db.QueryRow(`
+		SELECT COALESCE(SUM(principal), 0), COALESCE(SUM(interest), 0), COUNT(*), MAX(payment_date)
+		FROM loan_payments WHERE loan_id = ?`,
+		loanID,
+	).Scan(&principal, &interest, &payments, &last)
+	if err != nil {
+		return 0, 0, 0, "", fmt.Errorf("failed to sum loan payments: %w", err)
+	}
+
+	return principal, interest, payments, DateOnly(last.String), nil
+}
+
+// AddPayment records a payment on a loan, splitting it into interest for one
+// period on the current balance and principal
+func (r *LoanRepository) AddPayment(loan *models.Loan, payment *models.LoanPayment) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	id, err := addLoanPayment(tx, loan, payment)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit loan payment: %w", err)
+	}
+
+	payment.ID = id
+	return nil
+}
+
+// CreateLoanPayment records a payment on a loan with a new expense. The
+// expense and the payment are written in one transaction, so neither is kept
+// without the other.
+func (r *ExpenseRepository) CreateLoanPayment(ctx context.Context, loan *models.Loan, payment *models.LoanPayment, expense *models.Expense) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	expenseID, err := r.createTx(ctx, tx, expense)
+	if err != nil {
+		return err
+	}
+
+	payment.ExpenseID = &expenseID
+	paymentID, err := addLoanPayment(tx, loan, payment)
+	if err != nil {
+		payment.ExpenseID = nil
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		payment.ExpenseID = nil
+		return fmt.Errorf("failed to commit loan payment: %w", err)
+	}
+
+	expense.ID = expenseID
+	payment.ID = paymentID
+	r.notify(ChangeEvent{UserID: expense.UserID, Entity: "expense", Action: ActionCreated, ID: expenseID})
+	return nil
+}
+
+// addLoanPayment writes a payment, splitting it into principal and interest
+// on the loan's balance, and returns its id
+func addLoanPayment(tx execer, loan *models.Loan, payment *models.LoanPayment) (int64, error) {
+	var repaid float64
+	err := tx.QueryRow(`SELECT COALESCE(SUM(principal), 0) FROM loan_payments WHERE loan_id = ?`, loan.ID).Scan(&repaid)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum loan payments: %w", err)
+	}
+
+	balance := toCents(loan.Principal - repaid)
+	principal, interest := amortization.Split(fromCents(balance), loan.AnnualRate, loan.Frequency, payment.Amount)
+	if toCents(principal)+toCents(interest) < toCents(payment.Amount) {
+		return 0, ErrOverpayment
+	}
+
+	payment.LoanID = loan.ID
+	payment.Principal, payment.Interest = principal, interest
+	payment.Balance = fromCents(balance - toCents(principal))
+
+	result, err := tx.Exec(`
+		INSERT INTO loan_payments (loan_id, payment_date, amount, principal, interest, balance, expense_id, note)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		payment.LoanID, payment.PaymentDate, payment.Amount, payment.Principal, payment.Interest, payment.Balance,
+		payment.ExpenseID, payment.Note,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create loan payment: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	if err := tx.QueryRow(`SELECT created_at FROM loan_payments WHERE id = ?`, id).Scan(&payment.CreatedAt); err != nil {
+		return 0, fmt.Errorf("failed to read loan payment: %w", err)
+	}
+
+	return id, nil
+}
+
+// DeletePayment deletes a payment from one of a user's loans. The expense
+// recorded for it, if any, is kept.
+func (r *LoanRepository) DeletePayment(id, loanID, userID int64) error {
+	result, err := r.db.Exec(`
+		DELETE FROM loan_payments
+		WHERE id = ? AND loan_id = ? AND loan_id IN (SELECT id FROM loans WHERE user_id = ?)`,
+		id, loanID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete loan payment: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete loan payment: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("loan payment not found or unauthorized")
+	}
+
+	return nil
+}
+
+// GetPayments retrieves the payments on a loan, newest first
+func (r *LoanRepository) GetPayments(loanID, userID int64) ([]models.LoanPayment, error) {
+	rows, err := r.db.Query(`
+		SELECT p.id, p.loan_id, p.payment_date, p.amount, p.principal, p.interest, p.balance, p.expense_id, p.note, p.created_at
+		FROM loan_payments p
+		JOIN loans l ON p.loan_id = l.id
+		WHERE p.loan_id = ? AND l.user_id = ?
+		ORDER BY p.payment_date DESC, p.id DESC`,
+		loanID, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query loan payments: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []models.LoanPayment
+	for rows.Next() {
+		var payment models.LoanPayment
+		if err := rows.Scan(
+			&payment.ID, &payment.LoanID, &payment.PaymentDate, &payment.Amount, &payment.Principal, &payment.Interest,
+			&payment.Balance, &payment.ExpenseID, &payment.Note, &payment.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan loan payment: %w", err)
+		}
+		payment.PaymentDate = DateOnly(payment.PaymentDate)
+		payments = append(payments, payment)
+	}
+
+	return payments, nil
+}
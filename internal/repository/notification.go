@@ -0,0 +1,368 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"myexpress-tracker/internal/models"
+	"strings"
+	"time"
+)
+
+// defaultDigestHour is the hour digests are sent for users without settings
+const defaultDigestHour = 8
+
+// DueDelivery is a delivery ready to be sent, with its notification and the
+// address it goes to
+type DueDelivery struct {
+	Delivery     models.NotificationDelivery
+	Notification models.Notification
+	Address      string // Email address or webhook URL; empty when not configured
+}
+
+// NotificationRepository handles database operations for notifications, their
+// deliveries and users' notification preferences
+type NotificationRepository struct {
+	db *sql.DB
+}
+
+// NewNotificationRepository creates a new notification repository
+func NewNotificationRepository(db *sql.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+// Create stores a notification unless the user already has one with the same
+// dedupe key. It reports whether a new notification was stored.
+func (r *NotificationRepository) Create(notification *models.Notification) (bool, error) {
+	result, err := r.db.Exec(`
+		INSERT OR IGNORE INTO notifications (user_id, event, dedupe_key, title, message, link, in_inbox)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		notification.UserID, notification.Event, notification.DedupeKey, notification.Title, notification.Message,
+		notification.Link, notification.InInbox,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to create notification: %w", err)
+	}
+
+	created, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if created == 0 {
+		return false, nil
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return false, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	if err := r.db.QueryRow(`SELECT created_at FROM notifications WHERE id = ?`, id).Scan(&notification.CreatedAt); err != nil {
+		return false, fmt.Errorf("failed to read notification: %w", err)
+	}
+
+	notification.ID = id
+	return true, nil
+}
+
+// GetByUser retrieves the notifications in a user's inbox, newest first
+func (r *NotificationRepository) GetByUser(userID int64, unreadOnly bool, limit int) ([]models.Notification, error) {
+	query := `
+		SELECT id, user_id, event, dedupe_key, title, message, link, in_inbox, created_at, read_at
+		FROM notifications
+		WHERE user_id = ? AND in_inbox = 1`
+	if unreadOnly {
+		query += ` AND read_at IS NULL`
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+
+	rows, err := r.db.Query(query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var notifications []models.Notification
+	for rows.Next() {
+		var notification models.Notification
+		if err := rows.Scan(
+			&notification.ID, &notification.UserID, &notification.Event, &notification.DedupeKey, &notification.Title,
+			&notification.Message, &notification.Link, &notification.InInbox, &notification.CreatedAt, &notification.ReadAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, nil
+}
+
+// UnreadCount counts the unread notifications in a user's inbox
+func (r *NotificationRepository) UnreadCount(userID int64) (int, error) {
+	var count int
+	err := r.db.QueryRow(
+		`SELECT COUNT(*) FROM notifications WHERE user_id = ? AND in_inbox = 1 AND read_at IS NULL`,
+		userID,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unread notifications: %w", err)
+	}
+	return count, nil
+}
+
+// MarkRead marks one of a user's notifications as read
+func (r *NotificationRepository) MarkRead(id, userID int64) error {
+	result, err := r.db.Exec(`
+		UPDATE notifications SET read_at = COALESCE(read_at, CURRENT_TIMESTAMP)
+		WHERE id = ? AND user_id = ? AND in_inbox = 1`,
+		id, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark notification read: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("notification not found or unauthorized")
+	}
+
+	return nil
+}
+
+// MarkAllRead marks every unread notification in a user's inbox as read and
+// returns how many there were
+func (r *NotificationRepository) MarkAllRead(userID int64) (int64, error) {
+	result, err := r.db.Exec(`
+		UPDATE notifications SET read_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND in_inbox = 1 AND read_at IS NULL`,
+		userID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark notifications read: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// GetPreferences retrieves the preferences a user has set, keyed by event type
+func (r *NotificationRepository) GetPreferences(userID int64) (map[string]models.NotificationPreference, error) {
+	rows, err := r.db.Query(
+		`SELECT event, in_app, email, webhook, digest FROM notification_preferences WHERE user_id = ?`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notification preferences: %w", err)
+	}
+	defer rows.Close()
+
+	preferences := make(map[string]models.NotificationPreference)
+	for rows.Next() {
+		var preference models.NotificationPreference
+		if err := rows.Scan(&preference.Event, &preference.InApp, &preference.Email, &preference.Webhook, &preference.Digest); err != nil {
+			return nil, fmt.Errorf("failed to scan notification preference: %w", err)
+		}
+		preferences[preference.Event] = preference
+	}
+
+	return preferences, nil
+}
+
+// SavePreferences stores a user's preferences for the given event types
+func (r *NotificationRepository) SavePreferences(userID int64, preferences []models.NotificationPreference) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, preference := range preferences {
+		_, err := tx.Exec(`
+			INSERT INTO notification_preferences (user_id, event, in_app, email, webhook, digest)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (user_id, event) DO UPDATE SET
+				in_app = excluded.in_app, email = excluded.email, webhook = excluded.webhook, digest = excluded.digest`,
+			userID, preference.Event, preference.InApp, preference.Email, preference.Webhook, preference.Digest,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save notification preference: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit notification preferences: %w", err)
+	}
+
+	return nil
+}
+
+// GetSettings retrieves where a user's notifications are delivered, with the
+// defaults for a user who has not set them
+func (r *NotificationRepository) GetSettings(userID int64) (*models.NotificationSettings, error) {
+	settings := &models.NotificationSettings{DigestHour: defaultDigestHour}
+	err := r.db.QueryRow(
+		`SELECT email, webhook_url, digest_hour FROM notification_settings WHERE user_id = ?`,
+		userID,
+	).Scan(&settings.Email, &settings.WebhookURL, &settings.DigestHour)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get notification settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+// SaveSettings stores where a user's notifications are delivered
+func (r *NotificationRepository) SaveSettings(userID int64, settings *models.NotificationSettings) error {
+	_, err := r.db.Exec(`
+		INSERT INTO notification_settings (user_id, email, webhook_url, digest_hour)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			email = excluded.email, webhook_url = excluded.webhook_url, digest_hour = excluded.digest_hour`,
+		userID, settings.Email, settings.WebhookURL, settings.DigestHour,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save notification settings: %w", err)
+	}
+	return nil
+}
+
+// AddDelivery queues a notification for delivery on a channel
+func (r *NotificationRepository) AddDelivery(delivery *models.NotificationDelivery) error {
+	delivery.Status = models.DeliveryPending
+	result, err := r.db.Exec(`
+		INSERT INTO notification_deliveries (notification_id, user_id, channel, digest, status, next_attempt_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		delivery.NotificationID, delivery.UserID, delivery.Channel, delivery.Digest, delivery.Status,
+		delivery.NextAttemptAt.UTC().Format(sqliteTimeFormat),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create notification delivery: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	delivery.ID = id
+	return nil
+}
+
+// DueDeliveries retrieves pending deliveries whose next attempt is due, oldest first
+func (r *NotificationRepository) DueDeliveries(now time.Time, limit int) ([]DueDelivery, error) {
+	rows, err := r.db.Query(`
+		SELECT d.id, d.notification_id, d.user_id, d.channel, d.digest, d.status, d.attempts, d.next_attempt_at,
+			d.last_error, d.created_at,
+			n.id, n.user_id, n.event, n.title, n.message, n.link, n.created_at,
+			CASE d.channel
+				WHEN 'email' THEN COALESCE(NULLIF(s.email, ''), u.email)
+				ELSE COALESCE(s.webhook_url, '')
+			END
+		FROM notification_deliveries d
+		JOIN notifications n ON d.notification_id = n.id
+		JOIN users u ON d.user_id = u.id
+		LEFT JOIN notification_settings s ON d.user_id = s.user_id
+		WHERE d.status = 'pending' AND d.next_attempt_at <= ?
+		ORDER BY d.next_attempt_at, d.id
+		LIMIT ?`,
+		now.UTC().Format(sqliteTimeFormat), limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var due []DueDelivery
+	for rows.Next() {
+		var item DueDelivery
+		delivery, notification := &item.Delivery, &item.Notification
+		if err := rows.Scan(
+			&delivery.ID, &delivery.NotificationID, &delivery.UserID, &delivery.Channel, &delivery.Digest, &delivery.Status,
+			&delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastError, &delivery.CreatedAt,
+			&notification.ID, &notification.UserID, &notification.Event, &notification.Title, &notification.Message,
+			&notification.Link, &notification.CreatedAt, &item.Address,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan due delivery: %w", err)
+		}
+		due = append(due, item)
+	}
+
+	return due, nil
+}
+
+// MarkSent records that deliveries were sent
+func (r *NotificationRepository) MarkSent(ids []int64, sentAt time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := []interface{}{sentAt.UTC().Format(sqliteTimeFormat)}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	_, err := r.db.Exec(`
+		UPDATE notification_deliveries
+		SET status = 'sent', attempts = attempts + 1, sent_at = ?, next_attempt_at = NULL, last_error = ''
+		WHERE id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark deliveries sent: %w", err)
+	}
+	return nil
+}
+
+// MarkAttemptFailed records a failed attempt at a delivery. It is retried at
+// retryAt, or given up on when retryAt is nil.
+func (r *NotificationRepository) MarkAttemptFailed(id int64, reason string, retryAt *time.Time) error {
+	status := models.DeliveryFailed
+	var next interface{}
+	if retryAt != nil {
+		status = models.DeliveryPending
+		next = retryAt.UTC().Format(sqliteTimeFormat)
+	}
+
+	_, err := r.db.Exec(`
+		UPDATE notification_deliveries
+		SET status = ?, attempts = attempts + 1, next_attempt_at = ?, last_error = ?
+		WHERE id = ?`,
+		status, next, reason, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record delivery failure: %w", err)
+	}
+	return nil
+}
+
+// GetDeliveries retrieves a user's most recent deliveries, newest first
+func (r *NotificationRepository) GetDeliveries(userID int64, limit int) ([]models.NotificationDelivery, error) {
+	rows, err := r.db.Query(`
+		SELECT d.id, d.notification_id, d.user_id, d.channel, d.digest, d.status, d.attempts, d.next_attempt_at,
+			d.last_error, d.sent_at, d.created_at, n.title
+		FROM notification_deliveries d
+		JOIN notifications n ON d.notification_id = n.id
+		WHERE d.user_id = ?
+		ORDER BY d.created_at DESC, d.id DESC
+		LIMIT ?`,
+		userID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notification deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.NotificationDelivery
+	for rows.Next() {
+		var delivery models.NotificationDelivery
+		if err := rows.Scan(
+			&delivery.ID, &delivery.NotificationID, &delivery.UserID, &delivery.Channel, &delivery.Digest, &delivery.Status,
+			&delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastError, &delivery.SentAt, &delivery.CreatedAt,
+			&delivery.Title,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan notification delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"myexpress-tracker/internal/models"
+)
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// monthOf returns the YYYY-MM month of a YYYY-MM-DD date
+func monthOf(date string) string {
+	if len(date) < 7 {
+		return date
+	}
+	return date[:7]
+}
+
+// adjustRollup adds amount and count to a user's monthly category rollup.
+// It must run in the same transaction as the write it mirrors.
+func adjustRollup(tx execer, userID int64, recordType string, categoryID int64, date string, amount float64, count int) error {
+	month := monthOf(date)
+
+	_, err := tx.Exec(`
+		INSERT INTO monthly_rollup (user_id, month, type, category_id, total, count)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, month, type, category_id)
+		DO UPDATE SET total = total + excluded.total, count = count + excluded.count
+	`, userID, month, recordType, categoryID, amount, count)
+	if err != nil {
+		return fmt.Errorf("failed to update %s rollup: %w", recordType, err)
+	}
+
+	// Drop empty buckets so rounding drift never leaves phantom totals behind
+	_, err = tx.Exec(`
+		DELETE FROM monthly_rollup
+		WHERE user_id = ? AND month = ? AND type = ? AND category_id = ? AND count <= 0
+	`, userID, month, recordType, categoryID)
+	if err != nil {
+		return fmt.Errorf("failed to clean %s rollup: %w", recordType, err)
+	}
+
+	return nil
+}
+
+// RollupRepository reads the monthly rollup tables
+type RollupRepository struct {
+	db *sql.DB
+}
+
+// NewRollupRepository creates a new rollup repository
+func NewRollupRepository(db *sql.DB) *RollupRepository {
+	return &RollupRepository{db: db}
+}
+
+// GetRange retrieves a user's monthly category totals between two months (YYYY-MM, inclusive)
+func (r *RollupRepository) GetRange(userID int64, fromMonth, toMonth string) ([]models.MonthlyRollup, error) {
+	query := `
+		SELECT r.month, r.type, r.category_id, c.name, r.total, r.count
+		FROM monthly_rollup r
+		JOIN categories c ON r.category_id = c.id
+		WHERE r.user_id = ? AND r.month >= ? AND r.month <= ?
+		ORDER BY r.month, r.type, c.name
+	`
+
+	rows, err := r.db.Query(query, userID, fromMonth, toMonth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rollups: %w", err)
+	}
+	defer rows.Close()
+
+	var rollups []models.MonthlyRollup
+	for rows.Next() {
+		var rollup models.MonthlyRollup
+		if err := rows.Scan(&rollup.Month, &rollup.Type, &rollup.CategoryID, &rollup.CategoryName, &rollup.Total, &rollup.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan rollup: %w", err)
+		}
+		rollups = append(rollups, rollup)
+	}
+
+	return rollups, nil
+}
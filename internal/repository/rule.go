@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"myexpress-tracker/internal/models"
+)
+
+// RuleRepository handles database operations for categorization rules
+type RuleRepository struct {
+	db *sql.DB
+}
+
+// NewRuleRepository creates a new rule repository
+func NewRuleRepository(db *sql.DB) *RuleRepository {
+	return &RuleRepository{db: db}
+}
+
+// Create creates a new rule
+func (r *RuleRepository) Create(ctx context.Context, rule *models.CategoryRule) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO category_rules (user_id, name, priority, enabled, description_contains, description_regex,
+			min_amount, max_amount, account, weekday, set_category_id, set_tags, set_description)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := tx.Exec(query, rule.UserID, rule.Name, rule.Priority, rule.Enabled, rule.DescriptionContains,
+		rule.DescriptionRegex, rule.MinAmount, rule.MaxAmount, rule.Account, rule.Weekday, rule.SetCategoryID,
+		rule.SetTags, rule.SetDescription)
+	if err != nil {
+		return fmt.Errorf("failed to create rule: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	if err := recordAudit(ctx, tx, rule.UserID, nil, "rule", id, ActionCreated, nil, rule); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rule: %w", err)
+	}
+
+	rule.ID = id
+	return nil
+}
+
+// Update updates an existing rule
+func (r *RuleRepository) Update(ctx context.Context, rule *models.CategoryRule) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	old, err := r.loadForWrite(tx, rule.ID, rule.UserID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE category_rules
+		SET name = ?, priority = ?, enabled = ?, description_contains = ?, description_regex = ?, min_amount = ?,
+			max_amount = ?, account = ?, weekday = ?, set_category_id = ?, set_tags = ?, set_description = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND user_id = ?
+	`
+	_, err = tx.Exec(query, rule.Name, rule.Priority, rule.Enabled, rule.DescriptionContains,
+		rule.DescriptionRegex, rule.MinAmount, rule.MaxAmount, rule.Account, rule.Weekday, rule.SetCategoryID,
+		rule.SetTags, rule.SetDescription, rule.ID, rule.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to update rule: %w", err)
+	}
+
+	if err := recordAudit(ctx, tx, rule.UserID, nil, "rule", rule.ID, ActionUpdated, old, rule); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rule: %w", err)
+	}
+
+	return nil
+}
+
+// Delete deletes a rule
+func (r *RuleRepository) Delete(ctx context.Context, id, userID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	old, err := r.loadForWrite(tx, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM category_rules WHERE id = ? AND user_id = ?`, id, userID); err != nil {
+		return fmt.Errorf("failed to delete rule: %w", err)
+	}
+
+	if err := recordAudit(ctx, tx, userID, nil, "rule", id, ActionDeleted, old, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rule: %w", err)
+	}
+
+	return nil
+}
+
+// loadForWrite loads a rule inside a write transaction
+func (r *RuleRepository) loadForWrite(tx execer, id, userID int64) (*models.CategoryRule, error) {
+	rule := &models.CategoryRule{}
+	err := tx.QueryRow(`
+		SELECT id, user_id, name, priority, enabled, description_contains, description_regex, min_amount, max_amount,
+			account, weekday, set_category_id, set_tags, set_description
+		FROM category_rules
+		WHERE id = ? AND user_id = ?
+	`, id, userID).Scan(
+		&rule.ID, &rule.UserID, &rule.Name, &rule.Priority, &rule.Enabled, &rule.DescriptionContains,
+		&rule.DescriptionRegex, &rule.MinAmount, &rule.MaxAmount, &rule.Account, &rule.Weekday,
+		&rule.SetCategoryID, &rule.SetTags, &rule.SetDescription,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("rule not found or unauthorized")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rule: %w", err)
+	}
+
+	return rule, nil
+}
+
+// GetByUser retrieves a user's rules in evaluation order
+func (r *RuleRepository) GetByUser(userID int64, enabledOnly bool) ([]models.CategoryRule, error) {
+	query := `
+		SELECT id, user_id, name, priority, enabled, description_contains, description_regex, min_amount, max_amount,
+			account, weekday, set_category_id, set_tags, set_description, created_at, updated_at
+		FROM category_rules
+		WHERE user_id = ?
+	`
+	if enabledOnly {
+		query += " AND enabled = 1"
+	}
+	query += " ORDER BY priority DESC, id"
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []models.CategoryRule{}
+	for rows.Next() {
+		var rule models.CategoryRule
+		if err := rows.Scan(
+			&rule.ID, &rule.UserID, &rule.Name, &rule.Priority, &rule.Enabled, &rule.DescriptionContains,
+			&rule.DescriptionRegex, &rule.MinAmount, &rule.MaxAmount, &rule.Account, &rule.Weekday,
+			&rule.SetCategoryID, &rule.SetTags, &rule.SetDescription, &rule.CreatedAt, &rule.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
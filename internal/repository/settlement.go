@@ -0,0 +1,304 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"myexpress-tracker/internal/models"
+)
+
+// ErrSettlementParties is returned when a settlement is not between two
+// different people, one of them the user or one of the user's contacts
+var ErrSettlementParties = errors.New("a settlement is between two different people, one of them you or your contact")
+
+// SettlementRepository handles settle-up payments and the debts that shared
+// bills create
+type SettlementRepository struct {
+	db *sql.DB
+}
+
+// NewSettlementRepository creates a new settlement repository
+func NewSettlementRepository(db *sql.DB) *SettlementRepository {
+	return &SettlementRepository{db: db}
+}
+
+// Create records a settlement. A nil From or To is the recording user.
+func (r *SettlementRepository) Create(settlement *models.Settlement) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	userID := settlement.RecordedBy
+	for _, party := range []**models.Party{&settlement.From, &settlement.To} {
+		if *party == nil {
+			*party = &models.Party{UserID: &userID}
+		}
+		if err := resolveParty(tx, userID, *party); err != nil {
+			return err
+		}
+	}
+
+	from, to := *settlement.From, *settlement.To
+	if from.Key() == to.Key() {
+		return ErrSettlementParties
+	}
+	// Contacts always belong to the recording user once resolved
+	if !isUser(from, userID) && !isUser(to, userID) && from.ContactID == nil && to.ContactID == nil {
+		return ErrSettlementParties
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO settlements (recorded_by, from_user_id, from_contact_id, to_user_id, to_contact_id, amount, settled_on, note)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		userID, from.UserID, from.ContactID, to.UserID, to.ContactID, settlement.Amount, settlement.SettledOn, settlement.Note,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create settlement: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit settlement: %w", err)
+	}
+
+	settlement.ID = id
+	return nil
+}
+
+// Delete deletes a settlement the user recorded
+func (r *SettlementRepository) Delete(id, userID int64) error {
+	result, err := r.db.Exec(`DELETE FROM settlements WHERE id = ? AND recorded_by = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete settlement: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete settlement: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("settlement not found or unauthorized")
+	}
+
+	return nil
+}
+
+// settlementSelect selects settlements (aliased s) with the names of both sides
+const settlementSelect = `
+	SELECT s.id, s.recorded_by, s.from_user_id, s.from_contact_id, COALESCE(fu.username, fc.name, ''),
+		s.to_user_id, s.to_contact_id, COALESCE(tu.username, tc.name, ''), s.amount, s.settled_on, s.note, s.created_at
+	FROM settlements s
+	LEFT JOIN users fu ON s.from_user_id = fu.id
+	LEFT JOIN contacts fc ON s.from_contact_id = fc.id
+	LEFT JOIN users tu ON s.to_user_id = tu.id
+	LEFT JOIN contacts tc ON s.to_contact_id = tc.id`
+
+// GetByID retrieves a settlement the user recorded or took part in
+func (r *SettlementRepository) GetByID(id, userID int64) (*models.Settlement, error) {
+	settlement, err := scanSettlement(r.db.QueryRow(settlementSelect+`
+		WHERE s.id = ? AND (s.recorded_by = ? OR s.from_user_id = ? OR s.to_user_id = ?)`,
+		id, userID, userID, userID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settlement by id: %w", err)
+	}
+
+	return settlement, nil
+}
+
+// GetByUser retrieves the settlements a user recorded or took part in, newest first
+func (r *SettlementRepository) GetByUser(userID int64) ([]models.Settlement, error) {
+	rows, err := r.db.Query(settlementSelect+`
+		WHERE s.recorded_by = ? OR s.from_user_id = ? OR s.to_user_id = ?
+		ORDER BY s.settled_on DESC, s.id DESC`,
+		userID, userID, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query settlements: %w", err)
+	}
+	defer rows.Close()
+
+	var settlements []models.Settlement
+	for rows.Next() {
+		settlement, err := scanSettlement(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan settlement: %w", err)
+		}
+		settlements = append(settlements, *settlement)
+	}
+
+	return settlements, nil
+}
+
+// scanSettlement scans a row selected with settlementSelect
+func scanSettlement(row rowScanner) (*models.Settlement, error) {
+	settlement := &models.Settlement{From: &models.Party{}, To: &models.Party{}}
+	if err := row.Scan(
+		&settlement.ID, &settlement.RecordedBy, &settlement.From.UserID, &settlement.From.ContactID, &settlement.From.Name,
+		&settlement.To.UserID, &settlement.To.ContactID, &settlement.To.Name, &settlement.Amount, &settlement.SettledOn,
+		&settlement.Note, &settlement.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	settlement.SettledOn = DateOnly(settlement.SettledOn)
+	return settlement, nil
+}
+
+// visibleBills restricts shared bills (expense aliased e) to live ones the user
+// owns or takes part in
+const visibleBills = `e.deleted_at IS NULL AND (e.user_id = ? OR EXISTS (
+	SELECT 1 FROM bill_participants x WHERE x.expense_id = e.id AND x.user_id = ?))`
+
+// Debts lists who owes whom across the shared bills and settlements a user
+// takes part in. On a bill everyone except the payer owes the payer their share;
+// a settlement from A to B makes B owe A the amount paid.
+func (r *SettlementRepository) Debts(userID int64) ([]models.Debt, error) {
+	type bill struct {
+		owner    models.Party
+		share    float64
+		payerKey string
+		parties  []models.BillParticipant
+	}
+
+	rows, err := r.db.Query(`
+		SELECT e.id, e.user_id, u.username, e.amount, b.paid_by_user_id, b.paid_by_contact_id
+		FROM shared_bills b
+		JOIN expense e ON b.expense_id = e.id
+		JOIN users u ON e.user_id = u.id
+		WHERE `+visibleBills,
+		userID, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shared bills: %w", err)
+	}
+
+	bills := make(map[int64]*bill)
+	var order []int64
+	for rows.Next() {
+		var expenseID, ownerID int64
+		var payer models.Party
+		b := &bill{}
+		if err := rows.Scan(&expenseID, &ownerID, &b.owner.Name, &b.share, &payer.UserID, &payer.ContactID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan shared bill: %w", err)
+		}
+		b.owner.UserID = &ownerID
+		b.payerKey = payer.Key()
+		if b.payerKey == "" {
+			b.payerKey = b.owner.Key()
+		}
+		bills[expenseID] = b
+		order = append(order, expenseID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query shared bills: %w", err)
+	}
+
+	rows, err = r.db.Query(`
+		SELECT p.expense_id, p.user_id, p.contact_id, COALESCE(bu.username, bc.name, ''), p.amount
+		FROM bill_participants p
+		JOIN expense e ON p.expense_id = e.id
+		LEFT JOIN users bu ON p.user_id = bu.id
+		LEFT JOIN contacts bc ON p.contact_id = bc.id
+		WHERE `+visibleBills+`
+		ORDER BY p.expense_id, p.position, p.id`,
+		userID, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bill participants: %w", err)
+	}
+	for rows.Next() {
+		var expenseID int64
+		var participant models.BillParticipant
+		if err := rows.Scan(&expenseID, &participant.UserID, &participant.ContactID, &participant.Name, &participant.Amount); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan bill participant: %w", err)
+		}
+		if b, ok := bills[expenseID]; ok {
+			b.parties = append(b.parties, participant)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query bill participants: %w", err)
+	}
+
+	var debts []models.Debt
+	for _, expenseID := range order {
+		b := bills[expenseID]
+		shares := append([]models.BillParticipant{{Party: b.owner, Amount: b.share}}, b.parties...)
+
+		var payer models.Party
+		for _, share := range shares {
+			if share.Key() == b.payerKey {
+				payer = share.Party
+			}
+		}
+		for _, share := range shares {
+			if share.Key() != b.payerKey {
+				debts = append(debts, models.Debt{From: share.Party, To: payer, Amount: share.Amount})
+			}
+		}
+	}
+
+	settlements, err := r.GetByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, settlement := range settlements {
+		debts = append(debts, models.Debt{From: *settlement.To, To: *settlement.From, Amount: settlement.Amount})
+	}
+
+	return debts, nil
+}
+
+// SharedWith retrieves the live bills other users shared with a user, newest first
+func (r *SettlementRepository) SharedWith(userID int64) ([]models.SharedWithMe, error) {
+	rows, err := r.db.Query(`
+		SELECT e.id, e.user_id, u.username, COALESCE(e.description, ''), e.expense_date, b.method, b.total,
+			b.paid_by_user_id, b.paid_by_contact_id, COALESCE(pu.username, pc.name, u.username), p.amount
+		FROM bill_participants p
+		JOIN shared_bills b ON p.expense_id = b.expense_id
+		JOIN expense e ON b.expense_id = e.id
+		JOIN users u ON e.user_id = u.id
+		LEFT JOIN users pu ON b.paid_by_user_id = pu.id
+		LEFT JOIN contacts pc ON b.paid_by_contact_id = pc.id
+		WHERE p.user_id = ? AND e.deleted_at IS NULL
+		ORDER BY e.expense_date DESC, e.id DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shared bills: %w", err)
+	}
+	defer rows.Close()
+
+	var bills []models.SharedWithMe
+	for rows.Next() {
+		var bill models.SharedWithMe
+		var ownerID int64
+		if err := rows.Scan(
+			&bill.ExpenseID, &ownerID, &bill.Owner.Name, &bill.Description, &bill.ExpenseDate, &bill.Method, &bill.Total,
+			&bill.PaidBy.UserID, &bill.PaidBy.ContactID, &bill.PaidBy.Name, &bill.MyShare,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan shared bill: %w", err)
+		}
+		bill.Owner.UserID = &ownerID
+		if bill.PaidBy.Key() == "" {
+			bill.PaidBy.UserID = &ownerID
+		}
+		bill.ExpenseDate = DateOnly(bill.ExpenseDate)
+		bills = append(bills, bill)
+	}
+
+	return bills, nil
+}
@@ -0,0 +1,286 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"myexpress-tracker/internal/models"
+)
+
+var (
+	// ErrShareTotal is returned when a bill's shares leave no positive share for someone
+	ErrShareTotal = errors.New("each share, including yours, must be more than zero")
+	// ErrParticipantNotFound is returned when a participant is not a known user or one of the user's contacts
+	ErrParticipantNotFound = errors.New("participant not found")
+	// ErrDuplicateParticipant is returned when someone appears twice on a bill
+	ErrDuplicateParticipant = errors.New("each person can share a bill only once")
+	// ErrPayerNotParticipant is returned when a bill's payer is not one of its participants
+	ErrPayerNotParticipant = errors.New("paid_by must be one of the participants")
+	// ErrSharedAmount is returned when a shared expense's amount is changed without its shares
+	ErrSharedAmount = errors.New("the amount of a shared expense follows its shares")
+	// ErrSharedHousehold is returned when sharing an expense in a household ledger
+	ErrSharedHousehold = errors.New("household expenses cannot be shared")
+)
+
+// prepareShares resolves the participants of a shared expense and works out
+// everyone's share, setting the expense amount to the owner's own share.
+// A bill without participants is dropped; other expenses are left untouched.
+func prepareShares(q execer, expense *models.Expense) error {
+	bill := expense.Shared
+	if bill == nil {
+		return nil
+	}
+	if len(bill.Participants) == 0 {
+		expense.Shared = nil
+		return nil
+	}
+	if expense.HouseholdID != nil {
+		return ErrSharedHousehold
+	}
+
+	seen := make(map[string]bool)
+	for i := range bill.Participants {
+		party := &bill.Participants[i].Party
+		if err := resolveParty(q, expense.UserID, party); err != nil {
+			return err
+		}
+		if seen[party.Key()] || isUser(*party, expense.UserID) {
+			return ErrDuplicateParticipant
+		}
+		seen[party.Key()] = true
+	}
+
+	if bill.PaidBy != nil {
+		if err := resolveParty(q, expense.UserID, bill.PaidBy); err != nil {
+			return err
+		}
+		if !seen[bill.PaidBy.Key()] {
+			return ErrPayerNotParticipant
+		}
+	}
+
+	// Work in cents so the shares always add up to the total exactly
+	totalCents := toCents(bill.Total)
+	ownCents := totalCents
+	switch bill.Method {
+	case models.ShareEqual:
+		people := int64(len(bill.Participants) + 1)
+		base, remainder := totalCents/people, totalCents%people
+		ownCents = base
+		if remainder > 0 {
+			ownCents++
+		}
+		for i := range bill.Participants {
+			cents := base
+			if int64(i+1) < remainder {
+				cents++
+			}
+			bill.Participants[i].Percent = nil
+			bill.Participants[i].Amount = fromCents(cents)
+		}
+	case models.SharePercentage:
+		for i := range bill.Participants {
+			participant := &bill.Participants[i]
+			if participant.Percent == nil {
+				return ErrShareTotal
+			}
+			cents := int64(math.Round(float64(totalCents) * *participant.Percent / 100))
+			participant.Amount = fromCents(cents)
+			ownCents -= cents
+		}
+	case models.ShareExact:
+		for i := range bill.Participants {
+			bill.Participants[i].Percent = nil
+			ownCents -= toCents(bill.Participants[i].Amount)
+		}
+	default:
+		return fmt.Errorf("unknown share method %q", bill.Method)
+	}
+
+	if ownCents <= 0 {
+		return ErrShareTotal
+	}
+	for _, participant := range bill.Participants {
+		if toCents(participant.Amount) <= 0 {
+			return ErrShareTotal
+		}
+	}
+
+	bill.Total = fromCents(totalCents)
+	expense.Amount = fromCents(ownCents)
+	return nil
+}
+
+// resolveParty checks that a party is a registered user or one of ownerID's
+// contacts, filling in its ID and name
+func resolveParty(q execer, ownerID int64, party *models.Party) error {
+	var err error
+	switch {
+	case party.ContactID != nil:
+		party.UserID = nil
+		err = q.QueryRow(
+			`SELECT name FROM contacts WHERE id = ? AND user_id = ?`,
+			*party.ContactID, ownerID,
+		).Scan(&party.Name)
+	case party.UserID != nil:
+		err = q.QueryRow(`SELECT username FROM users WHERE id = ?`, *party.UserID).Scan(&party.Name)
+	case party.User != "":
+		var userID int64
+		err = q.QueryRow(
+			`SELECT id, username FROM users WHERE email = ? OR username = ?`,
+			party.User, party.User,
+		).Scan(&userID, &party.Name)
+		party.UserID = &userID
+	default:
+		return ErrParticipantNotFound
+	}
+
+	if err == sql.ErrNoRows {
+		return ErrParticipantNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find participant: %w", err)
+	}
+
+	party.User = ""
+	return nil
+}
+
+// isUser reports whether a party is the given registered user
+func isUser(party models.Party, userID int64) bool {
+	return party.ContactID == nil && party.UserID != nil && *party.UserID == userID
+}
+
+// toCents converts an amount to whole cents
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// fromCents converts whole cents back to an amount
+func fromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
+
+// writeShares replaces the stored shared bill of an expense
+func writeShares(tx execer, expenseID int64, bill *models.SharedBill) error {
+	// Participants go with the bill through ON DELETE CASCADE
+	if _, err := tx.Exec(`DELETE FROM shared_bills WHERE expense_id = ?`, expenseID); err != nil {
+		return fmt.Errorf("failed to clear shared bill: %w", err)
+	}
+	if bill == nil {
+		return nil
+	}
+
+	var paidByUser, paidByContact *int64
+	if bill.PaidBy != nil {
+		paidByUser, paidByContact = bill.PaidBy.UserID, bill.PaidBy.ContactID
+	}
+
+	_, err := tx.Exec(
+		`INSERT INTO shared_bills (expense_id, method, total, paid_by_user_id, paid_by_contact_id) VALUES (?, ?, ?, ?, ?)`,
+		expenseID, bill.Method, bill.Total, paidByUser, paidByContact,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create shared bill: %w", err)
+	}
+
+	for i, participant := range bill.Participants {
+		_, err := tx.Exec(
+			`INSERT INTO bill_participants (expense_id, user_id, contact_id, percent, amount, position) VALUES (?, ?, ?, ?, ?, ?)`,
+			expenseID, participant.UserID, participant.ContactID, participant.Percent, participant.Amount, i,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create bill participant: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// loadShares loads the shared bill of an expense, if any, inside a write transaction
+func loadShares(q execer, expense *models.Expense) error {
+	expenses := []models.Expense{*expense}
+	if err := attachShares(q, expenses, "e.id = ?", expense.ID); err != nil {
+		return err
+	}
+	expense.Shared = expenses[0].Shared
+	return nil
+}
+
+// attachShares loads the shared bills, with participant names, of the expenses
+// selected by conditions on the expense table (aliased e) and attaches them to expenses
+func attachShares(q execer, expenses []models.Expense, conditions string, args ...interface{}) error {
+	if len(expenses) == 0 {
+		return nil
+	}
+
+	rows, err := q.Query(`
+		SELECT b.expense_id, b.method, b.total, b.paid_by_user_id, b.paid_by_contact_id, COALESCE(pu.username, pc.name, '')
+		FROM shared_bills b
+		JOIN expense e ON b.expense_id = e.id
+		LEFT JOIN users pu ON b.paid_by_user_id = pu.id
+		LEFT JOIN contacts pc ON b.paid_by_contact_id = pc.id
+		WHERE `+conditions,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query shared bills: %w", err)
+	}
+
+	bills := make(map[int64]*models.SharedBill)
+	for rows.Next() {
+		var expenseID int64
+		var payer models.Party
+		bill := &models.SharedBill{Participants: []models.BillParticipant{}}
+		if err := rows.Scan(&expenseID, &bill.Method, &bill.Total, &payer.UserID, &payer.ContactID, &payer.Name); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan shared bill: %w", err)
+		}
+		if payer.Key() != "" {
+			bill.PaidBy = &payer
+		}
+		bills[expenseID] = bill
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query shared bills: %w", err)
+	}
+	if len(bills) == 0 {
+		return nil
+	}
+
+	rows, err = q.Query(`
+		SELECT p.expense_id, p.user_id, p.contact_id, COALESCE(bu.username, bc.name, ''), p.percent, p.amount
+		FROM bill_participants p
+		JOIN expense e ON p.expense_id = e.id
+		LEFT JOIN users bu ON p.user_id = bu.id
+		LEFT JOIN contacts bc ON p.contact_id = bc.id
+		WHERE `+conditions+`
+		ORDER BY p.expense_id, p.position, p.id`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query bill participants: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var expenseID int64
+		var participant models.BillParticipant
+		if err := rows.Scan(&expenseID, &participant.UserID, &participant.ContactID, &participant.Name, &participant.Percent, &participant.Amount); err != nil {
+			return fmt.Errorf("failed to scan bill participant: %w", err)
+		}
+		if bill, ok := bills[expenseID]; ok {
+			bill.Participants = append(bill.Participants, participant)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query bill participants: %w", err)
+	}
+
+	for i := range expenses {
+		expenses[i].Shared = bills[expenses[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"myexpress-tracker/internal/models"
+)
+
+// ErrSplitTotal is returned when an expense's splits do not add up to its amount
+var ErrSplitTotal = errors.New("splits must add up to the expense amount")
+
+// prepareSplits checks that a split expense's line items add up to its amount
+// and points its category at the largest split. Expenses without splits are
+// left untouched.
+func prepareSplits(expense *models.Expense) error {
+	if len(expense.Splits) == 0 {
+		return nil
+	}
+
+	total := 0.0
+	largest := expense.Splits[0]
+	for i := range expense.Splits {
+		split := &expense.Splits[i]
+		split.CategoryName = ""
+		total += split.Amount
+		if split.Amount > largest.Amount {
+			largest = *split
+		}
+	}
+
+	if math.Abs(total-expense.Amount) >= 0.005 {
+		return ErrSplitTotal
+	}
+
+	expense.CategoryID = largest.CategoryID
+	return nil
+}
+
+// expenseLines returns the category amounts an expense counts towards: one per
+// split, or the whole amount under its own category when it is not split
+func expenseLines(expense *models.Expense) []models.ExpenseSplit {
+	if len(expense.Splits) > 0 {
+		return expense.Splits
+	}
+	return []models.ExpenseSplit{{CategoryID: expense.CategoryID, Amount: expense.Amount}}
+}
+
+// adjustExpenseRollups adds (sign 1) or removes (sign -1) a personal expense's
+// lines from the monthly rollups. Household records are not rolled up.
+func adjustExpenseRollups(tx execer, expense *models.Expense, sign float64) error {
+	if expense.HouseholdID != nil {
+		return nil
+	}
+	for _, line := range expenseLines(expense) {
+		if err := adjustRollup(tx, expense.UserID, "expense", line.CategoryID, expense.ExpenseDate, sign*line.Amount, int(sign)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSplits replaces the stored splits of an expense
+func writeSplits(tx execer, expenseID int64, splits []models.ExpenseSplit) error {
+	if _, err := tx.Exec(`DELETE FROM expense_splits WHERE expense_id = ?`, expenseID); err != nil {
+		return fmt.Errorf("failed to clear expense splits: %w", err)
+	}
+
+	for i, split := range splits {
+		_, err := tx.Exec(
+			`INSERT INTO expense_splits (expense_id, category_id, amount, note, position) VALUES (?, ?, ?, ?, ?)`,
+			expenseID, split.CategoryID, split.Amount, split.Note, i,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create expense split: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// loadSplits loads the splits of an expense inside a write transaction
+func loadSplits(tx execer, expenseID int64) ([]models.ExpenseSplit, error) {
+	rows, err := tx.Query(
+		`SELECT category_id, amount, note FROM expense_splits WHERE expense_id = ? ORDER BY position, id`,
+		expenseID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expense splits: %w", err)
+	}
+	defer rows.Close()
+
+	var splits []models.ExpenseSplit
+	for rows.Next() {
+		var split models.ExpenseSplit
+		if err := rows.Scan(&split.CategoryID, &split.Amount, &split.Note); err != nil {
+			return nil, fmt.Errorf("failed to scan expense split: %w", err)
+		}
+		splits = append(splits, split)
+	}
+
+	return splits, nil
+}
+
+// attachSplits loads the splits, with category names, of the expenses selected
+// by conditions on the expense table (aliased e) and attaches them to expenses
+func attachSplits(q execer, expenses []models.Expense, conditions string, args ...interface{}) error {
+	if len(expenses) == 0 {
+		return nil
+	}
+
+	rows, err := q.Query(`
+		SELECT s.expense_id, s.category_id, s.amount, s.note, c.name
+		FROM expense_splits s
+		JOIN expense e ON s.expense_id = e.id
+		JOIN categories c ON s.category_id = c.id
+		WHERE `+conditions+`
+		ORDER BY s.expense_id, s.position, s.id`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query expense splits: %w", err)
+	}
+	defer rows.Close()
+
+	byExpense := make(map[int64][]models.ExpenseSplit)
+	for rows.Next() {
+		var expenseID int64
+		var split models.ExpenseSplit
+		if err := rows.Scan(&expenseID, &split.CategoryID, &split.Amount, &split.Note, &split.CategoryName); err != nil {
+			return fmt.Errorf("failed to scan expense split: %w", err)
+		}
+		byExpense[expenseID] = append(byExpense[expenseID], split)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query expense splits: %w", err)
+	}
+
+	for i := range expenses {
+		expenses[i].Splits = byExpense[expenses[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUUIDTaken is returned when a client UUID already names another record
+var ErrUUIDTaken = errors.New("uuid already names another record")
+
+// Record states reported by SyncRepository.State
+const (
+	RecordLive    = "live"
+	RecordTrashed = "trashed"
+	RecordGone    = "gone"
+)
+
+// contextKey is the type for repository context keys
+type contextKey string
+
+// clientUUIDKey is the context key for the UUID a client chose for a new record
+const clientUUIDKey contextKey = "client_uuid"
+
+// WithClientUUID returns a context that makes Create store the record under a
+// UUID chosen by the client instead of a generated one
+func WithClientUUID(ctx context.Context, uuid string) context.Context {
+	return context.WithValue(ctx, clientUUIDKey, uuid)
+}
+
+// claimClientUUID gives a newly created personal record the client's UUID, if
+// ctx carries one. It must run in the creating transaction, so a retried push
+// finds the record instead of creating it again.
+func claimClientUUID(ctx context.Context, tx execer, userID int64, entity string, id int64) error {
+	uuid, _ := ctx.Value(clientUUIDKey).(string)
+	if uuid == "" {
+		return nil
+	}
+
+	// A record purged earlier may have left the UUID behind
+	_, err := tx.Exec(`
+		DELETE FROM sync_ids
+		WHERE user_id = ? AND uuid = ? AND NOT EXISTS (
+			SELECT 1 FROM income WHERE sync_ids.entity = 'income' AND income.id = sync_ids.entity_id
+			UNION ALL
+			SELECT 1 FROM expense WHERE sync_ids.entity = 'expense' AND expense.id = sync_ids.entity_id
+		)`,
+		userID, uuid,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to release uuid: %w", err)
+	}
+
+	_, err = tx.Exec(`UPDATE sync_ids SET uuid = ? WHERE entity = ? AND entity_id = ?`, uuid, entity, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrUUIDTaken
+		}
+		return fmt.Errorf("failed to store uuid: %w", err)
+	}
+
+	return nil
+}
+
+// SyncEntry is one entry of the change feed before its record is loaded
+type SyncEntry struct {
+	Version  int64
+	Entity   string
+	EntityID int64
+	UUID     string
+}
+
+// SyncRepository reads the change feed kept by the sync triggers
+type SyncRepository struct {
+	db *sql.DB
+}
+
+// NewSyncRepository creates a new sync repository
+func NewSyncRepository(db *sql.DB) *SyncRepository {
+	return &SyncRepository{db: db}
+}
+
+// Changes retrieves up to limit of a user's feed entries after a version,
+// oldest first. Each record appears once, at its latest version.
+func (r *SyncRepository) Changes(userID, since int64, limit int) ([]SyncEntry, error) {
+	rows, err := r.db.Query(`
+		SELECT c.version, c.entity, c.entity_id, COALESCE(s.uuid, '')
+		FROM sync_changes c
+		LEFT JOIN sync_ids s ON s.entity = c.entity AND s.entity_id = c.entity_id
+		WHERE c.version > ? AND (c.user_id = ? OR (c.user_id IS NULL AND c.entity = 'category'))
+		ORDER BY c.version
+		LIMIT ?`,
+		since, userID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sync changes: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []SyncEntry
+	for rows.Next() {
+		var entry SyncEntry
+		if err := rows.Scan(&entry.Version, &entry.Entity, &entry.EntityID, &entry.UUID); err != nil {
+			return nil, fmt.Errorf("failed to scan sync change: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// Version returns the latest version of a record, or 0 if it was never changed
+func (r *SyncRepository) Version(entity string, id int64) (int64, error) {
+	var version int64
+	err := r.db.QueryRow(`SELECT version FROM sync_changes WHERE entity = ? AND entity_id = ?`, entity, id).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get sync version: %w", err)
+	}
+
+	return version, nil
+}
+
+// ByUUID finds the personal record a user's UUID names. It returns an empty
+// entity when the UUID is unknown.
+func (r *SyncRepository) ByUUID(userID int64, uuid string) (entity string, id int64, err error) {
+	err = r.db.QueryRow(`SELECT entity, entity_id FROM sync_ids WHERE user_id = ? AND uuid = ?`, userID, uuid).Scan(&entity, &id)
+	if err == sql.ErrNoRows {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to look up uuid: %w", err)
+	}
+
+	return entity, id, nil
+}
+
+// UUID returns the UUID of a personal income or expense record
+func (r *SyncRepository) UUID(entity string, id int64) (string, error) {
+	var uuid string
+	err := r.db.QueryRow(`SELECT uuid FROM sync_ids WHERE entity = ? AND entity_id = ?`, entity, id).Scan(&uuid)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get uuid: %w", err)
+	}
+
+	return uuid, nil
+}
+
+// State reports whether one of a user's personal income or expense records
+// is live, in the trash or gone for good
+func (r *SyncRepository) State(entity string, id, userID int64) (string, error) {
+	table := "income"
+	if entity == "expense" {
+		table = "expense"
+	}
+
+	var trashed bool
+	err := r.db.QueryRow(
+		`SELECT deleted_at IS NOT NULL FROM `+table+` WHERE id = ? AND user_id = ? AND household_id IS NULL`,
+		id, userID,
+	).Scan(&trashed)
+	if err == sql.ErrNoRows {
+		return RecordGone, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get %s state: %w", entity, err)
+	}
+	if trashed {
+		return RecordTrashed, nil
+	}
+
+	return RecordLive, nil
+}
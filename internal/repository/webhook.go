@@ -0,0 +1,407 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"myexpress-tracker/internal/models"
+	"strings"
+	"time"
+)
+
+// DueWebhookDelivery is a webhook delivery ready to be sent, with where it goes
+// and the key it is signed with
+type DueWebhookDelivery struct {
+	Delivery models.WebhookDelivery
+	URL      string
+	Secret   string
+}
+
+// WebhookRepository handles database operations for webhooks, their deliveries
+// and delivery attempts
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+// NewWebhookRepository creates a new webhook repository
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// Create creates a new webhook
+func (r *WebhookRepository) Create(webhook *models.Webhook) error {
+	result, err := r.db.Exec(`
+		INSERT INTO webhooks (user_id, url, description, events, secret, active)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		webhook.UserID, webhook.URL, webhook.Description, strings.Join(webhook.Events, ","), webhook.Secret, webhook.Active,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	webhook.ID = id
+	return nil
+}
+
+// Update updates a webhook's URL, description, events and whether it is active
+func (r *WebhookRepository) Update(webhook *models.Webhook) error {
+	result, err := r.db.Exec(`
+		UPDATE webhooks
+		SET url = ?, description = ?, events = ?, active = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND user_id = ?`,
+		webhook.URL, webhook.Description, strings.Join(webhook.Events, ","), webhook.Active, webhook.ID, webhook.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update webhook: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("webhook not found or unauthorized")
+	}
+
+	return nil
+}
+
+// RotateSecret replaces a webhook's signing key
+func (r *WebhookRepository) RotateSecret(id, userID int64, secret string) error {
+	result, err := r.db.Exec(`
+		UPDATE webhooks SET secret = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ?`,
+		secret, id, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to rotate webhook secret: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to rotate webhook secret: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("webhook not found or unauthorized")
+	}
+
+	return nil
+}
+
+// Delete deletes a webhook with its deliveries
+func (r *WebhookRepository) Delete(id, userID int64) error {
+	result, err := r.db.Exec(`DELETE FROM webhooks WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("webhook not found or unauthorized")
+	}
+
+	return nil
+}
+
+// webhookSelect selects webhooks without their secret
+const webhookSelect = `
+	SELECT id, user_id, url, description, events, active, created_at, updated_at
+	FROM webhooks`
+
+// scanWebhook scans a row selected with webhookSelect
+func scanWebhook(row rowScanner) (*models.Webhook, error) {
+	webhook := &models.Webhook{}
+	var events string
+	if err := row.Scan(
+		&webhook.ID, &webhook.UserID, &webhook.URL, &webhook.Description, &events, &webhook.Active,
+		&webhook.CreatedAt, &webhook.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	webhook.Events = strings.Split(events, ",")
+	return webhook, nil
+}
+
+// GetByID retrieves one of a user's webhooks
+func (r *WebhookRepository) GetByID(id, userID int64) (*models.Webhook, error) {
+	webhook, err := scanWebhook(r.db.QueryRow(webhookSelect+` WHERE id = ? AND user_id = ?`, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook by id: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// GetByUser retrieves a user's webhooks, oldest first
+func (r *WebhookRepository) GetByUser(userID int64) ([]models.Webhook, error) {
+	return r.query(webhookSelect+` WHERE user_id = ? ORDER BY id`, userID)
+}
+
+// Subscribed retrieves a user's active webhooks that subscribe to an event
+func (r *WebhookRepository) Subscribed(userID int64, event string) ([]models.Webhook, error) {
+	return r.query(webhookSelect+`
+		WHERE user_id = ? AND active = 1 AND ',' || events || ',' LIKE '%,' || ? || ',%'
+		ORDER BY id`,
+		userID, event,
+	)
+}
+
+// query runs a webhook query selected with webhookSelect
+func (r *WebhookRepository) query(query string, args ...interface{}) ([]models.Webhook, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+
+	return webhooks, nil
+}
+
+// GetSecret retrieves the signing key of one of a user's webhooks
+func (r *WebhookRepository) GetSecret(id, userID int64) (string, error) {
+	var secret string
+	err := r.db.QueryRow(`SELECT secret FROM webhooks WHERE id = ? AND user_id = ?`, id, userID).Scan(&secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to get webhook secret: %w", err)
+	}
+	return secret, nil
+}
+
+// AddDelivery queues an event for delivery to a webhook
+func (r *WebhookRepository) AddDelivery(delivery *models.WebhookDelivery) error {
+	delivery.Status = models.DeliveryPending
+	result, err := r.db.Exec(`
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, status, next_attempt_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		delivery.WebhookID, delivery.EventID, delivery.Event, delivery.Payload, delivery.Status,
+		delivery.NextAttemptAt.UTC().Format(sqliteTimeFormat),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	if err := r.db.QueryRow(`SELECT created_at FROM webhook_deliveries WHERE id = ?`, id).Scan(&delivery.CreatedAt); err != nil {
+		return fmt.Errorf("failed to read webhook delivery: %w", err)
+	}
+
+	delivery.ID = id
+	return nil
+}
+
+// deliverySelect selects webhook deliveries (aliased d)
+const deliverySelect = `
+	SELECT d.id, d.webhook_id, d.event_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at, d.created_at,
+		d.completed_at
+	FROM webhook_deliveries d`
+
+// scanDelivery scans a row selected with deliverySelect, plus any extra columns
+func scanDelivery(row rowScanner, extra ...interface{}) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{}
+	dest := []interface{}{
+		&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.Event, &delivery.Payload, &delivery.Status,
+		&delivery.Attempts, &delivery.NextAttemptAt, &delivery.CreatedAt, &delivery.CompletedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// DueDeliveries retrieves pending deliveries to active webhooks whose next
+// attempt is due, oldest first
+func (r *WebhookRepository) DueDeliveries(now time.Time, limit int) ([]DueWebhookDelivery, error) {
+	rows, err := r.db.Query(`
+		SELECT d.id, d.webhook_id, d.event_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at, d.created_at,
+			d.completed_at, w.url, w.secret
+		FROM webhook_deliveries d
+		JOIN webhooks w ON d.webhook_id = w.id
+		WHERE d.status = 'pending' AND d.next_attempt_at <= ? AND w.active = 1
+		ORDER BY d.next_attempt_at, d.id
+		LIMIT ?`,
+		now.UTC().Format(sqliteTimeFormat), limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var due []DueWebhookDelivery
+	for rows.Next() {
+		var item DueWebhookDelivery
+		delivery, err := scanDelivery(rows, &item.URL, &item.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		item.Delivery = *delivery
+		due = append(due, item)
+	}
+
+	return due, nil
+}
+
+// RecordAttempt logs an attempt at a delivery and moves the delivery to its
+// new status. A pending delivery is tried again at retryAt.
+func (r *WebhookRepository) RecordAttempt(attempt *models.WebhookAttempt, status string, retryAt *time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO webhook_attempts (delivery_id, status_code, error, response_body, duration_ms, attempted_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		attempt.DeliveryID, attempt.StatusCode, attempt.Error, attempt.ResponseBody, attempt.DurationMs,
+		attempt.AttemptedAt.UTC().Format(sqliteTimeFormat),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook attempt: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	var next, completed interface{}
+	if retryAt != nil {
+		next = retryAt.UTC().Format(sqliteTimeFormat)
+	}
+	if status != models.DeliveryPending {
+		completed = attempt.AttemptedAt.UTC().Format(sqliteTimeFormat)
+	}
+	_, err = tx.Exec(`
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = attempts + 1, next_attempt_at = ?, completed_at = ?
+		WHERE id = ?`,
+		status, next, completed, attempt.DeliveryID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit webhook attempt: %w", err)
+	}
+
+	attempt.ID = id
+	return nil
+}
+
+// Redeliver queues a delivery to one of a user's webhooks to be sent again
+// right away, with a fresh set of retries
+func (r *WebhookRepository) Redeliver(id, webhookID, userID int64, now time.Time) error {
+	result, err := r.db.Exec(`
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = ?, completed_at = NULL
+		WHERE id = ? AND webhook_id = ? AND webhook_id IN (SELECT id FROM webhooks WHERE user_id = ?)`,
+		now.UTC().Format(sqliteTimeFormat), id, webhookID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to redeliver webhook delivery: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to redeliver webhook delivery: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("webhook delivery not found or unauthorized")
+	}
+
+	return nil
+}
+
+// GetDeliveries retrieves the most recent deliveries to one of a user's
+// webhooks, newest first, optionally only those with a status
+func (r *WebhookRepository) GetDeliveries(webhookID, userID int64, status string, limit int) ([]models.WebhookDelivery, error) {
+	query := deliverySelect + `
+		JOIN webhooks w ON d.webhook_id = w.id
+		WHERE d.webhook_id = ? AND w.user_id = ?`
+	args := []interface{}{webhookID, userID}
+	if status != "" {
+		query += ` AND d.status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY d.created_at DESC, d.id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+
+	return deliveries, nil
+}
+
+// GetDelivery retrieves a delivery to one of a user's webhooks with its attempts
+func (r *WebhookRepository) GetDelivery(id, webhookID, userID int64) (*models.WebhookDelivery, error) {
+	delivery, err := scanDelivery(r.db.QueryRow(deliverySelect+`
+		JOIN webhooks w ON d.webhook_id = w.id
+		WHERE d.id = ? AND d.webhook_id = ? AND w.user_id = ?`,
+		id, webhookID, userID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery by id: %w", err)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT id, delivery_id, status_code, error, response_body, duration_ms, attempted_at
+		FROM webhook_attempts
+		WHERE delivery_id = ?
+		ORDER BY attempted_at, id`,
+		id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook attempts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var attempt models.WebhookAttempt
+		if err := rows.Scan(
+			&attempt.ID, &attempt.DeliveryID, &attempt.StatusCode, &attempt.Error, &attempt.ResponseBody,
+			&attempt.DurationMs, &attempt.AttemptedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook attempt: %w", err)
+		}
+		delivery.AttemptLog = append(delivery.AttemptLog, attempt)
+	}
+
+	return delivery, nil
+}
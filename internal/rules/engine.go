@@ -0,0 +1,172 @@
+package rules
+
+import (
+	"fmt"
+	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/repository"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Engine applies a user's categorization rules to expenses
+type Engine struct {
+	ruleRepo *repository.RuleRepository
+}
+
+// NewEngine creates a new rules engine
+func NewEngine(ruleRepo *repository.RuleRepository) *Engine {
+	return &Engine{ruleRepo: ruleRepo}
+}
+
+// Load compiles a user's enabled rules in evaluation order
+func (e *Engine) Load(userID int64) (*RuleSet, error) {
+	rules, err := e.ruleRepo.GetByUser(userID, true)
+	if err != nil {
+		return nil, err
+	}
+	return Compile(rules)
+}
+
+// Apply runs a user's rules against a new expense. The category is only
+// assigned when the expense has none. It reports whether anything changed.
+func (e *Engine) Apply(userID int64, expense *models.Expense) (bool, error) {
+	set, err := e.Load(userID)
+	if err != nil {
+		return false, err
+	}
+	return set.Apply(expense, false), nil
+}
+
+// compiledRule is a rule with its regular expression compiled
+type compiledRule struct {
+	rule  models.CategoryRule
+	regex *regexp.Regexp
+}
+
+// RuleSet is an ordered list of compiled rules
+type RuleSet struct {
+	rules []compiledRule
+}
+
+// Compile validates and compiles rules, keeping their order
+func Compile(rules []models.CategoryRule) (*RuleSet, error) {
+	set := &RuleSet{}
+	for _, rule := range rules {
+		compiled := compiledRule{rule: rule}
+		if rule.DescriptionRegex != "" {
+			regex, err := regexp.Compile("(?i)" + rule.DescriptionRegex)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q has an invalid regex: %w", rule.Name, err)
+			}
+			compiled.regex = regex
+		}
+		set.rules = append(set.rules, compiled)
+	}
+	return set, nil
+}
+
+// Validate checks that a rule is well formed before it is stored
+func Validate(rule *models.CategoryRule) error {
+	if strings.TrimSpace(rule.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if rule.DescriptionRegex != "" {
+		if _, err := regexp.Compile(rule.DescriptionRegex); err != nil {
+			return fmt.Errorf("invalid description_regex")
+		}
+	}
+	if rule.Weekday != nil && (*rule.Weekday < 0 || *rule.Weekday > 6) {
+		return fmt.Errorf("weekday must be between 0 (Sunday) and 6 (Saturday)")
+	}
+	if rule.MinAmount != nil && rule.MaxAmount != nil && *rule.MinAmount > *rule.MaxAmount {
+		return fmt.Errorf("min_amount must not exceed max_amount")
+	}
+	if rule.SetCategoryID == nil && rule.SetTags == "" && rule.SetDescription == "" {
+		return fmt.Errorf("rule must set a category, tags or description")
+	}
+	return nil
+}
+
+// Apply runs the first matching rule against an expense. When overrideCategory
+// is false an existing category is kept; split expenses always keep theirs.
+// It reports whether anything changed.
+func (s *RuleSet) Apply(expense *models.Expense, overrideCategory bool) bool {
+	for _, compiled := range s.rules {
+		if !compiled.matches(expense) {
+			continue
+		}
+
+		before := *expense
+		rule := compiled.rule
+		if rule.SetCategoryID != nil && len(expense.Splits) == 0 && (overrideCategory || expense.CategoryID == 0) {
+			expense.CategoryID = *rule.SetCategoryID
+		}
+		if rule.SetTags != "" {
+			expense.Tags = MergeTags(expense.Tags, rule.SetTags)
+		}
+		if rule.SetDescription != "" {
+			expense.Description = rule.SetDescription
+		}
+
+		return before.CategoryID != expense.CategoryID || before.Tags != expense.Tags ||
+			before.Description != expense.Description
+	}
+	return false
+}
+
+// Matches reports whether any rule in the set matches the expense
+func (s *RuleSet) Matches(expense *models.Expense) bool {
+	for _, compiled := range s.rules {
+		if compiled.matches(expense) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether every condition of the rule holds for the expense
+func (c compiledRule) matches(expense *models.Expense) bool {
+	rule := c.rule
+
+	if rule.DescriptionContains != "" &&
+		!strings.Contains(strings.ToLower(expense.Description), strings.ToLower(rule.DescriptionContains)) {
+		return false
+	}
+	if c.regex != nil && !c.regex.MatchString(expense.Description) {
+		return false
+	}
+	if rule.MinAmount != nil && expense.Amount < *rule.MinAmount {
+		return false
+	}
+	if rule.MaxAmount != nil && expense.Amount > *rule.MaxAmount {
+		return false
+	}
+	if rule.Account != "" && !strings.EqualFold(strings.TrimSpace(expense.Account), strings.TrimSpace(rule.Account)) {
+		return false
+	}
+	if rule.Weekday != nil {
+		day, err := time.Parse("2006-01-02", repository.DateOnly(expense.ExpenseDate))
+		if err != nil || int(day.Weekday()) != *rule.Weekday {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MergeTags combines two comma-separated tag lists, dropping blanks and duplicates
+func MergeTags(existing, added string) string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, tag := range strings.Split(existing+","+added, ",") {
+		tag = strings.TrimSpace(tag)
+		key := strings.ToLower(tag)
+		if tag == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		tags = append(tags, tag)
+	}
+	return strings.Join(tags, ",")
+}
@@ -0,0 +1,130 @@
+package settleup
+
+import (
+	"math"
+	"myexpress-tracker/internal/models"
+	"sort"
+)
+
+// Summarize works out what each counterparty and the user owe each other and
+// suggests the fewest payments that would settle everyone in the debts.
+// Amounts are handled in cents so the balances always add up exactly.
+func Summarize(userID int64, debts []models.Debt) models.BalanceSummary {
+	parties := make(map[string]models.Party)
+	net := make(map[string]int64)
+	withUser := make(map[string]int64)
+
+	for _, debt := range debts {
+		cents := toCents(debt.Amount)
+		from, to := debt.From.Key(), debt.To.Key()
+		parties[from], parties[to] = debt.From, debt.To
+		net[from] -= cents
+		net[to] += cents
+
+		switch {
+		case isUser(debt.From, userID):
+			withUser[to] -= cents
+		case isUser(debt.To, userID):
+			withUser[from] += cents
+		}
+	}
+
+	summary := models.BalanceSummary{
+		Balances:    []models.Balance{},
+		Suggestions: simplify(parties, net),
+	}
+	var owed, owe int64
+	for key, cents := range withUser {
+		if cents == 0 {
+			continue
+		}
+		summary.Balances = append(summary.Balances, models.Balance{Party: parties[key], Amount: fromCents(cents)})
+		if cents > 0 {
+			owed += cents
+		} else {
+			owe -= cents
+		}
+	}
+	summary.YouAreOwed, summary.YouOwe = fromCents(owed), fromCents(owe)
+
+	// People who owe the user first, then by name
+	sort.Slice(summary.Balances, func(i, j int) bool {
+		a, b := summary.Balances[i], summary.Balances[j]
+		if a.Amount != b.Amount {
+			return a.Amount > b.Amount
+		}
+		return a.Party.Name < b.Party.Name
+	})
+
+	return summary
+}
+
+// simplify pays off the net balances greedily: the largest debtor pays the
+// largest creditor until one of them is settled. This needs at most one
+// payment fewer than there are people with a balance.
+func simplify(parties map[string]models.Party, net map[string]int64) []models.Transfer {
+	type balance struct {
+		key   string
+		cents int64
+	}
+
+	var creditors, debtors []balance
+	for key, cents := range net {
+		if cents > 0 {
+			creditors = append(creditors, balance{key, cents})
+		} else if cents < 0 {
+			debtors = append(debtors, balance{key, -cents})
+		}
+	}
+
+	largestFirst := func(list []balance) {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].cents != list[j].cents {
+				return list[i].cents > list[j].cents
+			}
+			return list[i].key < list[j].key
+		})
+	}
+	largestFirst(creditors)
+	largestFirst(debtors)
+
+	transfers := []models.Transfer{}
+	for i, j := 0, 0; i < len(creditors) && j < len(debtors); {
+		cents := creditors[i].cents
+		if debtors[j].cents < cents {
+			cents = debtors[j].cents
+		}
+
+		transfers = append(transfers, models.Transfer{
+			From:   parties[debtors[j].key],
+			To:     parties[creditors[i].key],
+			Amount: fromCents(cents),
+		})
+
+		creditors[i].cents -= cents
+		debtors[j].cents -= cents
+		if creditors[i].cents == 0 {
+			i++
+		}
+		if debtors[j].cents == 0 {
+			j++
+		}
+	}
+
+	return transfers
+}
+
+// isUser reports whether a party is the given registered user
+func isUser(party models.Party, userID int64) bool {
+	return party.ContactID == nil && party.UserID != nil && *party.UserID == userID
+}
+
+// toCents converts an amount to whole cents
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// fromCents converts whole cents back to an amount
+func fromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
@@ -0,0 +1,322 @@
+package stream
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"myexpress-tracker/internal/repository"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// historySize is how many recent events are kept per user for resuming
+	historySize = 256
+	// bufferSize is how many events a subscriber may fall behind before it is
+	// dropped; it then reconnects and resumes from its last event
+	bufferSize = 64
+)
+
+// Event types sent to subscribers
+const (
+	EventChange  = "change"
+	EventSummary = "summary"
+	EventReset   = "reset"
+)
+
+// Event is one message on a user's stream
+type Event struct {
+	ID   string
+	Type string
+	Data []byte
+	seq  uint64
+}
+
+// Change is the data of a change event
+type Change struct {
+	Entity string `json:"entity"`
+	Action string `json:"action"`
+	ID     int64  `json:"id"`
+}
+
+// Totals are the headline figures of a user's personal dashboard
+type Totals struct {
+	TotalIncome    float64 `json:"total_income"`
+	TotalExpense   float64 `json:"total_expense"`
+	Balance        float64 `json:"balance"`
+	TodayIncome    float64 `json:"today_income"`
+	TodayExpense   float64 `json:"today_expense"`
+	MonthlyIncome  float64 `json:"monthly_income"`
+	MonthlyExpense float64 `json:"monthly_expense"`
+}
+
+// Summary is the data of a summary event: the current totals and how much
+// each changed since the previous summary on the stream
+type Summary struct {
+	Totals Totals `json:"totals"`
+	Delta  Totals `json:"delta"`
+}
+
+// Subscription receives a user's events until it is cancelled. Events is
+// closed when the subscriber falls too far behind.
+type Subscription struct {
+	Events <-chan Event
+	// Replay holds the events missed since the Last-Event-ID given to Subscribe
+	Replay []Event
+	// Reset is set when the missed events are no longer known, so the client
+	// must reload its state
+	Reset bool
+
+	broker *Broker
+	userID int64
+	ch     chan Event
+}
+
+// hub is one user's event history and subscribers. It is kept after their
+// streams close, so a client that reconnects can catch up.
+type hub struct {
+	history []Event
+	// floor is the last sequence number before the history: events up to it
+	// were published before the hub existed or have been evicted
+	floor uint64
+	subs  map[chan Event]struct{}
+	last  *Totals
+}
+
+// Broker fans changes to users' records out to their open streams
+type Broker struct {
+	db    *sql.DB
+	epoch string
+	mu    sync.Mutex
+	seq   uint64
+	hubs  map[int64]*hub
+}
+
+// NewBroker creates a new event broker. Event IDs carry the broker's start
+// time, so IDs from before a restart are recognised and answered with a reset.
+func NewBroker(db *sql.DB) *Broker {
+	return &Broker{
+		db:    db,
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+		hubs:  make(map[int64]*hub),
+	}
+}
+
+// OnChange publishes a change event and refreshed totals to the user's
+// streams. Users who never opened a stream are skipped, and totals are only
+// computed while one is open.
+func (b *Broker) OnChange(event repository.ChangeEvent) {
+	if !b.known(event.UserID) {
+		return
+	}
+
+	data, err := json.Marshal(Change{Entity: event.Entity, Action: event.Action, ID: event.ID})
+	if err != nil {
+		log.Printf("stream: failed to encode change: %v", err)
+		return
+	}
+	b.publish(event.UserID, EventChange, data)
+
+	if event.Entity != "income" && event.Entity != "expense" || !b.listening(event.UserID) {
+		return
+	}
+	if err := b.PublishSummary(event.UserID); err != nil {
+		log.Printf("stream: failed to publish summary for user %d: %v", event.UserID, err)
+	}
+}
+
+// PublishSummary computes the user's totals and publishes them with their
+// change since the last summary
+func (b *Broker) PublishSummary(userID int64) error {
+	totals, err := b.totals(userID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	h := b.hub(userID)
+	summary := Summary{Totals: *totals}
+	if h.last != nil {
+		summary.Delta = subtract(*totals, *h.last)
+	}
+	h.last = totals
+	b.mu.Unlock()
+
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("failed to encode summary: %w", err)
+	}
+	b.publish(userID, EventSummary, data)
+	return nil
+}
+
+// Subscribe opens a stream for a user. lastEventID is the Last-Event-ID the
+// client resumes from, if any.
+func (b *Broker) Subscribe(userID int64, lastEventID string) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	h := b.hub(userID)
+	sub := &Subscription{broker: b, userID: userID, ch: make(chan Event, bufferSize)}
+	sub.Events = sub.ch
+	h.subs[sub.ch] = struct{}{}
+
+	if lastEventID == "" {
+		return sub
+	}
+
+	seq, ok := b.parseID(lastEventID)
+	if !ok {
+		sub.Reset = true
+		return sub
+	}
+	if seq < h.floor {
+		sub.Reset = true
+		return sub
+	}
+	for _, event := range h.history {
+		if event.seq > seq {
+			sub.Replay = append(sub.Replay, event)
+		}
+	}
+	return sub
+}
+
+// Cancel closes the subscription
+func (s *Subscription) Cancel() {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	h, ok := b.hubs[s.userID]
+	if !ok {
+		return
+	}
+	if _, ok := h.subs[s.ch]; ok {
+		delete(h.subs, s.ch)
+		close(s.ch)
+	}
+}
+
+// known reports whether a user has opened a stream since the broker started
+func (b *Broker) known(userID int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.hubs[userID]
+	return ok
+}
+
+// listening reports whether a user has any open streams
+func (b *Broker) listening(userID int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h, ok := b.hubs[userID]
+	return ok && len(h.subs) > 0
+}
+
+// publish records an event in the user's history and sends it to their
+// subscribers, dropping any that are too far behind
+func (b *Broker) publish(userID int64, eventType string, data []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	event := Event{ID: b.epoch + "-" + strconv.FormatUint(b.seq, 10), Type: eventType, Data: data, seq: b.seq}
+
+	h := b.hub(userID)
+	h.history = append(h.history, event)
+	if len(h.history) > historySize {
+		evicted := len(h.history) - historySize
+		h.floor = h.history[evicted-1].seq
+		h.history = h.history[evicted:]
+	}
+
+	for ch := range h.subs {
+		select {
+		case ch <- event:
+		default:
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// hub returns a user's hub, creating it if needed. b.mu must be held.
+func (b *Broker) hub(userID int64) *hub {
+	h, ok := b.hubs[userID]
+	if !ok {
+		h = &hub{floor: b.seq, subs: make(map[chan Event]struct{})}
+		b.hubs[userID] = h
+	}
+	return h
+}
+
+// parseID returns the sequence number of an event ID issued by this broker
+func (b *Broker) parseID(id string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(id, "-")
+	if !ok || epoch != b.epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil || n > b.seq {
+		return 0, false
+	}
+	return n, true
+}
+
+// totals computes a user's personal dashboard totals
+func (b *Broker) totals(userID int64, now time.Time) (*Totals, error) {
+	today := now.Format("2006-01-02")
+	month := now.Format("2006-01")
+
+	t := &Totals{}
+	err := b.db.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN type = 'income' THEN total END), 0),
+			COALESCE(SUM(CASE WHEN type = 'expense' THEN total END), 0),
+			COALESCE(SUM(CASE WHEN type = 'income' AND month = ? THEN total END), 0),
+			COALESCE(SUM(CASE WHEN type = 'expense' AND month = ? THEN total END), 0)
+		FROM monthly_rollup WHERE user_id = ?`,
+		month, month, userID,
+	).Scan(&t.TotalIncome, &t.TotalExpense, &t.MonthlyIncome, &t.MonthlyExpense)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum totals: %w", err)
+	}
+
+	err = b.db.QueryRow(`
+		SELECT
+			(SELECT COALESCE(SUM(amount), 0) FROM income
+			 WHERE user_id = ? AND household_id IS NULL AND income_date = ? AND deleted_at IS NULL),
+			(SELECT COALESCE(SUM(amount), 0) FROM expense
+			 WHERE user_id = ? AND household_id IS NULL AND expense_date = ? AND deleted_at IS NULL)`,
+		userID, today, userID, today,
+	).Scan(&t.TodayIncome, &t.TodayExpense)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum today's totals: %w", err)
+	}
+
+	t.Balance = t.TotalIncome - t.TotalExpense
+	return t, nil
+}
+
+// subtract returns the change from previous to current
+func subtract(current, previous Totals) Totals {
+	return Totals{
+		TotalIncome:    round(current.TotalIncome - previous.TotalIncome),
+		TotalExpense:   round(current.TotalExpense - previous.TotalExpense),
+		Balance:        round(current.Balance - previous.Balance),
+		TodayIncome:    round(current.TodayIncome - previous.TodayIncome),
+		TodayExpense:   round(current.TodayExpense - previous.TodayExpense),
+		MonthlyIncome:  round(current.MonthlyIncome - previous.MonthlyIncome),
+		MonthlyExpense: round(current.MonthlyExpense - previous.MonthlyExpense),
+	}
+}
+
+// round rounds an amount to cents, hiding floating point noise in deltas
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
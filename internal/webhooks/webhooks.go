@@ -0,0 +1,335 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"myexpress-tracker/internal/models"
+	"myexpress-tracker/internal/netguard"
+	"myexpress-tracker/internal/repository"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// pollInterval is how often the worker looks for deliveries that are due
+	pollInterval = 30 * time.Second
+	// batchSize caps the deliveries sent per pass of the worker
+	batchSize = 100
+	// requestTimeout bounds a single delivery attempt
+	requestTimeout = 10 * time.Second
+	// maxResponseBody is how much of a response is kept in the attempt log
+	maxResponseBody = 1024
+	// maxAttempts is how many times a delivery is tried before giving up
+	maxAttempts = 6
+)
+
+// retryDelays is the wait after each failed attempt, indexed by attempts made - 1
+var retryDelays = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 12 * time.Hour}
+
+// Events lists the event types a webhook can subscribe to
+var Events = []string{
+	models.WebhookExpenseCreated, models.WebhookExpenseUpdated, models.WebhookExpenseDeleted, models.WebhookExpenseRestored,
+	models.WebhookIncomeCreated, models.WebhookIncomeUpdated, models.WebhookIncomeDeleted, models.WebhookIncomeRestored,
+}
+
+// Request headers sent with every delivery
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderEventID   = "X-Webhook-Event-ID"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Payload is the JSON body of a delivery
+type Payload struct {
+	ID        string      `json:"id"` // Event ID
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Dispatcher turns changes to users' records into webhook deliveries and
+// sends them, retrying failures with backoff
+type Dispatcher struct {
+	webhookRepo *repository.WebhookRepository
+	incomeRepo  *repository.IncomeRepository
+	expenseRepo *repository.ExpenseRepository
+	client      *http.Client
+	wake        chan struct{}
+}
+
+// NewDispatcher creates a new webhook dispatcher
+func NewDispatcher(webhookRepo *repository.WebhookRepository, incomeRepo *repository.IncomeRepository, expenseRepo *repository.ExpenseRepository) *Dispatcher {
+	return &Dispatcher{
+		webhookRepo: webhookRepo,
+		incomeRepo:  incomeRepo,
+		expenseRepo: expenseRepo,
+		client:      netguard.NewClient(requestTimeout),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Start launches the delivery worker
+func (d *Dispatcher) Start() {
+	go d.worker()
+}
+
+// NewSecret generates a webhook signing key
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign computes the signature of a delivery: the hex HMAC-SHA256, keyed with
+// the webhook's secret, of the timestamp, a dot and the body
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// OnChange queues deliveries for a change to a user's personal income or
+// expenses. It is registered as a repository change listener. Changes to
+// household records, including deletes, are not sent.
+func (d *Dispatcher) OnChange(event repository.ChangeEvent) {
+	if event.HouseholdID != 0 || (event.Entity != "income" && event.Entity != "expense") {
+		return
+	}
+	name := event.Entity + "." + event.Action
+
+	var data interface{} = map[string]int64{"id": event.ID}
+	if event.Action != repository.ActionDeleted {
+		var err error
+		data, err = d.load(event)
+		if err != nil {
+			log.Printf("webhooks: failed to load %s %d: %v", event.Entity, event.ID, err)
+			return
+		}
+		if data == nil {
+			return
+		}
+	}
+
+	if err := d.Publish(event.UserID, name, data); err != nil {
+		log.Printf("webhooks: failed to queue %s for user %d: %v", name, event.UserID, err)
+	}
+}
+
+// load fetches the personal record a change event is about, or nil if it is
+// not in the user's personal ledger
+func (d *Dispatcher) load(event repository.ChangeEvent) (interface{}, error) {
+	if event.Entity == "income" {
+		income, err := d.incomeRepo.GetByID(event.ID, repository.Personal(event.UserID))
+		if err != nil || income == nil {
+			return nil, err
+		}
+		return income, nil
+	}
+
+	expense, err := d.expenseRepo.GetByID(event.ID, repository.Personal(event.UserID))
+	if err != nil || expense == nil {
+		return nil, err
+	}
+	return expense, nil
+}
+
+// Publish queues an event for every active webhook of the user subscribed to it
+func (d *Dispatcher) Publish(userID int64, event string, data interface{}) error {
+	webhooks, err := d.webhookRepo.Subscribed(userID, event)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+
+	payload, eventID, err := newPayload(event, data)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, webhook := range webhooks {
+		delivery := models.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			EventID:       eventID,
+			Event:         event,
+			Payload:       payload,
+			NextAttemptAt: &now,
+		}
+		if err := d.webhookRepo.AddDelivery(&delivery); err != nil {
+			return err
+		}
+	}
+
+	d.poke()
+	return nil
+}
+
+// newPayload encodes the body of a new event
+func newPayload(event string, data interface{}) (payload, eventID string, err error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate event id: %w", err)
+	}
+	eventID = "evt_" + hex.EncodeToString(b)
+
+	body, err := json.Marshal(Payload{ID: eventID, Event: event, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+	return string(body), eventID, nil
+}
+
+// Ping sends a ping event to a webhook right away and returns the delivery
+// with its attempt. A failed ping is not retried.
+func (d *Dispatcher) Ping(webhook *models.Webhook, secret string) (*models.WebhookDelivery, error) {
+	payload, eventID, err := newPayload(models.WebhookPing, map[string]interface{}{
+		"webhook_id": webhook.ID,
+		"events":     webhook.Events,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	delivery := &models.WebhookDelivery{
+		WebhookID:     webhook.ID,
+		EventID:       eventID,
+		Event:         models.WebhookPing,
+		Payload:       payload,
+		NextAttemptAt: &now,
+	}
+	if err := d.webhookRepo.AddDelivery(delivery); err != nil {
+		return nil, err
+	}
+
+	attempt := d.send(webhook.URL, secret, delivery)
+	status := models.DeliverySent
+	if !succeeded(attempt) {
+		status = models.DeliveryFailed
+	}
+	if err := d.webhookRepo.RecordAttempt(&attempt, status, nil); err != nil {
+		return nil, err
+	}
+
+	return d.webhookRepo.GetDelivery(delivery.ID, webhook.ID, webhook.UserID)
+}
+
+// Redeliver queues a delivery to be sent again right away
+func (d *Dispatcher) Redeliver(deliveryID, webhookID, userID int64) error {
+	if err := d.webhookRepo.Redeliver(deliveryID, webhookID, userID, time.Now()); err != nil {
+		return err
+	}
+	d.poke()
+	return nil
+}
+
+// poke wakes the worker without waiting for its next poll
+func (d *Dispatcher) poke() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// worker sends due deliveries whenever poked, and at least every poll interval
+func (d *Dispatcher) worker() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-d.wake:
+		}
+		if err := d.DeliverDue(time.Now()); err != nil {
+			log.Printf("webhooks: delivery run failed: %v", err)
+		}
+	}
+}
+
+// DeliverDue sends the deliveries that are due, scheduling a retry for each
+// failure until the last attempt
+func (d *Dispatcher) DeliverDue(now time.Time) error {
+	due, err := d.webhookRepo.DueDeliveries(now, batchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range due {
+		attempt := d.send(item.URL, item.Secret, &item.Delivery)
+
+		status := models.DeliverySent
+		var retryAt *time.Time
+		if !succeeded(attempt) {
+			status = models.DeliveryFailed
+			if attempts := item.Delivery.Attempts + 1; attempts < maxAttempts {
+				status = models.DeliveryPending
+				next := attempt.AttemptedAt.Add(retryDelays[attempts-1])
+				retryAt = &next
+			}
+		}
+
+		if err := d.webhookRepo.RecordAttempt(&attempt, status, retryAt); err != nil {
+			log.Printf("webhooks: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// send makes one attempt at a delivery
+func (d *Dispatcher) send(url, secret string, delivery *models.WebhookDelivery) models.WebhookAttempt {
+	started := time.Now()
+	attempt := models.WebhookAttempt{DeliveryID: delivery.ID, AttemptedAt: started}
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		attempt.DurationMs = time.Since(started).Milliseconds()
+		return attempt
+	}
+
+	timestamp := started.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "MyExpress-Tracker-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderEventID, delivery.EventID)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, "sha256="+Sign(secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		attempt.Error = err.Error()
+		attempt.DurationMs = time.Since(started).Milliseconds()
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	attempt.StatusCode = resp.StatusCode
+	attempt.ResponseBody = string(response)
+	if !succeeded(attempt) {
+		attempt.Error = "endpoint responded with " + resp.Status
+	}
+	attempt.DurationMs = time.Since(started).Milliseconds()
+	return attempt
+}
+
+// succeeded reports whether an attempt got a 2xx response
+func succeeded(attempt models.WebhookAttempt) bool {
+	return attempt.StatusCode >= 200 && attempt.StatusCode <= 299
+}
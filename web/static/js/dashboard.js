@@ -297,11 +297,24 @@ async function loadUserProfile() {
     }
 }
 
+// Last dashboard payload and its ETag, reused when the server answers 304
+let dashboardETag = null;
+let dashboardCache = null;
+
 // Load Dashboard Data
 async function loadDashboardData() {
     try {
-        const response = await apiRequest('/dashboard');
-        const data = await response.json();
+        const headers = dashboardETag ? { 'If-None-Match': dashboardETag } : {};
+        const response = await apiRequest('/dashboard', { headers });
+        
+        let data;
+        if (response.status === 304 && dashboardCache) {
+            data = dashboardCache;
+        } else {
+            data = await response.json();
+            dashboardETag = response.headers.get('ETag');
+            dashboardCache = data;
+        }
         
         // Update summary cards
         document.getElementById('totalIncome').textContent = data.total_income.toFixed(2);
@@ -691,7 +704,7 @@ async function deleteIncome(id) {
     try {
         const response = await apiRequest(`/income/${id}`, { method: 'DELETE' });
         if (response.ok) {
-            showNotification('Income deleted', 'success');
+            showNotification('Income moved to trash', 'success');
             await loadDashboardData();
             await loadIncomeList();
         }
@@ -706,7 +719,7 @@ async function deleteExpense(id) {
     try {
         const response = await apiRequest(`/expense/${id}`, { method: 'DELETE' });
         if (response.ok) {
-            showNotification('Expense deleted', 'success');
+            showNotification('Expense moved to trash', 'success');
             await loadDashboardData();
             await loadExpenseList();
         }
@@ -829,6 +842,117 @@ function showNotification(message, type = 'info') {
     }, 3000);
 }
 
+// Live Updates
+// Follows /api/stream so changes made on another device show up without a
+// manual refresh. EventSource cannot send the Authorization header, so the
+// stream is read with fetch and parsed here.
+let lastStreamEventId = null;
+let streamRetryMs = 3000;
+let streamRefreshTimer = null;
+let streamRefreshEntities = new Set();
+
+async function connectStream() {
+    const token = localStorage.getItem('token');
+    if (!token) return;
+    
+    const headers = { 'Authorization': `Bearer ${token}` };
+    if (lastStreamEventId) {
+        headers['Last-Event-ID'] = lastStreamEventId;
+    }
+    
+    try {
+        const response = await fetch(`${API_BASE}/stream`, { headers });
+        if (response.status === 401) {
+            localStorage.removeItem('token');
+            window.location.href = '/login.html';
+            return;
+        }
+        if (!response.ok || !response.body) {
+            throw new Error(`stream responded with ${response.status}`);
+        }
+        
+        const reader = response.body.getReader();
+        const decoder = new TextDecoder();
+        let buffer = '';
+        
+        while (true) {
+            const { value, done } = await reader.read();
+            if (done) break;
+            
+            buffer += decoder.decode(value, { stream: true }).replace(/\r\n?/g, '\n');
+            let boundary;
+            while ((boundary = buffer.indexOf('\n\n')) !== -1) {
+                handleStreamBlock(buffer.slice(0, boundary));
+                buffer = buffer.slice(boundary + 2);
+            }
+        }
+    } catch (error) {
+        console.error('Live updates disconnected:', error);
+    }
+    
+    setTimeout(connectStream, streamRetryMs);
+}
+
+function handleStreamBlock(block) {
+    let type = 'message';
+    let id = null;
+    const data = [];
+    
+    block.split('\n').forEach(line => {
+        if (line === '' || line.startsWith(':')) return;
+        const colon = line.indexOf(':');
+        const field = colon === -1 ? line : line.slice(0, colon);
+        const value = colon === -1 ? '' : line.slice(colon + 1).replace(/^ /, '');
+        
+        if (field === 'event') type = value;
+        else if (field === 'data') data.push(value);
+        else if (field === 'id') id = value;
+        else if (field === 'retry' && /^\d+$/.test(value)) streamRetryMs = parseInt(value, 10);
+    });
+    
+    if (id !== null) lastStreamEventId = id;
+    if (data.length === 0) return;
+    
+    let payload;
+    try {
+        payload = JSON.parse(data.join('\n'));
+    } catch (error) {
+        console.error('Invalid stream event:', error);
+        return;
+    }
+    
+    if (type === 'summary') {
+        applySummary(payload.totals);
+    } else if (type === 'change') {
+        scheduleStreamRefresh(payload.entity);
+    } else if (type === 'reset') {
+        scheduleStreamRefresh('income');
+        scheduleStreamRefresh('expense');
+    }
+}
+
+// Update the summary cards in place from a summary event
+function applySummary(totals) {
+    if (!totals) return;
+    document.getElementById('totalIncome').textContent = totals.total_income.toFixed(2);
+    document.getElementById('totalExpense').textContent = totals.total_expense.toFixed(2);
+    document.getElementById('balance').textContent = totals.balance.toFixed(2);
+}
+
+// Changes often arrive in bursts, so charts and lists reload once they settle
+function scheduleStreamRefresh(entity) {
+    streamRefreshEntities.add(entity);
+    clearTimeout(streamRefreshTimer);
+    streamRefreshTimer = setTimeout(async () => {
+        const entities = streamRefreshEntities;
+        streamRefreshEntities = new Set();
+        
+        await loadDashboardData();
+        if (entities.has('income')) await loadIncomeList();
+        if (entities.has('expense')) await loadExpenseList();
+    }, 500);
+}
+
 // Initialize
 document.addEventListener('DOMContentLoaded', async () => {
     console.log('Dashboard initializing...');
@@ -893,5 +1017,7 @@ document.addEventListener('DOMContentLoaded', async () => {
         console.error('Error loading expense list:', error);
     }
     
+    connectStream();
+    
     console.log('Dashboard initialization complete');
 });